@@ -1,5 +1,8 @@
 module git.mfdlabs.local/petko/mfdlabs-ssl-go
 
-go 1.13
+go 1.16
 
-require gopkg.in/yaml.v2 v2.4.0
+require (
+	golang.org/x/crypto v0.0.0-20220525230936-793ad666bf5e
+	gopkg.in/yaml.v2 v2.4.0
+)
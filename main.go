@@ -6,16 +6,35 @@ import (
 	"os"
 
 	"git.mfdlabs.local/petko/mfdlabs-ssl-go/pkg"
+	"git.mfdlabs.local/petko/mfdlabs-ssl-go/pkg/backend"
 	"git.mfdlabs.local/petko/mfdlabs-ssl-go/pkg/certificates"
-	"git.mfdlabs.local/petko/mfdlabs-ssl-go/pkg/configuration"
-	"git.mfdlabs.local/petko/mfdlabs-ssl-go/pkg/ssl"
+	"git.mfdlabs.local/petko/mfdlabs-ssl-go/pkg/commands"
 )
 
 var configurationFilePath = flag.String("configurationFilePath", "", "The path to the configuration file. Can be absolute or relative to the current working directory. Can be a json or yaml file.")
+var outputDirectory = flag.String("outputDirectory", "", "The directory the certificates are written to. Can be absolute or relative to the current working directory. Overrides the output directory of the configuration file, defaults to ./bin.")
+var backendName = flag.String("backend", "", "The backend used to generate the certificates, either native or script. Overrides the backend of the configuration file, defaults to native.")
 
 func main() {
+	// Dispatch to the command if the first argument is one, e.g. ssl-go validate <configuration file>
+	if len(os.Args) > 1 {
+		if command := commands.Get(os.Args[1]); command != nil {
+			os.Exit(command.Run(os.Args[2:]))
+		}
+	}
+
 	flag.Usage = func() {
 		fmt.Println("ssl-go [options]")
+		fmt.Println("ssl-go <command> [arguments]")
+		fmt.Println()
+		fmt.Println("Commands:")
+
+		for _, command := range commands.All() {
+			fmt.Printf("  %-14s %s\n", command.Name, command.Description)
+		}
+
+		fmt.Println()
+		fmt.Println("Options:")
 		flag.PrintDefaults()
 	}
 
@@ -29,34 +48,35 @@ func main() {
 
 	// Error out if we aren't running on unix
 	if os.PathSeparator != '/' {
-		panic("This program is only supported on unix systems")
+		commands.ExitWithError(fmt.Errorf("this program is only supported on unix systems"))
 	}
 
-	// If the ./bin directory doesn't exist, create it
-	if _, err := os.Stat("./bin"); os.IsNotExist(err) {
-		err = os.Mkdir("./bin", 0755)
+	// Load the configuration file
+	conf, err := pkg.LoadConfiguration(*configurationFilePath)
 
-		if err != nil {
-			panic(err)
-		}
+	if err != nil {
+		commands.ExitWithError(err)
 	}
 
-	// Determine if generation scripts are available
-	err := ssl.DetermineIfScriptsAvailable()
+	// The command line takes precedence over the configuration file
+	if *backendName != "" {
+		conf.Backend = *backendName
+	}
 
-	if err != nil {
-		panic(err)
+	if *outputDirectory != "" {
+		conf.OutputDirectory = *outputDirectory
 	}
 
-	// Load the configuration file
-	conf, err := pkg.LoadConfiguration(*configurationFilePath)
+	// Get the backend, the script backend extracts the bundled generation scripts when it generates a certificate
+	generator, err := backend.GetBackend(conf.Backend)
 
 	if err != nil {
-		panic(err)
+		commands.ExitWithError(err)
 	}
 
-	loadedRootCerts := make(map[string]*configuration.RootCertificateAuthority)
-	loadedIntermediateCerts := make(map[string]*configuration.IntermediateCertificateAuthority)
+	err = certificates.Run(*configurationFilePath, conf, generator)
 
-	certificates.Run(*configurationFilePath, conf, loadedRootCerts, loadedIntermediateCerts)
+	if err != nil {
+		commands.ExitWithError(err)
+	}
 }
@@ -0,0 +1,303 @@
+// Package backend contains the implementations that turn a validated certificate entry into its artifacts.
+package backend
+
+import (
+	"crypto/x509"
+	"fmt"
+
+	"git.mfdlabs.local/petko/mfdlabs-ssl-go/pkg/configuration"
+	"git.mfdlabs.local/petko/mfdlabs-ssl-go/pkg/helper"
+	"git.mfdlabs.local/petko/mfdlabs-ssl-go/pkg/nss"
+	"git.mfdlabs.local/petko/mfdlabs-ssl-go/pkg/ssl"
+	"git.mfdlabs.local/petko/mfdlabs-ssl-go/pkg/truststore"
+)
+
+const (
+	// Generates the certificates in-process with crypto/x509.
+	NativeBackendName = "native"
+
+	// Generates the certificates with the generate-*.sh openssl scripts bundled into the binary.
+	ScriptBackendName = "script"
+)
+
+// A Backend generates the key, certificate, chain and pfx artifacts of a certificate in its output directory.
+type Backend interface {
+	// The name of the backend, as used in the configuration and on the command line.
+	Name() string
+
+	GenerateRootCertificateAuthority(request *RootCertificateAuthorityRequest) error
+	GenerateIntermediateCertificateAuthority(request *IntermediateCertificateAuthorityRequest) error
+	GenerateLeafCertificate(request *LeafCertificateRequest) error
+
+	// Signs a certificate signing request generated elsewhere, only the certificate and its chain are written.
+	SignCertificateRequest(request *SigningRequest) error
+
+	// Describe the invocation that would generate the certificate, with the passwords redacted.
+	DescribeRootCertificateAuthority(request *RootCertificateAuthorityRequest) string
+	DescribeIntermediateCertificateAuthority(request *IntermediateCertificateAuthorityRequest) string
+	DescribeLeafCertificate(request *LeafCertificateRequest) string
+	DescribeSigningRequest(request *SigningRequest) string
+}
+
+// How a pfx is encoded, see pkcs12.Options.
+type PfxOptions struct {
+	Encryption   string
+	MacAlgorithm string
+	Iterations   int
+	FriendlyName string
+
+	// Determines if the certificates of the issuers are in the pfx.
+	IncludeChain bool
+}
+
+// A Java keystore or truststore of a certificate.
+type KeystoreOptions struct {
+	// helper.KeystoreType or helper.TruststoreType.
+	Type string
+
+	// helper.PKCS12KeystoreFormat or helper.JKSKeystoreFormat.
+	Format string
+
+	// The path of the store, relative to the output directory of the certificate. Empty for the name of the
+	// certificate with the extension of the type and format.
+	Path string
+
+	// The alias of the entry, the common name of the root is used if a truststore doesn't set it.
+	Alias    string
+	Password string
+}
+
+// The files of a leaf certificate laid out for a server.
+type BundleOptions struct {
+	// One of the helper bundle profiles, e.g. helper.NginxBundleProfile.
+	Profile string
+
+	// The directory the files are written to, relative to the output directory of the certificate.
+	Directory string
+
+	// The directory the server reads the files from, empty for the directory they are written to.
+	MountPath string
+}
+
+// The resolved values of a root certificate authority entry.
+type RootCertificateAuthorityRequest struct {
+	Name        string
+	Password    string
+	PfxPassword string
+
+	// How the pfx is encoded, nil for the defaults.
+	Pfx *PfxOptions
+
+	// The keystores written once the certificate is generated, by WriteKeystores.
+	Keystores []*KeystoreOptions
+
+	// The absolute path of the directory the artifacts are written to.
+	OutputDirectory string
+
+	KeyAlgorithm   string
+	KeySize        int
+	ValidityPeriod int
+
+	// Determines if the private key already in the output directory is kept when the certificate is renewed.
+	RenewalKeyPolicy string
+
+	// Determines if the certificate is issued for the private key already in the output directory instead of a new one.
+	ReuseExistingKey bool
+
+	// The trust store the certificate is installed into once generated, nil if it isn't.
+	TrustStore *truststore.Options
+
+	// The NSS databases the certificate is imported into once generated, nil if it isn't.
+	NssDatabases *nss.Options
+
+	GenerateDHParameters bool
+
+	Configuration *configuration.BaseCertificateConfiguration
+}
+
+// The resolved values of an intermediate certificate authority entry.
+type IntermediateCertificateAuthorityRequest struct {
+	Name        string
+	Password    string
+	PfxPassword string
+
+	// How the pfx is encoded, nil for the defaults.
+	Pfx *PfxOptions
+
+	// The keystores written once the certificate is generated, by WriteKeystores.
+	Keystores []*KeystoreOptions
+
+	// The absolute path of the directory the artifacts are written to.
+	OutputDirectory string
+
+	ChainName                       string
+	ChainPassword                   string
+	IsChainRootCertificateAuthority bool
+
+	// The absolute path of the directory the issuer was written to.
+	ChainOutputDirectory string
+
+	KeyAlgorithm   string
+	KeySize        int
+	ValidityPeriod int
+
+	// Determines if the private key already in the output directory is kept when the certificate is renewed.
+	RenewalKeyPolicy string
+
+	// Determines if the certificate is issued for the private key already in the output directory instead of a new one.
+	ReuseExistingKey bool
+
+	// The trust store the certificate is installed into once generated, nil if it isn't.
+	TrustStore *truststore.Options
+
+	// The NSS databases the certificate is imported into once generated, nil if it isn't.
+	NssDatabases *nss.Options
+
+	GenerateDHParameters       bool
+	KeepCertificateRequestFile bool
+
+	Configuration *configuration.BaseCertificateConfiguration
+}
+
+// The resolved values of a leaf certificate entry.
+type LeafCertificateRequest struct {
+	Name        string
+	Password    string
+	PfxPassword string
+
+	// How the pfx is encoded, nil for the defaults.
+	Pfx *PfxOptions
+
+	// The keystores written once the certificate is generated, by WriteKeystores.
+	Keystores []*KeystoreOptions
+
+	// The server bundles written once the certificate is generated, by WriteBundles.
+	Bundles []*BundleOptions
+
+	// The absolute path of the directory the artifacts are written to.
+	OutputDirectory string
+
+	ChainName                       string
+	ChainPassword                   string
+	IsChainRootCertificateAuthority bool
+
+	// The absolute path of the directory the issuer was written to.
+	ChainOutputDirectory string
+
+	KeyAlgorithm   string
+	KeySize        int
+	ValidityPeriod int
+
+	// Determines if the private key already in the output directory is kept when the certificate is renewed.
+	RenewalKeyPolicy string
+
+	// Determines if the certificate is issued for the private key already in the output directory instead of a new one.
+	ReuseExistingKey bool
+
+	GenerateDHParameters       bool
+	KeepCertificateRequestFile bool
+
+	Configuration *configuration.LeafCertificateConfiguration
+}
+
+// The resolved values of a certificate signing request entry.
+type SigningRequest struct {
+	Name string
+
+	// The absolute path of the directory the artifacts are written to.
+	OutputDirectory string
+
+	// The path the certificate signing request was read from, and its parsed content.
+	RequestPath        string
+	CertificateRequest *x509.CertificateRequest
+
+	ChainName                       string
+	ChainPassword                   string
+	IsChainRootCertificateAuthority bool
+
+	// The absolute path of the directory the issuer was written to.
+	ChainOutputDirectory string
+
+	ValidityPeriod int
+
+	Configuration *configuration.LeafCertificateConfiguration
+}
+
+// Gets the backend with the specified name, an empty name selects the native backend.
+// Getting a backend has no side effects, the script backend only extracts the bundled scripts when it generates a
+// certificate.
+func GetBackend(name string) (Backend, error) {
+	err := CheckBackendName(name)
+	if err != nil {
+		return nil, err
+	}
+
+	if name == ScriptBackendName {
+		return NewScriptBackend(), nil
+	}
+
+	return NewNativeBackend(), nil
+}
+
+// Checks that the backend name is known and, for the script backend, that the scripts are bundled into this build,
+// without creating the backend.
+func CheckBackendName(name string) error {
+	switch name {
+	case "", NativeBackendName:
+		return nil
+	case ScriptBackendName:
+		err := ssl.CheckBundle()
+		if err != nil {
+			return fmt.Errorf("the %s backend cannot be used: %s", ScriptBackendName, err)
+		}
+
+		return nil
+	}
+
+	return fmt.Errorf("unknown backend: %s, must be %s or %s", name, NativeBackendName, ScriptBackendName)
+}
+
+// Checks that the backend can generate keys of the key algorithm.
+func CheckKeyAlgorithmSupport(name string, keyAlgorithm string) error {
+	if name == ScriptBackendName {
+		return checkScriptKeyAlgorithm(keyAlgorithm)
+	}
+
+	return nil
+}
+
+// Checks that the backend can issue a certificate for an existing private key.
+func CheckKeyReuseSupport(name string) error {
+	if name == ScriptBackendName {
+		return errScriptKeyReuse
+	}
+
+	return nil
+}
+
+// Checks that the backend can encode the pfx as the options say, nil options are the defaults of the backend.
+func CheckPfxSupport(name string, options *PfxOptions) error {
+	if name == ScriptBackendName && options != nil {
+		return errScriptPfx
+	}
+
+	return nil
+}
+
+// Checks that the backend can sign certificate signing requests.
+func CheckSigningSupport(name string) error {
+	if name == ScriptBackendName {
+		return errScriptSigning
+	}
+
+	return nil
+}
+
+// Gets the artifact type of the chain certificate.
+func getChainType(isChainRootCertificateAuthority bool) string {
+	if isChainRootCertificateAuthority {
+		return helper.RootCertificateType
+	}
+
+	return helper.IntermediateCertificateType
+}
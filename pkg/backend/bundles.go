@@ -0,0 +1,207 @@
+package backend
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"gopkg.in/yaml.v2"
+
+	"git.mfdlabs.local/petko/mfdlabs-ssl-go/pkg/helper"
+)
+
+const envoySecretType = "type.googleapis.com/envoy.extensions.transport_sockets.tls.v3.Secret"
+
+// A file of a bundle and who can read it.
+type bundleFile struct {
+	extension string
+	content   []byte
+	private   bool
+}
+
+type envoyDataSource struct {
+	Filename string `yaml:"filename"`
+}
+
+type envoyTlsCertificate struct {
+	CertificateChain envoyDataSource `yaml:"certificate_chain"`
+	PrivateKey       envoyDataSource `yaml:"private_key"`
+}
+
+type envoyValidationContext struct {
+	TrustedCa envoyDataSource `yaml:"trusted_ca"`
+}
+
+type envoySecret struct {
+	Type              string                  `yaml:"@type"`
+	Name              string                  `yaml:"name"`
+	TlsCertificate    *envoyTlsCertificate    `yaml:"tls_certificate,omitempty"`
+	ValidationContext *envoyValidationContext `yaml:"validation_context,omitempty"`
+}
+
+type envoyDiscoveryResponse struct {
+	Resources []*envoySecret `yaml:"resources"`
+}
+
+// Writes the server bundles of a certificate that was generated, from the key and chain in its output directory. The
+// chain is verified first, so no server is given a chain in the wrong order or a key of another certificate.
+func WriteBundles(outputDirectory string, certType string, certName string, password string, bundles []*BundleOptions) error {
+	if len(bundles) == 0 {
+		return nil
+	}
+
+	certificate, err := LoadCertificate(outputDirectory, certType, certName, password)
+	if err != nil {
+		return err
+	}
+
+	err = verifyChain(certificate)
+	if err != nil {
+		return fmt.Errorf("the chain of the %s certificate %s cannot be bundled: %s", certType, certName, err)
+	}
+
+	for _, bundle := range bundles {
+		directory := GetBundleDirectory(outputDirectory, bundle)
+
+		files, err := getBundleFiles(certificate, certName, directory, bundle)
+		if err != nil {
+			return fmt.Errorf("failed to lay out the %s bundle %s: %s", bundle.Profile, directory, err)
+		}
+
+		err = os.MkdirAll(directory, 0755)
+		if err != nil {
+			return err
+		}
+
+		for _, file := range files {
+			mode := os.FileMode(0644)
+			if file.private {
+				mode = 0600
+			}
+
+			err = ioutil.WriteFile(filepath.Join(directory, certName+file.extension), file.content, mode)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// Gets the absolute path of the directory of a bundle of a certificate.
+func GetBundleDirectory(outputDirectory string, bundle *BundleOptions) string {
+	directory := bundle.Directory
+	if directory == "" {
+		directory = bundle.Profile
+	}
+
+	if filepath.IsAbs(directory) {
+		return directory
+	}
+
+	return filepath.Join(outputDirectory, directory)
+}
+
+// Gets the certificates a server sends, the certificate and its issuers without the self-signed root the clients
+// already trust.
+func GetServedChain(chain []*x509.Certificate) []*x509.Certificate {
+	root := chain[len(chain)-1]
+
+	if len(chain) > 1 && bytes.Equal(root.RawSubject, root.RawIssuer) {
+		return chain[:len(chain)-1]
+	}
+
+	return chain
+}
+
+func getBundleFiles(certificate *Certificate, certName string, directory string, bundle *BundleOptions) ([]*bundleFile, error) {
+	key, err := EncodePrivateKey(certificate.PrivateKey)
+	if err != nil {
+		return nil, err
+	}
+
+	served := GetServedChain(certificate.Chain)
+
+	switch bundle.Profile {
+	case helper.NginxBundleProfile:
+		return []*bundleFile{
+			{extension: helper.FullChainExtension, content: EncodeCertificates(served...)},
+			{extension: helper.PrivateKeyExtension, content: key, private: true},
+		}, nil
+	case helper.HAProxyBundleProfile:
+		return []*bundleFile{
+			{extension: helper.CombinedBundleExtension, content: append(key, EncodeCertificates(served...)...), private: true},
+		}, nil
+	case helper.EnvoyBundleProfile:
+		mountPath := bundle.MountPath
+		if mountPath == "" {
+			mountPath = directory
+		}
+
+		sds, err := yaml.Marshal(&envoyDiscoveryResponse{Resources: []*envoySecret{
+			{
+				Type: envoySecretType,
+				Name: certName,
+				TlsCertificate: &envoyTlsCertificate{
+					CertificateChain: envoyDataSource{Filename: filepath.Join(mountPath, certName+helper.CertificateExtension)},
+					PrivateKey:       envoyDataSource{Filename: filepath.Join(mountPath, certName+helper.PrivateKeyExtension)},
+				},
+			},
+			{
+				Type: envoySecretType,
+				Name: certName + "-ca",
+				ValidationContext: &envoyValidationContext{
+					TrustedCa: envoyDataSource{Filename: filepath.Join(mountPath, certName+helper.RootCertificateExtension)},
+				},
+			},
+		}})
+		if err != nil {
+			return nil, err
+		}
+
+		return []*bundleFile{
+			{extension: helper.CertificateExtension, content: EncodeCertificates(served...)},
+			{extension: helper.PrivateKeyExtension, content: key, private: true},
+			{extension: helper.RootCertificateExtension, content: EncodeCertificates(certificate.Chain[len(certificate.Chain)-1])},
+			{extension: helper.SecretDiscoveryExtension, content: sds},
+		}, nil
+	case helper.ApacheBundleProfile:
+		files := []*bundleFile{
+			{extension: helper.CertificateExtension, content: EncodeCertificates(certificate.Certificate)},
+			{extension: helper.PrivateKeyExtension, content: key, private: true},
+		}
+
+		// A certificate issued by the root itself has no chain to send
+		if len(served) > 1 {
+			files = append(files, &bundleFile{extension: helper.ChainCertificateExtension, content: EncodeCertificates(served[1:]...)})
+		}
+
+		return files, nil
+	}
+
+	return nil, fmt.Errorf("unknown bundle profile: %s", bundle.Profile)
+}
+
+// Verifies that the private key belongs to the certificate, and that every certificate of the chain is issued by the
+// one after it.
+func verifyChain(certificate *Certificate) error {
+	publicKey, ok := certificate.PrivateKey.Public().(interface{ Equal(crypto.PublicKey) bool })
+	if !ok || !publicKey.Equal(certificate.Certificate.PublicKey) {
+		return fmt.Errorf("the private key does not belong to the certificate %s", certificate.Certificate.Subject)
+	}
+
+	for i := 0; i < len(certificate.Chain)-1; i++ {
+		subject, issuer := certificate.Chain[i], certificate.Chain[i+1]
+
+		if !bytes.Equal(subject.RawIssuer, issuer.RawSubject) || subject.CheckSignatureFrom(issuer) != nil {
+			return fmt.Errorf("%s is not issued by %s, the certificate after it in the chain", subject.Subject, issuer.Subject)
+		}
+	}
+
+	return nil
+}
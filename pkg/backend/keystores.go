@@ -0,0 +1,95 @@
+package backend
+
+import (
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"git.mfdlabs.local/petko/mfdlabs-ssl-go/pkg/helper"
+	"git.mfdlabs.local/petko/mfdlabs-ssl-go/pkg/jks"
+	"git.mfdlabs.local/petko/mfdlabs-ssl-go/pkg/pkcs12"
+)
+
+// Writes the keystores of a certificate that was generated, from the key and chain in its output directory. This
+// works the same whichever backend generated the certificate.
+func WriteKeystores(outputDirectory string, certType string, certName string, password string, keystores []*KeystoreOptions) error {
+	if len(keystores) == 0 {
+		return nil
+	}
+
+	certificate, err := LoadCertificate(outputDirectory, certType, certName, password)
+	if err != nil {
+		return err
+	}
+
+	for _, keystore := range keystores {
+		path := GetKeystorePath(outputDirectory, certType, certName, keystore)
+
+		content, err := encodeKeystore(certificate, keystore)
+		if err != nil {
+			return fmt.Errorf("failed to encode the %s %s: %s", keystore.Type, path, err)
+		}
+
+		err = os.MkdirAll(filepath.Dir(path), 0755)
+		if err != nil {
+			return err
+		}
+
+		// Truststores hold no secret, but their password still guards them from being modified
+		err = ioutil.WriteFile(path, content, 0600)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Gets the absolute path of a keystore of a certificate.
+func GetKeystorePath(outputDirectory string, certType string, certName string, keystore *KeystoreOptions) string {
+	if keystore.Path == "" {
+		return helper.GetArtifactPath(outputDirectory, certType, certName, helper.GetKeystoreExtension(keystore.Type, keystore.Format))
+	}
+
+	if filepath.IsAbs(keystore.Path) {
+		return keystore.Path
+	}
+
+	return filepath.Join(outputDirectory, keystore.Path)
+}
+
+func encodeKeystore(certificate *Certificate, keystore *KeystoreOptions) ([]byte, error) {
+	if keystore.Type == helper.TruststoreType {
+		// The last certificate of the chain is the root certificate authority
+		root := certificate.Chain[len(certificate.Chain)-1]
+
+		alias := keystore.Alias
+		if alias == "" {
+			alias = strings.ToLower(root.Subject.CommonName)
+		}
+
+		if alias == "" {
+			alias = helper.RootCertificateType
+		}
+
+		if keystore.Format == helper.JKSKeystoreFormat {
+			return jks.Encode(keystore.Password, nil, []*jks.TrustedCertificateEntry{{Alias: alias, Certificate: root}})
+		}
+
+		return pkcs12.EncodeTrustStore([]*x509.Certificate{root}, []string{alias}, keystore.Password, nil)
+	}
+
+	if keystore.Format == helper.JKSKeystoreFormat {
+		return jks.Encode(keystore.Password, []*jks.PrivateKeyEntry{{
+			Alias:      keystore.Alias,
+			PrivateKey: certificate.PrivateKey,
+			Chain:      certificate.Chain,
+			Password:   keystore.Password,
+		}}, nil)
+	}
+
+	return pkcs12.Encode(certificate.PrivateKey, certificate.Certificate, certificate.Chain[1:], keystore.Password, &pkcs12.Options{FriendlyName: keystore.Alias})
+}
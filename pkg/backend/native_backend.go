@@ -0,0 +1,430 @@
+package backend
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"git.mfdlabs.local/petko/mfdlabs-ssl-go/pkg/configuration"
+	"git.mfdlabs.local/petko/mfdlabs-ssl-go/pkg/helper"
+	"git.mfdlabs.local/petko/mfdlabs-ssl-go/pkg/nss"
+	"git.mfdlabs.local/petko/mfdlabs-ssl-go/pkg/pkcs12"
+	"git.mfdlabs.local/petko/mfdlabs-ssl-go/pkg/truststore"
+)
+
+const (
+	// What the passwords are shown as when describing an invocation.
+	redactedPassword = "<redacted>"
+)
+
+type nativeBackend struct{}
+
+// Creates the backend that generates the certificates in-process with crypto/x509. The certificates are generated
+// from the configuration entries, the .conf files next to them are informational and never read back.
+func NewNativeBackend() Backend {
+	return &nativeBackend{}
+}
+
+func (b *nativeBackend) Name() string {
+	return NativeBackendName
+}
+
+func (b *nativeBackend) GenerateRootCertificateAuthority(request *RootCertificateAuthorityRequest) error {
+	privateKey, err := getPrivateKey(request.OutputDirectory, helper.RootCertificateType, request.Name, request.Password, request.ReuseExistingKey, request.KeyAlgorithm, request.KeySize)
+	if err != nil {
+		return err
+	}
+
+	template, err := getCertificateAuthorityTemplate(request.Name, request.Configuration, request.ValidityPeriod, privateKey.Public())
+	if err != nil {
+		return err
+	}
+
+	// Root certificate authorities are self signed
+	template.AuthorityKeyId = template.SubjectKeyId
+
+	certificate, err := signCertificate(template, template, privateKey.Public(), privateKey)
+	if err != nil {
+		return err
+	}
+
+	return writeArtifacts(&artifacts{
+		outputDirectory:      request.OutputDirectory,
+		certType:             helper.RootCertificateType,
+		name:                 request.Name,
+		password:             request.Password,
+		pfxPassword:          request.PfxPassword,
+		pfx:                  request.Pfx,
+		privateKey:           privateKey,
+		certificate:          certificate,
+		generateDHParameters: request.GenerateDHParameters,
+		keySize:              request.KeySize,
+	})
+}
+
+func (b *nativeBackend) GenerateIntermediateCertificateAuthority(request *IntermediateCertificateAuthorityRequest) error {
+	parent, err := LoadCertificate(request.ChainOutputDirectory, getChainType(request.IsChainRootCertificateAuthority), request.ChainName, request.ChainPassword)
+	if err != nil {
+		return err
+	}
+
+	privateKey, err := getPrivateKey(request.OutputDirectory, helper.IntermediateCertificateType, request.Name, request.Password, request.ReuseExistingKey, request.KeyAlgorithm, request.KeySize)
+	if err != nil {
+		return err
+	}
+
+	template, err := getCertificateAuthorityTemplate(request.Name, request.Configuration, request.ValidityPeriod, privateKey.Public())
+	if err != nil {
+		return err
+	}
+
+	certificate, err := signCertificate(template, parent.Certificate, privateKey.Public(), parent.PrivateKey)
+	if err != nil {
+		return err
+	}
+
+	return writeArtifacts(&artifacts{
+		outputDirectory:            request.OutputDirectory,
+		certType:                   helper.IntermediateCertificateType,
+		name:                       request.Name,
+		password:                   request.Password,
+		pfxPassword:                request.PfxPassword,
+		pfx:                        request.Pfx,
+		privateKey:                 privateKey,
+		certificate:                certificate,
+		template:                   template,
+		chain:                      parent.Chain,
+		generateDHParameters:       request.GenerateDHParameters,
+		keepCertificateRequestFile: request.KeepCertificateRequestFile,
+		keySize:                    request.KeySize,
+	})
+}
+
+func (b *nativeBackend) GenerateLeafCertificate(request *LeafCertificateRequest) error {
+	parent, err := LoadCertificate(request.ChainOutputDirectory, getChainType(request.IsChainRootCertificateAuthority), request.ChainName, request.ChainPassword)
+	if err != nil {
+		return err
+	}
+
+	privateKey, err := getPrivateKey(request.OutputDirectory, helper.LeafCertificateType, request.Name, request.Password, request.ReuseExistingKey, request.KeyAlgorithm, request.KeySize)
+	if err != nil {
+		return err
+	}
+
+	template, err := getLeafCertificateTemplate(request.Name, request.Configuration, request.KeyAlgorithm, request.ValidityPeriod, privateKey.Public())
+	if err != nil {
+		return err
+	}
+
+	certificate, err := signCertificate(template, parent.Certificate, privateKey.Public(), parent.PrivateKey)
+	if err != nil {
+		return err
+	}
+
+	return writeArtifacts(&artifacts{
+		outputDirectory:            request.OutputDirectory,
+		certType:                   helper.LeafCertificateType,
+		name:                       request.Name,
+		password:                   request.Password,
+		pfxPassword:                request.PfxPassword,
+		pfx:                        request.Pfx,
+		privateKey:                 privateKey,
+		certificate:                certificate,
+		template:                   template,
+		chain:                      parent.Chain,
+		generateDHParameters:       request.GenerateDHParameters,
+		keepCertificateRequestFile: request.KeepCertificateRequestFile,
+		keySize:                    request.KeySize,
+	})
+}
+
+func (b *nativeBackend) DescribeRootCertificateAuthority(request *RootCertificateAuthorityRequest) string {
+	return fmt.Sprintf("native: self-signed %s, %s, %d days, trust store: %s, nss: %s, dhparam: %t, %s, keystores: %s", describeOutputs(request.OutputDirectory, helper.RootCertificateType, request.Name), describeKey(request.KeyAlgorithm, request.KeySize), request.ValidityPeriod, describeTrustStore(request.TrustStore), describeNssDatabases(request.NssDatabases), request.GenerateDHParameters, describePfx(request.Pfx), describeKeystores(request.OutputDirectory, helper.RootCertificateType, request.Name, request.Keystores))
+}
+
+func (b *nativeBackend) DescribeIntermediateCertificateAuthority(request *IntermediateCertificateAuthorityRequest) string {
+	return fmt.Sprintf("native: %s signed by %s, %s, %d days, trust store: %s, nss: %s, dhparam: %t, csr: %t, %s, keystores: %s", describeOutputs(request.OutputDirectory, helper.IntermediateCertificateType, request.Name), describeIssuer(request.ChainOutputDirectory, request.IsChainRootCertificateAuthority, request.ChainName), describeKey(request.KeyAlgorithm, request.KeySize), request.ValidityPeriod, describeTrustStore(request.TrustStore), describeNssDatabases(request.NssDatabases), request.GenerateDHParameters, request.KeepCertificateRequestFile, describePfx(request.Pfx), describeKeystores(request.OutputDirectory, helper.IntermediateCertificateType, request.Name, request.Keystores))
+}
+
+func (b *nativeBackend) DescribeLeafCertificate(request *LeafCertificateRequest) string {
+	return fmt.Sprintf("native: %s signed by %s, %s, %d days, dhparam: %t, csr: %t, %s, keystores: %s, bundles: %s", describeOutputs(request.OutputDirectory, helper.LeafCertificateType, request.Name), describeIssuer(request.ChainOutputDirectory, request.IsChainRootCertificateAuthority, request.ChainName), describeKey(request.KeyAlgorithm, request.KeySize), request.ValidityPeriod, request.GenerateDHParameters, request.KeepCertificateRequestFile, describePfx(request.Pfx), describeKeystores(request.OutputDirectory, helper.LeafCertificateType, request.Name, request.Keystores), describeBundles(request.OutputDirectory, request.Bundles))
+}
+
+func describeOutputs(outputDirectory string, certType string, certName string) string {
+	base := helper.GetArtifactPath(outputDirectory, certType, certName, "")
+
+	return fmt.Sprintf("%s{%s,%s,%s,%s} (password: %s, pfx password: %s)", base, helper.PrivateKeyExtension, helper.CertificateExtension, helper.ChainCertificateExtension, helper.PfxExtension, redactedPassword, redactedPassword)
+}
+
+// Ed25519 keys have a fixed size, so only the algorithm is shown for them.
+func describeKey(keyAlgorithm string, keySize int) string {
+	if keyAlgorithm == helper.Ed25519KeyAlgorithm {
+		return keyAlgorithm
+	}
+
+	return fmt.Sprintf("%s %d", keyAlgorithm, keySize)
+}
+
+func describeKeystores(outputDirectory string, certType string, certName string, keystores []*KeystoreOptions) string {
+	if len(keystores) == 0 {
+		return "no"
+	}
+
+	var descriptions []string
+	for _, keystore := range keystores {
+		descriptions = append(descriptions, fmt.Sprintf("%s %s %s", keystore.Format, keystore.Type, GetKeystorePath(outputDirectory, certType, certName, keystore)))
+	}
+
+	return strings.Join(descriptions, ", ")
+}
+
+func describeBundles(outputDirectory string, bundles []*BundleOptions) string {
+	if len(bundles) == 0 {
+		return "no"
+	}
+
+	var descriptions []string
+	for _, bundle := range bundles {
+		descriptions = append(descriptions, fmt.Sprintf("%s %s", bundle.Profile, GetBundleDirectory(outputDirectory, bundle)))
+	}
+
+	return strings.Join(descriptions, ", ")
+}
+
+func describePfx(options *PfxOptions) string {
+	if options == nil {
+		return "pfx: default encoding"
+	}
+
+	encryption, _ := pkcs12.CheckEncryption(options.Encryption)
+	macAlgorithm, _ := pkcs12.CheckMacAlgorithm(options.MacAlgorithm, encryption)
+
+	return fmt.Sprintf("pfx: %s with a %s MAC, %d iterations, friendly name %s, chain: %t", encryption, macAlgorithm, options.Iterations, options.FriendlyName, options.IncludeChain)
+}
+
+func describeTrustStore(options *truststore.Options) string {
+	if options == nil {
+		return "no"
+	}
+
+	if options.Distribution == truststore.AutoDistribution {
+		return fmt.Sprintf("undetected distribution of %s", options.Root)
+	}
+
+	return fmt.Sprintf("%s %s", options.Distribution, filepath.Join(options.Root, options.Directory))
+}
+
+func describeNssDatabases(options *nss.Options) string {
+	if options == nil {
+		return "no"
+	}
+
+	return fmt.Sprintf("%s %s", options.Method, strings.Join(options.Databases, ", "))
+}
+
+func describeIssuer(chainOutputDirectory string, isChainRootCertificateAuthority bool, chainName string) string {
+	keyPath := helper.GetArtifactPath(chainOutputDirectory, getChainType(isChainRootCertificateAuthority), chainName, helper.PrivateKeyExtension)
+
+	return fmt.Sprintf("%s (password: %s)", keyPath, redactedPassword)
+}
+
+func getBaseTemplate(validityPeriod int, publicKey crypto.PublicKey) (*x509.Certificate, error) {
+	// Random 128 bit serial number
+	serialNumber, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, err
+	}
+
+	subjectKeyId, err := getSubjectKeyId(publicKey)
+	if err != nil {
+		return nil, err
+	}
+
+	notBefore := time.Now().UTC()
+
+	return &x509.Certificate{
+		SerialNumber: serialNumber,
+		NotBefore:    notBefore,
+		NotAfter:     notBefore.AddDate(0, 0, validityPeriod),
+		SubjectKeyId: subjectKeyId,
+	}, nil
+}
+
+func getCertificateAuthorityTemplate(name string, conf *configuration.BaseCertificateConfiguration, validityPeriod int, publicKey crypto.PublicKey) (*x509.Certificate, error) {
+	template, err := getBaseTemplate(validityPeriod, publicKey)
+	if err != nil {
+		return nil, err
+	}
+
+	// Without a configuration the certificate is named after the entry
+	if conf == nil {
+		conf = &configuration.BaseCertificateConfiguration{CommonName: name}
+	}
+
+	template.Subject = getSubject(conf)
+
+	err = applyBaseExtensions(template, conf, true, []string{"keyCertSign", "cRLSign"}, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return template, nil
+}
+
+func getLeafCertificateTemplate(name string, conf *configuration.LeafCertificateConfiguration, keyAlgorithm string, validityPeriod int, publicKey crypto.PublicKey) (*x509.Certificate, error) {
+	template, err := getBaseTemplate(validityPeriod, publicKey)
+	if err != nil {
+		return nil, err
+	}
+
+	// Without a configuration the certificate is named after the entry
+	if conf == nil {
+		conf = &configuration.LeafCertificateConfiguration{BaseCertificateConfiguration: configuration.BaseCertificateConfiguration{CommonName: name}}
+	}
+
+	template.Subject = getSubject(&conf.BaseCertificateConfiguration)
+
+	err = applyBaseExtensions(template, &conf.BaseCertificateConfiguration, false, helper.GetDefaultLeafKeyUsages(keyAlgorithm), []string{"serverAuth", "clientAuth"})
+	if err != nil {
+		return nil, err
+	}
+
+	err = applySubjectAltNames(template, conf)
+	if err != nil {
+		return nil, err
+	}
+
+	err = applyMustStaple(template, conf)
+	if err != nil {
+		return nil, err
+	}
+
+	return template, nil
+}
+
+// Signs the certificate with the parent. A certificate never outlives its issuer, the validity period is cut short
+// at the expiry of the issuer.
+func signCertificate(template *x509.Certificate, parent *x509.Certificate, publicKey crypto.PublicKey, signer crypto.Signer) (*x509.Certificate, error) {
+	if template != parent && template.NotAfter.After(parent.NotAfter) {
+		template.NotAfter = parent.NotAfter
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, publicKey, signer)
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign the certificate: %s", err)
+	}
+
+	return x509.ParseCertificate(der)
+}
+
+// Everything that gets written to the output directory for a certificate.
+type artifacts struct {
+	outputDirectory string
+
+	certType    string
+	name        string
+	password    string
+	pfxPassword string
+
+	// How the pfx is encoded, nil for the defaults.
+	pfx *PfxOptions
+
+	privateKey  crypto.Signer
+	certificate *x509.Certificate
+
+	// The template the certificate was issued from, used for the certificate request.
+	template *x509.Certificate
+
+	// The chain of the issuer, empty for root certificate authorities.
+	chain []*x509.Certificate
+
+	generateDHParameters       bool
+	keepCertificateRequestFile bool
+	keySize                    int
+}
+
+func writeArtifacts(a *artifacts) error {
+	key, err := encodeEncryptedPrivateKey(a.privateKey, a.password)
+	if err != nil {
+		return err
+	}
+
+	err = writeArtifact(a.outputDirectory, a.certType, a.name, helper.PrivateKeyExtension, key, 0600)
+	if err != nil {
+		return err
+	}
+
+	err = writeArtifact(a.outputDirectory, a.certType, a.name, helper.CertificateExtension, EncodeCertificates(a.certificate), 0644)
+	if err != nil {
+		return err
+	}
+
+	// The chain file is the certificate followed by its issuers
+	chain := append([]*x509.Certificate{a.certificate}, a.chain...)
+
+	err = writeArtifact(a.outputDirectory, a.certType, a.name, helper.ChainCertificateExtension, EncodeCertificates(chain...), 0644)
+	if err != nil {
+		return err
+	}
+
+	pfxOptions := a.pfx
+	if pfxOptions == nil {
+		pfxOptions = &PfxOptions{FriendlyName: a.name, IncludeChain: true}
+	}
+
+	var caCerts []*x509.Certificate
+	if pfxOptions.IncludeChain {
+		caCerts = a.chain
+	}
+
+	pfx, err := pkcs12.Encode(a.privateKey, a.certificate, caCerts, a.pfxPassword, &pkcs12.Options{
+		Encryption:   pfxOptions.Encryption,
+		MacAlgorithm: pfxOptions.MacAlgorithm,
+		Iterations:   pfxOptions.Iterations,
+		FriendlyName: pfxOptions.FriendlyName,
+	})
+	if err != nil {
+		return err
+	}
+
+	err = writeArtifact(a.outputDirectory, a.certType, a.name, helper.PfxExtension, pfx, 0600)
+	if err != nil {
+		return err
+	}
+
+	if a.keepCertificateRequestFile && a.template != nil {
+		der, err := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{
+			Subject:         a.template.Subject,
+			ExtraExtensions: a.template.ExtraExtensions,
+		}, a.privateKey)
+		if err != nil {
+			return err
+		}
+
+		err = writeArtifact(a.outputDirectory, a.certType, a.name, helper.CertificateRequestExtension, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE REQUEST", Bytes: der}), 0644)
+		if err != nil {
+			return err
+		}
+	}
+
+	if a.generateDHParameters {
+		err = writeArtifact(a.outputDirectory, a.certType, a.name, helper.DHParametersExtension, getDHParameters(a.keySize), 0644)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func writeArtifact(outputDirectory string, certType string, certName string, extension string, content []byte, mode os.FileMode) error {
+	return ioutil.WriteFile(helper.GetArtifactPath(outputDirectory, certType, certName, extension), content, mode)
+}
@@ -0,0 +1,463 @@
+package backend
+
+import (
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"fmt"
+	"math/bits"
+	"net"
+	"strconv"
+	"strings"
+
+	"git.mfdlabs.local/petko/mfdlabs-ssl-go/pkg/configuration"
+)
+
+var (
+	oidEmailAddress                 = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 1}
+	oidExtensionKeyUsage            = asn1.ObjectIdentifier{2, 5, 29, 15}
+	oidExtensionBasicConstraints    = asn1.ObjectIdentifier{2, 5, 29, 19}
+	oidExtensionExtendedKeyUsage    = asn1.ObjectIdentifier{2, 5, 29, 37}
+	oidExtensionCertificatePolicies = asn1.ObjectIdentifier{2, 5, 29, 32}
+	oidExtensionSubjectAltName      = asn1.ObjectIdentifier{2, 5, 29, 17}
+	oidExtensionTLSFeature          = asn1.ObjectIdentifier{1, 3, 6, 1, 5, 5, 7, 1, 24}
+	oidExtensionOCSPNoCheck         = asn1.ObjectIdentifier{1, 3, 6, 1, 5, 5, 7, 48, 1, 5}
+)
+
+// The status_request TLS feature of RFC 7633, better known as OCSP must-staple.
+const tlsFeatureStatusRequest = 5
+
+// The key usage names understood by openssl.
+var keyUsages = map[string]x509.KeyUsage{
+	"digitalSignature":  x509.KeyUsageDigitalSignature,
+	"nonRepudiation":    x509.KeyUsageContentCommitment,
+	"contentCommitment": x509.KeyUsageContentCommitment,
+	"keyEncipherment":   x509.KeyUsageKeyEncipherment,
+	"dataEncipherment":  x509.KeyUsageDataEncipherment,
+	"keyAgreement":      x509.KeyUsageKeyAgreement,
+	"keyCertSign":       x509.KeyUsageCertSign,
+	"cRLSign":           x509.KeyUsageCRLSign,
+	"encipherOnly":      x509.KeyUsageEncipherOnly,
+	"decipherOnly":      x509.KeyUsageDecipherOnly,
+}
+
+// The extended key usage names understood by openssl.
+var extendedKeyUsages = map[string]asn1.ObjectIdentifier{
+	"serverAuth":          {1, 3, 6, 1, 5, 5, 7, 3, 1},
+	"clientAuth":          {1, 3, 6, 1, 5, 5, 7, 3, 2},
+	"codeSigning":         {1, 3, 6, 1, 5, 5, 7, 3, 3},
+	"emailProtection":     {1, 3, 6, 1, 5, 5, 7, 3, 4},
+	"ipsecEndSystem":      {1, 3, 6, 1, 5, 5, 7, 3, 5},
+	"ipsecTunnel":         {1, 3, 6, 1, 5, 5, 7, 3, 6},
+	"ipsecUser":           {1, 3, 6, 1, 5, 5, 7, 3, 7},
+	"timeStamping":        {1, 3, 6, 1, 5, 5, 7, 3, 8},
+	"OCSPSigning":         {1, 3, 6, 1, 5, 5, 7, 3, 9},
+	"msSGC":               {1, 3, 6, 1, 4, 1, 311, 10, 3, 3},
+	"nsSGC":               {2, 16, 840, 1, 113730, 4, 1},
+	"anyExtendedKeyUsage": {2, 5, 29, 37, 0},
+}
+
+type basicConstraints struct {
+	IsCA       bool `asn1:"optional"`
+	MaxPathLen int  `asn1:"optional,default:-1"`
+}
+
+type policyInformation struct {
+	Policy asn1.ObjectIdentifier
+}
+
+func getSubject(conf *configuration.BaseCertificateConfiguration) pkix.Name {
+	var subject pkix.Name
+
+	if conf.Country != "" {
+		subject.Country = []string{conf.Country}
+	}
+
+	if conf.State != "" {
+		subject.Province = []string{conf.State}
+	}
+
+	if conf.Locality != "" {
+		subject.Locality = []string{conf.Locality}
+	}
+
+	if conf.Organization != "" {
+		subject.Organization = []string{conf.Organization}
+	}
+
+	if conf.OrganizationalUnit != "" {
+		subject.OrganizationalUnit = []string{conf.OrganizationalUnit}
+	}
+
+	subject.CommonName = conf.CommonName
+
+	if conf.EmailAddress != "" {
+		subject.ExtraNames = append(subject.ExtraNames, pkix.AttributeTypeAndValue{Type: oidEmailAddress, Value: conf.EmailAddress})
+	}
+
+	return subject
+}
+
+// Builds the key usage, basic constraints, extended key usage, certificate policies, name constraints and authority
+// information access of the certificate, mirroring the [config_extensions] section written to the .conf file.
+func applyBaseExtensions(template *x509.Certificate, conf *configuration.BaseCertificateConfiguration, isCA bool, defaultKeyUsages []string, defaultExtendedKeyUsages []string) error {
+	// Key usage
+	usages := conf.KeyUsages
+	if len(usages) == 0 {
+		usages = defaultKeyUsages
+	}
+
+	keyUsage, err := parseKeyUsages(usages)
+	if err != nil {
+		return err
+	}
+
+	extension, err := marshalKeyUsage(keyUsage, conf.HasCriticalKeyUsage)
+	if err != nil {
+		return err
+	}
+
+	template.KeyUsage = keyUsage
+	template.ExtraExtensions = append(template.ExtraExtensions, extension)
+
+	// Basic constraints
+	maxPathLen, err := parseBasicConstraints(conf.BasicConstraints)
+	if err != nil {
+		return err
+	}
+
+	value, err := asn1.Marshal(basicConstraints{IsCA: isCA, MaxPathLen: maxPathLen})
+	if err != nil {
+		return err
+	}
+
+	template.BasicConstraintsValid = true
+	template.IsCA = isCA
+	template.ExtraExtensions = append(template.ExtraExtensions, pkix.Extension{Id: oidExtensionBasicConstraints, Critical: conf.HasCriticalBasicConstraints, Value: value})
+
+	// Extended key usage
+	extendedUsages := conf.ExtendedKeyUsages
+	if len(extendedUsages) == 0 {
+		extendedUsages = defaultExtendedKeyUsages
+	}
+
+	if len(extendedUsages) != 0 {
+		oids, err := parseExtendedKeyUsages(extendedUsages)
+		if err != nil {
+			return err
+		}
+
+		value, err := asn1.Marshal(oids)
+		if err != nil {
+			return err
+		}
+
+		template.ExtraExtensions = append(template.ExtraExtensions, pkix.Extension{Id: oidExtensionExtendedKeyUsage, Critical: conf.HasCriticalExtendedKeyUsage, Value: value})
+	}
+
+	// Certificate policies
+	if len(conf.CertificatePolicies) != 0 {
+		var policies []policyInformation
+
+		for _, policy := range conf.CertificatePolicies {
+			oid, err := parseObjectIdentifier(policy)
+			if err != nil {
+				return fmt.Errorf("invalid certificate policy %s: %s", policy, err)
+			}
+
+			policies = append(policies, policyInformation{Policy: oid})
+		}
+
+		value, err := asn1.Marshal(policies)
+		if err != nil {
+			return err
+		}
+
+		template.ExtraExtensions = append(template.ExtraExtensions, pkix.Extension{Id: oidExtensionCertificatePolicies, Critical: conf.HasCriticalCertificatePolicies, Value: value})
+	}
+
+	// Name constraints
+	if len(conf.NameConstraints) != 0 {
+		err := applyNameConstraints(template, conf.NameConstraints)
+		if err != nil {
+			return err
+		}
+
+		template.PermittedDNSDomainsCritical = conf.HasCriticalNameConstraints
+	}
+
+	// Authority information access, crypto/x509 builds the extension from the responders
+	template.OCSPServer = conf.OcspServers
+
+	return nil
+}
+
+func applySubjectAltNames(template *x509.Certificate, conf *configuration.LeafCertificateConfiguration) error {
+	if conf.SubjectAlternativeName == nil {
+		return nil
+	}
+
+	var names []asn1.RawValue
+
+	for _, dnsName := range conf.SubjectAlternativeName.DNSNames {
+		names = append(names, asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 2, Bytes: []byte(dnsName)})
+	}
+
+	for _, emailAddress := range conf.SubjectAlternativeName.EmailAddresses {
+		names = append(names, asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 1, Bytes: []byte(emailAddress)})
+	}
+
+	for _, ipAddress := range conf.SubjectAlternativeName.IPAddresses {
+		ip := net.ParseIP(ipAddress)
+
+		if ip == nil {
+			return fmt.Errorf("invalid subject alternative name IP address: %s", ipAddress)
+		}
+
+		if ipv4 := ip.To4(); ipv4 != nil {
+			ip = ipv4
+		}
+
+		names = append(names, asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 7, Bytes: ip})
+	}
+
+	if len(names) == 0 {
+		return nil
+	}
+
+	value, err := asn1.Marshal(names)
+	if err != nil {
+		return err
+	}
+
+	template.ExtraExtensions = append(template.ExtraExtensions, pkix.Extension{Id: oidExtensionSubjectAltName, Critical: conf.HasCriticalSubjectAltNames, Value: value})
+
+	return nil
+}
+
+func applyMustStaple(template *x509.Certificate, conf *configuration.LeafCertificateConfiguration) error {
+	if !conf.MustStaple {
+		return nil
+	}
+
+	value, err := asn1.Marshal([]int{tlsFeatureStatusRequest})
+	if err != nil {
+		return err
+	}
+
+	template.ExtraExtensions = append(template.ExtraExtensions, pkix.Extension{Id: oidExtensionTLSFeature, Value: value})
+
+	return nil
+}
+
+func parseKeyUsages(names []string) (x509.KeyUsage, error) {
+	var keyUsage x509.KeyUsage
+
+	for _, name := range names {
+		usage, ok := keyUsages[strings.TrimSpace(name)]
+
+		if !ok {
+			return 0, fmt.Errorf("unknown key usage: %s", name)
+		}
+
+		keyUsage |= usage
+	}
+
+	return keyUsage, nil
+}
+
+func parseExtendedKeyUsages(names []string) ([]asn1.ObjectIdentifier, error) {
+	var oids []asn1.ObjectIdentifier
+
+	for _, name := range names {
+		name = strings.TrimSpace(name)
+
+		if oid, ok := extendedKeyUsages[name]; ok {
+			oids = append(oids, oid)
+			continue
+		}
+
+		oid, err := parseObjectIdentifier(name)
+
+		if err != nil {
+			return nil, fmt.Errorf("unknown extended key usage: %s", name)
+		}
+
+		oids = append(oids, oid)
+	}
+
+	return oids, nil
+}
+
+// Gets the path length from the basic constraints (pathlen:N), -1 if it's not set.
+func parseBasicConstraints(constraints []string) (int, error) {
+	maxPathLen := -1
+
+	for _, constraint := range constraints {
+		constraint = strings.Replace(constraint, " ", "", -1)
+
+		if strings.HasPrefix(constraint, "CA:") {
+			continue
+		}
+
+		if !strings.HasPrefix(constraint, "pathlen:") {
+			return 0, fmt.Errorf("unsupported basic constraint: %s", constraint)
+		}
+
+		pathLen, err := strconv.Atoi(constraint[len("pathlen:"):])
+
+		if err != nil || pathLen < 0 {
+			return 0, fmt.Errorf("invalid path length: %s", constraint)
+		}
+
+		maxPathLen = pathLen
+	}
+
+	return maxPathLen, nil
+}
+
+// Applies name constraints in the openssl format, e.g. permitted;DNS:example.com or excluded;IP:10.0.0.0/255.0.0.0
+func applyNameConstraints(template *x509.Certificate, constraints []string) error {
+	for _, constraint := range constraints {
+		parts := strings.SplitN(strings.TrimSpace(constraint), ";", 2)
+
+		if len(parts) != 2 || (parts[0] != "permitted" && parts[0] != "excluded") {
+			return fmt.Errorf("invalid name constraint: %s", constraint)
+		}
+
+		permitted := parts[0] == "permitted"
+
+		nameParts := strings.SplitN(parts[1], ":", 2)
+
+		if len(nameParts) != 2 {
+			return fmt.Errorf("invalid name constraint: %s", constraint)
+		}
+
+		value := nameParts[1]
+
+		switch nameParts[0] {
+		case "DNS":
+			if permitted {
+				template.PermittedDNSDomains = append(template.PermittedDNSDomains, value)
+			} else {
+				template.ExcludedDNSDomains = append(template.ExcludedDNSDomains, value)
+			}
+		case "email":
+			if permitted {
+				template.PermittedEmailAddresses = append(template.PermittedEmailAddresses, value)
+			} else {
+				template.ExcludedEmailAddresses = append(template.ExcludedEmailAddresses, value)
+			}
+		case "URI":
+			if permitted {
+				template.PermittedURIDomains = append(template.PermittedURIDomains, value)
+			} else {
+				template.ExcludedURIDomains = append(template.ExcludedURIDomains, value)
+			}
+		case "IP":
+			ipNet, err := parseIPRange(value)
+
+			if err != nil {
+				return fmt.Errorf("invalid name constraint: %s: %s", constraint, err)
+			}
+
+			if permitted {
+				template.PermittedIPRanges = append(template.PermittedIPRanges, ipNet)
+			} else {
+				template.ExcludedIPRanges = append(template.ExcludedIPRanges, ipNet)
+			}
+		default:
+			return fmt.Errorf("unsupported name constraint type: %s", nameParts[0])
+		}
+	}
+
+	return nil
+}
+
+// Parses an IP range either in the openssl address/mask format or in CIDR notation.
+func parseIPRange(value string) (*net.IPNet, error) {
+	if _, ipNet, err := net.ParseCIDR(value); err == nil {
+		return ipNet, nil
+	}
+
+	parts := strings.SplitN(value, "/", 2)
+
+	if len(parts) != 2 {
+		return nil, fmt.Errorf("expected address/mask")
+	}
+
+	ip := net.ParseIP(parts[0])
+	mask := net.ParseIP(parts[1])
+
+	if ip == nil || mask == nil {
+		return nil, fmt.Errorf("expected address/mask")
+	}
+
+	if ipv4 := ip.To4(); ipv4 != nil {
+		ip = ipv4
+		mask = mask.To4()
+	}
+
+	return &net.IPNet{IP: ip, Mask: net.IPMask(mask)}, nil
+}
+
+func parseObjectIdentifier(value string) (asn1.ObjectIdentifier, error) {
+	parts := strings.Split(strings.TrimSpace(value), ".")
+
+	if len(parts) < 2 {
+		return nil, fmt.Errorf("not a dotted object identifier")
+	}
+
+	oid := make(asn1.ObjectIdentifier, len(parts))
+
+	for i, part := range parts {
+		number, err := strconv.Atoi(part)
+
+		if err != nil || number < 0 {
+			return nil, fmt.Errorf("not a dotted object identifier")
+		}
+
+		oid[i] = number
+	}
+
+	return oid, nil
+}
+
+// Marshals the key usage the same way crypto/x509 does, but with a configurable criticality.
+func marshalKeyUsage(keyUsage x509.KeyUsage, critical bool) (pkix.Extension, error) {
+	var a [2]byte
+	a[0] = bits.Reverse8(byte(keyUsage))
+	a[1] = bits.Reverse8(byte(keyUsage >> 8))
+
+	l := 1
+	if a[1] != 0 {
+		l = 2
+	}
+
+	bitString := a[:l]
+	value, err := asn1.Marshal(asn1.BitString{Bytes: bitString, BitLength: asn1BitLength(bitString)})
+
+	if err != nil {
+		return pkix.Extension{}, err
+	}
+
+	return pkix.Extension{Id: oidExtensionKeyUsage, Critical: critical, Value: value}, nil
+}
+
+func asn1BitLength(bitString []byte) int {
+	bitLen := len(bitString) * 8
+
+	for i := range bitString {
+		b := bitString[len(bitString)-i-1]
+
+		for bit := uint(0); bit < 8; bit++ {
+			if (b>>bit)&1 == 1 {
+				return bitLen
+			}
+
+			bitLen--
+		}
+	}
+
+	return 0
+}
@@ -0,0 +1,290 @@
+package backend
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+
+	"git.mfdlabs.local/petko/mfdlabs-ssl-go/pkg/helper"
+)
+
+type subjectPublicKeyInfo struct {
+	Algorithm        pkix.AlgorithmIdentifier
+	SubjectPublicKey asn1.BitString
+}
+
+// A generated certificate loaded back from its output directory.
+type Certificate struct {
+	Certificate *x509.Certificate
+	PrivateKey  crypto.Signer
+
+	// The certificate followed by its issuers, up to the root certificate authority.
+	Chain []*x509.Certificate
+}
+
+func generatePrivateKey(keyAlgorithm string, keySize int) (crypto.Signer, error) {
+	switch keyAlgorithm {
+	case helper.ECDSAKeyAlgorithm:
+		switch keySize {
+		case 384:
+			return ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+		case 521:
+			return ecdsa.GenerateKey(elliptic.P521(), rand.Reader)
+		}
+
+		return ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	case helper.Ed25519KeyAlgorithm:
+		_, privateKey, err := ed25519.GenerateKey(rand.Reader)
+
+		return privateKey, err
+	}
+
+	return rsa.GenerateKey(rand.Reader, keySize)
+}
+
+// Gets the private key a certificate is issued for, either a new one or the one already in its output directory.
+func getPrivateKey(outputDirectory string, certType string, certName string, password string, reuseExistingKey bool, keyAlgorithm string, keySize int) (crypto.Signer, error) {
+	if !reuseExistingKey {
+		return generatePrivateKey(keyAlgorithm, keySize)
+	}
+
+	content, err := ioutil.ReadFile(helper.GetArtifactPath(outputDirectory, certType, certName, helper.PrivateKeyExtension))
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the private key to reuse: %s", err)
+	}
+
+	privateKey, err := decodeEncryptedPrivateKey(content, password)
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt the private key to reuse: %s", err)
+	}
+
+	// The key algorithm of the configuration may have changed since the key was generated
+	existingKeyAlgorithm, err := getKeyAlgorithm(privateKey.Public())
+	if err != nil {
+		return nil, err
+	}
+
+	if existingKeyAlgorithm != keyAlgorithm || (keyAlgorithm != helper.Ed25519KeyAlgorithm && getPublicKeySize(privateKey.Public()) != keySize) {
+		return nil, fmt.Errorf("the private key to reuse is not a %s %d key, rotate it instead", keyAlgorithm, keySize)
+	}
+
+	return privateKey, nil
+}
+
+// Encodes the private key as a PEM block encrypted with AES-256, like `openssl genrsa -aes256` does.
+// RSA keys are PKCS#1, ECDSA keys SEC 1 and Ed25519 keys PKCS#8, which is what openssl writes for each of them.
+func encodeEncryptedPrivateKey(privateKey crypto.Signer, password string) ([]byte, error) {
+	var blockType string
+	var der []byte
+
+	switch key := privateKey.(type) {
+	case *rsa.PrivateKey:
+		blockType = "RSA PRIVATE KEY"
+		der = x509.MarshalPKCS1PrivateKey(key)
+	case *ecdsa.PrivateKey:
+		var err error
+
+		blockType = "EC PRIVATE KEY"
+		der, err = x509.MarshalECPrivateKey(key)
+
+		if err != nil {
+			return nil, err
+		}
+	case ed25519.PrivateKey:
+		var err error
+
+		blockType = "PRIVATE KEY"
+		der, err = x509.MarshalPKCS8PrivateKey(key)
+
+		if err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("unsupported private key type: %T", privateKey)
+	}
+
+	block, err := x509.EncryptPEMBlock(rand.Reader, blockType, der, []byte(password), x509.PEMCipherAES256)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return pem.EncodeToMemory(block), nil
+}
+
+func decodeEncryptedPrivateKey(content []byte, password string) (crypto.Signer, error) {
+	block, _ := pem.Decode(content)
+
+	if block == nil {
+		return nil, fmt.Errorf("no PEM block found")
+	}
+
+	der := block.Bytes
+
+	if x509.IsEncryptedPEMBlock(block) {
+		var err error
+
+		der, err = x509.DecryptPEMBlock(block, []byte(password))
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if key, err := x509.ParsePKCS1PrivateKey(der); err == nil {
+		return key, nil
+	}
+
+	if key, err := x509.ParseECPrivateKey(der); err == nil {
+		return key, nil
+	}
+
+	key, err := x509.ParsePKCS8PrivateKey(der)
+
+	if err != nil {
+		return nil, err
+	}
+
+	signer, ok := key.(crypto.Signer)
+
+	if !ok {
+		return nil, fmt.Errorf("unsupported private key type: %T", key)
+	}
+
+	return signer, nil
+}
+
+// Encodes the certificates as PEM, in order.
+func EncodeCertificates(certificates ...*x509.Certificate) []byte {
+	var out []byte
+
+	for _, certificate := range certificates {
+		out = append(out, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: certificate.Raw})...)
+	}
+
+	return out
+}
+
+// Encodes the private key as an unencrypted PKCS#8 PEM block, for consumers that cannot decrypt keys.
+func EncodePrivateKey(privateKey crypto.Signer) ([]byte, error) {
+	der, err := x509.MarshalPKCS8PrivateKey(privateKey)
+	if err != nil {
+		return nil, err
+	}
+
+	return pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), nil
+}
+
+func decodeCertificates(content []byte) ([]*x509.Certificate, error) {
+	var certificates []*x509.Certificate
+
+	for {
+		var block *pem.Block
+
+		block, content = pem.Decode(content)
+
+		if block == nil {
+			break
+		}
+
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+
+		certificate, err := x509.ParseCertificate(block.Bytes)
+
+		if err != nil {
+			return nil, err
+		}
+
+		certificates = append(certificates, certificate)
+	}
+
+	if len(certificates) == 0 {
+		return nil, fmt.Errorf("no certificates found")
+	}
+
+	return certificates, nil
+}
+
+// Loads the certificate, decrypted private key and chain of an already generated certificate from its output directory.
+func LoadCertificate(outputDirectory string, certType string, certName string, password string) (*Certificate, error) {
+	keyContent, err := ioutil.ReadFile(helper.GetArtifactPath(outputDirectory, certType, certName, helper.PrivateKeyExtension))
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the private key of the %s certificate %s: %s", certType, certName, err)
+	}
+
+	privateKey, err := decodeEncryptedPrivateKey(keyContent, password)
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt the private key of the %s certificate %s: %s", certType, certName, err)
+	}
+
+	chain, err := LoadCertificateChain(outputDirectory, certType, certName)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &Certificate{
+		Certificate: chain[0],
+		PrivateKey:  privateKey,
+		Chain:       chain,
+	}, nil
+}
+
+// Loads the chain of an already generated certificate from its output directory, the certificate comes first.
+// This is all there is for signed certificates, their private key is never seen.
+func LoadCertificateChain(outputDirectory string, certType string, certName string) ([]*x509.Certificate, error) {
+	// Root certificate authorities are their own chain
+	chainExtension := helper.ChainCertificateExtension
+	if certType == helper.RootCertificateType {
+		chainExtension = helper.CertificateExtension
+	}
+
+	chainContent, err := ioutil.ReadFile(helper.GetArtifactPath(outputDirectory, certType, certName, chainExtension))
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the chain of the %s certificate %s: %s", certType, certName, err)
+	}
+
+	chain, err := decodeCertificates(chainContent)
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse the chain of the %s certificate %s: %s", certType, certName, err)
+	}
+
+	return chain, nil
+}
+
+// Computes the subject key identifier as described in RFC 5280, section 4.2.1.2 (1).
+func getSubjectKeyId(publicKey crypto.PublicKey) ([]byte, error) {
+	der, err := x509.MarshalPKIXPublicKey(publicKey)
+
+	if err != nil {
+		return nil, err
+	}
+
+	var info subjectPublicKeyInfo
+
+	if _, err := asn1.Unmarshal(der, &info); err != nil {
+		return nil, err
+	}
+
+	hash := sha1.Sum(info.SubjectPublicKey.Bytes)
+
+	return hash[:], nil
+}
@@ -0,0 +1,132 @@
+package backend
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"io/ioutil"
+	"time"
+
+	"git.mfdlabs.local/petko/mfdlabs-ssl-go/pkg/configuration"
+	"git.mfdlabs.local/petko/mfdlabs-ssl-go/pkg/helper"
+)
+
+// The number of days a delegated OCSP responder certificate is valid for, a new one is issued once it's about to expire.
+const ocspResponderValidityPeriod = 30
+
+// Loads the delegated OCSP responder of the certificate authority from its output directory. A new responder is
+// issued if there is none, if it expires within a day or if it wasn't issued by the current key of the certificate
+// authority. Returns true if the responder was issued.
+func LoadOCSPResponder(outputDirectory string, certType string, certName string, password string, ca *Certificate) (*Certificate, bool, error) {
+	keyPath := helper.GetArtifactPath(outputDirectory, certType, certName, helper.OCSPResponderPrivateKeyExtension)
+	certificatePath := helper.GetArtifactPath(outputDirectory, certType, certName, helper.OCSPResponderCertificateExtension)
+
+	keyContent, keyErr := ioutil.ReadFile(keyPath)
+	certificateContent, certificateErr := ioutil.ReadFile(certificatePath)
+
+	if keyErr == nil && certificateErr == nil {
+		privateKey, err := decodeEncryptedPrivateKey(keyContent, password)
+
+		if err == nil {
+			certificates, err := decodeCertificates(certificateContent)
+
+			if err == nil && isOCSPResponderValid(certificates[0], ca.Certificate) {
+				return &Certificate{
+					Certificate: certificates[0],
+					PrivateKey:  privateKey,
+					Chain:       append([]*x509.Certificate{certificates[0]}, ca.Chain...),
+				}, false, nil
+			}
+		}
+	}
+
+	responder, err := issueOCSPResponder(ca)
+	if err != nil {
+		return nil, false, err
+	}
+
+	key, err := encodeEncryptedPrivateKey(responder.PrivateKey, password)
+	if err != nil {
+		return nil, false, err
+	}
+
+	err = writeArtifact(outputDirectory, certType, certName, helper.OCSPResponderPrivateKeyExtension, key, 0600)
+	if err != nil {
+		return nil, false, err
+	}
+
+	err = writeArtifact(outputDirectory, certType, certName, helper.OCSPResponderCertificateExtension, EncodeCertificates(responder.Certificate), 0644)
+	if err != nil {
+		return nil, false, err
+	}
+
+	return responder, true, nil
+}
+
+func isOCSPResponderValid(responder *x509.Certificate, ca *x509.Certificate) bool {
+	if time.Now().AddDate(0, 0, 1).After(responder.NotAfter) {
+		return false
+	}
+
+	return responder.CheckSignatureFrom(ca) == nil
+}
+
+// Issues a responder certificate that can only sign OCSP responses, with the same key algorithm as the certificate
+// authority. It carries id-pkix-ocsp-nocheck so clients don't check its own revocation status (RFC 6960 4.2.2.2.1).
+func issueOCSPResponder(ca *Certificate) (*Certificate, error) {
+	keyAlgorithm, err := getKeyAlgorithm(ca.Certificate.PublicKey)
+	if err != nil {
+		return nil, err
+	}
+
+	privateKey, err := generatePrivateKey(keyAlgorithm, getPublicKeySize(ca.Certificate.PublicKey))
+	if err != nil {
+		return nil, err
+	}
+
+	template, err := getBaseTemplate(ocspResponderValidityPeriod, privateKey.Public())
+	if err != nil {
+		return nil, err
+	}
+
+	template.Subject = pkix.Name{CommonName: ca.Certificate.Subject.CommonName + " OCSP Responder"}
+
+	conf := &configuration.BaseCertificateConfiguration{
+		HasCriticalKeyUsage: true,
+		KeyUsages:           []string{"digitalSignature"},
+		ExtendedKeyUsages:   []string{"OCSPSigning"},
+	}
+
+	err = applyBaseExtensions(template, conf, false, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	template.ExtraExtensions = append(template.ExtraExtensions, pkix.Extension{Id: oidExtensionOCSPNoCheck, Value: asn1.NullBytes})
+
+	certificate, err := signCertificate(template, ca.Certificate, privateKey.Public(), ca.PrivateKey)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Certificate{
+		Certificate: certificate,
+		PrivateKey:  privateKey,
+		Chain:       append([]*x509.Certificate{certificate}, ca.Chain...),
+	}, nil
+}
+
+// Gets the size of the public key the way the private key size is configured, 0 for Ed25519.
+func getPublicKeySize(publicKey crypto.PublicKey) int {
+	switch publicKey := publicKey.(type) {
+	case *rsa.PublicKey:
+		return publicKey.N.BitLen()
+	case *ecdsa.PublicKey:
+		return publicKey.Curve.Params().BitSize
+	}
+
+	return 0
+}
@@ -0,0 +1,214 @@
+package backend
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/pem"
+	"fmt"
+
+	"git.mfdlabs.local/petko/mfdlabs-ssl-go/pkg/configuration"
+	"git.mfdlabs.local/petko/mfdlabs-ssl-go/pkg/helper"
+)
+
+// The openssl names of the key usages, indexed by their bit in the key usage extension.
+var keyUsageNames = []string{
+	"digitalSignature",
+	"nonRepudiation",
+	"keyEncipherment",
+	"dataEncipherment",
+	"keyAgreement",
+	"keyCertSign",
+	"cRLSign",
+	"encipherOnly",
+	"decipherOnly",
+}
+
+func (b *nativeBackend) SignCertificateRequest(request *SigningRequest) error {
+	parent, err := LoadCertificate(request.ChainOutputDirectory, getChainType(request.IsChainRootCertificateAuthority), request.ChainName, request.ChainPassword)
+	if err != nil {
+		return err
+	}
+
+	template, err := getSignedCertificateTemplate(request)
+	if err != nil {
+		return err
+	}
+
+	certificate, err := signCertificate(template, parent.Certificate, request.CertificateRequest.PublicKey, parent.PrivateKey)
+	if err != nil {
+		return err
+	}
+
+	err = writeArtifact(request.OutputDirectory, helper.SignedCertificateType, request.Name, helper.CertificateExtension, EncodeCertificates(certificate), 0644)
+	if err != nil {
+		return err
+	}
+
+	// The chain file is the certificate followed by its issuers
+	chain := append([]*x509.Certificate{certificate}, parent.Chain...)
+
+	return writeArtifact(request.OutputDirectory, helper.SignedCertificateType, request.Name, helper.ChainCertificateExtension, EncodeCertificates(chain...), 0644)
+}
+
+func (b *nativeBackend) DescribeSigningRequest(request *SigningRequest) string {
+	base := helper.GetArtifactPath(request.OutputDirectory, helper.SignedCertificateType, request.Name, "")
+
+	return fmt.Sprintf("native: sign %s into %s{%s,%s} signed by %s, %d days", request.RequestPath, base, helper.CertificateExtension, helper.ChainCertificateExtension, describeIssuer(request.ChainOutputDirectory, request.IsChainRootCertificateAuthority, request.ChainName), request.ValidityPeriod)
+}
+
+// Parses a PEM or DER encoded certificate signing request and checks its signature.
+func ParseCertificateRequest(content []byte) (*x509.CertificateRequest, error) {
+	der := content
+
+	if block, _ := pem.Decode(content); block != nil {
+		if block.Type != "CERTIFICATE REQUEST" && block.Type != "NEW CERTIFICATE REQUEST" {
+			return nil, fmt.Errorf("expected a CERTIFICATE REQUEST PEM block, got %s", block.Type)
+		}
+
+		der = block.Bytes
+	}
+
+	csr, err := x509.ParseCertificateRequest(der)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse the certificate signing request: %s", err)
+	}
+
+	err = csr.CheckSignature()
+	if err != nil {
+		return nil, fmt.Errorf("the signature of the certificate signing request is not valid: %s", err)
+	}
+
+	_, err = getKeyAlgorithm(csr.PublicKey)
+	if err != nil {
+		return nil, err
+	}
+
+	return csr, nil
+}
+
+// Builds the certificate from the certificate signing request, the configuration of the entry overrides what the
+// request asks for.
+func getSignedCertificateTemplate(request *SigningRequest) (*x509.Certificate, error) {
+	csr := request.CertificateRequest
+
+	keyAlgorithm, err := getKeyAlgorithm(csr.PublicKey)
+	if err != nil {
+		return nil, err
+	}
+
+	policy, err := getSigningPolicy(request.Configuration, csr)
+	if err != nil {
+		return nil, err
+	}
+
+	template, err := getLeafCertificateTemplate(request.Name, policy, keyAlgorithm, request.ValidityPeriod, csr.PublicKey)
+	if err != nil {
+		return nil, err
+	}
+
+	// Without a configuration the subject of the request is kept as it is
+	if request.Configuration == nil {
+		template.RawSubject = csr.RawSubject
+	}
+
+	return template, nil
+}
+
+// Fills in what the configuration doesn't set with the subject, key usages and subject alternative names requested by
+// the certificate signing request. Certificate authority key usages are never taken from the request.
+func getSigningPolicy(conf *configuration.LeafCertificateConfiguration, csr *x509.CertificateRequest) (*configuration.LeafCertificateConfiguration, error) {
+	policy := &configuration.LeafCertificateConfiguration{}
+
+	if conf != nil {
+		confCopy := *conf
+		policy = &confCopy
+	}
+
+	mergeSubject(&policy.BaseCertificateConfiguration, &csr.Subject)
+
+	for _, extension := range csr.Extensions {
+		switch {
+		case extension.Id.Equal(oidExtensionKeyUsage) && len(policy.KeyUsages) == 0:
+			var bits asn1.BitString
+
+			if _, err := asn1.Unmarshal(extension.Value, &bits); err != nil {
+				return nil, fmt.Errorf("failed to parse the requested key usage: %s", err)
+			}
+
+			for i, name := range keyUsageNames {
+				if bits.At(i) != 0 && name != "keyCertSign" && name != "cRLSign" {
+					policy.KeyUsages = append(policy.KeyUsages, name)
+				}
+			}
+		case extension.Id.Equal(oidExtensionExtendedKeyUsage) && len(policy.ExtendedKeyUsages) == 0:
+			var oids []asn1.ObjectIdentifier
+
+			if _, err := asn1.Unmarshal(extension.Value, &oids); err != nil {
+				return nil, fmt.Errorf("failed to parse the requested extended key usage: %s", err)
+			}
+
+			for _, oid := range oids {
+				policy.ExtendedKeyUsages = append(policy.ExtendedKeyUsages, oid.String())
+			}
+		}
+	}
+
+	if policy.SubjectAlternativeName == nil {
+		subjectAlternativeName := &configuration.SubjectAlternativeNameConfiguration{
+			DNSNames:       csr.DNSNames,
+			EmailAddresses: csr.EmailAddresses,
+		}
+
+		for _, ip := range csr.IPAddresses {
+			subjectAlternativeName.IPAddresses = append(subjectAlternativeName.IPAddresses, ip.String())
+		}
+
+		policy.SubjectAlternativeName = subjectAlternativeName
+	}
+
+	return policy, nil
+}
+
+// Takes every attribute of the subject the policy doesn't set from the subject of the request.
+func mergeSubject(policy *configuration.BaseCertificateConfiguration, subject *pkix.Name) {
+	policy.Country = getSubjectAttribute(policy.Country, subject.Country)
+	policy.State = getSubjectAttribute(policy.State, subject.Province)
+	policy.Locality = getSubjectAttribute(policy.Locality, subject.Locality)
+	policy.Organization = getSubjectAttribute(policy.Organization, subject.Organization)
+	policy.OrganizationalUnit = getSubjectAttribute(policy.OrganizationalUnit, subject.OrganizationalUnit)
+	policy.CommonName = getSubjectAttribute(policy.CommonName, []string{subject.CommonName})
+
+	for _, name := range subject.Names {
+		if email, ok := name.Value.(string); ok && name.Type.Equal(oidEmailAddress) {
+			policy.EmailAddress = getSubjectAttribute(policy.EmailAddress, []string{email})
+		}
+	}
+}
+
+// Gets the value of the policy, or the first value of the request if the policy doesn't set one.
+func getSubjectAttribute(value string, requested []string) string {
+	if value != "" || len(requested) == 0 {
+		return value
+	}
+
+	return requested[0]
+}
+
+// Gets the key algorithm of a public key, only the algorithms ssl-go can generate are supported.
+func getKeyAlgorithm(publicKey crypto.PublicKey) (string, error) {
+	switch publicKey.(type) {
+	case *rsa.PublicKey:
+		return helper.RSAKeyAlgorithm, nil
+	case *ecdsa.PublicKey:
+		return helper.ECDSAKeyAlgorithm, nil
+	case ed25519.PublicKey:
+		return helper.Ed25519KeyAlgorithm, nil
+	}
+
+	return "", fmt.Errorf("unsupported public key type %T", publicKey)
+}
@@ -0,0 +1,114 @@
+package backend
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"testing"
+
+	"git.mfdlabs.local/petko/mfdlabs-ssl-go/pkg/configuration"
+	"git.mfdlabs.local/petko/mfdlabs-ssl-go/pkg/helper"
+)
+
+func TestSignCertificateRequestWithPartialPolicy(t *testing.T) {
+	directory := t.TempDir()
+	generator := NewNativeBackend()
+
+	err := generator.GenerateRootCertificateAuthority(&RootCertificateAuthorityRequest{
+		Name:            "root",
+		Password:        "password",
+		PfxPassword:     "password",
+		OutputDirectory: directory,
+		KeyAlgorithm:    helper.ECDSAKeyAlgorithm,
+		KeySize:         256,
+		ValidityPeriod:  30,
+		Configuration:   &configuration.BaseCertificateConfiguration{CommonName: "Root"},
+	})
+	if err != nil {
+		t.Fatalf("failed to generate the root certificate authority: %s", err)
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	der, err := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{
+		Subject: pkix.Name{
+			Country:            []string{"US"},
+			Organization:       []string{"Requester"},
+			OrganizationalUnit: []string{"Operations"},
+			CommonName:         "service.example.com",
+			ExtraNames:         []pkix.AttributeTypeAndValue{{Type: oidEmailAddress, Value: "ops@example.com"}},
+		},
+		DNSNames: []string{"service.example.com"},
+	}, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	csr, err := x509.ParseCertificateRequest(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The policy only overrides the organization, it has no common name
+	policy := &configuration.LeafCertificateConfiguration{
+		BaseCertificateConfiguration: configuration.BaseCertificateConfiguration{Organization: "Policy"},
+	}
+
+	if errs := configuration.ValidateSigningPolicy(policy); len(errs) != 0 {
+		t.Fatalf("expected the partial policy to be valid: %s", errs)
+	}
+
+	err = generator.SignCertificateRequest(&SigningRequest{
+		Name:                            "service",
+		OutputDirectory:                 directory,
+		CertificateRequest:              csr,
+		ChainName:                       "root",
+		ChainPassword:                   "password",
+		IsChainRootCertificateAuthority: true,
+		ChainOutputDirectory:            directory,
+		ValidityPeriod:                  10,
+		Configuration:                   policy,
+	})
+	if err != nil {
+		t.Fatalf("failed to sign the request: %s", err)
+	}
+
+	chain, err := LoadCertificateChain(directory, helper.SignedCertificateType, "service")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	subject := chain[0].Subject
+
+	if subject.CommonName != "service.example.com" {
+		t.Fatalf("expected the common name of the request, got %q", subject.CommonName)
+	}
+
+	if len(subject.Organization) != 1 || subject.Organization[0] != "Policy" {
+		t.Fatalf("expected the organization of the policy, got %v", subject.Organization)
+	}
+
+	if len(subject.Country) != 1 || subject.Country[0] != "US" || len(subject.OrganizationalUnit) != 1 || subject.OrganizationalUnit[0] != "Operations" {
+		t.Fatalf("expected the country and organizational unit of the request, got %v and %v", subject.Country, subject.OrganizationalUnit)
+	}
+
+	var email interface{}
+	for _, name := range subject.Names {
+		if name.Type.Equal(oidEmailAddress) {
+			email = name.Value
+		}
+	}
+
+	if email != "ops@example.com" {
+		t.Fatalf("expected the email address of the request, got %v", email)
+	}
+
+	if len(chain[0].DNSNames) != 1 || chain[0].DNSNames[0] != "service.example.com" {
+		t.Fatalf("expected the DNS names of the request, got %v", chain[0].DNSNames)
+	}
+}
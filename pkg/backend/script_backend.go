@@ -0,0 +1,318 @@
+package backend
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"git.mfdlabs.local/petko/mfdlabs-ssl-go/pkg/helper"
+	"git.mfdlabs.local/petko/mfdlabs-ssl-go/pkg/ssl"
+)
+
+// The generation scripts always generate the private key themselves.
+var errScriptSigning = fmt.Errorf("the %s backend cannot sign certificate signing requests, use the %s backend", ScriptBackendName, NativeBackendName)
+
+var errScriptKeyReuse = fmt.Errorf("the %s backend always generates a new private key, use the %s backend to reuse keys", ScriptBackendName, NativeBackendName)
+
+var errScriptPfx = fmt.Errorf("the %s backend always encodes the pfx with the defaults of openssl, use the %s backend to choose its encryption", ScriptBackendName, NativeBackendName)
+
+// Where the scripts are described to be run from, they are extracted to a new directory for every certificate.
+const describedScriptsDirectory = "<scripts>"
+
+// The scripts read their inputs from and write the artifacts to the bin directory of the directory they run in.
+const scriptOutputDirectory = "bin"
+
+// What the scripts read of the issuer of a certificate.
+var issuerArtifactExtensions = []string{helper.PrivateKeyExtension, helper.CertificateExtension, helper.ChainCertificateExtension}
+
+// What the scripts write for a certificate.
+var scriptArtifactExtensions = []string{
+	helper.PrivateKeyExtension,
+	helper.CertificateExtension,
+	helper.ChainCertificateExtension,
+	helper.PfxExtension,
+	helper.CertificateRequestExtension,
+	helper.DHParametersExtension,
+}
+
+// The scripts only know the Debian trust store, certificate authorities are installed by the truststore package
+// once the scripts generated them.
+const trustedStoreArgument = "NO"
+
+type scriptBackend struct{}
+
+// Creates the backend that runs the generation scripts bundled into the binary.
+func NewScriptBackend() Backend {
+	return &scriptBackend{}
+}
+
+func (b *scriptBackend) Name() string {
+	return ScriptBackendName
+}
+
+func (b *scriptBackend) GenerateRootCertificateAuthority(request *RootCertificateAuthorityRequest) error {
+	err := checkScriptKeyAlgorithm(request.KeyAlgorithm)
+	if err != nil {
+		return err
+	}
+
+	scripts, err := ssl.ExtractScripts()
+	if err != nil {
+		return err
+	}
+
+	defer scripts.Remove()
+
+	secrets, err := helper.NewSecretDirectory()
+	if err != nil {
+		return err
+	}
+
+	defer secrets.Remove()
+
+	rootCaPasswordFilename, err := secrets.WritePasswordFile("root", request.Name, "normal", request.Password)
+	if err != nil {
+		return err
+	}
+	rootCaPfxPasswordFilename, err := secrets.WritePasswordFile("root", request.Name, "pfx", request.PfxPassword)
+	if err != nil {
+		return err
+	}
+
+	err = stageScriptInputs(scripts.Directory, request.OutputDirectory, helper.RootCertificateType, request.Name, helper.ConfigurationExtension)
+	if err != nil {
+		return err
+	}
+
+	// Execute the command
+	err = helper.ExecuteCommandInDirectory(getRootCertificateAuthorityCommand(scripts.Directory, request, rootCaPasswordFilename, rootCaPfxPasswordFilename), scripts.Directory)
+	if err != nil {
+		return err
+	}
+
+	return collectScriptArtifacts(scripts.Directory, request.OutputDirectory, helper.RootCertificateType, request.Name)
+}
+
+func (b *scriptBackend) GenerateIntermediateCertificateAuthority(request *IntermediateCertificateAuthorityRequest) error {
+	err := checkScriptKeyAlgorithm(request.KeyAlgorithm)
+	if err != nil {
+		return err
+	}
+
+	scripts, err := ssl.ExtractScripts()
+	if err != nil {
+		return err
+	}
+
+	defer scripts.Remove()
+
+	secrets, err := helper.NewSecretDirectory()
+	if err != nil {
+		return err
+	}
+
+	defer secrets.Remove()
+
+	caChainPasswordFilename, err := secrets.WritePasswordFile("chain", request.ChainName, "normal", request.ChainPassword)
+	if err != nil {
+		return err
+	}
+	intCertPasswordFilename, err := secrets.WritePasswordFile("intermediate", request.Name, "normal", request.Password)
+	if err != nil {
+		return err
+	}
+	intCertPfxPasswordFilename, err := secrets.WritePasswordFile("intermediate", request.Name, "pfx", request.PfxPassword)
+	if err != nil {
+		return err
+	}
+
+	err = stageScriptInputs(scripts.Directory, request.OutputDirectory, helper.IntermediateCertificateType, request.Name, helper.ConfigurationExtension)
+	if err != nil {
+		return err
+	}
+
+	err = stageScriptInputs(scripts.Directory, request.ChainOutputDirectory, getChainType(request.IsChainRootCertificateAuthority), request.ChainName, issuerArtifactExtensions...)
+	if err != nil {
+		return err
+	}
+
+	// Execute the command
+	err = helper.ExecuteCommandInDirectory(getIntermediateCertificateAuthorityCommand(scripts.Directory, request, intCertPasswordFilename, intCertPfxPasswordFilename, caChainPasswordFilename), scripts.Directory)
+	if err != nil {
+		return err
+	}
+
+	return collectScriptArtifacts(scripts.Directory, request.OutputDirectory, helper.IntermediateCertificateType, request.Name)
+}
+
+func (b *scriptBackend) GenerateLeafCertificate(request *LeafCertificateRequest) error {
+	err := checkScriptKeyAlgorithm(request.KeyAlgorithm)
+	if err != nil {
+		return err
+	}
+
+	scripts, err := ssl.ExtractScripts()
+	if err != nil {
+		return err
+	}
+
+	defer scripts.Remove()
+
+	secrets, err := helper.NewSecretDirectory()
+	if err != nil {
+		return err
+	}
+
+	defer secrets.Remove()
+
+	caChainPasswordFilename, err := secrets.WritePasswordFile("chain", request.ChainName, "normal", request.ChainPassword)
+	if err != nil {
+		return err
+	}
+	leafCertPasswordFilename, err := secrets.WritePasswordFile("leaf", request.Name, "normal", request.Password)
+	if err != nil {
+		return err
+	}
+	leafCertPfxPasswordFilename, err := secrets.WritePasswordFile("leaf", request.Name, "pfx", request.PfxPassword)
+	if err != nil {
+		return err
+	}
+
+	err = stageScriptInputs(scripts.Directory, request.OutputDirectory, helper.LeafCertificateType, request.Name, helper.ConfigurationExtension)
+	if err != nil {
+		return err
+	}
+
+	err = stageScriptInputs(scripts.Directory, request.ChainOutputDirectory, getChainType(request.IsChainRootCertificateAuthority), request.ChainName, issuerArtifactExtensions...)
+	if err != nil {
+		return err
+	}
+
+	// Execute the command
+	err = helper.ExecuteCommandInDirectory(getLeafCertificateCommand(scripts.Directory, request, leafCertPasswordFilename, leafCertPfxPasswordFilename, caChainPasswordFilename), scripts.Directory)
+	if err != nil {
+		return err
+	}
+
+	return collectScriptArtifacts(scripts.Directory, request.OutputDirectory, helper.LeafCertificateType, request.Name)
+}
+
+func (b *scriptBackend) SignCertificateRequest(request *SigningRequest) error {
+	return errScriptSigning
+}
+
+func (b *scriptBackend) DescribeRootCertificateAuthority(request *RootCertificateAuthorityRequest) string {
+	return fmt.Sprintf("%s, keystores: %s", getRootCertificateAuthorityCommand(describedScriptsDirectory, request, redactedPassword, redactedPassword), describeKeystores(request.OutputDirectory, helper.RootCertificateType, request.Name, request.Keystores))
+}
+
+func (b *scriptBackend) DescribeIntermediateCertificateAuthority(request *IntermediateCertificateAuthorityRequest) string {
+	return fmt.Sprintf("%s, keystores: %s", getIntermediateCertificateAuthorityCommand(describedScriptsDirectory, request, redactedPassword, redactedPassword, redactedPassword), describeKeystores(request.OutputDirectory, helper.IntermediateCertificateType, request.Name, request.Keystores))
+}
+
+func (b *scriptBackend) DescribeLeafCertificate(request *LeafCertificateRequest) string {
+	return fmt.Sprintf("%s, keystores: %s, bundles: %s", getLeafCertificateCommand(describedScriptsDirectory, request, redactedPassword, redactedPassword, redactedPassword), describeKeystores(request.OutputDirectory, helper.LeafCertificateType, request.Name, request.Keystores), describeBundles(request.OutputDirectory, request.Bundles))
+}
+
+func (b *scriptBackend) DescribeSigningRequest(request *SigningRequest) string {
+	return errScriptSigning.Error()
+}
+
+func getRootCertificateAuthorityCommand(scriptsDirectory string, request *RootCertificateAuthorityRequest, passwordFile string, pfxPasswordFile string) string {
+	skipDhParam := helper.Ternary(request.GenerateDHParameters, "NO", "YES").(string)
+
+	return fmt.Sprintf("%s %s @%s @%s %s %s YES %d %d", filepath.Join(scriptsDirectory, ssl.RootCertificateAuthorityScript), request.Name, passwordFile, pfxPasswordFile, trustedStoreArgument, skipDhParam, request.ValidityPeriod, request.KeySize)
+}
+
+func getIntermediateCertificateAuthorityCommand(scriptsDirectory string, request *IntermediateCertificateAuthorityRequest, passwordFile string, pfxPasswordFile string, chainPasswordFile string) string {
+	skipDhParam := helper.Ternary(request.GenerateDHParameters, "NO", "YES").(string)
+	keepCertificateRequestFile := helper.Ternary(request.KeepCertificateRequestFile, "YES", "NO").(string)
+	isLastChainRootCa := helper.Ternary(request.IsChainRootCertificateAuthority, "YES", "NO").(string)
+
+	return fmt.Sprintf("%s %s @%s @%s %s @%s %s %s %s %s %d %d", filepath.Join(scriptsDirectory, ssl.IntermediateCertificateAuthorityScript), request.Name, passwordFile, pfxPasswordFile, request.ChainName, chainPasswordFile, isLastChainRootCa, trustedStoreArgument, skipDhParam, keepCertificateRequestFile, request.ValidityPeriod, request.KeySize)
+}
+
+func getLeafCertificateCommand(scriptsDirectory string, request *LeafCertificateRequest, passwordFile string, pfxPasswordFile string, chainPasswordFile string) string {
+	skipDhParam := helper.Ternary(request.GenerateDHParameters, "NO", "YES").(string)
+	keepCertificateRequestFile := helper.Ternary(request.KeepCertificateRequestFile, "YES", "NO").(string)
+	isLastChainRootCa := helper.Ternary(request.IsChainRootCertificateAuthority, "YES", "NO").(string)
+
+	return fmt.Sprintf("%s %s @%s @%s %s @%s %s %s %s %d %d", filepath.Join(scriptsDirectory, ssl.LeafCertificateScript), request.Name, passwordFile, pfxPasswordFile, request.ChainName, chainPasswordFile, isLastChainRootCa, skipDhParam, keepCertificateRequestFile, request.ValidityPeriod, request.KeySize)
+}
+
+// The generation scripts only know how to generate RSA keys.
+func checkScriptKeyAlgorithm(keyAlgorithm string) error {
+	if keyAlgorithm != "" && keyAlgorithm != helper.RSAKeyAlgorithm {
+		return fmt.Errorf("the %s backend only supports %s keys, use the %s backend for %s keys", ScriptBackendName, helper.RSAKeyAlgorithm, NativeBackendName, keyAlgorithm)
+	}
+
+	return nil
+}
+
+// Copies the files of a certificate the script reads into the bin directory of its working directory, the ones
+// that don't exist are left out.
+func stageScriptInputs(workingDirectory string, outputDirectory string, certType string, certName string, extensions ...string) error {
+	for _, extension := range extensions {
+		path := helper.GetArtifactPath(outputDirectory, certType, certName, extension)
+
+		info, err := os.Stat(path)
+		if os.IsNotExist(err) {
+			continue
+		}
+
+		if err != nil {
+			return err
+		}
+
+		err = copyFile(path, helper.GetArtifactPath(filepath.Join(workingDirectory, scriptOutputDirectory), certType, certName, extension), info.Mode().Perm())
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Moves the artifacts the script wrote to the bin directory of its working directory into the output directory.
+func collectScriptArtifacts(workingDirectory string, outputDirectory string, certType string, certName string) error {
+	for _, extension := range scriptArtifactExtensions {
+		path := helper.GetArtifactPath(filepath.Join(workingDirectory, scriptOutputDirectory), certType, certName, extension)
+
+		info, err := os.Stat(path)
+		if os.IsNotExist(err) {
+			continue
+		}
+
+		if err != nil {
+			return err
+		}
+
+		destination := helper.GetArtifactPath(outputDirectory, certType, certName, extension)
+
+		// The working directory can be on another file system than the output directory
+		if os.Rename(path, destination) == nil {
+			continue
+		}
+
+		err = copyFile(path, destination, info.Mode().Perm())
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func copyFile(source string, destination string, mode os.FileMode) error {
+	content, err := ioutil.ReadFile(source)
+	if err != nil {
+		return err
+	}
+
+	err = os.MkdirAll(filepath.Dir(destination), 0700)
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(destination, content, mode)
+}
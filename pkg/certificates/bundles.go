@@ -0,0 +1,80 @@
+package certificates
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"git.mfdlabs.local/petko/mfdlabs-ssl-go/pkg/backend"
+	"git.mfdlabs.local/petko/mfdlabs-ssl-go/pkg/configuration"
+	"git.mfdlabs.local/petko/mfdlabs-ssl-go/pkg/helper"
+)
+
+// Resolves and validates the server bundles of the certificate, the problems with them are added to the errors of
+// the resolver.
+func getBundleOptions(resolver *fieldResolver, bundles []*configuration.BundleConfiguration) []*backend.BundleOptions {
+	var options []*backend.BundleOptions
+
+	directories := make(map[string]int)
+
+	for i, bundle := range bundles {
+		if bundle == nil {
+			continue
+		}
+
+		field := fmt.Sprintf("bundles[%d]", i)
+
+		profileName := resolver.resolve(field+".profile", bundle.Profile)
+		directory := resolver.resolve(field+".directory", bundle.Directory)
+		mountPath := resolver.resolve(field+".mountPath", bundle.MountPath)
+
+		profile, err := helper.CheckBundleProfile(profileName)
+		if err != nil {
+			resolver.errs = append(resolver.errs, helper.NewValidationError(resolver.certType, resolver.certName, field+".profile", err.Error()))
+		}
+
+		option := &backend.BundleOptions{
+			Profile:   profile,
+			Directory: directory,
+			MountPath: mountPath,
+		}
+
+		options = append(options, option)
+
+		// The profiles share file names, e.g. the .crt of apache has no chain but the one of envoy does
+		if profile == "" {
+			continue
+		}
+
+		key := backend.GetBundleDirectory("", option)
+
+		if previous, ok := directories[key]; ok {
+			resolver.errs = append(resolver.errs, helper.NewValidationError(resolver.certType, resolver.certName, field+".directory", fmt.Sprintf("is the same as the directory of bundles[%d]", previous)))
+		}
+
+		directories[key] = i
+	}
+
+	return options
+}
+
+// Writes the bundles of a kept certificate whose directory doesn't exist yet.
+func writeMissingBundles(outputDirectory string, certType string, certName string, password string, bundles []*backend.BundleOptions, output io.Writer) error {
+	var missing []*backend.BundleOptions
+
+	for _, bundle := range bundles {
+		directory := backend.GetBundleDirectory(outputDirectory, bundle)
+
+		if _, err := os.Stat(directory); os.IsNotExist(err) {
+			fmt.Fprintf(output, "Writing the missing %s bundle of the %s certificate %s: %s\n", bundle.Profile, certType, certName, directory)
+			missing = append(missing, bundle)
+		}
+	}
+
+	err := backend.WriteBundles(outputDirectory, certType, certName, password, missing)
+	if err != nil {
+		return fmt.Errorf("failed to write the bundles of the %s certificate %s: %w", certType, certName, err)
+	}
+
+	return nil
+}
@@ -1,10 +1,345 @@
 package certificates
 
-import "git.mfdlabs.local/petko/mfdlabs-ssl-go/pkg/configuration"
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
 
-func Run(configFilePath string, conf *configuration.SslConfiguration, loadedRootCerts map[string]*configuration.RootCertificateAuthority, loadedIntermediateCerts map[string]*configuration.IntermediateCertificateAuthority) {
-	// Root certificates first
-	LoadRootCertificates(configFilePath, conf, loadedRootCerts)
-	LoadIntermediateCertificates(configFilePath, conf, loadedIntermediateCerts)
-	LoadLeafCertificates(configFilePath, conf, loadedIntermediateCerts)
+	"git.mfdlabs.local/petko/mfdlabs-ssl-go/pkg/backend"
+	"git.mfdlabs.local/petko/mfdlabs-ssl-go/pkg/configuration"
+	"git.mfdlabs.local/petko/mfdlabs-ssl-go/pkg/helper"
+	"git.mfdlabs.local/petko/mfdlabs-ssl-go/pkg/inventory"
+)
+
+// Validates every certificate of the configuration and generates them, the returned error is a helper.Errors
+// holding every problem found. Nothing is generated if the configuration is not valid, and the certificates
+// issued by a certificate that failed to generate are skipped.
+// Certificates already in their output directory are kept, unless their issuer is generated again in which case they are
+// signed again by the new issuer.
+func Run(configFilePath string, conf *configuration.SslConfiguration, generator backend.Backend) error {
+	plan, requests, err := prepare(configFilePath, conf, generator, true)
+	if err != nil {
+		return err
+	}
+
+	return execute(context.Background(), plan, requests, generator, os.Stdout, nil)
+}
+
+// Signs the certificate signing requests of the configuration with the given names, or every one of them if no name
+// is given. The whole configuration is validated, and the issuers of the requests are generated first if they don't
+// exist yet. The selected requests are always signed again, as the request itself may have changed.
+func Sign(configFilePath string, conf *configuration.SslConfiguration, generator backend.Backend, names []string) error {
+	plan, requests, err := prepare(configFilePath, conf, generator, true)
+	if err != nil {
+		return err
+	}
+
+	var errs helper.Errors
+	var selected []*PlanNode
+
+	found := make(map[string]bool)
+
+	for _, node := range plan.Nodes {
+		if node.Type == helper.SignedCertificateType && (len(names) == 0 || contains(names, node.Name)) {
+			selected = append(selected, node)
+			found[node.Name] = true
+		}
+	}
+
+	for _, name := range names {
+		if !found[name] {
+			errs = append(errs, fmt.Errorf("the configuration has no certificate signing request named %s", name))
+		}
+	}
+
+	if len(names) == 0 && len(selected) == 0 {
+		errs = append(errs, fmt.Errorf("the configuration has no certificate signing requests"))
+	}
+
+	if len(errs) != 0 {
+		return errs
+	}
+
+	// Only generate the selected requests and their issuers
+	included := make(map[*PlanNode]bool)
+
+	for _, node := range selected {
+		node.Action = helper.Ternary(artifactExists(node.OutputDirectory, node.Type, node.Name, helper.CertificateExtension), RecreateAction, CreateAction).(string)
+
+		for ; node != nil; node = node.Parent() {
+			included[node] = true
+		}
+	}
+
+	signingPlan := &Plan{OutputDirectory: plan.OutputDirectory}
+
+	for _, node := range plan.Nodes {
+		if included[node] {
+			signingPlan.Nodes = append(signingPlan.Nodes, node)
+		}
+	}
+
+	return execute(context.Background(), signingPlan, requests, generator, os.Stdout, nil)
+}
+
+// Builds the plan of the configuration and validates every certificate of it, so every problem is reported at once.
+// Every certificate is also checked against the generator, which is nil when nothing is generated, and the trust store
+// is only detected when something is generated. The generated passwords are only stored in the secrets sink if persist
+// is set, commands that only read the certificates leave it as it is.
+func prepare(configFilePath string, conf *configuration.SslConfiguration, generator backend.Backend, persist bool) (*Plan, map[*PlanNode]interface{}, error) {
+	plan, err := BuildPlan(configFilePath, conf)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if generator != nil {
+		err = resolveTrustStore(plan.Nodes)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	var errs helper.Errors
+
+	// The generated passwords are stored before anything is generated with them
+	passwords, err := newPasswordSource(conf, plan, persist)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	isYaml := configuration.IsYamlFile(configFilePath)
+	requests := make(map[*PlanNode]interface{})
+
+	for _, node := range plan.Nodes {
+		request, err := getRequest(configFilePath, node, passwords)
+		if err != nil {
+			errs = append(errs, locateValidationErrors(err, node, isYaml)...)
+			continue
+		}
+
+		if generator != nil {
+			err = checkBackendSupport(generator.Name(), node, request)
+			if err != nil {
+				errs = append(errs, locateValidationErrors(err, node, isYaml)...)
+				continue
+			}
+		}
+
+		requests[node] = request
+	}
+
+	if len(errs) != 0 {
+		return nil, nil, errs
+	}
+
+	return plan, requests, nil
+}
+
+// Generates the certificates of a validated plan, writing the progress to output and recording every generated
+// certificate in the inventory of the plan. done is called with every certificate
+// that was generated or kept, and may be nil. Generation stops before the next certificate once the context is done.
+func execute(ctx context.Context, plan *Plan, requests map[*PlanNode]interface{}, generator backend.Backend, output io.Writer, done func(node *PlanNode, request interface{})) error {
+	records, err := inventory.Load(plan.OutputDirectory)
+	if err != nil {
+		return err
+	}
+
+	var errs helper.Errors
+
+	// Every issuer is generated before the certificates it issues
+	failed := make(map[*PlanNode]bool)
+
+	for _, node := range plan.Nodes {
+		if err := ctx.Err(); err != nil {
+			errs = append(errs, err)
+			break
+		}
+
+		if parent := node.Parent(); parent != nil && failed[parent] {
+			failed[node] = true
+			errs = append(errs, fmt.Errorf("skipped the %s certificate %s because its issuer %s could not be generated", node.Type, node.Name, parent.Name))
+			continue
+		}
+
+		if node.Action == SkipAction {
+			fmt.Fprintf(output, "Skipping %s certificate: %s, it already exists\n", node.Type, node.Name)
+
+			// The certificate exists, so the certificates it issues are still generated
+			err := completeOutputs(requests[node], output)
+			if err != nil {
+				errs = append(errs, err)
+			}
+		} else {
+			err := os.MkdirAll(node.OutputDirectory, 0755)
+			if err == nil {
+				err = generate(node, requests[node], generator, output)
+			}
+
+			if err != nil {
+				failed[node] = true
+				errs = append(errs, err)
+				continue
+			}
+
+			// The certificate exists even if it couldn't be recorded, so the certificates it issues are still generated
+			err = recordIssuance(records, node, requests[node], generator)
+			if err != nil {
+				errs = append(errs, err)
+			}
+		}
+
+		if done != nil {
+			done(node, requests[node])
+		}
+	}
+
+	if len(errs) != 0 {
+		return errs
+	}
+
+	return nil
+}
+
+// Writes the outputs of a kept certificate that are missing, e.g. the ones added to its entry after it was generated.
+func completeOutputs(request interface{}, output io.Writer) error {
+	switch request := request.(type) {
+	case *backend.RootCertificateAuthorityRequest:
+		err := writeMissingKeystores(request.OutputDirectory, helper.RootCertificateType, request.Name, request.Password, request.Keystores, output)
+		if err != nil {
+			return err
+		}
+
+		err = installMissingIntoTrustStore(request.TrustStore, request.OutputDirectory, helper.RootCertificateType, request.Name, output)
+		if err != nil {
+			return err
+		}
+
+		return importMissingIntoNssDatabases(request.NssDatabases, request.OutputDirectory, helper.RootCertificateType, request.Name, output)
+	case *backend.IntermediateCertificateAuthorityRequest:
+		err := writeMissingKeystores(request.OutputDirectory, helper.IntermediateCertificateType, request.Name, request.Password, request.Keystores, output)
+		if err != nil {
+			return err
+		}
+
+		err = installMissingIntoTrustStore(request.TrustStore, request.OutputDirectory, helper.IntermediateCertificateType, request.Name, output)
+		if err != nil {
+			return err
+		}
+
+		return importMissingIntoNssDatabases(request.NssDatabases, request.OutputDirectory, helper.IntermediateCertificateType, request.Name, output)
+	case *backend.LeafCertificateRequest:
+		err := writeMissingKeystores(request.OutputDirectory, helper.LeafCertificateType, request.Name, request.Password, request.Keystores, output)
+		if err != nil {
+			return err
+		}
+
+		return writeMissingBundles(request.OutputDirectory, helper.LeafCertificateType, request.Name, request.Password, request.Bundles, output)
+	}
+
+	return nil
+}
+
+// Resolves and validates the configuration entry of the node, with the passwords it doesn't set filled by the
+// password source. The password of the certificate is recorded so the certificates it issues can default to it.
+func getRequest(configFilePath string, node *PlanNode, passwords *passwordSource) (interface{}, error) {
+	switch node.Type {
+	case helper.RootCertificateType:
+		entry := *node.RootCertificateAuthority
+
+		err := passwords.fill(node, entry.Passwords, &entry.RootCertificatePassword, &entry.RootCertificatePfxPassword, nil)
+		if err != nil {
+			return nil, err
+		}
+
+		request, err := getRootCertificateAuthorityRequest(&entry)
+		if err != nil {
+			return nil, err
+		}
+
+		request.OutputDirectory = node.OutputDirectory
+		request.TrustStore = node.TrustStore
+		request.NssDatabases = node.NssDatabases
+		passwords.resolved[node] = request.Password
+
+		return request, nil
+	case helper.IntermediateCertificateType:
+		entry := *node.IntermediateCertificateAuthority
+
+		err := passwords.fill(node, entry.Passwords, &entry.IntermediateCertificateAuthorityPassword, &entry.IntermediateCertificateAuthorityPfxPassword, &entry.LastChainCertificatePassword)
+		if err != nil {
+			return nil, err
+		}
+
+		request, err := getIntermediateCertificateAuthorityRequest(&entry)
+		if err != nil {
+			return nil, err
+		}
+
+		request.OutputDirectory = node.OutputDirectory
+		request.ChainOutputDirectory = node.ParentOutputDirectory
+		request.TrustStore = node.TrustStore
+		request.NssDatabases = node.NssDatabases
+		passwords.resolved[node] = request.Password
+
+		return request, nil
+	case helper.SignedCertificateType:
+		entry := *node.CertificateSigningRequest
+
+		err := passwords.fill(node, nil, nil, nil, &entry.LastChainCertificatePassword)
+		if err != nil {
+			return nil, err
+		}
+
+		request, err := getSigningRequest(configFilePath, &entry)
+		if err != nil {
+			return nil, err
+		}
+
+		request.OutputDirectory = node.OutputDirectory
+		request.ChainOutputDirectory = node.ParentOutputDirectory
+
+		return request, nil
+	}
+
+	entry := *node.LeafCertificate
+
+	err := passwords.fill(node, entry.Passwords, &entry.LeafCertificatePassword, &entry.LeafCertificatePfxPassword, &entry.LastChainCertificatePassword)
+	if err != nil {
+		return nil, err
+	}
+
+	request, err := getLeafCertificateRequest(&entry)
+	if err != nil {
+		return nil, err
+	}
+
+	request.OutputDirectory = node.OutputDirectory
+	request.ChainOutputDirectory = node.ParentOutputDirectory
+	passwords.resolved[node] = request.Password
+
+	return request, nil
+}
+
+func generate(node *PlanNode, request interface{}, generator backend.Backend, output io.Writer) error {
+	switch node.Type {
+	case helper.RootCertificateType:
+		return loadRootCertificateAuthority(node.RootCertificateAuthority, request.(*backend.RootCertificateAuthorityRequest), generator, output)
+	case helper.IntermediateCertificateType:
+		return loadIntermediateCertificateAuthority(node.IntermediateCertificateAuthority, request.(*backend.IntermediateCertificateAuthorityRequest), generator, output)
+	case helper.SignedCertificateType:
+		return loadSignedCertificate(request.(*backend.SigningRequest), generator, output)
+	}
+
+	return loadLeafCertificate(node.LeafCertificate, request.(*backend.LeafCertificateRequest), generator, output)
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+
+	return false
 }
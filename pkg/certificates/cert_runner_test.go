@@ -0,0 +1,207 @@
+package certificates
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+
+	"git.mfdlabs.local/petko/mfdlabs-ssl-go/pkg/backend"
+	"git.mfdlabs.local/petko/mfdlabs-ssl-go/pkg/configuration"
+	"git.mfdlabs.local/petko/mfdlabs-ssl-go/pkg/helper"
+	"git.mfdlabs.local/petko/mfdlabs-ssl-go/pkg/nss"
+	"git.mfdlabs.local/petko/mfdlabs-ssl-go/pkg/truststore"
+)
+
+func TestCompleteOutputsWritesMissingKeystores(t *testing.T) {
+	directory := t.TempDir()
+	request := generateTestRoot(t, directory)
+
+	existing := &backend.KeystoreOptions{Type: helper.KeystoreType, Format: helper.PKCS12KeystoreFormat, Password: "changeit"}
+	missing := &backend.KeystoreOptions{Type: helper.TruststoreType, Format: helper.JKSKeystoreFormat, Password: "changeit"}
+
+	existingPath := backend.GetKeystorePath(directory, helper.RootCertificateType, "root", existing)
+	missingPath := backend.GetKeystorePath(directory, helper.RootCertificateType, "root", missing)
+
+	// A keystore written by an earlier run is kept as it is
+	err := ioutil.WriteFile(existingPath, []byte("earlier run"), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	request.Keystores = []*backend.KeystoreOptions{existing, missing}
+
+	var output bytes.Buffer
+
+	err = completeOutputs(request, &output)
+	if err != nil {
+		t.Fatalf("failed to complete the outputs: %s", err)
+	}
+
+	content, err := ioutil.ReadFile(existingPath)
+	if err != nil || string(content) != "earlier run" {
+		t.Fatalf("expected the existing keystore to be kept, got %q, %v", content, err)
+	}
+
+	if _, err := os.Stat(missingPath); err != nil {
+		t.Fatalf("expected the missing truststore to be written: %s", err)
+	}
+
+	if !bytes.Contains(output.Bytes(), []byte(missingPath)) || bytes.Contains(output.Bytes(), []byte(existingPath)) {
+		t.Fatalf("expected only the missing truststore to be reported, got %q", output.String())
+	}
+}
+
+func TestCompleteOutputsWritesMissingBundles(t *testing.T) {
+	directory := t.TempDir()
+	generateTestRoot(t, directory)
+
+	request := &backend.LeafCertificateRequest{
+		Name:                            "leaf",
+		Password:                        "password",
+		PfxPassword:                     "password",
+		OutputDirectory:                 directory,
+		ChainName:                       "root",
+		ChainPassword:                   "password",
+		ChainOutputDirectory:            directory,
+		IsChainRootCertificateAuthority: true,
+		KeyAlgorithm:                    helper.ECDSAKeyAlgorithm,
+		KeySize:                         256,
+		ValidityPeriod:                  10,
+		Configuration:                   &configuration.LeafCertificateConfiguration{BaseCertificateConfiguration: configuration.BaseCertificateConfiguration{CommonName: "leaf"}},
+	}
+
+	err := backend.NewNativeBackend().GenerateLeafCertificate(request)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	existing := &backend.BundleOptions{Profile: helper.NginxBundleProfile}
+	missing := &backend.BundleOptions{Profile: helper.HAProxyBundleProfile}
+
+	// A bundle written by an earlier run is kept as it is
+	err = os.Mkdir(backend.GetBundleDirectory(directory, existing), 0755)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	request.Bundles = []*backend.BundleOptions{existing, missing}
+
+	err = completeOutputs(request, ioutil.Discard)
+	if err != nil {
+		t.Fatalf("failed to complete the outputs: %s", err)
+	}
+
+	for bundle, expected := range map[*backend.BundleOptions]int{existing: 0, missing: 1} {
+		files, err := ioutil.ReadDir(backend.GetBundleDirectory(directory, bundle))
+		if err != nil || len(files) != expected {
+			t.Fatalf("expected %d files in the %s bundle, got %d, %v", expected, bundle.Profile, len(files), err)
+		}
+	}
+}
+
+func TestCompleteOutputsInstallsMissingTrustStoreCertificates(t *testing.T) {
+	directory := t.TempDir()
+	request := generateTestRoot(t, directory)
+
+	options, err := truststore.GetOptions(truststore.DebianDistribution, t.TempDir(), "", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	request.TrustStore = options
+
+	err = completeOutputs(request, ioutil.Discard)
+	if err != nil {
+		t.Fatalf("failed to complete the outputs: %s", err)
+	}
+
+	path := options.GetPath("root-ca-root")
+
+	installed, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatalf("expected the root certificate authority to be installed: %s", err)
+	}
+
+	certificate, err := ioutil.ReadFile(helper.GetArtifactPath(directory, helper.RootCertificateType, "root", helper.CertificateExtension))
+	if err != nil || !bytes.Equal(installed, certificate) {
+		t.Fatalf("expected the certificate to be installed as it is, %v", err)
+	}
+
+	// A certificate authority that is already installed is left as it is
+	err = ioutil.WriteFile(path, []byte("earlier run"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = completeOutputs(request, ioutil.Discard)
+	if err != nil {
+		t.Fatalf("failed to complete the outputs: %s", err)
+	}
+
+	if installed, _ := ioutil.ReadFile(path); string(installed) != "earlier run" {
+		t.Fatalf("expected the installed certificate to be kept, got %q", installed)
+	}
+}
+
+func TestCompleteOutputsImportsMissingNssCertificates(t *testing.T) {
+	directory := t.TempDir()
+	request := generateTestRoot(t, directory)
+
+	options, err := nss.GetOptions(nss.NativeMethod, t.TempDir(), []string{"imported", "missing"}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	request.NssDatabases = &nss.Options{Method: options.Method, Home: options.Home, Databases: options.Databases[:1]}
+
+	err = completeOutputs(request, ioutil.Discard)
+	if err != nil {
+		t.Fatalf("failed to complete the outputs: %s", err)
+	}
+
+	// The database the certificate authority is already in is left as it is
+	request.NssDatabases = options
+
+	var output bytes.Buffer
+
+	err = completeOutputs(request, &output)
+	if err != nil {
+		t.Fatalf("failed to complete the outputs: %s", err)
+	}
+
+	if strings.Count(output.String(), "Imported") != 1 || !strings.Contains(output.String(), options.Databases[1]) {
+		t.Fatalf("expected only the missing database to be imported into, got %q", output.String())
+	}
+
+	for _, database := range options.Databases {
+		imported, err := nss.Imported(options, database)
+		if err != nil || len(imported) != 1 || imported[0] != "root-ca-root" {
+			t.Fatalf("expected root-ca-root in %s, got %v, %v", database, imported, err)
+		}
+	}
+}
+
+// Generates a root certificate authority named root into the directory.
+func generateTestRoot(t *testing.T, directory string) *backend.RootCertificateAuthorityRequest {
+	t.Helper()
+
+	request := &backend.RootCertificateAuthorityRequest{
+		Name:            "root",
+		Password:        "password",
+		PfxPassword:     "password",
+		OutputDirectory: directory,
+		KeyAlgorithm:    helper.ECDSAKeyAlgorithm,
+		KeySize:         256,
+		ValidityPeriod:  30,
+		Configuration:   &configuration.BaseCertificateConfiguration{CommonName: "Root"},
+	}
+
+	err := backend.NewNativeBackend().GenerateRootCertificateAuthority(request)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return request
+}
@@ -0,0 +1,92 @@
+package certificates
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"git.mfdlabs.local/petko/mfdlabs-ssl-go/pkg/backend"
+	"git.mfdlabs.local/petko/mfdlabs-ssl-go/pkg/configuration"
+	"git.mfdlabs.local/petko/mfdlabs-ssl-go/pkg/helper"
+)
+
+// The statuses of a certificate in an expiry report, from the least to the most severe.
+const (
+	ValidExpiryStatus    = "valid"
+	MissingExpiryStatus  = "missing"
+	ExpiringExpiryStatus = "expiring"
+	ExpiredExpiryStatus  = "expired"
+)
+
+// The remaining lifetime of a certificate of the configuration.
+type CertificateExpiry struct {
+	Type   string `json:"type"`
+	Name   string `json:"name"`
+	Status string `json:"status"`
+
+	// The path of the certificate in the output directory, whether it exists or not.
+	Path string `json:"path"`
+
+	// The validity of the certificate, nil if it's missing.
+	NotAfter *time.Time `json:"notAfter,omitempty"`
+
+	// The whole days left until the certificate expires, negative once it expired. Nil if it's missing.
+	RemainingDays *int `json:"remainingDays,omitempty"`
+}
+
+// Checks when every certificate of the configuration expires. Certificates that expire within the given duration
+// are expiring, certificates that were not generated yet are missing.
+func CheckExpiry(configFilePath string, conf *configuration.SslConfiguration, within time.Duration) ([]*CertificateExpiry, error) {
+	plan, err := BuildPlan(configFilePath, conf)
+	if err != nil {
+		return nil, err
+	}
+
+	var errs helper.Errors
+	var report []*CertificateExpiry
+
+	now := time.Now().UTC()
+
+	for _, node := range plan.Nodes {
+		expiry := &CertificateExpiry{
+			Type:   node.Type,
+			Name:   node.Name,
+			Status: MissingExpiryStatus,
+			Path:   helper.GetArtifactPath(node.OutputDirectory, node.Type, node.Name, helper.CertificateExtension),
+		}
+
+		report = append(report, expiry)
+
+		if !artifactExists(node.OutputDirectory, node.Type, node.Name, helper.CertificateExtension) {
+			continue
+		}
+
+		chain, err := backend.LoadCertificateChain(node.OutputDirectory, node.Type, node.Name)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to load the %s certificate %s: %w", node.Type, node.Name, err))
+			continue
+		}
+
+		notAfter := chain[0].NotAfter.UTC()
+		remaining := notAfter.Sub(now)
+		remainingDays := int(math.Floor(remaining.Hours() / 24))
+
+		expiry.NotAfter = &notAfter
+		expiry.RemainingDays = &remainingDays
+
+		switch {
+		case remaining <= 0:
+			expiry.Status = ExpiredExpiryStatus
+		case remaining <= within:
+			expiry.Status = ExpiringExpiryStatus
+		default:
+			expiry.Status = ValidExpiryStatus
+		}
+	}
+
+	if len(errs) != 0 {
+		return nil, errs
+	}
+
+	return report, nil
+}
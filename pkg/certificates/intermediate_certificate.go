@@ -2,114 +2,80 @@ package certificates
 
 import (
 	"fmt"
+	"io"
 
+	"git.mfdlabs.local/petko/mfdlabs-ssl-go/pkg/backend"
 	"git.mfdlabs.local/petko/mfdlabs-ssl-go/pkg/configuration"
 	"git.mfdlabs.local/petko/mfdlabs-ssl-go/pkg/helper"
 )
 
-func LoadIntermediateCertificates(configFilePath string, conf *configuration.SslConfiguration, loadedIntCerts map[string]*configuration.IntermediateCertificateAuthority) {
-	// check if the int ca certificates are empty
-	if len(conf.IntermediateCertificateAuthorities) != 0 {
-		// load the int ca certificates
-		for _, intCert := range conf.IntermediateCertificateAuthorities {
-			loadIntermediateCertificateAuthority(configFilePath, intCert, loadedIntCerts, conf.IntermediateCertificateAuthorities)
-		}
-	}
-}
+// Resolves and validates the intermediate certificate authority, every problem with it is reported.
+func getIntermediateCertificateAuthorityRequest(intCert *configuration.IntermediateCertificateAuthority) (*backend.IntermediateCertificateAuthorityRequest, error) {
+	resolver := &fieldResolver{certType: helper.IntermediateCertificateType}
 
-func loadIntermediateCertificateAuthority(configFilePath string, intCert *configuration.IntermediateCertificateAuthority, loadedIntCerts map[string]*configuration.IntermediateCertificateAuthority, intCerts []*configuration.IntermediateCertificateAuthority) {
-	err := DetermineIfIntermediateCAIsReference(configFilePath, intCert)
+	intCertName := resolver.resolve("name", intCert.IntermediateCertificateAuthorityName)
+	resolver.certName = intCertName
 
-	caChainName := helper.ReplaceEnvironmentExpression(intCert.LastChainCertificateName)
-	caChainPassword := helper.ReplaceEnvironmentExpression(intCert.LastChainCertificatePassword)
-	intCertName := helper.ReplaceEnvironmentExpression(intCert.IntermediateCertificateAuthorityName)
-	intCertPassword := helper.ReplaceEnvironmentExpression(intCert.IntermediateCertificateAuthorityPassword)
-	intCertPfxPassword := helper.ReplaceEnvironmentExpression(intCert.IntermediateCertificateAuthorityPfxPassword)
+	caChainName := resolver.resolve("caChainName", intCert.LastChainCertificateName)
+	caChainPassword := resolver.resolve("caChainPassword", intCert.LastChainCertificatePassword)
+	intCertPassword := resolver.resolve("password", intCert.IntermediateCertificateAuthorityPassword)
+	intCertPfxPassword := resolver.resolve("pfxPassword", intCert.IntermediateCertificateAuthorityPfxPassword)
 
-	// check if the root certificate is already loaded
-	if _, ok := loadedIntCerts[caChainName]; ok {
-		return
-	}
+	// The output directory itself is resolved by the plan
+	resolver.resolve("outputDirectory", intCert.OutputDirectory)
 
-	fmt.Printf("Loading intermediate certificate: %s\n", intCertName)
+	keyAlgorithmName := resolver.resolve("keyAlgorithm", intCert.KeyAlgorithm)
+	renewalKeyPolicyName := resolver.resolve("renewalKeyPolicy", intCert.RenewalKeyPolicy)
+	conf := resolver.resolveConfiguration(intCert.Configuration).(*configuration.BaseCertificateConfiguration)
+	pfx := getPfxOptions(resolver, intCert.Pfx)
+	keystores := getKeystoreOptions(resolver, intCert.Keystores)
 
-	if err != nil {
-		panic(err)
-	}
+	errs := resolver.errs
 
 	// Check if the required fields are empty
 	if caChainName == "" {
-		panic("The ca chain certificate name cannot be empty")
+		errs = append(errs, helper.NewValidationError(helper.IntermediateCertificateType, intCertName, "caChainName", "cannot be empty"))
+	} else if err := helper.CheckCertificateName(caChainName); err != nil {
+		errs = append(errs, helper.NewValidationError(helper.IntermediateCertificateType, intCertName, "caChainName", err.Error()))
 	}
 
 	// Chain cannot be the same as the int cert if it's not a Root CA
-	if intCertName == caChainName {
-		if !intCert.IsLastChainCertificateRootCertificateAuthority {
-			panic("The chain certificate name cannot be the same as the intermediate certificate name if it's not a root certificate authority")
-		}
+	if intCertName == caChainName && !intCert.IsLastChainCertificateRootCertificateAuthority {
+		errs = append(errs, helper.NewValidationError(helper.IntermediateCertificateType, intCertName, "caChainName", "cannot be the same as the intermediate certificate name if it's not a root certificate authority"))
 	}
 
 	if caChainPassword == "" {
-		panic("The chain certificate password cannot be empty")
-	}
-
-	// Check if password is less than 8 characters
-	if len(caChainPassword) < 4 {
-		panic("The chain certificate password cannot be less than 4 characters")
+		errs = append(errs, helper.NewValidationError(helper.IntermediateCertificateType, intCertName, "caChainPassword", "cannot be empty"))
+	} else if len(caChainPassword) < 4 {
+		// Check if password is less than 4 characters
+		errs = append(errs, helper.NewValidationError(helper.IntermediateCertificateType, intCertName, "caChainPassword", "cannot be less than 4 characters"))
 	}
 
 	if intCertName == "" {
-		panic("The intermediate certificate name cannot be empty")
+		errs = append(errs, helper.NewValidationError(helper.IntermediateCertificateType, intCertName, "name", "cannot be empty"))
+	} else if err := helper.CheckCertificateName(intCertName); err != nil {
+		errs = append(errs, helper.NewValidationError(helper.IntermediateCertificateType, intCertName, "name", err.Error()))
 	}
 
-	// Check if password is less than 8 characters
+	// Check if password is less than 4 characters
 	if len(intCertPassword) < 4 {
-		panic("The intermediate certificate password cannot be less than 4 characters")
+		errs = append(errs, helper.NewValidationError(helper.IntermediateCertificateType, intCertName, "password", "cannot be less than 4 characters"))
 	}
 
-	// Check if password is less than 8 characters
+	// Check if password is less than 4 characters
 	if len(intCertPfxPassword) < 4 {
-		panic("The intermediate certificate PFX password cannot be less than 4 characters")
+		errs = append(errs, helper.NewValidationError(helper.IntermediateCertificateType, intCertName, "pfxPassword", "cannot be less than 4 characters"))
 	}
 
-	err = helper.CheckCertificateName(caChainName)
-	if err != nil {
-		panic(err)
-	}
-	err = helper.CheckCertificateName(intCertName)
+	// Validate the key size for the key algorithm, defaults to a 2048 bit RSA key
+	keyAlgorithm, keyLength, err := helper.CheckKeyAlgorithm(keyAlgorithmName, intCert.PrivateKeySize)
 	if err != nil {
-		panic(err)
-	}
-
-	// Section for generating the ca chain certificate if it's in the config and further down in the code
-	if len(intCerts) > 0 && !intCert.IsLastChainCertificateRootCertificateAuthority {
-		// Check if we have the last chain certificate generated
-		if _, ok := loadedIntCerts[caChainName]; !ok {
-			// iterate through the int certs until we find the last chain certificate
-			for _, intCert := range intCerts {
-				if intCert.IntermediateCertificateAuthorityName == caChainName {
-					loadIntermediateCertificateAuthority(configFilePath, intCert, loadedIntCerts, intCerts)
-				}
-			}
-		}
-	}
-
-	// Get string of if the cert should be inserted into the trust store
-	shouldInsertIntoTrustedStore := helper.Ternary(intCert.ShouldInsertIntoTrustedStore, "YES", "NO").(string)
-	skipDhParam := helper.Ternary(intCert.GenerateDHParameters, "NO", "YES").(string)
-	keepCertificateRequestFile := helper.Ternary(intCert.KeepCertificateRequestFile, "YES", "NO").(string)
-	isLastChainRootCa := helper.Ternary(intCert.IsLastChainCertificateRootCertificateAuthority, "YES", "NO").(string)
-
-	keyLength := intCert.PrivateKeySize
-
-	// If the key length is not set, set it to 2048
-	if keyLength == 0 {
-		keyLength = 2048
+		errs = append(errs, helper.NewValidationError(helper.IntermediateCertificateType, intCertName, getKeyAlgorithmField(keyAlgorithmName), err.Error()))
 	}
 
-	// If the key length is not 1024, 2048 or 4096, error out
-	if keyLength != 1024 && keyLength != 2048 && keyLength != 4096 {
-		panic("The key length must be 1024, 2048 or 4096")
+	renewalKeyPolicy, err := helper.CheckRenewalKeyPolicy(renewalKeyPolicyName)
+	if err != nil {
+		errs = append(errs, helper.NewValidationError(helper.IntermediateCertificateType, intCertName, "renewalKeyPolicy", err.Error()))
 	}
 
 	expirationInDays := intCert.ValidityPeriod
@@ -121,32 +87,71 @@ func loadIntermediateCertificateAuthority(configFilePath string, intCert *config
 
 	// If the expiration in days is less than 0, error out
 	if expirationInDays < 0 {
-		panic("The expiration in days must be greater than or equal to 0")
+		errs = append(errs, helper.NewValidationError(helper.IntermediateCertificateType, intCertName, "validityPeriod", "must be greater than or equal to 0"))
 	}
 
-	configuration.GenerateIntermediateCertificateConfigurationFileIfNotExists(intCert)
+	if intCert.CrlValidityPeriod < 0 {
+		errs = append(errs, helper.NewValidationError(helper.IntermediateCertificateType, intCertName, "crlValidityPeriod", "must be greater than or equal to 0"))
+	}
 
-	caChainPasswordFilename, err := helper.WritePasswordFile("chain", caChainName, "normal", caChainPassword)
-	if err != nil {
-		panic(err)
+	// Validate the subject and extensions of the certificate
+	errs = append(errs, nameValidationErrors(configuration.ValidateBaseCertificateConfiguration(conf), helper.IntermediateCertificateType, intCertName)...)
+
+	if len(errs) != 0 {
+		return nil, errs
 	}
-	intCertPasswordFilename, err := helper.WritePasswordFile("intermediate", intCertName, "normal", intCertPassword)
+
+	return &backend.IntermediateCertificateAuthorityRequest{
+		Name:                            intCertName,
+		Password:                        intCertPassword,
+		PfxPassword:                     intCertPfxPassword,
+		Pfx:                             pfx,
+		Keystores:                       keystores,
+		ChainName:                       caChainName,
+		ChainPassword:                   caChainPassword,
+		IsChainRootCertificateAuthority: intCert.IsLastChainCertificateRootCertificateAuthority,
+		KeyAlgorithm:                    keyAlgorithm,
+		KeySize:                         keyLength,
+		ValidityPeriod:                  expirationInDays,
+		RenewalKeyPolicy:                renewalKeyPolicy,
+		GenerateDHParameters:            intCert.GenerateDHParameters,
+		KeepCertificateRequestFile:      intCert.KeepCertificateRequestFile,
+		Configuration:                   conf,
+	}, nil
+}
+
+func loadIntermediateCertificateAuthority(intCert *configuration.IntermediateCertificateAuthority, request *backend.IntermediateCertificateAuthorityRequest, generator backend.Backend, output io.Writer) error {
+	fmt.Fprintf(output, "Loading intermediate certificate: %s\n", request.Name)
+
+	// The configuration file is generated from the resolved configuration
+	resolved := *intCert
+	resolved.Configuration = request.Configuration
+	resolved.OverwriteExistingConfiguration = overwritesConfiguration(intCert.OverwriteExistingConfiguration, generator)
+
+	err := configuration.GenerateIntermediateCertificateConfigurationFileIfNotExists(request.OutputDirectory, request.Name, &resolved)
 	if err != nil {
-		panic(err)
+		return err
 	}
-	intCertPfxPasswordFilename, err := helper.WritePasswordFile("intermediate", intCertName, "pfx", intCertPfxPassword)
+
+	err = generator.GenerateIntermediateCertificateAuthority(request)
 	if err != nil {
-		panic(err)
+		return fmt.Errorf("failed to generate the intermediate certificate %s: %w", request.Name, err)
 	}
 
-	// Get command text
-	command := fmt.Sprintf("./ssl/generate-intermediate-ca.sh %s @%s @%s %s @%s %s %s %s %s %d %d", intCertName, intCertPasswordFilename, intCertPfxPasswordFilename, caChainName, caChainPasswordFilename, isLastChainRootCa, shouldInsertIntoTrustedStore, skipDhParam, keepCertificateRequestFile, expirationInDays, keyLength)
+	err = backend.WriteKeystores(request.OutputDirectory, helper.IntermediateCertificateType, request.Name, request.Password, request.Keystores)
+	if err != nil {
+		return fmt.Errorf("failed to write the keystores of the intermediate certificate %s: %w", request.Name, err)
+	}
 
-	// Execute the command
-	helper.ExecuteRawCommand(command)
+	err = installIntoTrustStore(request.TrustStore, request.OutputDirectory, helper.IntermediateCertificateType, request.Name, output)
+	if err != nil {
+		return fmt.Errorf("failed to install the intermediate certificate %s into the trust store: %w", request.Name, err)
+	}
 
-	// Add the root certificate to the map
-	loadedIntCerts[caChainName] = intCert
+	err = importIntoNssDatabases(request.NssDatabases, request.OutputDirectory, helper.IntermediateCertificateType, request.Name, output)
+	if err != nil {
+		return fmt.Errorf("failed to import the intermediate certificate %s into the NSS databases: %w", request.Name, err)
+	}
 
-	defer helper.DeleteTmpPasswords([]string{caChainPasswordFilename, intCertPasswordFilename, intCertPfxPasswordFilename})
+	return nil
 }
@@ -0,0 +1,107 @@
+package certificates
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"git.mfdlabs.local/petko/mfdlabs-ssl-go/pkg/backend"
+	"git.mfdlabs.local/petko/mfdlabs-ssl-go/pkg/configuration"
+	"git.mfdlabs.local/petko/mfdlabs-ssl-go/pkg/inventory"
+)
+
+// What the configuration hash of a certificate is computed over, everything that shapes the certificate but the
+// passwords and the paths.
+type hashedConfiguration struct {
+	KeyAlgorithm       string      `json:"keyAlgorithm,omitempty"`
+	KeySize            int         `json:"keySize,omitempty"`
+	ValidityPeriod     int         `json:"validityPeriod"`
+	Issuer             string      `json:"issuer,omitempty"`
+	CertificateRequest []byte      `json:"certificateRequest,omitempty"`
+	Configuration      interface{} `json:"config"`
+}
+
+// Loads the inventory of the output directory of the configuration.
+func LoadInventory(conf *configuration.SslConfiguration) (*inventory.Inventory, error) {
+	outputDirectory, err := resolveOutputDirectory(conf.OutputDirectory, "")
+	if err != nil {
+		return nil, fmt.Errorf("the output directory %s is not valid: %s", conf.OutputDirectory, err)
+	}
+
+	return inventory.Load(outputDirectory)
+}
+
+// Records the certificate of the node, which was just generated, in the inventory.
+func recordIssuance(records *inventory.Inventory, node *PlanNode, request interface{}, generator backend.Backend) error {
+	chain, err := backend.LoadCertificateChain(node.OutputDirectory, node.Type, node.Name)
+	if err != nil {
+		return err
+	}
+
+	hash, err := getConfigurationHash(node, request)
+	if err != nil {
+		return err
+	}
+
+	paths := inventory.ArtifactPaths(*getArtifactPaths(node))
+
+	record := inventory.NewRecord(chain[0])
+	record.Type = node.Type
+	record.Name = node.Name
+	record.ParentType = node.ParentType
+	record.ParentName = node.ParentName
+	record.ConfigurationHash = hash
+	record.Backend = generator.Name()
+	record.IssuedAt = time.Now().UTC().Truncate(time.Second)
+	record.Artifacts = &paths
+
+	records.Add(record)
+
+	err = records.Save()
+	if err != nil {
+		return fmt.Errorf("failed to record the %s certificate %s in the inventory: %w", node.Type, node.Name, err)
+	}
+
+	return nil
+}
+
+func getConfigurationHash(node *PlanNode, request interface{}) (string, error) {
+	hashed := &hashedConfiguration{}
+
+	if node.ParentName != "" {
+		hashed.Issuer = node.ParentType + "/" + node.ParentName
+	}
+
+	switch request := request.(type) {
+	case *backend.RootCertificateAuthorityRequest:
+		hashed.KeyAlgorithm = request.KeyAlgorithm
+		hashed.KeySize = request.KeySize
+		hashed.ValidityPeriod = request.ValidityPeriod
+		hashed.Configuration = request.Configuration
+	case *backend.IntermediateCertificateAuthorityRequest:
+		hashed.KeyAlgorithm = request.KeyAlgorithm
+		hashed.KeySize = request.KeySize
+		hashed.ValidityPeriod = request.ValidityPeriod
+		hashed.Configuration = request.Configuration
+	case *backend.LeafCertificateRequest:
+		hashed.KeyAlgorithm = request.KeyAlgorithm
+		hashed.KeySize = request.KeySize
+		hashed.ValidityPeriod = request.ValidityPeriod
+		hashed.Configuration = request.Configuration
+	case *backend.SigningRequest:
+		hashed.ValidityPeriod = request.ValidityPeriod
+		hashed.CertificateRequest = request.CertificateRequest.Raw
+		hashed.Configuration = request.Configuration
+	}
+
+	content, err := json.Marshal(hashed)
+	if err != nil {
+		return "", err
+	}
+
+	sum := sha256.Sum256(content)
+
+	return hex.EncodeToString(sum[:]), nil
+}
@@ -0,0 +1,298 @@
+package certificates
+
+import (
+	"context"
+	"crypto"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"git.mfdlabs.local/petko/mfdlabs-ssl-go/pkg/backend"
+	"git.mfdlabs.local/petko/mfdlabs-ssl-go/pkg/configuration"
+	"git.mfdlabs.local/petko/mfdlabs-ssl-go/pkg/helper"
+)
+
+// An Issuer generates the certificates of a configuration in-process, for tools that use ssl-go as a library.
+// It always uses the native backend, and every path it reads or writes is absolute so it never depends on the
+// working directory of the process.
+type Issuer struct {
+	conf                  *configuration.SslConfiguration
+	configurationFilePath string
+	generator             backend.Backend
+}
+
+// The options of an Issuer.
+type IssuerOptions struct {
+	// The absolute path of the configuration file, relative $ref entries are relative to its directory.
+	// It can be empty if the configuration doesn't have relative $ref entries.
+	ConfigurationFilePath string
+
+	// The absolute path of the directory the certificates are written to, overrides the output directory
+	// of the configuration. One of them has to be set.
+	OutputDirectory string
+}
+
+// A certificate of the configuration after it was issued, or kept because it already existed.
+type IssuedCertificate struct {
+	// The type of the certificate: root, intermediate, leaf or signed.
+	Type string
+
+	// The resolved name of the certificate.
+	Name string
+
+	// Either create, recreate or skip when the existing certificate was kept.
+	Action string
+
+	Certificate *x509.Certificate
+
+	// The private key of the certificate, nil for signed certificates.
+	PrivateKey crypto.Signer
+
+	// The certificate followed by its issuers, up to the root certificate authority.
+	Chain []*x509.Certificate
+
+	// The absolute paths of the artifacts of the certificate.
+	Paths *ArtifactPaths
+}
+
+// The absolute paths of the artifacts of a certificate, the ones that weren't written are empty.
+type ArtifactPaths struct {
+	PrivateKey         string
+	Certificate        string
+	Chain              string
+	Pfx                string
+	CertificateRequest string
+	DHParameters       string
+	Configuration      string
+}
+
+// Creates an issuer for the configuration, the configuration is not modified.
+func NewIssuer(conf *configuration.SslConfiguration, options IssuerOptions) (*Issuer, error) {
+	if conf == nil {
+		return nil, fmt.Errorf("the configuration cannot be nil")
+	}
+
+	if options.ConfigurationFilePath != "" && !filepath.IsAbs(options.ConfigurationFilePath) {
+		return nil, fmt.Errorf("the configuration file path %s must be absolute", options.ConfigurationFilePath)
+	}
+
+	// Work on a copy, so the output directory of the caller's configuration is left alone
+	issuerConf := *conf
+	issuerConf.Backend = backend.NativeBackendName
+
+	if options.OutputDirectory != "" {
+		issuerConf.OutputDirectory = options.OutputDirectory
+	}
+
+	return &Issuer{
+		conf:                  &issuerConf,
+		configurationFilePath: options.ConfigurationFilePath,
+		generator:             backend.NewNativeBackend(),
+	}, nil
+}
+
+// Validates the whole configuration without generating anything, see Validate.
+func (issuer *Issuer) Validate(ctx context.Context) error {
+	err := ctx.Err()
+	if err != nil {
+		return err
+	}
+
+	err = issuer.checkPaths()
+	if err != nil {
+		return err
+	}
+
+	return Validate(issuer.configurationFilePath, issuer.conf)
+}
+
+// Describes what Issue would do with every certificate, without writing anything, see DescribePlan.
+func (issuer *Issuer) Plan(ctx context.Context) ([]*PlannedCertificate, error) {
+	err := ctx.Err()
+	if err != nil {
+		return nil, err
+	}
+
+	err = issuer.checkPaths()
+	if err != nil {
+		return nil, err
+	}
+
+	return DescribePlan(issuer.configurationFilePath, issuer.conf, issuer.generator)
+}
+
+// Generates the certificates of the configuration, issuers first, and returns every certificate that was issued or
+// kept. Nothing is generated if the configuration is not valid. Generation stops before the next certificate once the
+// context is done, the certificates issued until then are returned along with the error.
+func (issuer *Issuer) Issue(ctx context.Context) ([]*IssuedCertificate, error) {
+	err := ctx.Err()
+	if err != nil {
+		return nil, err
+	}
+
+	err = issuer.checkPaths()
+	if err != nil {
+		return nil, err
+	}
+
+	plan, requests, err := prepare(issuer.configurationFilePath, issuer.conf, issuer.generator, true)
+	if err != nil {
+		return nil, err
+	}
+
+	var issued []*IssuedCertificate
+	var errs helper.Errors
+
+	err = execute(ctx, plan, requests, issuer.generator, ioutil.Discard, func(node *PlanNode, request interface{}) {
+		certificate, err := loadIssuedCertificate(node, request)
+		if err != nil {
+			errs = append(errs, err)
+			return
+		}
+
+		issued = append(issued, certificate)
+	})
+
+	errs = helper.AppendError(errs, err)
+
+	if len(errs) != 0 {
+		return issued, errs
+	}
+
+	return issued, nil
+}
+
+// Checks that every output directory, $ref entry and the secrets sink are absolute, or relative to an absolute path.
+func (issuer *Issuer) checkPaths() error {
+	var errs helper.Errors
+
+	outputDirectory, err := helper.ResolveExpressions(issuer.conf.OutputDirectory)
+	if err != nil {
+		errs = append(errs, fmt.Errorf("the output directory %s is not valid: %s", issuer.conf.OutputDirectory, err))
+	} else if outputDirectory == "" {
+		errs = append(errs, fmt.Errorf("the output directory must be set, either in the configuration or in the options of the issuer"))
+	} else if !filepath.IsAbs(outputDirectory) {
+		errs = append(errs, fmt.Errorf("the output directory must be an absolute path, got %q", outputDirectory))
+	}
+
+	// The file and keyring sinks are read and written by every run
+	if sink := issuer.conf.SecretsSink; sink != nil && sink.Type != providerSecretsSink {
+		path, err := helper.ResolveExpressions(sink.Path)
+		if err == nil && path != "" && !filepath.IsAbs(path) {
+			errs = append(errs, fmt.Errorf("the path of the secrets sink must be an absolute path, got %q", path))
+		}
+	}
+
+	checkEntry := func(certType string, index int, outputDirectory string, ref string) {
+		// Unresolved expressions are reported by the validation of the certificate
+		resolved, err := helper.ResolveExpressions(outputDirectory)
+		if err == nil && resolved != "" && !filepath.IsAbs(resolved) {
+			errs = append(errs, fmt.Errorf("the output directory of %s certificate #%d must be an absolute path, got %q", certType, index, resolved))
+		}
+
+		if ref != "" && !filepath.IsAbs(ref) && issuer.configurationFilePath == "" {
+			errs = append(errs, fmt.Errorf("the $ref %s of %s certificate #%d is relative, but there is no configuration file path", ref, certType, index))
+		}
+	}
+
+	for i, cert := range issuer.conf.RootCertificateAuthorities {
+		checkEntry(helper.RootCertificateType, i, cert.OutputDirectory, cert.ReferencedConfigurationPath)
+	}
+
+	for i, cert := range issuer.conf.IntermediateCertificateAuthorities {
+		checkEntry(helper.IntermediateCertificateType, i, cert.OutputDirectory, cert.ReferencedConfigurationPath)
+	}
+
+	for i, cert := range issuer.conf.LeafCertificateAuthorities {
+		checkEntry(helper.LeafCertificateType, i, cert.OutputDirectory, cert.ReferencedConfigurationPath)
+	}
+
+	for i, cert := range issuer.conf.CertificateSigningRequests {
+		checkEntry(helper.SignedCertificateType, i, cert.OutputDirectory, cert.ReferencedConfigurationPath)
+
+		// Like $ref entries, relative requests are relative to the configuration file
+		requestPath, err := helper.ResolveExpressions(cert.RequestPath)
+		if err == nil && requestPath != "" && !filepath.IsAbs(requestPath) && issuer.configurationFilePath == "" {
+			errs = append(errs, fmt.Errorf("the request %s of %s certificate #%d is relative, but there is no configuration file path", requestPath, helper.SignedCertificateType, i))
+		}
+	}
+
+	if len(errs) != 0 {
+		return errs
+	}
+
+	return nil
+}
+
+// Loads the certificate of the node back from its output directory.
+func loadIssuedCertificate(node *PlanNode, request interface{}) (*IssuedCertificate, error) {
+	// Only the certificate and chain of signed certificates are known
+	if node.Type == helper.SignedCertificateType {
+		chain, err := backend.LoadCertificateChain(node.OutputDirectory, node.Type, node.Name)
+		if err != nil {
+			return nil, err
+		}
+
+		return &IssuedCertificate{
+			Type:        node.Type,
+			Name:        node.Name,
+			Action:      node.Action,
+			Certificate: chain[0],
+			Chain:       chain,
+			Paths:       getArtifactPaths(node),
+		}, nil
+	}
+
+	var password string
+
+	switch request := request.(type) {
+	case *backend.RootCertificateAuthorityRequest:
+		password = request.Password
+	case *backend.IntermediateCertificateAuthorityRequest:
+		password = request.Password
+	case *backend.LeafCertificateRequest:
+		password = request.Password
+	}
+
+	certificate, err := backend.LoadCertificate(node.OutputDirectory, node.Type, node.Name, password)
+	if err != nil {
+		return nil, err
+	}
+
+	return &IssuedCertificate{
+		Type:        node.Type,
+		Name:        node.Name,
+		Action:      node.Action,
+		Certificate: certificate.Certificate,
+		PrivateKey:  certificate.PrivateKey,
+		Chain:       certificate.Chain,
+		Paths:       getArtifactPaths(node),
+	}, nil
+}
+
+func getArtifactPaths(node *PlanNode) *ArtifactPaths {
+	path := func(extension string) string {
+		return helper.GetArtifactPath(node.OutputDirectory, node.Type, node.Name, extension)
+	}
+
+	// The optional artifacts depend on the type, the configuration and on earlier runs
+	optionalPath := func(extension string) string {
+		if _, err := os.Stat(path(extension)); err != nil {
+			return ""
+		}
+
+		return path(extension)
+	}
+
+	return &ArtifactPaths{
+		PrivateKey:         optionalPath(helper.PrivateKeyExtension),
+		Certificate:        path(helper.CertificateExtension),
+		Chain:              path(helper.ChainCertificateExtension),
+		Pfx:                optionalPath(helper.PfxExtension),
+		CertificateRequest: optionalPath(helper.CertificateRequestExtension),
+		DHParameters:       optionalPath(helper.DHParametersExtension),
+		Configuration:      optionalPath(helper.ConfigurationExtension),
+	}
+}
@@ -0,0 +1,101 @@
+package certificates
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"git.mfdlabs.local/petko/mfdlabs-ssl-go/pkg/backend"
+	"git.mfdlabs.local/petko/mfdlabs-ssl-go/pkg/configuration"
+	"git.mfdlabs.local/petko/mfdlabs-ssl-go/pkg/helper"
+)
+
+// Resolves and validates the keystores of the certificate, the problems with them are added to the errors of the
+// resolver.
+func getKeystoreOptions(resolver *fieldResolver, keystores []*configuration.KeystoreConfiguration) []*backend.KeystoreOptions {
+	var options []*backend.KeystoreOptions
+
+	paths := make(map[string]int)
+
+	for i, keystore := range keystores {
+		if keystore == nil {
+			continue
+		}
+
+		field := fmt.Sprintf("keystores[%d]", i)
+
+		typeName := resolver.resolve(field+".type", keystore.Type)
+		formatName := resolver.resolve(field+".format", keystore.Format)
+		path := resolver.resolve(field+".path", keystore.Path)
+		alias := resolver.resolve(field+".alias", keystore.Alias)
+		password := resolver.resolve(field+".password", keystore.Password)
+
+		keystoreType, err := helper.CheckKeystoreType(typeName)
+		if err != nil {
+			resolver.errs = append(resolver.errs, helper.NewValidationError(resolver.certType, resolver.certName, field+".type", err.Error()))
+		}
+
+		format, err := helper.CheckKeystoreFormat(formatName)
+		if err != nil {
+			resolver.errs = append(resolver.errs, helper.NewValidationError(resolver.certType, resolver.certName, field+".format", err.Error()))
+		}
+
+		// keytool refuses passwords shorter than 6 characters
+		if password == "" {
+			resolver.errs = append(resolver.errs, helper.NewValidationError(resolver.certType, resolver.certName, field+".password", "cannot be empty"))
+		} else if len(password) < 6 {
+			resolver.errs = append(resolver.errs, helper.NewValidationError(resolver.certType, resolver.certName, field+".password", "cannot be less than 6 characters"))
+		}
+
+		// The truststore alias defaults to the root, which is only known once the chain is loaded
+		if alias == "" && keystoreType == helper.KeystoreType {
+			alias = resolver.certName
+		}
+
+		option := &backend.KeystoreOptions{
+			Type:     keystoreType,
+			Format:   format,
+			Path:     path,
+			Alias:    alias,
+			Password: password,
+		}
+
+		options = append(options, option)
+
+		// Stores at the same path would overwrite each other, the default path is only known for valid stores
+		if keystoreType == "" || format == "" {
+			continue
+		}
+
+		key := backend.GetKeystorePath("", resolver.certType, resolver.certName, option)
+
+		if previous, ok := paths[key]; ok {
+			resolver.errs = append(resolver.errs, helper.NewValidationError(resolver.certType, resolver.certName, field+".path", fmt.Sprintf("is the same as the path of keystores[%d]", previous)))
+		}
+
+		paths[key] = i
+	}
+
+	return options
+}
+
+// Writes the keystores of a kept certificate that aren't at their path yet, the existing ones are left as they are.
+func writeMissingKeystores(outputDirectory string, certType string, certName string, password string, keystores []*backend.KeystoreOptions, output io.Writer) error {
+	var missing []*backend.KeystoreOptions
+
+	for _, keystore := range keystores {
+		path := backend.GetKeystorePath(outputDirectory, certType, certName, keystore)
+
+		if _, err := os.Stat(path); os.IsNotExist(err) {
+			fmt.Fprintf(output, "Writing the missing %s of the %s certificate %s: %s\n", keystore.Type, certType, certName, path)
+			missing = append(missing, keystore)
+		}
+	}
+
+	err := backend.WriteKeystores(outputDirectory, certType, certName, password, missing)
+	if err != nil {
+		return fmt.Errorf("failed to write the keystores of the %s certificate %s: %w", certType, certName, err)
+	}
+
+	return nil
+}
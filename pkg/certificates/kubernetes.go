@@ -0,0 +1,373 @@
+package certificates
+
+import (
+	"bytes"
+	"crypto/x509"
+	"encoding/base64"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"text/template"
+
+	"gopkg.in/yaml.v2"
+
+	"git.mfdlabs.local/petko/mfdlabs-ssl-go/pkg/backend"
+	"git.mfdlabs.local/petko/mfdlabs-ssl-go/pkg/configuration"
+	"git.mfdlabs.local/petko/mfdlabs-ssl-go/pkg/helper"
+)
+
+// The kinds of Kubernetes manifests that are exported.
+const (
+	SecretKubernetesKind    = "Secret"
+	ConfigMapKubernetesKind = "ConfigMap"
+)
+
+const (
+	defaultKubernetesNamespace   = "default"
+	defaultSecretNameTemplate    = "{{ dns .Name }}-tls"
+	defaultConfigMapNameTemplate = "{{ dns .Name }}-ca"
+
+	managedByLabel = "app.kubernetes.io/managed-by"
+)
+
+var (
+	// A DNS-1123 subdomain, what most Kubernetes objects are named with.
+	kubernetesNamePattern = regexp.MustCompile(`^[a-z0-9]([-a-z0-9]*[a-z0-9])?(\.[a-z0-9]([-a-z0-9]*[a-z0-9])?)*$`)
+
+	// A DNS-1123 label, what namespaces are named with.
+	kubernetesNamespacePattern = regexp.MustCompile(`^[a-z0-9]([-a-z0-9]*[a-z0-9])?$`)
+
+	kubernetesLabelValuePattern = regexp.MustCompile(`^(([A-Za-z0-9][-A-Za-z0-9_.]*)?[A-Za-z0-9])?$`)
+
+	invalidKubernetesNameCharacters = regexp.MustCompile(`[^a-z0-9.-]+`)
+)
+
+// A Kubernetes manifest of a certificate of the configuration.
+type KubernetesManifest struct {
+	Kind      string
+	Name      string
+	Namespace string
+
+	// The YAML document of the manifest.
+	Content []byte
+}
+
+type KubernetesExportOptions struct {
+	// Overrides the namespace of the configuration if it's not empty.
+	Namespace string
+
+	// Exports the trust bundles of the certificate authorities even if the configuration doesn't.
+	TrustBundles bool
+}
+
+// What the name, namespace and label templates are executed with.
+type kubernetesTemplateData struct {
+	Name       string
+	Type       string
+	CommonName string
+	Namespace  string
+}
+
+type kubernetesMetadata struct {
+	Name      string            `yaml:"name"`
+	Namespace string            `yaml:"namespace"`
+	Labels    map[string]string `yaml:"labels,omitempty"`
+}
+
+type kubernetesSecret struct {
+	APIVersion string             `yaml:"apiVersion"`
+	Kind       string             `yaml:"kind"`
+	Metadata   kubernetesMetadata `yaml:"metadata"`
+	Type       string             `yaml:"type"`
+	Data       map[string]string  `yaml:"data"`
+}
+
+type kubernetesConfigMap struct {
+	APIVersion string             `yaml:"apiVersion"`
+	Kind       string             `yaml:"kind"`
+	Metadata   kubernetesMetadata `yaml:"metadata"`
+	Data       map[string]string  `yaml:"data"`
+}
+
+// The templates of the kubernetes configuration, parsed once.
+type kubernetesTemplates struct {
+	namespace     *template.Template
+	secretName    *template.Template
+	configMapName *template.Template
+	labelNames    []string
+	labels        map[string]*template.Template
+}
+
+// Exports a kubernetes.io/tls Secret for every leaf certificate of the configuration, with its chain in tls.crt, its
+// decrypted key in tls.key and its root certificate authority in ca.crt, and a ConfigMap trust bundle with the chain
+// of every certificate authority up to its root if trust bundles are enabled. Signed certificates are skipped, their key is never
+// seen. Every certificate has to be generated already.
+func ExportKubernetesManifests(configFilePath string, conf *configuration.SslConfiguration, options *KubernetesExportOptions) ([]*KubernetesManifest, error) {
+	kubernetes := conf.Kubernetes
+	if kubernetes == nil {
+		kubernetes = &configuration.KubernetesConfiguration{}
+	}
+
+	templates, err := parseKubernetesTemplates(kubernetes, options)
+	if err != nil {
+		return nil, err
+	}
+
+	plan, requests, err := prepare(configFilePath, conf, nil, false)
+	if err != nil {
+		return nil, err
+	}
+
+	var errs helper.Errors
+	var manifests []*KubernetesManifest
+
+	trustBundles := kubernetes.TrustBundles || options.TrustBundles
+	exported := make(map[string]string)
+
+	for _, node := range plan.Nodes {
+		if node.Type == helper.SignedCertificateType || (node.Type != helper.LeafCertificateType && !trustBundles) {
+			continue
+		}
+
+		if !artifactExists(node.OutputDirectory, node.Type, node.Name, helper.CertificateExtension) {
+			errs = append(errs, fmt.Errorf("the %s certificate %s has not been generated yet", node.Type, node.Name))
+			continue
+		}
+
+		manifest, err := getKubernetesManifest(node, requests[node], templates)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to export the %s certificate %s: %s", node.Type, node.Name, err))
+			continue
+		}
+
+		// Manifests with the same name would replace each other when they are applied
+		key := manifest.Kind + "/" + manifest.Namespace + "/" + manifest.Name
+
+		if previous, ok := exported[key]; ok {
+			errs = append(errs, fmt.Errorf("the %s certificate %s is exported as the %s %s/%s, like %s", node.Type, node.Name, manifest.Kind, manifest.Namespace, manifest.Name, previous))
+			continue
+		}
+
+		exported[key] = fmt.Sprintf("the %s certificate %s", node.Type, node.Name)
+		manifests = append(manifests, manifest)
+	}
+
+	if len(errs) != 0 {
+		return nil, errs
+	}
+
+	return manifests, nil
+}
+
+func getKubernetesManifest(node *PlanNode, request interface{}, templates *kubernetesTemplates) (*KubernetesManifest, error) {
+	chain, err := loadChainToRoot(node)
+	if err != nil {
+		return nil, err
+	}
+
+	data := &kubernetesTemplateData{
+		Name:       node.Name,
+		Type:       node.Type,
+		CommonName: chain[0].Subject.CommonName,
+	}
+
+	data.Namespace, err = executeKubernetesTemplate(templates.namespace, data)
+	if err != nil {
+		return nil, err
+	}
+
+	if !kubernetesNamespacePattern.MatchString(data.Namespace) || len(data.Namespace) > 63 {
+		return nil, fmt.Errorf("the namespace %q is not a valid Kubernetes namespace", data.Namespace)
+	}
+
+	nameTemplate := templates.configMapName
+	if node.Type == helper.LeafCertificateType {
+		nameTemplate = templates.secretName
+	}
+
+	name, err := executeKubernetesTemplate(nameTemplate, data)
+	if err != nil {
+		return nil, err
+	}
+
+	if !kubernetesNamePattern.MatchString(name) || len(name) > 253 {
+		return nil, fmt.Errorf("the name %q is not a valid Kubernetes name, the dns template function makes text valid", name)
+	}
+
+	metadata := kubernetesMetadata{Name: name, Namespace: data.Namespace, Labels: map[string]string{managedByLabel: "ssl-go"}}
+
+	for _, labelName := range templates.labelNames {
+		value, err := executeKubernetesTemplate(templates.labels[labelName], data)
+		if err != nil {
+			return nil, err
+		}
+
+		if !kubernetesLabelValuePattern.MatchString(value) || len(value) > 63 {
+			return nil, fmt.Errorf("the value %q of the label %s is not a valid Kubernetes label value", value, labelName)
+		}
+
+		metadata.Labels[labelName] = value
+	}
+
+	manifest := &KubernetesManifest{Name: name, Namespace: data.Namespace}
+
+	var document interface{}
+
+	if node.Type == helper.LeafCertificateType {
+		certificate, err := backend.LoadCertificate(node.OutputDirectory, node.Type, node.Name, request.(*backend.LeafCertificateRequest).Password)
+		if err != nil {
+			return nil, err
+		}
+
+		key, err := backend.EncodePrivateKey(certificate.PrivateKey)
+		if err != nil {
+			return nil, err
+		}
+
+		// Servers send the chain without the root, clients trust the root from ca.crt
+		served := backend.GetServedChain(certificate.Chain)
+		root := chain[len(chain)-1]
+
+		manifest.Kind = SecretKubernetesKind
+		document = &kubernetesSecret{
+			APIVersion: "v1",
+			Kind:       SecretKubernetesKind,
+			Metadata:   metadata,
+			Type:       "kubernetes.io/tls",
+			Data: map[string]string{
+				"tls.crt": base64.StdEncoding.EncodeToString(backend.EncodeCertificates(served...)),
+				"tls.key": base64.StdEncoding.EncodeToString(key),
+				"ca.crt":  base64.StdEncoding.EncodeToString(backend.EncodeCertificates(root)),
+			},
+		}
+	} else {
+		manifest.Kind = ConfigMapKubernetesKind
+		document = &kubernetesConfigMap{
+			APIVersion: "v1",
+			Kind:       ConfigMapKubernetesKind,
+			Metadata:   metadata,
+			Data: map[string]string{
+				"ca.crt": string(backend.EncodeCertificates(chain...)),
+			},
+		}
+	}
+
+	manifest.Content, err = yaml.Marshal(document)
+	if err != nil {
+		return nil, err
+	}
+
+	return manifest, nil
+}
+
+// Loads the chain of the certificate up to its root certificate authority, which clients need to build a path to an
+// anchor. The chain file stops short of the root when an issuer was generated by an older run or another tool, the
+// chains of the issuers fill in the rest.
+func loadChainToRoot(node *PlanNode) ([]*x509.Certificate, error) {
+	chain, err := backend.LoadCertificateChain(node.OutputDirectory, node.Type, node.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	for issuer := node; !isSelfSigned(chain[len(chain)-1]); issuer = issuer.Parent() {
+		if issuer == nil || issuer.ParentType == "" {
+			return nil, fmt.Errorf("the chain of the %s certificate %s doesn't reach a root certificate authority", node.Type, node.Name)
+		}
+
+		issuerChain, err := backend.LoadCertificateChain(issuer.ParentOutputDirectory, issuer.ParentType, issuer.ParentName)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, certificate := range issuerChain {
+			if !containsCertificate(chain, certificate) {
+				chain = append(chain, certificate)
+			}
+		}
+	}
+
+	return chain, nil
+}
+
+func isSelfSigned(certificate *x509.Certificate) bool {
+	return bytes.Equal(certificate.RawSubject, certificate.RawIssuer)
+}
+
+func containsCertificate(chain []*x509.Certificate, certificate *x509.Certificate) bool {
+	for _, existing := range chain {
+		if existing.Equal(certificate) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func parseKubernetesTemplates(kubernetes *configuration.KubernetesConfiguration, options *KubernetesExportOptions) (*kubernetesTemplates, error) {
+	var errs helper.Errors
+
+	parse := func(field string, text string, fallback string) *template.Template {
+		resolved, err := helper.ResolveExpressions(text)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("the kubernetes %s is not valid: %s", field, err))
+			return nil
+		}
+
+		if resolved == "" {
+			resolved = fallback
+		}
+
+		parsed, err := template.New(field).Option("missingkey=error").Funcs(template.FuncMap{
+			"dns":   toKubernetesName,
+			"lower": strings.ToLower,
+		}).Parse(resolved)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("the kubernetes %s is not a valid template: %s", field, err))
+			return nil
+		}
+
+		return parsed
+	}
+
+	namespace := kubernetes.Namespace
+	if options.Namespace != "" {
+		namespace = options.Namespace
+	}
+
+	templates := &kubernetesTemplates{
+		namespace:     parse("namespace", namespace, defaultKubernetesNamespace),
+		secretName:    parse("secretName", kubernetes.SecretName, defaultSecretNameTemplate),
+		configMapName: parse("configMapName", kubernetes.ConfigMapName, defaultConfigMapNameTemplate),
+		labels:        make(map[string]*template.Template),
+	}
+
+	for labelName, value := range kubernetes.Labels {
+		templates.labelNames = append(templates.labelNames, labelName)
+		templates.labels[labelName] = parse("labels."+labelName, value, "")
+	}
+
+	sort.Strings(templates.labelNames)
+
+	if len(errs) != 0 {
+		return nil, errs
+	}
+
+	return templates, nil
+}
+
+func executeKubernetesTemplate(parsed *template.Template, data *kubernetesTemplateData) (string, error) {
+	var out strings.Builder
+
+	err := parsed.Execute(&out, data)
+	if err != nil {
+		return "", err
+	}
+
+	return strings.TrimSpace(out.String()), nil
+}
+
+// Turns text into a valid Kubernetes name: lower case, with every run of other characters replaced by a dash.
+func toKubernetesName(text string) string {
+	return strings.Trim(invalidKubernetesNameCharacters.ReplaceAllString(strings.ToLower(text), "-"), "-.")
+}
@@ -0,0 +1,150 @@
+package certificates
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"io/ioutil"
+	"math/big"
+	"testing"
+	"time"
+
+	"git.mfdlabs.local/petko/mfdlabs-ssl-go/pkg/backend"
+	"git.mfdlabs.local/petko/mfdlabs-ssl-go/pkg/helper"
+)
+
+func TestLoadChainToRoot(t *testing.T) {
+	chain := createTestChain(t, "Root", "First", "Second")
+	root, first, second := chain[0], chain[1], chain[2]
+
+	tests := []struct {
+		name   string
+		chains map[string][]*x509.Certificate
+	}{
+		{
+			name:   "complete chain files",
+			chains: map[string][]*x509.Certificate{"first": {first, root}, "second": {second, first, root}},
+		},
+		{
+			// The chain files of older runs end with the issuer
+			name:   "chain files without the root",
+			chains: map[string][]*x509.Certificate{"first": {first}, "second": {second, first}},
+		},
+		{
+			name:   "chain files with only the certificate",
+			chains: map[string][]*x509.Certificate{"first": {first}, "second": {second}},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			directory := t.TempDir()
+
+			writeTestArtifact(t, directory, helper.RootCertificateType, "root", helper.CertificateExtension, root)
+
+			for name, chain := range test.chains {
+				writeTestArtifact(t, directory, helper.IntermediateCertificateType, name, helper.ChainCertificateExtension, chain...)
+			}
+
+			rootNode := &PlanNode{Type: helper.RootCertificateType, Name: "root", OutputDirectory: directory}
+			firstNode := &PlanNode{Type: helper.IntermediateCertificateType, Name: "first", ParentType: helper.RootCertificateType, ParentName: "root", OutputDirectory: directory, ParentOutputDirectory: directory, parent: rootNode}
+			secondNode := &PlanNode{Type: helper.IntermediateCertificateType, Name: "second", ParentType: helper.IntermediateCertificateType, ParentName: "first", OutputDirectory: directory, ParentOutputDirectory: directory, parent: firstNode}
+
+			expected := map[*PlanNode][]*x509.Certificate{
+				rootNode:   {root},
+				firstNode:  {first, root},
+				secondNode: {second, first, root},
+			}
+
+			for node, expectedChain := range expected {
+				chain, err := loadChainToRoot(node)
+				if err != nil {
+					t.Fatalf("failed to load the chain of %s: %s", node.Name, err)
+				}
+
+				if len(chain) != len(expectedChain) {
+					t.Fatalf("expected the chain of %s to have %d certificates, got %d", node.Name, len(expectedChain), len(chain))
+				}
+
+				for i, certificate := range expectedChain {
+					if !certificate.Equal(chain[i]) {
+						t.Fatalf("certificate %d of the chain of %s is %s, expected %s", i, node.Name, chain[i].Subject.CommonName, certificate.Subject.CommonName)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestLoadChainToRootWithoutRoot(t *testing.T) {
+	chain := createTestChain(t, "Root", "External", "First")
+	external, first := chain[1], chain[2]
+
+	directory := t.TempDir()
+
+	// The issuer was generated by an earlier run and its chain file ends with it, nothing leads to the root
+	writeTestArtifact(t, directory, helper.IntermediateCertificateType, "external", helper.ChainCertificateExtension, external)
+	writeTestArtifact(t, directory, helper.IntermediateCertificateType, "first", helper.ChainCertificateExtension, first)
+
+	node := &PlanNode{Type: helper.IntermediateCertificateType, Name: "first", ParentType: helper.IntermediateCertificateType, ParentName: "external", OutputDirectory: directory, ParentOutputDirectory: directory, IsParentExternal: true}
+
+	if _, err := loadChainToRoot(node); err == nil {
+		t.Fatal("expected a chain that never reaches a root certificate authority to fail")
+	}
+}
+
+func writeTestArtifact(t *testing.T, directory string, certType string, name string, extension string, certificates ...*x509.Certificate) {
+	t.Helper()
+
+	err := ioutil.WriteFile(helper.GetArtifactPath(directory, certType, name, extension), backend.EncodeCertificates(certificates...), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// Creates a chain of certificate authorities from a self-signed root, each signed by the one before it.
+func createTestChain(t *testing.T, commonNames ...string) []*x509.Certificate {
+	t.Helper()
+
+	var chain []*x509.Certificate
+	var issuerKey *ecdsa.PrivateKey
+
+	for i, commonName := range commonNames {
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		template := &x509.Certificate{
+			SerialNumber:          big.NewInt(int64(i + 1)),
+			Subject:               pkix.Name{CommonName: commonName},
+			NotBefore:             time.Now().Add(-time.Hour),
+			NotAfter:              time.Now().Add(time.Hour),
+			IsCA:                  true,
+			BasicConstraintsValid: true,
+			KeyUsage:              x509.KeyUsageCertSign,
+		}
+
+		issuer, signer := template, key
+		if i > 0 {
+			issuer, signer = chain[i-1], issuerKey
+		}
+
+		der, err := x509.CreateCertificate(rand.Reader, template, issuer, &key.PublicKey, signer)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		certificate, err := x509.ParseCertificate(der)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		chain = append(chain, certificate)
+		issuerKey = key
+	}
+
+	return chain
+}
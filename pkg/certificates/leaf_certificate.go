@@ -2,113 +2,81 @@ package certificates
 
 import (
 	"fmt"
+	"io"
 
+	"git.mfdlabs.local/petko/mfdlabs-ssl-go/pkg/backend"
 	"git.mfdlabs.local/petko/mfdlabs-ssl-go/pkg/configuration"
 	"git.mfdlabs.local/petko/mfdlabs-ssl-go/pkg/helper"
 )
 
-func LoadLeafCertificates(configFilePath string, conf *configuration.SslConfiguration, loadedIntCerts map[string]*configuration.IntermediateCertificateAuthority) {
-	// check if the leaf certificates are empty
-	if len(conf.LeafCertificateAuthorities) != 0 {
-		// load the leaf certificates
-		for _, leafCert := range conf.LeafCertificateAuthorities {
-			loadLeafCertificate(configFilePath, leafCert, loadedIntCerts, conf.IntermediateCertificateAuthorities)
-		}
-	}
-}
+// Resolves and validates the leaf certificate, every problem with it is reported.
+func getLeafCertificateRequest(leafCert *configuration.LeafCertificate) (*backend.LeafCertificateRequest, error) {
+	resolver := &fieldResolver{certType: helper.LeafCertificateType}
 
-func loadLeafCertificate(configFilePath string, leafCert *configuration.LeafCertificate, loadedIntCerts map[string]*configuration.IntermediateCertificateAuthority, intCerts []*configuration.IntermediateCertificateAuthority) {
-	err := DetermineIfLeafCertificateIsReference(configFilePath, leafCert)
+	leafCertName := resolver.resolve("name", leafCert.LeafCertificateName)
+	resolver.certName = leafCertName
 
-	caChainName := helper.ReplaceEnvironmentExpression(leafCert.LastChainCertificateName)
-	caChainPassword := helper.ReplaceEnvironmentExpression(leafCert.LastChainCertificatePassword)
-	leafCertName := helper.ReplaceEnvironmentExpression(leafCert.LeafCertificateName)
-	leafCertPassword := helper.ReplaceEnvironmentExpression(leafCert.LeafCertificatePassword)
-	leafCertPfxPassword := helper.ReplaceEnvironmentExpression(leafCert.LeafCertificatePfxPassword)
+	caChainName := resolver.resolve("caName", leafCert.LastChainCertificateName)
+	caChainPassword := resolver.resolve("caPassword", leafCert.LastChainCertificatePassword)
+	leafCertPassword := resolver.resolve("password", leafCert.LeafCertificatePassword)
+	leafCertPfxPassword := resolver.resolve("pfxPassword", leafCert.LeafCertificatePfxPassword)
 
-	// check if the root certificate is already loaded
-	if _, ok := loadedIntCerts[caChainName]; ok {
-		return
-	}
+	// The output directory itself is resolved by the plan
+	resolver.resolve("outputDirectory", leafCert.OutputDirectory)
 
-	fmt.Printf("Loading leaf certificate: %s\n", leafCertName)
+	keyAlgorithmName := resolver.resolve("keyAlgorithm", leafCert.KeyAlgorithm)
+	renewalKeyPolicyName := resolver.resolve("renewalKeyPolicy", leafCert.RenewalKeyPolicy)
+	conf := resolver.resolveConfiguration(leafCert.Configuration).(*configuration.LeafCertificateConfiguration)
+	pfx := getPfxOptions(resolver, leafCert.Pfx)
+	keystores := getKeystoreOptions(resolver, leafCert.Keystores)
+	bundles := getBundleOptions(resolver, leafCert.Bundles)
 
-	if err != nil {
-		panic(err)
-	}
+	errs := resolver.errs
 
 	// Check if the required fields are empty
 	if caChainName == "" {
-		panic("The chain certificate name cannot be empty")
+		errs = append(errs, helper.NewValidationError(helper.LeafCertificateType, leafCertName, "caName", "cannot be empty"))
+	} else if err := helper.CheckCertificateName(caChainName); err != nil {
+		errs = append(errs, helper.NewValidationError(helper.LeafCertificateType, leafCertName, "caName", err.Error()))
 	}
 
-	// Chain cannot be the same as the int cert if it's not a Root CA
-	if leafCertName == caChainName {
-		if !leafCert.IsLastChainCertificateRootCertificateAuthority {
-			panic("The chain certificate name cannot be the same as the leaf certificate name if it's not a root certificate")
-		}
+	// Chain cannot be the same as the leaf cert if it's not a Root CA
+	if leafCertName == caChainName && !leafCert.IsLastChainCertificateRootCertificateAuthority {
+		errs = append(errs, helper.NewValidationError(helper.LeafCertificateType, leafCertName, "caName", "cannot be the same as the leaf certificate name if it's not a root certificate authority"))
 	}
 
 	if caChainPassword == "" {
-		panic("The chain certificate password cannot be empty")
-	}
-
-	// Check if password is less than 8 characters
-	if len(caChainPassword) < 4 {
-		panic("The chain certificate password cannot be less than 4 characters")
+		errs = append(errs, helper.NewValidationError(helper.LeafCertificateType, leafCertName, "caPassword", "cannot be empty"))
+	} else if len(caChainPassword) < 4 {
+		// Check if password is less than 4 characters
+		errs = append(errs, helper.NewValidationError(helper.LeafCertificateType, leafCertName, "caPassword", "cannot be less than 4 characters"))
 	}
 
 	if leafCertName == "" {
-		panic("The leaf certificate name cannot be empty")
+		errs = append(errs, helper.NewValidationError(helper.LeafCertificateType, leafCertName, "name", "cannot be empty"))
+	} else if err := helper.CheckCertificateName(leafCertName); err != nil {
+		errs = append(errs, helper.NewValidationError(helper.LeafCertificateType, leafCertName, "name", err.Error()))
 	}
 
-	// Check if password is less than 8 characters
+	// Check if password is less than 4 characters
 	if len(leafCertPassword) < 4 {
-		panic("The leaf certificate password cannot be less than 4 characters")
+		errs = append(errs, helper.NewValidationError(helper.LeafCertificateType, leafCertName, "password", "cannot be less than 4 characters"))
 	}
 
-	// Check if password is less than 8 characters
+	// Check if password is less than 4 characters
 	if len(leafCertPfxPassword) < 4 {
-		panic("The leaf certificate PFX password cannot be less than 4 characters")
+		errs = append(errs, helper.NewValidationError(helper.LeafCertificateType, leafCertName, "pfxPassword", "cannot be less than 4 characters"))
 	}
 
-	err = helper.CheckCertificateName(caChainName)
-	if err != nil {
-		panic(err)
-	}
-	err = helper.CheckCertificateName(leafCertName)
+	// Validate the key size for the key algorithm, defaults to a 2048 bit RSA key
+	keyAlgorithm, keyLength, err := helper.CheckKeyAlgorithm(keyAlgorithmName, leafCert.PrivateKeySize)
 	if err != nil {
-		panic(err)
-	}
-
-	// Section for generating the ca chain certificate if it's in the config and further down in the code
-	if len(intCerts) > 0 && !leafCert.IsLastChainCertificateRootCertificateAuthority {
-		// Check if we have the last chain certificate generated
-		if _, ok := loadedIntCerts[caChainName]; !ok {
-			// iterate through the int certs until we find the last chain certificate
-			for _, intCert := range intCerts {
-				if intCert.IntermediateCertificateAuthorityName == caChainName {
-					loadIntermediateCertificateAuthority(configFilePath, intCert, loadedIntCerts, intCerts)
-				}
-			}
-		}
-	}
-
-	// Get string of if the cert should be inserted into the trust store
-	skipDhParam := helper.Ternary(leafCert.GenerateDHParameters, "NO", "YES").(string)
-	keepCertificateRequestFile := helper.Ternary(leafCert.KeepCertificateRequestFile, "YES", "NO").(string)
-	isLastChainRootCa := helper.Ternary(leafCert.IsLastChainCertificateRootCertificateAuthority, "YES", "NO").(string)
-
-	keyLength := leafCert.PrivateKeySize
-
-	// If the key length is not set, set it to 2048
-	if keyLength == 0 {
-		keyLength = 2048
+		errs = append(errs, helper.NewValidationError(helper.LeafCertificateType, leafCertName, getKeyAlgorithmField(keyAlgorithmName), err.Error()))
 	}
 
-	// If the key length is not 1024, 2048 or 4096, error out
-	if keyLength != 1024 && keyLength != 2048 && keyLength != 4096 {
-		panic("The key length must be 1024, 2048 or 4096")
+	renewalKeyPolicy, err := helper.CheckRenewalKeyPolicy(renewalKeyPolicyName)
+	if err != nil {
+		errs = append(errs, helper.NewValidationError(helper.LeafCertificateType, leafCertName, "renewalKeyPolicy", err.Error()))
 	}
 
 	expirationInDays := leafCert.ValidityPeriod
@@ -120,29 +88,64 @@ func loadLeafCertificate(configFilePath string, leafCert *configuration.LeafCert
 
 	// If the expiration in days is less than 0, error out
 	if expirationInDays < 0 {
-		panic("The expiration in days must be greater than or equal to 0")
-	}
+		errs = append(errs, helper.NewValidationError(helper.LeafCertificateType, leafCertName, "validityPeriod", "must be greater than or equal to 0"))
+	}
+
+	// Validate the subject and extensions of the certificate
+	errs = append(errs, nameValidationErrors(configuration.ValidateLeafCertificateConfiguration(conf), helper.LeafCertificateType, leafCertName)...)
+
+	if len(errs) != 0 {
+		return nil, errs
+	}
+
+	return &backend.LeafCertificateRequest{
+		Name:                            leafCertName,
+		Password:                        leafCertPassword,
+		PfxPassword:                     leafCertPfxPassword,
+		Pfx:                             pfx,
+		Keystores:                       keystores,
+		Bundles:                         bundles,
+		ChainName:                       caChainName,
+		ChainPassword:                   caChainPassword,
+		IsChainRootCertificateAuthority: leafCert.IsLastChainCertificateRootCertificateAuthority,
+		KeyAlgorithm:                    keyAlgorithm,
+		KeySize:                         keyLength,
+		ValidityPeriod:                  expirationInDays,
+		RenewalKeyPolicy:                renewalKeyPolicy,
+		GenerateDHParameters:            leafCert.GenerateDHParameters,
+		KeepCertificateRequestFile:      leafCert.KeepCertificateRequestFile,
+		Configuration:                   conf,
+	}, nil
+}
 
-	configuration.GenerateLeafCertificateConfigurationFileIfNotExists(leafCert)
+func loadLeafCertificate(leafCert *configuration.LeafCertificate, request *backend.LeafCertificateRequest, generator backend.Backend, output io.Writer) error {
+	fmt.Fprintf(output, "Loading leaf certificate: %s\n", request.Name)
 
-	caChainPasswordFilename, err := helper.WritePasswordFile("chain", caChainName, "normal", caChainPassword)
+	// The configuration file is generated from the resolved configuration
+	resolved := *leafCert
+	resolved.Configuration = request.Configuration
+	resolved.OverwriteExistingConfiguration = overwritesConfiguration(leafCert.OverwriteExistingConfiguration, generator)
+	resolved.KeyAlgorithm = request.KeyAlgorithm
+
+	err := configuration.GenerateLeafCertificateConfigurationFileIfNotExists(request.OutputDirectory, request.Name, &resolved)
 	if err != nil {
-		panic(err)
+		return err
 	}
-	leafCertPasswordFilename, err := helper.WritePasswordFile("leaf", leafCertName, "normal", leafCertPassword)
+
+	err = generator.GenerateLeafCertificate(request)
 	if err != nil {
-		panic(err)
+		return fmt.Errorf("failed to generate the leaf certificate %s: %w", request.Name, err)
 	}
-	leafCertPfxPasswordFilename, err := helper.WritePasswordFile("leaf", leafCertName, "pfx", leafCertPfxPassword)
+
+	err = backend.WriteKeystores(request.OutputDirectory, helper.LeafCertificateType, request.Name, request.Password, request.Keystores)
 	if err != nil {
-		panic(err)
+		return fmt.Errorf("failed to write the keystores of the leaf certificate %s: %w", request.Name, err)
 	}
 
-	// Get command text
-	command := fmt.Sprintf("./ssl/generate-certs-v2.sh %s @%s @%s %s @%s %s %s %s %d %d", leafCertName, leafCertPasswordFilename, leafCertPfxPasswordFilename, caChainName, caChainPasswordFilename, isLastChainRootCa, skipDhParam, keepCertificateRequestFile, expirationInDays, keyLength)
-
-	// Execute the command
-	helper.ExecuteRawCommand(command)
+	err = backend.WriteBundles(request.OutputDirectory, helper.LeafCertificateType, request.Name, request.Password, request.Bundles)
+	if err != nil {
+		return fmt.Errorf("failed to write the bundles of the leaf certificate %s: %w", request.Name, err)
+	}
 
-	defer helper.DeleteTmpPasswords([]string{caChainPasswordFilename, leafCertPasswordFilename, leafCertPfxPasswordFilename})
+	return nil
 }
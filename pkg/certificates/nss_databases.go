@@ -0,0 +1,169 @@
+package certificates
+
+import (
+	"fmt"
+	"io"
+
+	"git.mfdlabs.local/petko/mfdlabs-ssl-go/pkg/backend"
+	"git.mfdlabs.local/petko/mfdlabs-ssl-go/pkg/configuration"
+	"git.mfdlabs.local/petko/mfdlabs-ssl-go/pkg/helper"
+	"git.mfdlabs.local/petko/mfdlabs-ssl-go/pkg/nss"
+)
+
+// Resolves the NSS databases of the configuration, every field can be a ${{ }} expression.
+func getNssOptions(conf *configuration.NssConfiguration) (*nss.Options, error) {
+	if conf == nil {
+		conf = &configuration.NssConfiguration{}
+	}
+
+	var errs helper.Errors
+
+	resolve := func(field string, value string) string {
+		resolved, err := helper.ResolveExpressions(value)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("the %s of the NSS databases is not valid: %s", field, err))
+		}
+
+		return resolved
+	}
+
+	home := resolve("home", conf.Home)
+	method := resolve("method", conf.Method)
+	password := resolve("password", conf.Password)
+
+	var databases []string
+	for i, database := range conf.Databases {
+		databases = append(databases, resolve(fmt.Sprintf("databases[%d]", i), database))
+	}
+
+	if len(errs) != 0 {
+		return nil, errs
+	}
+
+	options, err := nss.GetOptions(method, home, databases, password)
+	if err != nil {
+		return nil, fmt.Errorf("the NSS databases are not valid: %s", err)
+	}
+
+	return options, nil
+}
+
+// Sets the NSS databases of the certificate authorities that are imported into them. Like the trust store, they are
+// only resolved if a certificate authority is imported.
+func assignNssDatabases(conf *configuration.NssConfiguration, nodes []*PlanNode) error {
+	var imported []*PlanNode
+
+	for _, node := range nodes {
+		if (node.Type == helper.RootCertificateType && node.RootCertificateAuthority.ShouldImportIntoNssDatabases) ||
+			(node.Type == helper.IntermediateCertificateType && node.IntermediateCertificateAuthority.ShouldImportIntoNssDatabases) {
+			imported = append(imported, node)
+		}
+	}
+
+	if len(imported) == 0 {
+		return nil
+	}
+
+	options, err := getNssOptions(conf)
+	if err != nil {
+		return err
+	}
+
+	for _, node := range imported {
+		node.NssDatabases = options
+	}
+
+	return nil
+}
+
+// Imports a generated certificate authority into every NSS database, nil options import nothing.
+func importIntoNssDatabases(options *nss.Options, outputDirectory string, certType string, certName string, output io.Writer) error {
+	if options == nil {
+		return nil
+	}
+
+	chain, err := backend.LoadCertificateChain(outputDirectory, certType, certName)
+	if err != nil {
+		return err
+	}
+
+	for _, database := range options.Databases {
+		err = nss.Import(options, database, helper.GetArtifactPrefix(certType)+certName, chain[0])
+		if err != nil {
+			return err
+		}
+
+		fmt.Fprintf(output, "Imported the %s certificate %s into the NSS database %s (%s)\n", certType, certName, database, options.Method)
+	}
+
+	return nil
+}
+
+// Imports a kept certificate authority into the NSS databases it isn't in yet, e.g. because it was generated before
+// it was imported or because a Firefox profile was created since.
+func importMissingIntoNssDatabases(options *nss.Options, outputDirectory string, certType string, certName string, output io.Writer) error {
+	if options == nil {
+		return nil
+	}
+
+	missing := *options
+	missing.Databases = nil
+
+	for _, database := range options.Databases {
+		imported, err := nss.Imported(options, database)
+		if err != nil {
+			return err
+		}
+
+		if !contains(imported, helper.GetArtifactPrefix(certType)+certName) {
+			missing.Databases = append(missing.Databases, database)
+		}
+	}
+
+	return importIntoNssDatabases(&missing, outputDirectory, certType, certName, output)
+}
+
+// Removes the certificate authorities of the configuration from the NSS databases of the configuration. Only the
+// certificates ssl-go imported are removed.
+func RemoveFromNssDatabases(configFilePath string, conf *configuration.SslConfiguration, options *UntrustOptions, output io.Writer) error {
+	nssOptions, err := getNssOptions(conf.Nss)
+	if err != nil {
+		return err
+	}
+
+	var names []string
+
+	if !options.All {
+		names, err = getUntrustedNames(configFilePath, conf, options.Names)
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, database := range nssOptions.Databases {
+		imported := names
+
+		if options.All {
+			imported, err = nss.Imported(nssOptions, database)
+			if err != nil {
+				return err
+			}
+		}
+
+		for _, name := range imported {
+			removed, err := nss.Remove(nssOptions, database, name)
+			if err != nil {
+				return err
+			}
+
+			if !removed {
+				fmt.Fprintf(output, "Skipping %s, it is not in the NSS database %s\n", name, database)
+				continue
+			}
+
+			fmt.Fprintf(output, "Removed %s from the NSS database %s\n", name, database)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,129 @@
+package certificates
+
+import (
+	"fmt"
+	"math/big"
+	"time"
+
+	"git.mfdlabs.local/petko/mfdlabs-ssl-go/pkg/backend"
+	"git.mfdlabs.local/petko/mfdlabs-ssl-go/pkg/configuration"
+	"git.mfdlabs.local/petko/mfdlabs-ssl-go/pkg/helper"
+	"git.mfdlabs.local/petko/mfdlabs-ssl-go/pkg/revocation"
+)
+
+type OCSPResponderOptions struct {
+	// Determines if the responses are signed by a delegated responder certificate issued by each certificate
+	// authority, instead of by the certificate authorities themselves.
+	Delegate bool
+
+	// How long the responses can be cached for.
+	ValidityPeriod time.Duration
+}
+
+// Creates an OCSP responder for the certificate authorities of the configuration with the given names, or for every
+// certificate authority that was generated if no name is given. It answers from the revocation lists the revoke
+// command records to.
+func NewOCSPResponder(configFilePath string, conf *configuration.SslConfiguration, names []string, options *OCSPResponderOptions) (*revocation.Responder, error) {
+	plan, requests, err := prepare(configFilePath, conf, nil, false)
+	if err != nil {
+		return nil, err
+	}
+
+	var errs helper.Errors
+	var authorities []*revocation.Authority
+
+	found := make(map[string]bool)
+
+	for _, node := range plan.Nodes {
+		if node.Type != helper.RootCertificateType && node.Type != helper.IntermediateCertificateType {
+			continue
+		}
+
+		if len(names) != 0 && !contains(names, node.Name) {
+			continue
+		}
+
+		found[node.Name] = true
+
+		if len(names) == 0 && !artifactExists(node.OutputDirectory, node.Type, node.Name, helper.CertificateExtension) {
+			fmt.Printf("Skipping %s certificate: %s, it has not been generated yet\n", node.Type, node.Name)
+			continue
+		}
+
+		authority, err := getOCSPAuthority(plan, node, getCertificateAuthority(node, requests[node]), options.Delegate)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to load the %s certificate %s: %w", node.Type, node.Name, err))
+			continue
+		}
+
+		authorities = append(authorities, authority)
+	}
+
+	for _, name := range names {
+		if !found[name] {
+			errs = append(errs, fmt.Errorf("the configuration has no certificate authority named %s", name))
+		}
+	}
+
+	if len(errs) != 0 {
+		return nil, errs
+	}
+
+	if len(authorities) == 0 {
+		return nil, fmt.Errorf("no certificate authority has been generated yet")
+	}
+
+	return revocation.NewResponder(authorities, options.ValidityPeriod), nil
+}
+
+func getOCSPAuthority(plan *Plan, node *PlanNode, ca *certificateAuthority, delegate bool) (*revocation.Authority, error) {
+	certificate, err := backend.LoadCertificate(ca.outputDirectory, ca.certType, ca.name, ca.password)
+	if err != nil {
+		return nil, err
+	}
+
+	signer := certificate
+
+	if delegate {
+		var issued bool
+
+		signer, issued, err = backend.LoadOCSPResponder(ca.outputDirectory, ca.certType, ca.name, ca.password, certificate)
+		if err != nil {
+			return nil, err
+		}
+
+		if issued {
+			fmt.Printf("Issued OCSP responder certificate: %s\n", helper.GetArtifactPath(ca.outputDirectory, ca.certType, ca.name, helper.OCSPResponderCertificateExtension))
+		}
+	}
+
+	fmt.Printf("Answering for %s certificate: %s\n", node.Type, node.Name)
+
+	return &revocation.Authority{
+		Certificate:          certificate.Certificate,
+		ResponderCertificate: signer.Certificate,
+		Signer:               signer.PrivateKey,
+		RevocationListPath:   helper.GetArtifactPath(ca.outputDirectory, ca.certType, ca.name, helper.RevocationListExtension),
+		IsIssued: func(serialNumber *big.Int) bool {
+			return isIssuedBy(plan, node, serialNumber)
+		},
+	}, nil
+}
+
+// Determines if a certificate of the configuration issued by the certificate authority has the serial number. The
+// certificates are read again every time, so certificates generated while the responder runs are known.
+func isIssuedBy(plan *Plan, ca *PlanNode, serialNumber *big.Int) bool {
+	for _, node := range plan.Nodes {
+		if node.Parent() != ca {
+			continue
+		}
+
+		chain, err := backend.LoadCertificateChain(node.OutputDirectory, node.Type, node.Name)
+
+		if err == nil && chain[0].SerialNumber.Cmp(serialNumber) == 0 {
+			return true
+		}
+	}
+
+	return false
+}
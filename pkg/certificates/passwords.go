@@ -0,0 +1,238 @@
+package certificates
+
+import (
+	"crypto/rand"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"git.mfdlabs.local/petko/mfdlabs-ssl-go/pkg/configuration"
+	"git.mfdlabs.local/petko/mfdlabs-ssl-go/pkg/helper"
+	"git.mfdlabs.local/petko/mfdlabs-ssl-go/pkg/secrets"
+)
+
+// The length of the generated passwords, and the shortest length that can be configured.
+const (
+	defaultPasswordLength = 32
+	minimumPasswordLength = 16
+)
+
+// The kinds of secrets sinks.
+const (
+	fileSecretsSink     = "file"
+	keyringSecretsSink  = "keyring"
+	providerSecretsSink = "provider"
+)
+
+// Generated passwords only use letters and digits so they can be passed anywhere without quoting.
+const passwordCharacters = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789"
+
+// Fills the passwords that are generated, and the chain passwords of the certificates issued by a certificate
+// authority of the configuration.
+type passwordSource struct {
+	// Nil if no certificate generates passwords or the sink is not valid.
+	sink secrets.Sink
+
+	// Determines if new passwords are stored in the sink, they are only used for this run otherwise.
+	persist bool
+
+	// The password of every certificate a request was made for.
+	resolved map[*PlanNode]string
+}
+
+// Creates the password source of the plan. The sink is only opened if a certificate generates its passwords.
+func newPasswordSource(conf *configuration.SslConfiguration, plan *Plan, persist bool) (*passwordSource, error) {
+	source := &passwordSource{
+		persist:  persist,
+		resolved: make(map[*PlanNode]string),
+	}
+
+	for _, node := range plan.Nodes {
+		if generation := getPasswordGeneration(node); generation != nil && generation.Generate {
+			sink, err := openSecretsSink(conf.SecretsSink, plan.OutputDirectory)
+			if err != nil {
+				return source, err
+			}
+
+			// The sinks create the directory of their file so only the owner can access it, the output directory
+			// they default to is created first so it keeps its usual mode
+			if persist {
+				err = os.MkdirAll(plan.OutputDirectory, 0755)
+				if err != nil {
+					return source, err
+				}
+			}
+
+			source.sink = sink
+			break
+		}
+	}
+
+	return source, nil
+}
+
+// Fills the empty passwords of an entry of the node. The chain password defaults to the password of the issuer if
+// it's part of the configuration, the password and pfx password are read from the sink or generated if the entry
+// generates its passwords. Any of the passwords may be nil.
+func (source *passwordSource) fill(node *PlanNode, generation *configuration.PasswordGenerationConfiguration, password *string, pfxPassword *string, chainPassword *string) error {
+	if chainPassword != nil && *chainPassword == "" {
+		if parent := node.Parent(); parent != nil {
+			if parentPassword, ok := source.resolved[parent]; ok {
+				*chainPassword = escapeExpressions(parentPassword)
+			}
+		}
+	}
+
+	if generation == nil || !generation.Generate {
+		return nil
+	}
+
+	length := generation.Length
+
+	if length == 0 {
+		length = defaultPasswordLength
+	}
+
+	if length < minimumPasswordLength {
+		return helper.NewValidationError(node.Type, node.Name, "passwords.length", fmt.Sprintf("cannot be less than %d characters", minimumPasswordLength))
+	}
+
+	fields := []struct {
+		name  string
+		value *string
+	}{
+		{"password", password},
+		{"pfxPassword", pfxPassword},
+	}
+
+	for _, field := range fields {
+		if field.value == nil || *field.value != "" {
+			continue
+		}
+
+		key := fmt.Sprintf("%s/%s/%s", node.Type, node.Name, field.name)
+
+		var value string
+		var ok bool
+		var err error
+
+		// The sink could not be opened, which is already reported
+		if source.sink != nil {
+			value, ok, err = source.sink.Get(key)
+			if err != nil {
+				return fmt.Errorf("failed to read the %s of the %s certificate %s from the secrets sink: %s", field.name, node.Type, node.Name, err)
+			}
+		}
+
+		if !ok {
+			// The existing key can only be opened with the password it was generated with
+			if node.Action == SkipAction && source.sink != nil {
+				return helper.NewValidationError(node.Type, node.Name, field.name, "is generated but the certificate already exists and the secrets sink has no password for it")
+			}
+
+			value, err = generatePassword(length)
+			if err != nil {
+				return err
+			}
+
+			if source.persist && source.sink != nil {
+				err = source.sink.Put(key, value)
+				if err != nil {
+					return fmt.Errorf("failed to store the %s of the %s certificate %s in the secrets sink: %s", field.name, node.Type, node.Name, err)
+				}
+			}
+		}
+
+		*field.value = escapeExpressions(value)
+	}
+
+	return nil
+}
+
+// Opens the sink the generated passwords are kept in, a file in the output directory by default.
+func openSecretsSink(conf *configuration.SecretsSinkConfiguration, outputDirectory string) (secrets.Sink, error) {
+	if conf == nil {
+		conf = &configuration.SecretsSinkConfiguration{}
+	}
+
+	sinkType, err := helper.ResolveExpressions(conf.Type)
+	if err != nil {
+		return nil, fmt.Errorf("the secrets sink has an invalid type: %s", err)
+	}
+
+	path, err := helper.ResolveExpressions(conf.Path)
+	if err != nil {
+		return nil, fmt.Errorf("the secrets sink has an invalid path: %s", err)
+	}
+
+	switch sinkType {
+	case "", fileSecretsSink:
+		if path == "" {
+			path = filepath.Join(outputDirectory, "secrets.json")
+		}
+
+		return secrets.NewFileSink(path), nil
+	case keyringSecretsSink:
+		if path == "" {
+			path = filepath.Join(outputDirectory, "secrets.keyring")
+		}
+
+		passphrase, err := helper.ResolveExpressions(conf.Passphrase)
+		if err != nil {
+			return nil, fmt.Errorf("the secrets sink has an invalid passphrase: %s", err)
+		}
+
+		return secrets.NewKeyringSink(path, passphrase)
+	case providerSecretsSink:
+		reference, err := helper.ResolveExpressions(conf.Reference)
+		if err != nil {
+			return nil, fmt.Errorf("the secrets sink has an invalid reference: %s", err)
+		}
+
+		if reference == "" {
+			return nil, fmt.Errorf("the secrets sink needs a reference to store the passwords under, e.g. vault.secret/ssl-go")
+		}
+
+		return secrets.NewProviderSink(reference)
+	}
+
+	return nil, fmt.Errorf("the secrets sink has an invalid type %s, it has to be %s, %s or %s", sinkType, fileSecretsSink, keyringSecretsSink, providerSecretsSink)
+}
+
+// Gets the password generation of the entry of the node, nil if it has none.
+func getPasswordGeneration(node *PlanNode) *configuration.PasswordGenerationConfiguration {
+	switch node.Type {
+	case helper.RootCertificateType:
+		return node.RootCertificateAuthority.Passwords
+	case helper.IntermediateCertificateType:
+		return node.IntermediateCertificateAuthority.Passwords
+	case helper.LeafCertificateType:
+		return node.LeafCertificate.Passwords
+	}
+
+	return nil
+}
+
+// Generates a password of random letters and digits.
+func generatePassword(length int) (string, error) {
+	password := make([]byte, length)
+	max := big.NewInt(int64(len(passwordCharacters)))
+
+	for i := range password {
+		n, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			return "", err
+		}
+
+		password[i] = passwordCharacters[n.Int64()]
+	}
+
+	return string(password), nil
+}
+
+// Escapes a password so it is used as is when the entry is resolved.
+func escapeExpressions(value string) string {
+	return strings.ReplaceAll(value, "${{", "$${{")
+}
@@ -0,0 +1,52 @@
+package certificates
+
+import (
+	"git.mfdlabs.local/petko/mfdlabs-ssl-go/pkg/backend"
+	"git.mfdlabs.local/petko/mfdlabs-ssl-go/pkg/configuration"
+	"git.mfdlabs.local/petko/mfdlabs-ssl-go/pkg/helper"
+	"git.mfdlabs.local/petko/mfdlabs-ssl-go/pkg/pkcs12"
+)
+
+// Resolves and validates how the pfx of the certificate is encoded, nil if the entry leaves it to the backend. The
+// problems with it are added to the errors of the resolver.
+func getPfxOptions(resolver *fieldResolver, conf *configuration.PfxConfiguration) *backend.PfxOptions {
+	if conf == nil {
+		return nil
+	}
+
+	encryptionName := resolver.resolve("pfx.encryption", conf.Encryption)
+	macAlgorithmName := resolver.resolve("pfx.macAlgorithm", conf.MacAlgorithm)
+	friendlyName := resolver.resolve("pfx.friendlyName", conf.FriendlyName)
+
+	encryption, err := pkcs12.CheckEncryption(encryptionName)
+	if err != nil {
+		resolver.errs = append(resolver.errs, helper.NewValidationError(resolver.certType, resolver.certName, "pfx.encryption", err.Error()))
+	}
+
+	macAlgorithm, err := pkcs12.CheckMacAlgorithm(macAlgorithmName, encryption)
+	if err != nil {
+		resolver.errs = append(resolver.errs, helper.NewValidationError(resolver.certType, resolver.certName, "pfx.macAlgorithm", err.Error()))
+	}
+
+	iterations := conf.Iterations
+
+	if iterations == 0 {
+		iterations = pkcs12.DefaultIterations
+	}
+
+	if iterations < 0 {
+		resolver.errs = append(resolver.errs, helper.NewValidationError(resolver.certType, resolver.certName, "pfx.iterations", "must be greater than or equal to 0"))
+	}
+
+	if friendlyName == "" {
+		friendlyName = resolver.certName
+	}
+
+	return &backend.PfxOptions{
+		Encryption:   encryption,
+		MacAlgorithm: macAlgorithm,
+		Iterations:   iterations,
+		FriendlyName: friendlyName,
+		IncludeChain: conf.IncludeChain == nil || *conf.IncludeChain,
+	}
+}
@@ -0,0 +1,172 @@
+package certificates
+
+import (
+	"git.mfdlabs.local/petko/mfdlabs-ssl-go/pkg/backend"
+	"git.mfdlabs.local/petko/mfdlabs-ssl-go/pkg/configuration"
+	"git.mfdlabs.local/petko/mfdlabs-ssl-go/pkg/helper"
+)
+
+// What a run does with the configuration file of a certificate.
+const (
+	// The certificate has no configuration.
+	NoConfigurationAction = "none"
+
+	// The configuration file is not in the output directory yet.
+	CreateConfigurationAction = "create"
+
+	// The configuration file is in the output directory and overwriteConfig is set, the native backend never overwrites it.
+	OverwriteConfigurationAction = "overwrite"
+
+	// The configuration file is in the output directory and is left as it is.
+	KeepConfigurationAction = "keep"
+
+	// The certificate is skipped, so its configuration file isn't looked at.
+	SkippedConfigurationAction = "-"
+)
+
+// A certificate authority in the chain of a planned certificate.
+type PlannedIssuer struct {
+	Type string `json:"type"`
+	Name string `json:"name"`
+
+	// Determines if the issuer isn't part of the configuration, but was generated by an earlier run.
+	External bool `json:"external"`
+}
+
+// What a run would do with a certificate of the configuration.
+type PlannedCertificate struct {
+	Type string `json:"type"`
+	Name string `json:"name"`
+
+	// The absolute path of the directory the artifacts of the certificate are written to.
+	OutputDirectory string `json:"outputDirectory"`
+
+	// Either create, recreate or skip.
+	Action string `json:"action"`
+
+	// Either none, create, overwrite, keep or - when the certificate is skipped.
+	ConfigurationAction string `json:"configurationAction"`
+
+	// The issuers of the certificate, from the one that signs it up to the root certificate authority.
+	Chain []*PlannedIssuer `json:"chain"`
+
+	// The backend invocation that generates the certificate, with the passwords redacted.
+	Invocation string `json:"invocation"`
+}
+
+// Validates the configuration and describes what a run would do with every certificate, in the order they would
+// be generated in. Nothing is written to disk.
+func DescribePlan(configFilePath string, conf *configuration.SslConfiguration, generator backend.Backend) ([]*PlannedCertificate, error) {
+	err := validate(configFilePath, conf, generator.Name())
+	if err != nil {
+		return nil, err
+	}
+
+	plan, err := BuildPlan(configFilePath, conf)
+	if err != nil {
+		return nil, err
+	}
+
+	// A plan installs nothing, so a trust store whose distribution can't be detected is described as it is
+	_ = resolveTrustStore(plan.Nodes)
+
+	passwords, err := newPasswordSource(conf, plan, false)
+	if err != nil {
+		return nil, err
+	}
+
+	var planned []*PlannedCertificate
+
+	for _, node := range plan.Nodes {
+		request, err := getRequest(configFilePath, node, passwords)
+		if err != nil {
+			return nil, err
+		}
+
+		planned = append(planned, &PlannedCertificate{
+			Type:                node.Type,
+			Name:                node.Name,
+			OutputDirectory:     node.OutputDirectory,
+			Action:              node.Action,
+			ConfigurationAction: getConfigurationAction(node, generator),
+			Chain:               getChain(node),
+			Invocation:          describe(request, generator),
+		})
+	}
+
+	return planned, nil
+}
+
+func getConfigurationAction(node *PlanNode, generator backend.Backend) string {
+	if node.Action == SkipAction {
+		return SkippedConfigurationAction
+	}
+
+	var hasConfiguration, overwrite bool
+
+	switch node.Type {
+	case helper.RootCertificateType:
+		hasConfiguration = node.RootCertificateAuthority.Configuration != nil
+		overwrite = node.RootCertificateAuthority.OverwriteExistingConfiguration
+	case helper.IntermediateCertificateType:
+		hasConfiguration = node.IntermediateCertificateAuthority.Configuration != nil
+		overwrite = node.IntermediateCertificateAuthority.OverwriteExistingConfiguration
+	case helper.SignedCertificateType:
+		// Signed certificates don't have a configuration file, their configuration is only a policy
+	default:
+		hasConfiguration = node.LeafCertificate.Configuration != nil
+		overwrite = node.LeafCertificate.OverwriteExistingConfiguration
+	}
+
+	if !hasConfiguration {
+		return NoConfigurationAction
+	}
+
+	if !artifactExists(node.OutputDirectory, node.Type, node.Name, helper.ConfigurationExtension) {
+		return CreateConfigurationAction
+	}
+
+	if overwritesConfiguration(overwrite, generator) {
+		return OverwriteConfigurationAction
+	}
+
+	return KeepConfigurationAction
+}
+
+// The native backend generates the certificates from the configuration entries, so the configuration file is only a
+// record of them and overwriteConfig has no effect.
+func overwritesConfiguration(overwrite bool, generator backend.Backend) bool {
+	return overwrite && generator.Name() != backend.NativeBackendName
+}
+
+// Gets the issuers of the node, the chain stops at the first issuer that isn't part of the configuration.
+func getChain(node *PlanNode) []*PlannedIssuer {
+	chain := []*PlannedIssuer{}
+
+	for ; node != nil; node = node.Parent() {
+		if node.ParentName == "" {
+			break
+		}
+
+		chain = append(chain, &PlannedIssuer{
+			Type:     node.ParentType,
+			Name:     node.ParentName,
+			External: node.IsParentExternal,
+		})
+	}
+
+	return chain
+}
+
+func describe(request interface{}, generator backend.Backend) string {
+	switch request := request.(type) {
+	case *backend.RootCertificateAuthorityRequest:
+		return generator.DescribeRootCertificateAuthority(request)
+	case *backend.IntermediateCertificateAuthorityRequest:
+		return generator.DescribeIntermediateCertificateAuthority(request)
+	case *backend.SigningRequest:
+		return generator.DescribeSigningRequest(request)
+	}
+
+	return generator.DescribeLeafCertificate(request.(*backend.LeafCertificateRequest))
+}
@@ -0,0 +1,360 @@
+package certificates
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"git.mfdlabs.local/petko/mfdlabs-ssl-go/pkg/configuration"
+	"git.mfdlabs.local/petko/mfdlabs-ssl-go/pkg/helper"
+	"git.mfdlabs.local/petko/mfdlabs-ssl-go/pkg/nss"
+	"git.mfdlabs.local/petko/mfdlabs-ssl-go/pkg/truststore"
+)
+
+// A certificate of the configuration, with the certificate authority that issues it.
+type PlanNode struct {
+	// The type of the certificate: root, intermediate, leaf or signed.
+	Type string
+
+	// The resolved name of the certificate.
+	Name string
+
+	// The position of the certificate in its list of the configuration.
+	Index int
+
+	// The type and name of the issuing certificate authority, empty for root certificate authorities.
+	ParentType string
+	ParentName string
+
+	// Determines if the issuer isn't part of the configuration, but was generated by an earlier run.
+	IsParentExternal bool
+
+	// The absolute paths of the directories the artifacts of the certificate and of its issuer are in.
+	OutputDirectory       string
+	ParentOutputDirectory string
+
+	// What a run does with the certificate: create, recreate or skip.
+	Action string
+
+	// The trust store the certificate authority is installed into, nil if it isn't.
+	TrustStore *truststore.Options
+
+	// The NSS databases the certificate authority is imported into, nil if it isn't.
+	NssDatabases *nss.Options
+
+	// The configuration entry of the certificate, only the one matching the type is set.
+	RootCertificateAuthority         *configuration.RootCertificateAuthority
+	IntermediateCertificateAuthority *configuration.IntermediateCertificateAuthority
+	LeafCertificate                  *configuration.LeafCertificate
+	CertificateSigningRequest        *configuration.CertificateSigningRequest
+
+	parent *PlanNode
+}
+
+// What a run does with a certificate of the plan.
+const (
+	// The certificate is not in its output directory yet.
+	CreateAction = "create"
+
+	// The certificate is in its output directory, but its issuer is generated again so it has to be signed again.
+	RecreateAction = "recreate"
+
+	// The certificate and its issuers are already in their output directories.
+	SkipAction = "skip"
+)
+
+// The certificates of a configuration in the order they have to be generated in.
+type Plan struct {
+	// Every issuer comes before the certificates it issues.
+	Nodes []*PlanNode
+
+	// The absolute path of the output directory of the configuration, certificates may override it.
+	OutputDirectory string
+}
+
+// Gets the issuer of the node, nil for root certificate authorities and external issuers.
+func (node *PlanNode) Parent() *PlanNode {
+	return node.parent
+}
+
+func (node *PlanNode) key() string {
+	return getNodeKey(node.Type, node.Name)
+}
+
+// Nodes are keyed by the name of their artifacts, so two certificates can't write to the same files.
+func getNodeKey(certType string, certName string) string {
+	return helper.GetArtifactPrefix(certType) + certName
+}
+
+// Builds the dependency graph of the configuration from the ca chain names of the intermediate and leaf certificates,
+// resolving $ref entries, and sorts it topologically.
+// Cycles, duplicate names and missing issuers are reported as validation errors, the plan is returned even if the
+// hierarchy is not valid so the certificates that could be read can still be validated.
+func BuildPlan(configFilePath string, conf *configuration.SslConfiguration) (*Plan, error) {
+	var errs helper.Errors
+
+	isYaml := configuration.IsYamlFile(configFilePath)
+
+	outputDirectory, err := resolveOutputDirectory(conf.OutputDirectory, "")
+	if err != nil {
+		errs = append(errs, fmt.Errorf("the output directory %s is not valid: %s", conf.OutputDirectory, err))
+
+		// Keep planning against the default output directory, so the certificates are still validated
+		outputDirectory, _ = helper.GetOutputDirectory("")
+	}
+
+	nodes := make(map[string]*PlanNode)
+	var ordered []*PlanNode
+
+	addNode := func(node *PlanNode) {
+		if other, ok := nodes[node.key()]; ok {
+			errs = append(errs, locateValidationErrors(helper.NewValidationError(node.Type, node.Name, "name", "is used by another "+other.Type+" certificate"), node, isYaml)...)
+			return
+		}
+
+		nodes[node.key()] = node
+		ordered = append(ordered, node)
+	}
+
+	for i, rootCert := range conf.RootCertificateAuthorities {
+		if err := DetermineIfRootCAIsReference(configFilePath, rootCert); err != nil {
+			errs = append(errs, &helper.ValidationError{
+				CertificateType: helper.RootCertificateType,
+				CertificateName: rootCert.RootCertificateName,
+				Field:           "$ref",
+				Message:         err.Error(),
+				Path:            configuration.GetFieldPath(helper.RootCertificateType, i, "$ref", isYaml),
+			})
+			continue
+		}
+
+		addNode(&PlanNode{
+			Type:                     helper.RootCertificateType,
+			Name:                     resolveName(rootCert.RootCertificateName),
+			Index:                    i,
+			OutputDirectory:          getNodeOutputDirectory(rootCert.OutputDirectory, outputDirectory),
+			RootCertificateAuthority: rootCert,
+		})
+	}
+
+	for i, intCert := range conf.IntermediateCertificateAuthorities {
+		if err := DetermineIfIntermediateCAIsReference(configFilePath, intCert); err != nil {
+			errs = append(errs, &helper.ValidationError{
+				CertificateType: helper.IntermediateCertificateType,
+				CertificateName: intCert.IntermediateCertificateAuthorityName,
+				Field:           "$ref",
+				Message:         err.Error(),
+				Path:            configuration.GetFieldPath(helper.IntermediateCertificateType, i, "$ref", isYaml),
+			})
+			continue
+		}
+
+		addNode(&PlanNode{
+			Type:                             helper.IntermediateCertificateType,
+			Name:                             resolveName(intCert.IntermediateCertificateAuthorityName),
+			Index:                            i,
+			ParentType:                       getParentType(intCert.IsLastChainCertificateRootCertificateAuthority),
+			ParentName:                       resolveName(intCert.LastChainCertificateName),
+			OutputDirectory:                  getNodeOutputDirectory(intCert.OutputDirectory, outputDirectory),
+			IntermediateCertificateAuthority: intCert,
+		})
+	}
+
+	for i, leafCert := range conf.LeafCertificateAuthorities {
+		if err := DetermineIfLeafCertificateIsReference(configFilePath, leafCert); err != nil {
+			errs = append(errs, &helper.ValidationError{
+				CertificateType: helper.LeafCertificateType,
+				CertificateName: leafCert.LeafCertificateName,
+				Field:           "$ref",
+				Message:         err.Error(),
+				Path:            configuration.GetFieldPath(helper.LeafCertificateType, i, "$ref", isYaml),
+			})
+			continue
+		}
+
+		addNode(&PlanNode{
+			Type:            helper.LeafCertificateType,
+			Name:            resolveName(leafCert.LeafCertificateName),
+			Index:           i,
+			ParentType:      getParentType(leafCert.IsLastChainCertificateRootCertificateAuthority),
+			ParentName:      resolveName(leafCert.LastChainCertificateName),
+			OutputDirectory: getNodeOutputDirectory(leafCert.OutputDirectory, outputDirectory),
+			LeafCertificate: leafCert,
+		})
+	}
+
+	for i, signingRequest := range conf.CertificateSigningRequests {
+		if err := DetermineIfCertificateSigningRequestIsReference(configFilePath, signingRequest); err != nil {
+			errs = append(errs, &helper.ValidationError{
+				CertificateType: helper.SignedCertificateType,
+				CertificateName: signingRequest.SignedCertificateName,
+				Field:           "$ref",
+				Message:         err.Error(),
+				Path:            configuration.GetFieldPath(helper.SignedCertificateType, i, "$ref", isYaml),
+			})
+			continue
+		}
+
+		addNode(&PlanNode{
+			Type:                      helper.SignedCertificateType,
+			Name:                      resolveName(signingRequest.SignedCertificateName),
+			Index:                     i,
+			ParentType:                getParentType(signingRequest.IsLastChainCertificateRootCertificateAuthority),
+			ParentName:                resolveName(signingRequest.LastChainCertificateName),
+			OutputDirectory:           getNodeOutputDirectory(signingRequest.OutputDirectory, outputDirectory),
+			CertificateSigningRequest: signingRequest,
+		})
+	}
+
+	// Link every node to its issuer
+	for _, node := range ordered {
+		if node.Type == helper.RootCertificateType {
+			continue
+		}
+
+		// The missing name is reported by the validation of the certificate
+		if node.ParentName == "" {
+			continue
+		}
+
+		if parent, ok := nodes[getNodeKey(node.ParentType, node.ParentName)]; ok {
+			node.parent = parent
+			node.ParentOutputDirectory = parent.OutputDirectory
+			continue
+		}
+
+		// The issuer may have been generated by an earlier run
+		if artifactExists(outputDirectory, node.ParentType, node.ParentName, helper.CertificateExtension) {
+			node.IsParentExternal = true
+			node.ParentOutputDirectory = outputDirectory
+			continue
+		}
+
+		err := helper.NewValidationError(node.Type, node.Name, getParentField(node.Type), fmt.Sprintf("the %s certificate authority %s is not in the configuration or the output directory %s", node.ParentType, node.ParentName, outputDirectory))
+		errs = append(errs, locateValidationErrors(err, node, isYaml)...)
+	}
+
+	// Depth first search, appending every node after its issuer
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+
+	state := make(map[*PlanNode]int)
+	plan := &Plan{OutputDirectory: outputDirectory}
+
+	var visit func(node *PlanNode, path []string)
+	visit = func(node *PlanNode, path []string) {
+		switch state[node] {
+		case visited:
+			return
+		case visiting:
+			// Only report the part of the path that loops
+			for i, name := range path {
+				if name == node.Name {
+					path = path[i:]
+					break
+				}
+			}
+
+			err := helper.NewValidationError(node.Type, node.Name, getParentField(node.Type), fmt.Sprintf("the certificate chain contains a cycle: %s -> %s", strings.Join(path, " -> "), node.Name))
+			errs = append(errs, locateValidationErrors(err, node, isYaml)...)
+			return
+		}
+
+		state[node] = visiting
+
+		if node.parent != nil {
+			visit(node.parent, append(path, node.Name))
+		}
+
+		state[node] = visited
+		plan.Nodes = append(plan.Nodes, node)
+	}
+
+	for _, node := range ordered {
+		visit(node, nil)
+	}
+
+	// Issuers come first, so their action is known when the certificates they issue are looked at
+	for _, node := range plan.Nodes {
+		node.Action = getAction(node)
+	}
+
+	errs = helper.AppendError(errs, assignTrustStore(conf.TrustStore, plan.Nodes))
+	errs = helper.AppendError(errs, assignNssDatabases(conf.Nss, plan.Nodes))
+
+	if len(errs) != 0 {
+		return plan, errs
+	}
+
+	return plan, nil
+}
+
+func getParentType(isLastChainCertificateRootCertificateAuthority bool) string {
+	if isLastChainCertificateRootCertificateAuthority {
+		return helper.RootCertificateType
+	}
+
+	return helper.IntermediateCertificateType
+}
+
+// Resolves the ${{ }} expressions of a name, the unresolved ones are reported by the validation of the certificate.
+func resolveName(name string) string {
+	resolved, _ := helper.ResolveExpressions(name)
+
+	return resolved
+}
+
+// Gets the name of the field that holds the issuer of the certificate type.
+func getParentField(certType string) string {
+	if certType == helper.LeafCertificateType || certType == helper.SignedCertificateType {
+		return "caName"
+	}
+
+	return "caChainName"
+}
+
+// Certificates that are already in the bin directory are skipped, unless their issuer is generated again.
+func getAction(node *PlanNode) string {
+	exists := artifactExists(node.OutputDirectory, node.Type, node.Name, helper.CertificateExtension)
+
+	if parent := node.Parent(); parent != nil && parent.Action != SkipAction {
+		return helper.Ternary(exists, RecreateAction, CreateAction).(string)
+	}
+
+	return helper.Ternary(exists, SkipAction, CreateAction).(string)
+}
+
+// Resolves the ${{ }} expressions of an output directory and makes it absolute, an empty directory selects
+// the fallback, or the default output directory if there is no fallback.
+func resolveOutputDirectory(outputDirectory string, fallback string) (string, error) {
+	resolved, err := helper.ResolveExpressions(outputDirectory)
+	if err != nil {
+		return "", err
+	}
+
+	if resolved == "" {
+		resolved = fallback
+	}
+
+	return helper.GetOutputDirectory(resolved)
+}
+
+// Gets the output directory of a certificate, the problems with it are reported by the validation of the certificate.
+func getNodeOutputDirectory(outputDirectory string, fallback string) string {
+	resolved, err := resolveOutputDirectory(outputDirectory, fallback)
+	if err != nil {
+		return fallback
+	}
+
+	return resolved
+}
+
+// Determines if an artifact of a certificate is already in the output directory.
+func artifactExists(outputDirectory string, certType string, certName string, extension string) bool {
+	_, err := os.Stat(helper.GetArtifactPath(outputDirectory, certType, certName, extension))
+
+	return err == nil
+}
@@ -0,0 +1,227 @@
+package certificates
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"git.mfdlabs.local/petko/mfdlabs-ssl-go/pkg/configuration"
+	"git.mfdlabs.local/petko/mfdlabs-ssl-go/pkg/helper"
+	"git.mfdlabs.local/petko/mfdlabs-ssl-go/pkg/truststore"
+)
+
+func TestBuildPlanOrdersIssuersFirst(t *testing.T) {
+	directory := t.TempDir()
+
+	conf := &configuration.SslConfiguration{
+		OutputDirectory: directory,
+		LeafCertificateAuthorities: []*configuration.LeafCertificate{
+			{LeafCertificateName: "leaf", LastChainCertificateName: "second"},
+		},
+		IntermediateCertificateAuthorities: []*configuration.IntermediateCertificateAuthority{
+			{IntermediateCertificateAuthorityName: "second", LastChainCertificateName: "first"},
+			{IntermediateCertificateAuthorityName: "first", LastChainCertificateName: "root", IsLastChainCertificateRootCertificateAuthority: true},
+		},
+		RootCertificateAuthorities: []*configuration.RootCertificateAuthority{
+			{RootCertificateName: "root"},
+		},
+	}
+
+	plan, err := BuildPlan(filepath.Join(directory, "config.json"), conf)
+	if err != nil {
+		t.Fatalf("failed to build the plan: %s", err)
+	}
+
+	var names []string
+	for _, node := range plan.Nodes {
+		names = append(names, node.Name)
+	}
+
+	if strings.Join(names, ",") != "root,first,second,leaf" {
+		t.Fatalf("expected the plan root,first,second,leaf, got %s", strings.Join(names, ","))
+	}
+
+	if plan.Nodes[3].Parent() != plan.Nodes[2] || plan.Nodes[2].Parent() != plan.Nodes[1] || plan.Nodes[1].Parent() != plan.Nodes[0] {
+		t.Fatal("expected every certificate to be linked to its issuer")
+	}
+}
+
+func TestBuildPlanReportsCycles(t *testing.T) {
+	directory := t.TempDir()
+
+	conf := &configuration.SslConfiguration{
+		OutputDirectory: directory,
+		IntermediateCertificateAuthorities: []*configuration.IntermediateCertificateAuthority{
+			{IntermediateCertificateAuthorityName: "a", LastChainCertificateName: "b"},
+			{IntermediateCertificateAuthorityName: "b", LastChainCertificateName: "a"},
+		},
+	}
+
+	plan, err := BuildPlan(filepath.Join(directory, "config.json"), conf)
+	if err == nil {
+		t.Fatal("expected the cycle to be reported")
+	}
+
+	if !strings.Contains(err.Error(), "the certificate chain contains a cycle: a -> b -> a") {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	// The certificates are still planned so they can be validated
+	if len(plan.Nodes) != 2 {
+		t.Fatalf("expected 2 planned certificates, got %d", len(plan.Nodes))
+	}
+}
+
+func TestBuildPlanResolvesParents(t *testing.T) {
+	tests := []struct {
+		name     string
+		artifact bool
+		external bool
+		err      string
+	}{
+		{name: "missing parent", err: "the intermediate certificate authority missing is not in the configuration or the output directory"},
+		{name: "parent of an earlier run", artifact: true, external: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			directory := t.TempDir()
+
+			if test.artifact {
+				writeTestArtifact(t, directory, helper.IntermediateCertificateType, "missing", helper.CertificateExtension)
+			}
+
+			conf := &configuration.SslConfiguration{
+				OutputDirectory: directory,
+				LeafCertificateAuthorities: []*configuration.LeafCertificate{
+					{LeafCertificateName: "leaf", LastChainCertificateName: "missing"},
+				},
+			}
+
+			plan, err := BuildPlan(filepath.Join(directory, "config.json"), conf)
+
+			if test.err != "" {
+				if err == nil || !strings.Contains(err.Error(), test.err) {
+					t.Fatalf("expected an error containing %q, got %v", test.err, err)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("failed to build the plan: %s", err)
+			}
+
+			node := plan.Nodes[0]
+			if node.IsParentExternal != test.external || node.ParentOutputDirectory != directory || node.Parent() != nil {
+				t.Fatalf("expected the issuer of the leaf to be external in %s", directory)
+			}
+		})
+	}
+}
+
+func TestBuildPlanResolvesReferencedParents(t *testing.T) {
+	directory := t.TempDir()
+
+	err := ioutil.WriteFile(filepath.Join(directory, "intermediate.json"), []byte(`{"name": "intermediate", "caChainName": "root", "isLastChainRootCA": true}`), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	conf := &configuration.SslConfiguration{
+		OutputDirectory: directory,
+		RootCertificateAuthorities: []*configuration.RootCertificateAuthority{
+			{RootCertificateName: "root"},
+		},
+		IntermediateCertificateAuthorities: []*configuration.IntermediateCertificateAuthority{
+			{ReferencedConfigurationPath: "./intermediate.json"},
+		},
+		LeafCertificateAuthorities: []*configuration.LeafCertificate{
+			{LeafCertificateName: "leaf", LastChainCertificateName: "intermediate"},
+		},
+	}
+
+	plan, err := BuildPlan(filepath.Join(directory, "config.json"), conf)
+	if err != nil {
+		t.Fatalf("failed to build the plan: %s", err)
+	}
+
+	leaf := plan.Nodes[len(plan.Nodes)-1]
+	if leaf.Name != "leaf" || leaf.Parent() == nil || leaf.Parent().Name != "intermediate" {
+		t.Fatal("expected the leaf to be issued by the referenced intermediate certificate authority")
+	}
+
+	if leaf.Parent().Parent() == nil || leaf.Parent().Parent().Name != "root" {
+		t.Fatal("expected the referenced intermediate certificate authority to be issued by the root")
+	}
+}
+
+func TestGetAction(t *testing.T) {
+	tests := []struct {
+		name         string
+		exists       bool
+		parentAction string
+		expected     string
+	}{
+		{name: "new root", expected: CreateAction},
+		{name: "existing root", exists: true, expected: SkipAction},
+		{name: "new certificate of a new issuer", parentAction: CreateAction, expected: CreateAction},
+		{name: "existing certificate of a new issuer", exists: true, parentAction: CreateAction, expected: RecreateAction},
+		{name: "existing certificate of a recreated issuer", exists: true, parentAction: RecreateAction, expected: RecreateAction},
+		{name: "new certificate of a skipped issuer", parentAction: SkipAction, expected: CreateAction},
+		{name: "existing certificate of a skipped issuer", exists: true, parentAction: SkipAction, expected: SkipAction},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			directory := t.TempDir()
+
+			node := &PlanNode{Type: helper.LeafCertificateType, Name: "leaf", OutputDirectory: directory}
+			if test.parentAction != "" {
+				node.parent = &PlanNode{Type: helper.RootCertificateType, Name: "root", OutputDirectory: directory, Action: test.parentAction}
+			}
+
+			if test.exists {
+				writeTestArtifact(t, directory, node.Type, node.Name, helper.CertificateExtension)
+			}
+
+			if action := getAction(node); action != test.expected {
+				t.Fatalf("expected %s, got %s", test.expected, action)
+			}
+		})
+	}
+}
+
+func TestBuildPlanDoesNotDetectTheTrustStore(t *testing.T) {
+	directory := t.TempDir()
+
+	conf := &configuration.SslConfiguration{
+		OutputDirectory: directory,
+		TrustStore:      &configuration.TrustStoreConfiguration{Root: t.TempDir()},
+		RootCertificateAuthorities: []*configuration.RootCertificateAuthority{
+			{RootCertificateName: "root", ShouldInsertIntoTrustedStore: true},
+		},
+	}
+
+	plan, err := BuildPlan(filepath.Join(directory, "config.json"), conf)
+	if err != nil {
+		t.Fatalf("expected a root without os-release to be planned, got %s", err)
+	}
+
+	if plan.Nodes[0].TrustStore == nil || plan.Nodes[0].TrustStore.Distribution != truststore.AutoDistribution {
+		t.Fatalf("expected the trust store to be detected later, got %+v", plan.Nodes[0].TrustStore)
+	}
+
+	err = resolveTrustStore(plan.Nodes)
+	if err == nil || !strings.Contains(err.Error(), "failed to detect the distribution") {
+		t.Fatalf("expected the trust store not to be detected, got %v", err)
+	}
+
+	// A trust store that isn't used is never looked at
+	conf.TrustStore.Distribution = "arch"
+	conf.RootCertificateAuthorities[0].ShouldInsertIntoTrustedStore = false
+
+	if _, err := BuildPlan(filepath.Join(directory, "config.json"), conf); err != nil {
+		t.Fatalf("expected an unused trust store to be ignored, got %s", err)
+	}
+}
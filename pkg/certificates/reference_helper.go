@@ -58,15 +58,12 @@ func checkExtensionAndReload(certificate interface{}, path string) error {
 		return fmt.Errorf("the referenced configuration file %s is a directory", path)
 	}
 
-	// Get the file extension
-	fileExtension := fileInfo.Name()[len(fileInfo.Name())-3:]
-
 	// Determine if the file extension is json or yaml
-	if fileExtension == "yml" || fileExtension == "yaml" {
+	if configuration.IsYamlFile(path) {
 		return reloadCertYaml(path, certificate)
 	}
 
-	if fileExtension == "json" {
+	if filepath.Ext(path) == ".json" {
 		return reloadCertJson(path, certificate)
 	}
 
@@ -160,6 +157,35 @@ func DetermineIfIntermediateCAIsReference(configurationFilePath string, certific
 	return nil
 }
 
+func DetermineIfCertificateSigningRequestIsReference(configurationFilePath string, certificate *configuration.CertificateSigningRequest) error {
+	isRefCert := certificate.ReferencedConfigurationPath != ""
+
+	// We need to load the referenced configuration file
+	if isRefCert {
+		// Determine if the referenced path is absolute or relative
+		if isAbsolutePath(certificate.ReferencedConfigurationPath) {
+			return checkExtensionAndReload(&certificate, certificate.ReferencedConfigurationPath)
+		}
+
+		// The referenced path is relative, transform it to absolute to the path of the configuration file
+		// If the referenced path starts with ./ remove it, ../ is ok
+		certPath := certificate.ReferencedConfigurationPath
+
+		if certPath[0:2] == "./" {
+			certPath = certPath[2:]
+		}
+
+		// get config path without file name
+		configFilePath := configurationFilePath[:len(configurationFilePath)-len(filepath.Base(configurationFilePath))]
+
+		absolutePath := configFilePath + certPath
+
+		return checkExtensionAndReload(&certificate, absolutePath)
+	}
+
+	return nil
+}
+
 func isAbsolutePath(path string) bool {
 	return path[0] == '/'
 }
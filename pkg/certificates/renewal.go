@@ -0,0 +1,150 @@
+package certificates
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"git.mfdlabs.local/petko/mfdlabs-ssl-go/pkg/backend"
+	"git.mfdlabs.local/petko/mfdlabs-ssl-go/pkg/configuration"
+	"git.mfdlabs.local/petko/mfdlabs-ssl-go/pkg/helper"
+)
+
+// The number of days before they expire that certificates are renewed by default.
+const DefaultRenewalWindow = 30
+
+type RenewalOptions struct {
+	// Certificates that expire within this many days are renewed.
+	Window int
+
+	// The names of the certificates to consider, every certificate of the configuration if empty. The certificates
+	// issued by a renewed certificate authority are renewed whether they are named or not.
+	Names []string
+
+	// Determines if the considered certificates are renewed even if they don't expire within the window.
+	Force bool
+
+	// Determines if the certificates that would be renewed are only printed.
+	DryRun bool
+}
+
+// Renews the certificates of the configuration that expire soon. A renewed certificate keeps or replaces its private
+// key as its renewal key policy says, and every certificate it issued is issued again so the chains stay valid.
+func Renew(configFilePath string, conf *configuration.SslConfiguration, generator backend.Backend, options *RenewalOptions) error {
+	plan, requests, err := prepare(configFilePath, conf, generator, !options.DryRun)
+	if err != nil {
+		return err
+	}
+
+	var errs helper.Errors
+
+	found := make(map[string]bool)
+
+	for _, node := range plan.Nodes {
+		found[node.Name] = true
+	}
+
+	for _, name := range options.Names {
+		if !found[name] {
+			errs = append(errs, fmt.Errorf("the configuration has no certificate named %s", name))
+		}
+	}
+
+	if len(errs) != 0 {
+		return errs
+	}
+
+	now := time.Now()
+	deadline := now.AddDate(0, 0, options.Window)
+
+	renewalPlan := &Plan{OutputDirectory: plan.OutputDirectory}
+	renewed := make(map[*PlanNode]bool)
+
+	for _, node := range plan.Nodes {
+		node.Action = SkipAction
+
+		if !artifactExists(node.OutputDirectory, node.Type, node.Name, helper.CertificateExtension) {
+			if len(options.Names) == 0 || contains(options.Names, node.Name) {
+				fmt.Printf("Skipping %s certificate: %s, it has not been generated yet\n", node.Type, node.Name)
+			}
+
+			continue
+		}
+
+		var reason string
+
+		if parent := node.Parent(); parent != nil && renewed[parent] {
+			reason = fmt.Sprintf("its issuer %s is renewed", parent.Name)
+		} else if len(options.Names) == 0 || contains(options.Names, node.Name) {
+			chain, err := backend.LoadCertificateChain(node.OutputDirectory, node.Type, node.Name)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("failed to load the %s certificate %s: %w", node.Type, node.Name, err))
+				continue
+			}
+
+			notAfter := chain[0].NotAfter
+
+			switch {
+			case notAfter.Before(now):
+				reason = fmt.Sprintf("it expired on %s", notAfter.Format("2006-01-02"))
+			case notAfter.Before(deadline):
+				reason = fmt.Sprintf("it expires on %s, in %d days", notAfter.Format("2006-01-02"), int(notAfter.Sub(now).Hours()/24))
+			case options.Force:
+				reason = fmt.Sprintf("renewal is forced, it expires on %s", notAfter.Format("2006-01-02"))
+			default:
+				fmt.Printf("Keeping %s certificate: %s, it expires on %s\n", node.Type, node.Name, notAfter.Format("2006-01-02"))
+				continue
+			}
+		} else {
+			continue
+		}
+
+		reuseKey := setReuseExistingKey(requests[node])
+
+		// Signed certificates have no key of their own, the key of the request is kept
+		keyAction := ""
+
+		if node.Type != helper.SignedCertificateType {
+			keyAction = helper.Ternary(reuseKey, ", reusing its key", ", rotating its key").(string)
+		}
+
+		fmt.Printf("Renewing %s certificate: %s, %s%s\n", node.Type, node.Name, reason, keyAction)
+
+		node.Action = RecreateAction
+		renewed[node] = true
+		renewalPlan.Nodes = append(renewalPlan.Nodes, node)
+	}
+
+	if len(errs) != 0 {
+		return errs
+	}
+
+	if len(renewalPlan.Nodes) == 0 {
+		fmt.Printf("No certificate expires within %d days\n", options.Window)
+		return nil
+	}
+
+	if options.DryRun {
+		return nil
+	}
+
+	return execute(context.Background(), renewalPlan, requests, generator, os.Stdout, nil)
+}
+
+// Makes the request keep the existing private key if its renewal key policy says so, and returns if it does.
+func setReuseExistingKey(request interface{}) bool {
+	switch request := request.(type) {
+	case *backend.RootCertificateAuthorityRequest:
+		request.ReuseExistingKey = request.RenewalKeyPolicy == helper.ReuseRenewalKeyPolicy
+		return request.ReuseExistingKey
+	case *backend.IntermediateCertificateAuthorityRequest:
+		request.ReuseExistingKey = request.RenewalKeyPolicy == helper.ReuseRenewalKeyPolicy
+		return request.ReuseExistingKey
+	case *backend.LeafCertificateRequest:
+		request.ReuseExistingKey = request.RenewalKeyPolicy == helper.ReuseRenewalKeyPolicy
+		return request.ReuseExistingKey
+	}
+
+	return false
+}
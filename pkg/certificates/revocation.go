@@ -0,0 +1,331 @@
+package certificates
+
+import (
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"time"
+
+	"git.mfdlabs.local/petko/mfdlabs-ssl-go/pkg/backend"
+	"git.mfdlabs.local/petko/mfdlabs-ssl-go/pkg/configuration"
+	"git.mfdlabs.local/petko/mfdlabs-ssl-go/pkg/helper"
+	"git.mfdlabs.local/petko/mfdlabs-ssl-go/pkg/revocation"
+)
+
+// The number of days CRLs are valid for if the certificate authority doesn't configure it.
+const DefaultCrlValidityPeriod = 30
+
+// The certificate to revoke, either a certificate of the configuration or a serial number issued by a certificate
+// authority of the configuration.
+type RevocationTarget struct {
+	// The name of the certificate of the configuration, and its type if the name is used by more than one type.
+	Name string
+	Type string
+
+	// The serial number of a certificate that isn't in the configuration, and the name of the certificate authority
+	// of the configuration that issued it.
+	SerialNumber string
+	CaName       string
+
+	// One of the reasons of RFC 5280, defaults to unspecified.
+	Reason string
+}
+
+// A certificate authority whose CRL can be built.
+type certificateAuthority struct {
+	certType        string
+	name            string
+	password        string
+	outputDirectory string
+
+	// The number of days its CRLs are valid for.
+	crlValidityPeriod int
+}
+
+// Records the revocation of the certificate against the certificate authority that issued it, and builds the CRL of
+// that certificate authority again. crlValidityPeriod overrides the validity of the CRL when it's greater than 0.
+func Revoke(configFilePath string, conf *configuration.SslConfiguration, target *RevocationTarget, crlValidityPeriod int) error {
+	plan, requests, err := prepare(configFilePath, conf, nil, false)
+	if err != nil {
+		return err
+	}
+
+	reason := target.Reason
+	if reason == "" {
+		reason = "unspecified"
+	}
+
+	err = revocation.CheckReason(reason)
+	if err != nil {
+		return err
+	}
+
+	var ca *certificateAuthority
+	var serialNumber *big.Int
+
+	if target.Name != "" {
+		node, err := findRevocableNode(plan, target.Name, target.Type)
+		if err != nil {
+			return err
+		}
+
+		chain, err := backend.LoadCertificateChain(node.OutputDirectory, node.Type, node.Name)
+		if err != nil {
+			return err
+		}
+
+		serialNumber = chain[0].SerialNumber
+		ca = getIssuingCertificateAuthority(node, requests[node])
+	} else {
+		serialNumber, err = revocation.ParseSerialNumber(target.SerialNumber)
+		if err != nil {
+			return err
+		}
+
+		node, err := findCertificateAuthorityNode(plan, target.CaName, target.Type)
+		if err != nil {
+			return err
+		}
+
+		ca = getCertificateAuthority(node, requests[node])
+	}
+
+	if crlValidityPeriod > 0 {
+		ca.crlValidityPeriod = crlValidityPeriod
+	}
+
+	listPath := helper.GetArtifactPath(ca.outputDirectory, ca.certType, ca.name, helper.RevocationListExtension)
+
+	list, err := revocation.Load(listPath)
+	if err != nil {
+		return err
+	}
+
+	revoked, err := list.Revoke(serialNumber, target.Name, reason, time.Now())
+	if err != nil {
+		return err
+	}
+
+	// The list is only saved with the CRL, so a revocation is never recorded without being published
+	err = buildCRL(ca, list)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Revoked certificate %s issued by %s (%s)\n", revoked.SerialNumber, ca.name, revoked.Reason)
+
+	return nil
+}
+
+// Builds the CRLs of the certificate authorities of the configuration with the given names, or of every certificate
+// authority that was generated if no name is given. crlValidityPeriod overrides the validity of the CRLs when it's
+// greater than 0.
+func BuildCRLs(configFilePath string, conf *configuration.SslConfiguration, names []string, crlValidityPeriod int) error {
+	plan, requests, err := prepare(configFilePath, conf, nil, false)
+	if err != nil {
+		return err
+	}
+
+	var errs helper.Errors
+
+	found := make(map[string]bool)
+
+	for _, node := range plan.Nodes {
+		if node.Type != helper.RootCertificateType && node.Type != helper.IntermediateCertificateType {
+			continue
+		}
+
+		if len(names) != 0 && !contains(names, node.Name) {
+			continue
+		}
+
+		found[node.Name] = true
+
+		// Certificate authorities that were never generated have nothing to revoke
+		if len(names) == 0 && !artifactExists(node.OutputDirectory, node.Type, node.Name, helper.CertificateExtension) {
+			fmt.Printf("Skipping %s certificate: %s, it has not been generated yet\n", node.Type, node.Name)
+			continue
+		}
+
+		ca := getCertificateAuthority(node, requests[node])
+
+		if crlValidityPeriod > 0 {
+			ca.crlValidityPeriod = crlValidityPeriod
+		}
+
+		list, err := revocation.Load(helper.GetArtifactPath(ca.outputDirectory, ca.certType, ca.name, helper.RevocationListExtension))
+
+		if err == nil {
+			err = buildCRL(ca, list)
+		}
+
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to build the CRL of the %s certificate %s: %w", node.Type, node.Name, err))
+		}
+	}
+
+	for _, name := range names {
+		if !found[name] {
+			errs = append(errs, fmt.Errorf("the configuration has no certificate authority named %s", name))
+		}
+	}
+
+	if len(errs) != 0 {
+		return errs
+	}
+
+	return nil
+}
+
+// Signs the next CRL of the certificate authority, writes it as DER and PEM, then saves the list with its new CRL number.
+func buildCRL(ca *certificateAuthority, list *revocation.List) error {
+	certificate, err := backend.LoadCertificate(ca.outputDirectory, ca.certType, ca.name, ca.password)
+	if err != nil {
+		return err
+	}
+
+	// Relying parties reject CRLs signed by a certificate that isn't allowed to sign them
+	if certificate.Certificate.KeyUsage&x509.KeyUsageCRLSign == 0 {
+		return fmt.Errorf("the %s certificate %s cannot sign CRLs, its key usage doesn't include cRLSign", ca.certType, ca.name)
+	}
+
+	der, err := list.CreateCRL(certificate.Certificate, certificate.PrivateKey, ca.crlValidityPeriod, time.Now())
+	if err != nil {
+		return err
+	}
+
+	path := func(extension string) string {
+		return helper.GetArtifactPath(ca.outputDirectory, ca.certType, ca.name, extension)
+	}
+
+	err = ioutil.WriteFile(path(helper.CRLExtension), der, 0644)
+	if err != nil {
+		return err
+	}
+
+	err = ioutil.WriteFile(path(helper.PemCRLExtension), revocation.EncodeCRL(der), 0644)
+	if err != nil {
+		return err
+	}
+
+	err = list.Save(path(helper.RevocationListExtension))
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Wrote CRL #%d of the %s certificate %s: %s\n", list.CrlNumber, ca.certType, ca.name, path(helper.PemCRLExtension))
+
+	return nil
+}
+
+// Finds the certificate of the configuration to revoke, root certificate authorities can't be revoked.
+func findRevocableNode(plan *Plan, name string, certType string) (*PlanNode, error) {
+	var matches []*PlanNode
+
+	for _, node := range plan.Nodes {
+		if node.Name == name && (certType == "" || node.Type == certType) {
+			matches = append(matches, node)
+		}
+	}
+
+	if len(matches) == 0 {
+		return nil, fmt.Errorf("the configuration has no certificate named %s", name)
+	}
+
+	if len(matches) > 1 {
+		return nil, fmt.Errorf("more than one certificate is named %s, the type of the certificate to revoke has to be given", name)
+	}
+
+	if matches[0].Type == helper.RootCertificateType {
+		return nil, fmt.Errorf("the root certificate authority %s cannot be revoked, remove it from the trust stores instead", name)
+	}
+
+	return matches[0], nil
+}
+
+// Finds the certificate authority of the configuration with the name.
+func findCertificateAuthorityNode(plan *Plan, name string, certType string) (*PlanNode, error) {
+	var matches []*PlanNode
+
+	for _, node := range plan.Nodes {
+		if node.Type != helper.RootCertificateType && node.Type != helper.IntermediateCertificateType {
+			continue
+		}
+
+		if node.Name == name && (certType == "" || node.Type == certType) {
+			matches = append(matches, node)
+		}
+	}
+
+	if len(matches) == 0 {
+		return nil, fmt.Errorf("the configuration has no certificate authority named %s", name)
+	}
+
+	if len(matches) > 1 {
+		return nil, fmt.Errorf("both a root and an intermediate certificate authority are named %s, the type of the certificate authority has to be given", name)
+	}
+
+	return matches[0], nil
+}
+
+func getCertificateAuthority(node *PlanNode, request interface{}) *certificateAuthority {
+	ca := &certificateAuthority{
+		certType:          node.Type,
+		name:              node.Name,
+		outputDirectory:   node.OutputDirectory,
+		crlValidityPeriod: getCrlValidityPeriod(node),
+	}
+
+	switch request := request.(type) {
+	case *backend.RootCertificateAuthorityRequest:
+		ca.password = request.Password
+	case *backend.IntermediateCertificateAuthorityRequest:
+		ca.password = request.Password
+	}
+
+	return ca
+}
+
+// Gets the certificate authority that issued the certificate of the node, from the chain fields of its entry as the
+// issuer may not be part of the configuration.
+func getIssuingCertificateAuthority(node *PlanNode, request interface{}) *certificateAuthority {
+	ca := &certificateAuthority{
+		certType:          node.ParentType,
+		name:              node.ParentName,
+		outputDirectory:   node.ParentOutputDirectory,
+		crlValidityPeriod: DefaultCrlValidityPeriod,
+	}
+
+	if parent := node.Parent(); parent != nil {
+		ca.crlValidityPeriod = getCrlValidityPeriod(parent)
+	}
+
+	switch request := request.(type) {
+	case *backend.IntermediateCertificateAuthorityRequest:
+		ca.password = request.ChainPassword
+	case *backend.LeafCertificateRequest:
+		ca.password = request.ChainPassword
+	case *backend.SigningRequest:
+		ca.password = request.ChainPassword
+	}
+
+	return ca
+}
+
+func getCrlValidityPeriod(node *PlanNode) int {
+	crlValidityPeriod := 0
+
+	switch node.Type {
+	case helper.RootCertificateType:
+		crlValidityPeriod = node.RootCertificateAuthority.CrlValidityPeriod
+	case helper.IntermediateCertificateType:
+		crlValidityPeriod = node.IntermediateCertificateAuthority.CrlValidityPeriod
+	}
+
+	if crlValidityPeriod == 0 {
+		return DefaultCrlValidityPeriod
+	}
+
+	return crlValidityPeriod
+}
@@ -2,81 +2,64 @@ package certificates
 
 import (
 	"fmt"
+	"io"
 
+	"git.mfdlabs.local/petko/mfdlabs-ssl-go/pkg/backend"
 	"git.mfdlabs.local/petko/mfdlabs-ssl-go/pkg/configuration"
 	"git.mfdlabs.local/petko/mfdlabs-ssl-go/pkg/helper"
 )
 
-func LoadRootCertificates(configFilePath string, conf *configuration.SslConfiguration, loadedRootCerts map[string]*configuration.RootCertificateAuthority) {
-	// check if the root certificates are empty
-	if len(conf.RootCertificateAuthorities) != 0 {
-		// load the root certificates
-		for _, rootCert := range conf.RootCertificateAuthorities {
-			loadRootCertificateAuthority(configFilePath, rootCert, loadedRootCerts)
-		}
-	}
-}
+// Resolves and validates the root certificate authority, every problem with it is reported.
+func getRootCertificateAuthorityRequest(rootCert *configuration.RootCertificateAuthority) (*backend.RootCertificateAuthorityRequest, error) {
+	resolver := &fieldResolver{certType: helper.RootCertificateType}
 
-func loadRootCertificateAuthority(configFilePath string, rootCert *configuration.RootCertificateAuthority, loadedRootCerts map[string]*configuration.RootCertificateAuthority) {
-	err := DetermineIfRootCAIsReference(configFilePath, rootCert)
+	rootCaName := resolver.resolve("name", rootCert.RootCertificateName)
+	resolver.certName = rootCaName
 
-	rootCaName := helper.ReplaceEnvironmentExpression(rootCert.RootCertificateName)
-	rootCaPassword := helper.ReplaceEnvironmentExpression(rootCert.RootCertificatePassword)
-	rootCaPfxPassword := helper.ReplaceEnvironmentExpression(rootCert.RootCertificatePfxPassword)
+	rootCaPassword := resolver.resolve("password", rootCert.RootCertificatePassword)
+	rootCaPfxPassword := resolver.resolve("pfxPassword", rootCert.RootCertificatePfxPassword)
 
-	// check if the root certificate is already loaded
-	if _, ok := loadedRootCerts[rootCaName]; ok {
-		return
-	}
+	// The output directory itself is resolved by the plan
+	resolver.resolve("outputDirectory", rootCert.OutputDirectory)
 
-	fmt.Printf("Loading root certificate: %s\n", rootCaName)
+	keyAlgorithmName := resolver.resolve("keyAlgorithm", rootCert.KeyAlgorithm)
+	renewalKeyPolicyName := resolver.resolve("renewalKeyPolicy", rootCert.RenewalKeyPolicy)
+	conf := resolver.resolveConfiguration(rootCert.Configuration).(*configuration.BaseCertificateConfiguration)
+	pfx := getPfxOptions(resolver, rootCert.Pfx)
+	keystores := getKeystoreOptions(resolver, rootCert.Keystores)
 
-	if err != nil {
-		panic(err)
-	}
+	errs := resolver.errs
 
 	// Check if the required fields are empty
 	if rootCaName == "" {
-		panic("The root certificate name cannot be empty")
+		errs = append(errs, helper.NewValidationError(helper.RootCertificateType, rootCaName, "name", "cannot be empty"))
+	} else if err := helper.CheckCertificateName(rootCaName); err != nil {
+		errs = append(errs, helper.NewValidationError(helper.RootCertificateType, rootCaName, "name", err.Error()))
 	}
 
 	if rootCaPassword == "" {
-		panic("The root certificate password cannot be empty")
+		errs = append(errs, helper.NewValidationError(helper.RootCertificateType, rootCaName, "password", "cannot be empty"))
+	} else if len(rootCaPassword) < 4 {
+		// Check if password is less than 4 characters
+		errs = append(errs, helper.NewValidationError(helper.RootCertificateType, rootCaName, "password", "cannot be less than 4 characters"))
 	}
 
 	if rootCaPfxPassword == "" {
-		panic("The root certificate PFX password cannot be empty")
-	}
-
-	// Check if password is less than 8 characters
-	if len(rootCaPassword) < 4 {
-		panic("The root certificate password cannot be less than 4 characters")
+		errs = append(errs, helper.NewValidationError(helper.RootCertificateType, rootCaName, "pfxPassword", "cannot be empty"))
+	} else if len(rootCaPfxPassword) < 4 {
+		// Check if password is less than 4 characters
+		errs = append(errs, helper.NewValidationError(helper.RootCertificateType, rootCaName, "pfxPassword", "cannot be less than 4 characters"))
 	}
 
-	// Check if password is less than 8 characters
-	if len(rootCaPfxPassword) < 4 {
-		panic("The root certificate PFX password cannot be less than 4 characters")
-	}
-
-	err = helper.CheckCertificateName(rootCaName)
+	// Validate the key size for the key algorithm, defaults to a 2048 bit RSA key
+	keyAlgorithm, keyLength, err := helper.CheckKeyAlgorithm(keyAlgorithmName, rootCert.PrivateKeySize)
 	if err != nil {
-		panic(err)
+		errs = append(errs, helper.NewValidationError(helper.RootCertificateType, rootCaName, getKeyAlgorithmField(keyAlgorithmName), err.Error()))
 	}
 
-	// Get string of if the cert should be inserted into the trust store
-	shouldInsertIntoTrustedStore := helper.Ternary(rootCert.ShouldInsertIntoTrustedStore, "YES", "NO").(string)
-	skipDhParam := helper.Ternary(rootCert.GenerateDHParameters, "NO", "YES").(string)
-
-	keyLength := rootCert.PrivateKeySize
-
-	// If the key length is not set, set it to 2048
-	if keyLength == 0 {
-		keyLength = 2048
-	}
-
-	// If the key length is not 1024, 2048 or 4096, error out
-	if keyLength != 1024 && keyLength != 2048 && keyLength != 4096 {
-		panic("The key length must be 1024, 2048 or 4096")
+	renewalKeyPolicy, err := helper.CheckRenewalKeyPolicy(renewalKeyPolicyName)
+	if err != nil {
+		errs = append(errs, helper.NewValidationError(helper.RootCertificateType, rootCaName, "renewalKeyPolicy", err.Error()))
 	}
 
 	expirationInDays := rootCert.ValidityPeriod
@@ -88,28 +71,67 @@ func loadRootCertificateAuthority(configFilePath string, rootCert *configuration
 
 	// If the expiration in days is less than 0, error out
 	if expirationInDays < 0 {
-		panic("The expiration in days must be greater than or equal to 0")
+		errs = append(errs, helper.NewValidationError(helper.RootCertificateType, rootCaName, "validityPeriod", "must be greater than or equal to 0"))
 	}
 
-	configuration.GenerateRootCertificateConfigurationFileIfNotExists(rootCert)
+	if rootCert.CrlValidityPeriod < 0 {
+		errs = append(errs, helper.NewValidationError(helper.RootCertificateType, rootCaName, "crlValidityPeriod", "must be greater than or equal to 0"))
+	}
 
-	rootCaPasswordFilename, err := helper.WritePasswordFile("root", rootCaName, "normal", rootCaPassword)
+	// Validate the subject and extensions of the certificate
+	errs = append(errs, nameValidationErrors(configuration.ValidateBaseCertificateConfiguration(conf), helper.RootCertificateType, rootCaName)...)
+
+	if len(errs) != 0 {
+		return nil, errs
+	}
+
+	return &backend.RootCertificateAuthorityRequest{
+		Name:                 rootCaName,
+		Password:             rootCaPassword,
+		PfxPassword:          rootCaPfxPassword,
+		Pfx:                  pfx,
+		Keystores:            keystores,
+		KeyAlgorithm:         keyAlgorithm,
+		KeySize:              keyLength,
+		ValidityPeriod:       expirationInDays,
+		RenewalKeyPolicy:     renewalKeyPolicy,
+		GenerateDHParameters: rootCert.GenerateDHParameters,
+		Configuration:        conf,
+	}, nil
+}
+
+func loadRootCertificateAuthority(rootCert *configuration.RootCertificateAuthority, request *backend.RootCertificateAuthorityRequest, generator backend.Backend, output io.Writer) error {
+	fmt.Fprintf(output, "Loading root certificate: %s\n", request.Name)
+
+	// The configuration file is generated from the resolved configuration
+	resolved := *rootCert
+	resolved.Configuration = request.Configuration
+	resolved.OverwriteExistingConfiguration = overwritesConfiguration(rootCert.OverwriteExistingConfiguration, generator)
+
+	err := configuration.GenerateRootCertificateConfigurationFileIfNotExists(request.OutputDirectory, request.Name, &resolved)
 	if err != nil {
-		panic(err)
+		return err
 	}
-	rootCaPfxPasswordFilename, err := helper.WritePasswordFile("root", rootCaName, "pfx", rootCaPfxPassword)
+
+	err = generator.GenerateRootCertificateAuthority(request)
 	if err != nil {
-		panic(err)
+		return fmt.Errorf("failed to generate the root certificate %s: %w", request.Name, err)
 	}
 
-	// Get command text
-	command := fmt.Sprintf("./ssl/generate-root-ca.sh %s @%s @%s %s %s YES %d %d", rootCaName, rootCaPasswordFilename, rootCaPfxPasswordFilename, shouldInsertIntoTrustedStore, skipDhParam, expirationInDays, keyLength)
+	err = backend.WriteKeystores(request.OutputDirectory, helper.RootCertificateType, request.Name, request.Password, request.Keystores)
+	if err != nil {
+		return fmt.Errorf("failed to write the keystores of the root certificate %s: %w", request.Name, err)
+	}
 
-	// Execute the command
-	helper.ExecuteRawCommand(command)
+	err = installIntoTrustStore(request.TrustStore, request.OutputDirectory, helper.RootCertificateType, request.Name, output)
+	if err != nil {
+		return fmt.Errorf("failed to install the root certificate %s into the trust store: %w", request.Name, err)
+	}
 
-	// Add the root certificate to the map
-	loadedRootCerts[rootCaName] = rootCert
+	err = importIntoNssDatabases(request.NssDatabases, request.OutputDirectory, helper.RootCertificateType, request.Name, output)
+	if err != nil {
+		return fmt.Errorf("failed to import the root certificate %s into the NSS databases: %w", request.Name, err)
+	}
 
-	defer helper.DeleteTmpPasswords([]string{rootCaPasswordFilename, rootCaPfxPasswordFilename})
+	return nil
 }
@@ -0,0 +1,114 @@
+package certificates
+
+import (
+	"crypto/x509"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+
+	"git.mfdlabs.local/petko/mfdlabs-ssl-go/pkg/backend"
+	"git.mfdlabs.local/petko/mfdlabs-ssl-go/pkg/configuration"
+	"git.mfdlabs.local/petko/mfdlabs-ssl-go/pkg/helper"
+)
+
+// Resolves and validates the certificate signing request entry and reads the request, every problem with it is reported.
+func getSigningRequest(configFilePath string, signingRequest *configuration.CertificateSigningRequest) (*backend.SigningRequest, error) {
+	resolver := &fieldResolver{certType: helper.SignedCertificateType}
+
+	signedCertName := resolver.resolve("name", signingRequest.SignedCertificateName)
+	resolver.certName = signedCertName
+
+	caChainName := resolver.resolve("caName", signingRequest.LastChainCertificateName)
+	caChainPassword := resolver.resolve("caPassword", signingRequest.LastChainCertificatePassword)
+	requestPath := resolver.resolve("request", signingRequest.RequestPath)
+
+	// The output directory itself is resolved by the plan
+	resolver.resolve("outputDirectory", signingRequest.OutputDirectory)
+
+	conf := resolver.resolveConfiguration(signingRequest.Configuration).(*configuration.LeafCertificateConfiguration)
+
+	errs := resolver.errs
+
+	// Check if the required fields are empty
+	if signedCertName == "" {
+		errs = append(errs, helper.NewValidationError(helper.SignedCertificateType, signedCertName, "name", "cannot be empty"))
+	} else if err := helper.CheckCertificateName(signedCertName); err != nil {
+		errs = append(errs, helper.NewValidationError(helper.SignedCertificateType, signedCertName, "name", err.Error()))
+	}
+
+	if caChainName == "" {
+		errs = append(errs, helper.NewValidationError(helper.SignedCertificateType, signedCertName, "caName", "cannot be empty"))
+	} else if err := helper.CheckCertificateName(caChainName); err != nil {
+		errs = append(errs, helper.NewValidationError(helper.SignedCertificateType, signedCertName, "caName", err.Error()))
+	}
+
+	if caChainPassword == "" {
+		errs = append(errs, helper.NewValidationError(helper.SignedCertificateType, signedCertName, "caPassword", "cannot be empty"))
+	} else if len(caChainPassword) < 4 {
+		// Check if password is less than 4 characters
+		errs = append(errs, helper.NewValidationError(helper.SignedCertificateType, signedCertName, "caPassword", "cannot be less than 4 characters"))
+	}
+
+	var csr *x509.CertificateRequest
+
+	if requestPath == "" {
+		errs = append(errs, helper.NewValidationError(helper.SignedCertificateType, signedCertName, "request", "cannot be empty"))
+	} else {
+		// Relative requests are relative to the configuration file, like $ref entries
+		if !filepath.IsAbs(requestPath) {
+			requestPath = filepath.Join(filepath.Dir(configFilePath), requestPath)
+		}
+
+		content, err := ioutil.ReadFile(requestPath)
+
+		if err == nil {
+			csr, err = backend.ParseCertificateRequest(content)
+		}
+
+		if err != nil {
+			errs = append(errs, helper.NewValidationError(helper.SignedCertificateType, signedCertName, "request", err.Error()))
+		}
+	}
+
+	expirationInDays := signingRequest.ValidityPeriod
+
+	// If the expiration in days is not set, set it to 4086
+	if expirationInDays == 0 {
+		expirationInDays = 4086
+	}
+
+	// If the expiration in days is less than 0, error out
+	if expirationInDays < 0 {
+		errs = append(errs, helper.NewValidationError(helper.SignedCertificateType, signedCertName, "validityPeriod", "must be greater than or equal to 0"))
+	}
+
+	// Validate the subject and extensions of the certificate
+	errs = append(errs, nameValidationErrors(configuration.ValidateSigningPolicy(conf), helper.SignedCertificateType, signedCertName)...)
+
+	if len(errs) != 0 {
+		return nil, errs
+	}
+
+	return &backend.SigningRequest{
+		Name:                            signedCertName,
+		RequestPath:                     requestPath,
+		CertificateRequest:              csr,
+		ChainName:                       caChainName,
+		ChainPassword:                   caChainPassword,
+		IsChainRootCertificateAuthority: signingRequest.IsLastChainCertificateRootCertificateAuthority,
+		ValidityPeriod:                  expirationInDays,
+		Configuration:                   conf,
+	}, nil
+}
+
+func loadSignedCertificate(request *backend.SigningRequest, generator backend.Backend, output io.Writer) error {
+	fmt.Fprintf(output, "Signing certificate request: %s\n", request.Name)
+
+	err := generator.SignCertificateRequest(request)
+	if err != nil {
+		return fmt.Errorf("failed to sign the certificate request %s: %w", request.Name, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,239 @@
+package certificates
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+
+	"git.mfdlabs.local/petko/mfdlabs-ssl-go/pkg/configuration"
+	"git.mfdlabs.local/petko/mfdlabs-ssl-go/pkg/helper"
+	"git.mfdlabs.local/petko/mfdlabs-ssl-go/pkg/truststore"
+)
+
+// Resolves the trust store of the configuration, every field can be a ${{ }} expression.
+func getTrustStoreOptions(conf *configuration.TrustStoreConfiguration) (*truststore.Options, error) {
+	if conf == nil {
+		conf = &configuration.TrustStoreConfiguration{}
+	}
+
+	var errs helper.Errors
+
+	resolve := func(field string, value string) string {
+		resolved, err := helper.ResolveExpressions(value)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("the %s of the trust store is not valid: %s", field, err))
+		}
+
+		return resolved
+	}
+
+	distribution := resolve("distribution", conf.Distribution)
+	root := resolve("root", conf.Root)
+	directory := resolve("directory", conf.Directory)
+
+	if len(errs) != 0 {
+		return nil, errs
+	}
+
+	options, err := truststore.GetOptions(distribution, root, directory, conf.SkipUpdate)
+	if err != nil {
+		return nil, fmt.Errorf("the trust store is not valid: %s", err)
+	}
+
+	return options, nil
+}
+
+// Sets the trust store of the certificate authorities that are inserted into it. Its distribution isn't detected yet,
+// see resolveTrustStore.
+func assignTrustStore(conf *configuration.TrustStoreConfiguration, nodes []*PlanNode) error {
+	var trusted []*PlanNode
+
+	for _, node := range nodes {
+		if (node.Type == helper.RootCertificateType && node.RootCertificateAuthority.ShouldInsertIntoTrustedStore) ||
+			(node.Type == helper.IntermediateCertificateType && node.IntermediateCertificateAuthority.ShouldInsertIntoTrustedStore) {
+			trusted = append(trusted, node)
+		}
+	}
+
+	if len(trusted) == 0 {
+		return nil
+	}
+
+	options, err := getTrustStoreOptions(conf)
+	if err != nil {
+		return err
+	}
+
+	for _, node := range trusted {
+		node.TrustStore = options
+	}
+
+	return nil
+}
+
+// Detects the distribution of the trust store of the plan. Only the runs that install certificate authorities need it,
+// so the other commands work on systems whose distribution isn't known.
+func resolveTrustStore(nodes []*PlanNode) error {
+	var resolved *truststore.Options
+
+	for _, node := range nodes {
+		if node.TrustStore == nil {
+			continue
+		}
+
+		if resolved == nil {
+			options, err := truststore.Resolve(node.TrustStore)
+			if err != nil {
+				return fmt.Errorf("the trust store is not valid: %s", err)
+			}
+
+			resolved = options
+		}
+
+		node.TrustStore = resolved
+	}
+
+	return nil
+}
+
+// Installs a generated certificate authority into the trust store and rebuilds it, nil options install nothing.
+func installIntoTrustStore(options *truststore.Options, outputDirectory string, certType string, certName string, output io.Writer) error {
+	if options == nil {
+		return nil
+	}
+
+	content, err := ioutil.ReadFile(helper.GetArtifactPath(outputDirectory, certType, certName, helper.CertificateExtension))
+	if err != nil {
+		return err
+	}
+
+	path, err := truststore.Install(options, helper.GetArtifactPrefix(certType)+certName, content)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(output, "Installed the %s certificate %s into the %s trust store: %s\n", certType, certName, options.Distribution, path)
+
+	return updateTrustStore(options, output)
+}
+
+// Installs a kept certificate authority that isn't in the trust store yet, e.g. because it was generated before it
+// was inserted into the trust store.
+func installMissingIntoTrustStore(options *truststore.Options, outputDirectory string, certType string, certName string, output io.Writer) error {
+	if options == nil {
+		return nil
+	}
+
+	if _, err := os.Stat(options.GetPath(helper.GetArtifactPrefix(certType) + certName)); !os.IsNotExist(err) {
+		return err
+	}
+
+	return installIntoTrustStore(options, outputDirectory, certType, certName, output)
+}
+
+func updateTrustStore(options *truststore.Options, output io.Writer) error {
+	if !options.Updates() {
+		fmt.Fprintf(output, "The trust store is not rebuilt, run %s in %s to rebuild it\n", options.UpdateCommand(), options.Root)
+		return nil
+	}
+
+	return truststore.Update(options)
+}
+
+type UntrustOptions struct {
+	// The names of the certificate authorities to remove, every one of the configuration if empty.
+	Names []string
+
+	// Removes every certificate ssl-go installed into the trust store, even the ones no longer in the configuration.
+	All bool
+}
+
+// Removes the certificate authorities of the configuration from the trust store of the configuration, and rebuilds
+// it. Only the files ssl-go installed are removed, whether or not the certificate authorities are still inserted
+// into the trust store by the configuration.
+func Untrust(configFilePath string, conf *configuration.SslConfiguration, options *UntrustOptions, output io.Writer) error {
+	trustStore, err := getTrustStoreOptions(conf.TrustStore)
+	if err != nil {
+		return err
+	}
+
+	trustStore, err = truststore.Resolve(trustStore)
+	if err != nil {
+		return fmt.Errorf("the trust store is not valid: %s", err)
+	}
+
+	var installed []string
+
+	if options.All {
+		installed, err = truststore.Installed(trustStore)
+		if err != nil {
+			return err
+		}
+	} else {
+		installed, err = getUntrustedNames(configFilePath, conf, options.Names)
+		if err != nil {
+			return err
+		}
+	}
+
+	removed := 0
+
+	for _, name := range installed {
+		ok, err := truststore.Uninstall(trustStore, name)
+		if err != nil {
+			return err
+		}
+
+		if !ok {
+			fmt.Fprintf(output, "Skipping %s, it is not installed\n", name)
+			continue
+		}
+
+		removed++
+		fmt.Fprintf(output, "Removed %s from the %s trust store: %s\n", name, trustStore.Distribution, trustStore.GetPath(name))
+	}
+
+	if removed == 0 {
+		return nil
+	}
+
+	return updateTrustStore(trustStore, output)
+}
+
+// Gets the artifact names of the certificate authorities of the configuration with the names, every one if there
+// are no names.
+func getUntrustedNames(configFilePath string, conf *configuration.SslConfiguration, names []string) ([]string, error) {
+	plan, err := BuildPlan(configFilePath, conf)
+	if err != nil {
+		return nil, err
+	}
+
+	var errs helper.Errors
+	var artifactNames []string
+
+	found := make(map[string]bool)
+
+	for _, node := range plan.Nodes {
+		if node.Type != helper.RootCertificateType && node.Type != helper.IntermediateCertificateType {
+			continue
+		}
+
+		if len(names) == 0 || contains(names, node.Name) {
+			artifactNames = append(artifactNames, helper.GetArtifactPrefix(node.Type)+node.Name)
+			found[node.Name] = true
+		}
+	}
+
+	for _, name := range names {
+		if !found[name] {
+			errs = append(errs, fmt.Errorf("the configuration has no certificate authority named %s", name))
+		}
+	}
+
+	if len(errs) != 0 {
+		return nil, errs
+	}
+
+	return artifactNames, nil
+}
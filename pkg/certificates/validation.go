@@ -0,0 +1,213 @@
+package certificates
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"git.mfdlabs.local/petko/mfdlabs-ssl-go/pkg/backend"
+	"git.mfdlabs.local/petko/mfdlabs-ssl-go/pkg/configuration"
+	"git.mfdlabs.local/petko/mfdlabs-ssl-go/pkg/helper"
+)
+
+// Resolves the ${{ }} expressions of the fields of a certificate, recording the fields that can't be resolved.
+type fieldResolver struct {
+	certType string
+	certName string
+	errs     helper.Errors
+}
+
+func (resolver *fieldResolver) resolve(field string, value string) string {
+	resolved, err := helper.ResolveExpressions(value)
+
+	if err != nil {
+		resolver.errs = append(resolver.errs, helper.NewValidationError(resolver.certType, resolver.certName, field, err.Error()))
+	}
+
+	return resolved
+}
+
+// Resolves the expressions of every string of the configuration of a certificate, e.g. its common name and subject
+// alternative names, into a copy of it. The copy has the type of the configuration, nil stays nil.
+func (resolver *fieldResolver) resolveConfiguration(conf interface{}) interface{} {
+	return resolver.resolveValue("config", reflect.ValueOf(conf)).Interface()
+}
+
+func (resolver *fieldResolver) resolveValue(field string, value reflect.Value) reflect.Value {
+	switch value.Kind() {
+	case reflect.Ptr:
+		if value.IsNil() {
+			return value
+		}
+
+		copied := reflect.New(value.Type().Elem())
+		copied.Elem().Set(resolver.resolveValue(field, value.Elem()))
+
+		return copied
+	case reflect.Struct:
+		copied := reflect.New(value.Type()).Elem()
+
+		for i := 0; i < value.NumField(); i++ {
+			// The fields are named like the validation errors name them, embedded structs add nothing
+			name := strings.Split(value.Type().Field(i).Tag.Get("json"), ",")[0]
+			if name != "" {
+				name = field + "." + name
+			} else {
+				name = field
+			}
+
+			copied.Field(i).Set(resolver.resolveValue(name, value.Field(i)))
+		}
+
+		return copied
+	case reflect.Slice:
+		if value.IsNil() {
+			return value
+		}
+
+		copied := reflect.MakeSlice(value.Type(), value.Len(), value.Len())
+
+		for i := 0; i < value.Len(); i++ {
+			copied.Index(i).Set(resolver.resolveValue(field, value.Index(i)))
+		}
+
+		return copied
+	case reflect.String:
+		return reflect.ValueOf(resolver.resolve(field, value.String())).Convert(value.Type())
+	}
+
+	return value
+}
+
+// Validates the whole configuration without generating anything: the certificate hierarchy, the $ref entries,
+// the ${{ }} expressions and every field of every certificate. The returned error is a helper.Errors where
+// the validation errors carry their path in the configuration file.
+func Validate(configFilePath string, conf *configuration.SslConfiguration) error {
+	return validate(configFilePath, conf, conf.Backend)
+}
+
+// Validates the configuration for the named backend, which may differ from the backend of the configuration.
+func validate(configFilePath string, conf *configuration.SslConfiguration, backendName string) error {
+	var errs helper.Errors
+
+	err := backend.CheckBackendName(backendName)
+	if err != nil {
+		errs = append(errs, err)
+	}
+
+	// The plan holds every certificate that could be read, even if the hierarchy is not valid
+	plan, err := BuildPlan(configFilePath, conf)
+	errs = helper.AppendError(errs, err)
+
+	// Passwords that are not in the sink yet are only generated for the validation
+	passwords, err := newPasswordSource(conf, plan, false)
+	errs = helper.AppendError(errs, err)
+
+	isYaml := configuration.IsYamlFile(configFilePath)
+
+	for _, node := range plan.Nodes {
+		request, err := getRequest(configFilePath, node, passwords)
+		if err != nil {
+			errs = append(errs, locateValidationErrors(err, node, isYaml)...)
+			continue
+		}
+
+		err = checkBackendSupport(backendName, node, request)
+		if err != nil {
+			errs = append(errs, locateValidationErrors(err, node, isYaml)...)
+		}
+	}
+
+	if len(errs) != 0 {
+		return errs
+	}
+
+	return nil
+}
+
+// Checks that the backend can generate what the certificate asks for.
+func checkBackendSupport(backendName string, node *PlanNode, request interface{}) error {
+	var keyAlgorithm, renewalKeyPolicy string
+	var pfx *backend.PfxOptions
+
+	switch request := request.(type) {
+	case *backend.RootCertificateAuthorityRequest:
+		keyAlgorithm = request.KeyAlgorithm
+		renewalKeyPolicy = request.RenewalKeyPolicy
+		pfx = request.Pfx
+	case *backend.IntermediateCertificateAuthorityRequest:
+		keyAlgorithm = request.KeyAlgorithm
+		renewalKeyPolicy = request.RenewalKeyPolicy
+		pfx = request.Pfx
+	case *backend.LeafCertificateRequest:
+		keyAlgorithm = request.KeyAlgorithm
+		renewalKeyPolicy = request.RenewalKeyPolicy
+		pfx = request.Pfx
+	}
+
+	var errs helper.Errors
+
+	if node.Type == helper.SignedCertificateType {
+		err := backend.CheckSigningSupport(backendName)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("the %s certificate %s cannot be signed: %s", node.Type, node.Name, err))
+		}
+	}
+
+	err := backend.CheckKeyAlgorithmSupport(backendName, keyAlgorithm)
+	if err != nil {
+		errs = append(errs, helper.NewValidationError(node.Type, node.Name, "keyAlgorithm", err.Error()))
+	}
+
+	if renewalKeyPolicy == helper.ReuseRenewalKeyPolicy {
+		err = backend.CheckKeyReuseSupport(backendName)
+		if err != nil {
+			errs = append(errs, helper.NewValidationError(node.Type, node.Name, "renewalKeyPolicy", err.Error()))
+		}
+	}
+
+	err = backend.CheckPfxSupport(backendName, pfx)
+	if err != nil {
+		errs = append(errs, helper.NewValidationError(node.Type, node.Name, "pfx", err.Error()))
+	}
+
+	if len(errs) != 0 {
+		return errs
+	}
+
+	return nil
+}
+
+// Gets the field to blame when the key algorithm and size don't match, the size unless the algorithm itself is unknown.
+func getKeyAlgorithmField(keyAlgorithm string) string {
+	if _, _, err := helper.CheckKeyAlgorithm(keyAlgorithm, 0); err != nil {
+		return "keyAlgorithm"
+	}
+
+	return "privateKeySize"
+}
+
+// Fills in the certificate of validation errors that were raised without knowing it.
+func nameValidationErrors(errs helper.Errors, certType string, certName string) helper.Errors {
+	for _, err := range errs {
+		if validationErr, ok := err.(*helper.ValidationError); ok {
+			validationErr.CertificateType = certType
+			validationErr.CertificateName = certName
+		}
+	}
+
+	return errs
+}
+
+// Fills in the path in the configuration file of the validation errors of the node.
+func locateValidationErrors(err error, node *PlanNode, isYaml bool) helper.Errors {
+	errs := helper.AppendError(nil, err)
+
+	for _, err := range errs {
+		if validationErr, ok := err.(*helper.ValidationError); ok && validationErr.Path == "" {
+			validationErr.Path = configuration.GetFieldPath(node.Type, node.Index, validationErr.Field, isYaml)
+		}
+	}
+
+	return errs
+}
@@ -0,0 +1,68 @@
+package certificates
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"git.mfdlabs.local/petko/mfdlabs-ssl-go/pkg/configuration"
+	"git.mfdlabs.local/petko/mfdlabs-ssl-go/pkg/helper"
+)
+
+func TestFieldResolver(t *testing.T) {
+	os.Setenv("SSL_GO_TEST_DOMAIN", "example.com")
+	defer os.Unsetenv("SSL_GO_TEST_DOMAIN")
+
+	conf := &configuration.LeafCertificateConfiguration{
+		BaseCertificateConfiguration: configuration.BaseCertificateConfiguration{
+			CommonName:   "api.${{ env.SSL_GO_TEST_DOMAIN }}",
+			Organization: "${{ env.SSL_GO_TEST_UNSET || 'ssl-go' }}",
+			OcspServers:  []string{"http://ocsp.${{ env.SSL_GO_TEST_DOMAIN", "$${{ kept }}"},
+		},
+		SubjectAlternativeName: &configuration.SubjectAlternativeNameConfiguration{
+			DNSNames: []string{"${{ env.SSL_GO_TEST_DOMAIN }}", "*.${{ nope.NAME }}"},
+		},
+		MustStaple: true,
+	}
+
+	resolver := &fieldResolver{certType: helper.LeafCertificateType, certName: "api"}
+	resolved := resolver.resolveConfiguration(conf).(*configuration.LeafCertificateConfiguration)
+
+	if resolved == conf || resolved.SubjectAlternativeName == conf.SubjectAlternativeName {
+		t.Fatal("expected the configuration to be copied")
+	}
+
+	if conf.CommonName != "api.${{ env.SSL_GO_TEST_DOMAIN }}" || conf.SubjectAlternativeName.DNSNames[0] != "${{ env.SSL_GO_TEST_DOMAIN }}" {
+		t.Fatal("expected the configuration to be left as it is")
+	}
+
+	if resolved.CommonName != "api.example.com" || resolved.Organization != "ssl-go" || !resolved.MustStaple {
+		t.Fatalf("unexpected resolved configuration: %+v", resolved.BaseCertificateConfiguration)
+	}
+
+	if resolved.OcspServers[1] != "${{ kept }}" || resolved.SubjectAlternativeName.DNSNames[0] != "example.com" {
+		t.Fatalf("unexpected resolved lists: %v, %v", resolved.OcspServers, resolved.SubjectAlternativeName.DNSNames)
+	}
+
+	// The errors are named after the json fields
+	var fields []string
+	for _, err := range resolver.errs {
+		validationErr := err.(*helper.ValidationError)
+
+		if validationErr.CertificateType != helper.LeafCertificateType || validationErr.CertificateName != "api" {
+			t.Fatalf("expected the error to name the certificate, got %s", validationErr)
+		}
+
+		fields = append(fields, validationErr.Field)
+	}
+
+	if strings.Join(fields, ",") != "config.ocspServers,config.subjectAlternativeName.dnsNames" {
+		t.Fatalf("unexpected error fields: %v", fields)
+	}
+
+	var nilConf *configuration.BaseCertificateConfiguration
+
+	if resolved := resolver.resolveConfiguration(nilConf).(*configuration.BaseCertificateConfiguration); resolved != nil {
+		t.Fatal("expected a nil configuration to stay nil")
+	}
+}
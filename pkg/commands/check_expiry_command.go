@@ -0,0 +1,146 @@
+package commands
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"git.mfdlabs.local/petko/mfdlabs-ssl-go/pkg"
+	"git.mfdlabs.local/petko/mfdlabs-ssl-go/pkg/certificates"
+)
+
+// The exit codes of check-expiry, the most severe status of the certificates wins. 1 and 2 are the usual error and
+// usage exit codes.
+const (
+	missingExitCode  = 3
+	expiringExitCode = 4
+	expiredExitCode  = 5
+)
+
+var checkExpiryCommand = &Command{
+	Name:        "check-expiry",
+	Usage:       "[options] <configuration file>",
+	Description: "Reports the remaining lifetime of every certificate of the configuration. Exits with 3 if a certificate is missing, 4 if one expires within -within and 5 if one expired.",
+}
+
+func init() {
+	checkExpiryCommand.Run = runCheckExpiry
+	register(checkExpiryCommand)
+}
+
+func runCheckExpiry(args []string) int {
+	flags := flag.NewFlagSet(checkExpiryCommand.Name, flag.ExitOnError)
+	within := flags.String("within", "30d", "Certificates that expire within this long are expiring, in days like 30d or as a duration like 12h.")
+	outputDirectory := flags.String("outputDirectory", "", "The directory the certificates are written to. Overrides the output directory of the configuration file, defaults to ./bin.")
+	format := flags.String("format", tableFormat, "The format of the report, either table or json.")
+
+	flags.Usage = func() {
+		printUsage(checkExpiryCommand, flags.PrintDefaults)
+	}
+
+	flags.Parse(args)
+
+	if flags.NArg() != 1 || (*format != tableFormat && *format != jsonFormat) {
+		flags.Usage()
+		return 2
+	}
+
+	threshold, err := parseDays(*within)
+
+	if err != nil {
+		PrintErrors(fmt.Errorf("invalid -within %s: %s", *within, err))
+		return 2
+	}
+
+	configurationFilePath := flags.Arg(0)
+
+	// Load the configuration file
+	conf, err := pkg.LoadConfiguration(configurationFilePath)
+
+	if err != nil {
+		PrintErrors(err)
+		return 1
+	}
+
+	if *outputDirectory != "" {
+		conf.OutputDirectory = *outputDirectory
+	}
+
+	report, err := certificates.CheckExpiry(configurationFilePath, conf, threshold)
+
+	if err != nil {
+		PrintErrors(err)
+		return 1
+	}
+
+	if *format == jsonFormat {
+		// Always print an array, even for a configuration without certificates
+		if report == nil {
+			report = []*certificates.CertificateExpiry{}
+		}
+
+		err = printJSON(report)
+		if err != nil {
+			PrintErrors(err)
+			return 1
+		}
+	} else {
+		writer := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(writer, "TYPE\tNAME\tSTATUS\tNOT AFTER\tREMAINING")
+
+		for _, expiry := range report {
+			notAfter, remaining := "-", "-"
+
+			if expiry.NotAfter != nil {
+				notAfter = expiry.NotAfter.Format(time.RFC3339)
+				remaining = fmt.Sprintf("%d days", *expiry.RemainingDays)
+			}
+
+			fmt.Fprintf(writer, "%s\t%s\t%s\t%s\t%s\n", expiry.Type, expiry.Name, expiry.Status, notAfter, remaining)
+		}
+
+		writer.Flush()
+	}
+
+	exitCode := 0
+
+	for _, expiry := range report {
+		switch {
+		case expiry.Status == certificates.ExpiredExpiryStatus:
+			exitCode = expiredExitCode
+		case expiry.Status == certificates.ExpiringExpiryStatus && exitCode < expiringExitCode:
+			exitCode = expiringExitCode
+		case expiry.Status == certificates.MissingExpiryStatus && exitCode < missingExitCode:
+			exitCode = missingExitCode
+		}
+	}
+
+	return exitCode
+}
+
+// Parses a number of days like 30d, or a duration like 12h.
+func parseDays(value string) (time.Duration, error) {
+	if strings.HasSuffix(value, "d") {
+		days, err := strconv.Atoi(strings.TrimSuffix(value, "d"))
+		if err != nil || days < 0 {
+			return 0, fmt.Errorf("the number of days must be a whole number that is not negative")
+		}
+
+		return time.Duration(days) * 24 * time.Hour, nil
+	}
+
+	duration, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, err
+	}
+
+	if duration < 0 {
+		return 0, fmt.Errorf("the duration must not be negative")
+	}
+
+	return duration, nil
+}
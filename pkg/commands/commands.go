@@ -0,0 +1,89 @@
+// Package commands contains the subcommands of ssl-go, e.g. ssl-go validate <configuration file>.
+package commands
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"git.mfdlabs.local/petko/mfdlabs-ssl-go/pkg/helper"
+)
+
+// A Command is a subcommand of ssl-go.
+type Command struct {
+	// The name of the command, the first argument of ssl-go.
+	Name string
+
+	// The arguments of the command, e.g. [options] <configuration file>
+	Usage string
+
+	// A one line description of the command.
+	Description string
+
+	// Runs the command with the arguments after its name, returning the exit code.
+	Run func(args []string) int
+}
+
+var registeredCommands []*Command
+
+func register(command *Command) {
+	registeredCommands = append(registeredCommands, command)
+}
+
+// Gets the command with the name, nil if there is none.
+func Get(name string) *Command {
+	for _, command := range registeredCommands {
+		if command.Name == name {
+			return command
+		}
+	}
+
+	return nil
+}
+
+// Gets every command, in the order they were registered.
+func All() []*Command {
+	return registeredCommands
+}
+
+// Prints every error to stderr, validation errors are prefixed with their path in the configuration file.
+func PrintErrors(err error) {
+	errs, ok := err.(helper.Errors)
+
+	if !ok {
+		fmt.Fprintf(os.Stderr, "error: %s\n", err)
+		return
+	}
+
+	fmt.Fprintf(os.Stderr, "%d problem(s) found:\n", len(errs))
+
+	for _, err := range errs {
+		if validationErr, ok := err.(*helper.ValidationError); ok && validationErr.Path != "" {
+			fmt.Fprintf(os.Stderr, "  - %s: %s\n", validationErr.Location(), validationErr)
+			continue
+		}
+
+		fmt.Fprintf(os.Stderr, "  - %s\n", err)
+	}
+}
+
+// Prints every error and exits with a non-zero exit code.
+func ExitWithError(err error) {
+	PrintErrors(err)
+	os.Exit(1)
+}
+
+// Prints the usage of the command and its options.
+func printUsage(command *Command, printDefaults func()) {
+	fmt.Printf("ssl-go %s %s\n\n%s\n\n", command.Name, command.Usage, command.Description)
+	printDefaults()
+}
+
+// Prints the value as indented JSON to stdout, the invocations and paths are kept readable.
+func printJSON(value interface{}) error {
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	encoder.SetEscapeHTML(false)
+
+	return encoder.Encode(value)
+}
@@ -0,0 +1,59 @@
+package commands
+
+import (
+	"flag"
+
+	"git.mfdlabs.local/petko/mfdlabs-ssl-go/pkg"
+	"git.mfdlabs.local/petko/mfdlabs-ssl-go/pkg/certificates"
+)
+
+var crlCommand = &Command{
+	Name:        "crl",
+	Usage:       "[options] <configuration file> [ca name...]",
+	Description: "Builds the CRLs of the certificate authorities of the configuration, as DER (.crl) and PEM (.crl.pem), from the certificates they revoked.",
+}
+
+func init() {
+	crlCommand.Run = runCrl
+	register(crlCommand)
+}
+
+func runCrl(args []string) int {
+	flags := flag.NewFlagSet(crlCommand.Name, flag.ExitOnError)
+	nextUpdate := flags.Int("nextUpdate", 0, "The number of days the CRLs are valid for. Overrides the CRL validity period of the certificate authorities, defaults to 30.")
+	outputDirectory := flags.String("outputDirectory", "", "The directory the certificates are written to. Overrides the output directory of the configuration file, defaults to ./bin.")
+
+	flags.Usage = func() {
+		printUsage(crlCommand, flags.PrintDefaults)
+	}
+
+	flags.Parse(args)
+
+	if flags.NArg() < 1 {
+		flags.Usage()
+		return 2
+	}
+
+	configurationFilePath := flags.Arg(0)
+
+	// Load the configuration file
+	conf, err := pkg.LoadConfiguration(configurationFilePath)
+
+	if err != nil {
+		PrintErrors(err)
+		return 1
+	}
+
+	if *outputDirectory != "" {
+		conf.OutputDirectory = *outputDirectory
+	}
+
+	err = certificates.BuildCRLs(configurationFilePath, conf, flags.Args()[1:], *nextUpdate)
+
+	if err != nil {
+		PrintErrors(err)
+		return 1
+	}
+
+	return 0
+}
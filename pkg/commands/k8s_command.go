@@ -0,0 +1,120 @@
+package commands
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"git.mfdlabs.local/petko/mfdlabs-ssl-go/pkg"
+	"git.mfdlabs.local/petko/mfdlabs-ssl-go/pkg/certificates"
+)
+
+var k8sCommand = &Command{
+	Name:        "k8s",
+	Usage:       "[options] <configuration file>",
+	Description: "Exports a kubernetes.io/tls Secret for every leaf certificate of the configuration and, if enabled, a ConfigMap trust bundle for every certificate authority, as YAML that can be piped to kubectl apply -f -.",
+}
+
+func init() {
+	k8sCommand.Run = runK8s
+	register(k8sCommand)
+}
+
+func runK8s(args []string) int {
+	flags := flag.NewFlagSet(k8sCommand.Name, flag.ExitOnError)
+	namespace := flags.String("namespace", "", "The namespace of the manifests. Overrides the namespace of the configuration file, defaults to default.")
+	trustBundles := flags.Bool("trustBundles", false, "Export a ConfigMap trust bundle for every certificate authority, even if the configuration file doesn't.")
+	output := flags.String("output", "", "Where the manifests are written: - for stdout, a directory (existing or ending with a /) for one file per manifest, or a file for all of them. Defaults to stdout.")
+	outputDirectory := flags.String("outputDirectory", "", "The directory the certificates are written to. Overrides the output directory of the configuration file, defaults to ./bin.")
+
+	flags.Usage = func() {
+		printUsage(k8sCommand, flags.PrintDefaults)
+	}
+
+	flags.Parse(args)
+
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return 2
+	}
+
+	configurationFilePath := flags.Arg(0)
+
+	// Load the configuration file
+	conf, err := pkg.LoadConfiguration(configurationFilePath)
+
+	if err != nil {
+		PrintErrors(err)
+		return 1
+	}
+
+	if *outputDirectory != "" {
+		conf.OutputDirectory = *outputDirectory
+	}
+
+	manifests, err := certificates.ExportKubernetesManifests(configurationFilePath, conf, &certificates.KubernetesExportOptions{
+		Namespace:    *namespace,
+		TrustBundles: *trustBundles,
+	})
+
+	if err != nil {
+		PrintErrors(err)
+		return 1
+	}
+
+	if len(manifests) == 0 {
+		fmt.Fprintln(os.Stderr, "the configuration has no certificates to export")
+		return 0
+	}
+
+	err = writeManifests(manifests, *output)
+
+	if err != nil {
+		PrintErrors(err)
+		return 1
+	}
+
+	return 0
+}
+
+// Writes the manifests to stdout, a directory or a file. The manifests hold private keys, so only the owner can read
+// the files.
+func writeManifests(manifests []*certificates.KubernetesManifest, output string) error {
+	if info, err := os.Stat(output); strings.HasSuffix(output, "/") || (err == nil && info.IsDir()) {
+		err := os.MkdirAll(output, 0755)
+		if err != nil {
+			return err
+		}
+
+		for _, manifest := range manifests {
+			path := filepath.Join(output, fmt.Sprintf("%s-%s.yaml", strings.ToLower(manifest.Kind), manifest.Name))
+
+			err = ioutil.WriteFile(path, manifest.Content, 0600)
+			if err != nil {
+				return err
+			}
+
+			fmt.Fprintf(os.Stderr, "wrote the %s %s/%s to %s\n", manifest.Kind, manifest.Namespace, manifest.Name, path)
+		}
+
+		return nil
+	}
+
+	var documents bytes.Buffer
+
+	for _, manifest := range manifests {
+		documents.WriteString("---\n")
+		documents.Write(manifest.Content)
+	}
+
+	if output == "" || output == "-" {
+		_, err := os.Stdout.Write(documents.Bytes())
+		return err
+	}
+
+	return ioutil.WriteFile(output, documents.Bytes(), 0600)
+}
@@ -0,0 +1,107 @@
+package commands
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"git.mfdlabs.local/petko/mfdlabs-ssl-go/pkg"
+	"git.mfdlabs.local/petko/mfdlabs-ssl-go/pkg/certificates"
+	"git.mfdlabs.local/petko/mfdlabs-ssl-go/pkg/inventory"
+)
+
+var listCommand = &Command{
+	Name:        "list",
+	Usage:       "[options] <configuration file>",
+	Description: "Lists the certificates recorded in the inventory of the output directory, with their issuer, serial number and validity.",
+}
+
+func init() {
+	listCommand.Run = runList
+	register(listCommand)
+}
+
+func runList(args []string) int {
+	flags := flag.NewFlagSet(listCommand.Name, flag.ExitOnError)
+	certType := flags.String("type", "", "Only list the certificates of the type: root, intermediate, leaf or signed.")
+	issuer := flags.String("issuer", "", "Only list the certificates issued by the certificate authority with the name.")
+	all := flags.Bool("all", false, "List every issuance of the certificates instead of only the latest one.")
+	outputDirectory := flags.String("outputDirectory", "", "The directory the certificates are written to. Overrides the output directory of the configuration file, defaults to ./bin.")
+	format := flags.String("format", tableFormat, "The format of the list, either table or json.")
+
+	flags.Usage = func() {
+		printUsage(listCommand, flags.PrintDefaults)
+	}
+
+	flags.Parse(args)
+
+	if flags.NArg() != 1 || (*format != tableFormat && *format != jsonFormat) {
+		flags.Usage()
+		return 2
+	}
+
+	// Load the configuration file
+	conf, err := pkg.LoadConfiguration(flags.Arg(0))
+
+	if err != nil {
+		PrintErrors(err)
+		return 1
+	}
+
+	if *outputDirectory != "" {
+		conf.OutputDirectory = *outputDirectory
+	}
+
+	records, err := certificates.LoadInventory(conf)
+
+	if err != nil {
+		PrintErrors(err)
+		return 1
+	}
+
+	candidates := records.Latest()
+	if *all {
+		candidates = records.Certificates
+	}
+
+	// Always print an array, even when nothing matches
+	listed := []*inventory.Record{}
+
+	for _, record := range candidates {
+		if (*certType == "" || record.Type == *certType) && (*issuer == "" || record.ParentName == *issuer) {
+			listed = append(listed, record)
+		}
+	}
+
+	if *format == jsonFormat {
+		err = printJSON(listed)
+		if err != nil {
+			PrintErrors(err)
+			return 1
+		}
+
+		return 0
+	}
+
+	writer := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(writer, "TYPE\tNAME\tISSUER\tSERIAL\tNOT BEFORE\tNOT AFTER")
+
+	for _, record := range listed {
+		fmt.Fprintf(writer, "%s\t%s\t%s\t%s\t%s\t%s\n", record.Type, record.Name, formatIssuer(record), record.SerialNumber, record.NotBefore.Format(time.RFC3339), record.NotAfter.Format(time.RFC3339))
+	}
+
+	writer.Flush()
+
+	return 0
+}
+
+// Formats the issuer of the record like the chain of the plan, - for root certificate authorities.
+func formatIssuer(record *inventory.Record) string {
+	if record.ParentName == "" {
+		return "-"
+	}
+
+	return fmt.Sprintf("%s (%s)", record.ParentName, record.ParentType)
+}
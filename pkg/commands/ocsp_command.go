@@ -0,0 +1,91 @@
+package commands
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"time"
+
+	"git.mfdlabs.local/petko/mfdlabs-ssl-go/pkg"
+	"git.mfdlabs.local/petko/mfdlabs-ssl-go/pkg/certificates"
+)
+
+var ocspCommand = &Command{
+	Name:        "ocsp",
+	Usage:       "serve [options] <configuration file> [ca name...]",
+	Description: "Runs an OCSP responder over HTTP for the certificate authorities of the configuration, answering from the certificates they revoked.",
+}
+
+func init() {
+	ocspCommand.Run = runOcsp
+	register(ocspCommand)
+}
+
+func runOcsp(args []string) int {
+	flags := flag.NewFlagSet(ocspCommand.Name+" serve", flag.ExitOnError)
+	address := flags.String("address", "127.0.0.1:8080", "The address the responder listens on, the ocsp_servers of the certificates should point to it.")
+	delegate := flags.Bool("delegate", false, "Sign the responses with a delegated OCSP responder certificate issued by each certificate authority, instead of with the certificate authorities themselves.")
+	validityPeriod := flags.Duration("validityPeriod", time.Hour, "How long the responses can be cached for, their nextUpdate.")
+	outputDirectory := flags.String("outputDirectory", "", "The directory the certificates are written to. Overrides the output directory of the configuration file, defaults to ./bin.")
+
+	flags.Usage = func() {
+		printUsage(ocspCommand, flags.PrintDefaults)
+	}
+
+	// serve is the only subcommand for now
+	if len(args) == 0 || args[0] != "serve" {
+		flags.Usage()
+		return 2
+	}
+
+	flags.Parse(args[1:])
+
+	if flags.NArg() < 1 {
+		flags.Usage()
+		return 2
+	}
+
+	if *validityPeriod <= 0 {
+		PrintErrors(fmt.Errorf("the validity period of the responses must be greater than 0"))
+		return 2
+	}
+
+	configurationFilePath := flags.Arg(0)
+
+	// Load the configuration file
+	conf, err := pkg.LoadConfiguration(configurationFilePath)
+
+	if err != nil {
+		PrintErrors(err)
+		return 1
+	}
+
+	if *outputDirectory != "" {
+		conf.OutputDirectory = *outputDirectory
+	}
+
+	responder, err := certificates.NewOCSPResponder(configurationFilePath, conf, flags.Args()[1:], &certificates.OCSPResponderOptions{
+		Delegate:       *delegate,
+		ValidityPeriod: *validityPeriod,
+	})
+
+	if err != nil {
+		PrintErrors(err)
+		return 1
+	}
+
+	responder.Log = log.New(os.Stdout, "", log.LstdFlags)
+
+	fmt.Printf("Listening on http://%s\n", *address)
+
+	err = http.ListenAndServe(*address, responder)
+
+	if err != nil {
+		PrintErrors(err)
+		return 1
+	}
+
+	return 0
+}
@@ -0,0 +1,123 @@
+package commands
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"git.mfdlabs.local/petko/mfdlabs-ssl-go/pkg"
+	"git.mfdlabs.local/petko/mfdlabs-ssl-go/pkg/backend"
+	"git.mfdlabs.local/petko/mfdlabs-ssl-go/pkg/certificates"
+)
+
+// The formats the plan can be printed in.
+const (
+	tableFormat = "table"
+	jsonFormat  = "json"
+)
+
+var planCommand = &Command{
+	Name:        "plan",
+	Usage:       "[options] <configuration file>",
+	Description: "Shows whether every certificate of the configuration would be created, recreated or skipped, what happens to its .conf file, its issuers and the backend invocation that generates it, without writing anything to disk.",
+}
+
+func init() {
+	planCommand.Run = runPlan
+	register(planCommand)
+}
+
+func runPlan(args []string) int {
+	flags := flag.NewFlagSet(planCommand.Name, flag.ExitOnError)
+	backendName := flags.String("backend", "", "The backend the certificates would be generated with, either native or script. Overrides the backend of the configuration file.")
+	outputDirectory := flags.String("outputDirectory", "", "The directory the certificates would be written to. Overrides the output directory of the configuration file, defaults to ./bin.")
+	format := flags.String("format", tableFormat, "The format of the plan, either table or json.")
+
+	flags.Usage = func() {
+		printUsage(planCommand, flags.PrintDefaults)
+	}
+
+	flags.Parse(args)
+
+	if flags.NArg() != 1 || (*format != tableFormat && *format != jsonFormat) {
+		flags.Usage()
+		return 2
+	}
+
+	configurationFilePath := flags.Arg(0)
+
+	// Load the configuration file
+	conf, err := pkg.LoadConfiguration(configurationFilePath)
+
+	if err != nil {
+		PrintErrors(err)
+		return 1
+	}
+
+	// The command line takes precedence over the configuration file
+	if *backendName != "" {
+		conf.Backend = *backendName
+	}
+
+	if *outputDirectory != "" {
+		conf.OutputDirectory = *outputDirectory
+	}
+
+	generator, err := backend.GetBackend(conf.Backend)
+
+	if err != nil {
+		PrintErrors(err)
+		return 1
+	}
+
+	planned, err := certificates.DescribePlan(configurationFilePath, conf, generator)
+
+	if err != nil {
+		PrintErrors(err)
+		return 1
+	}
+
+	if *format == jsonFormat {
+		err = printJSON(planned)
+		if err != nil {
+			PrintErrors(err)
+			return 1
+		}
+
+		return 0
+	}
+
+	writer := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(writer, "TYPE\tNAME\tACTION\tCONFIG\tCHAIN\tINVOCATION")
+
+	for _, certificate := range planned {
+		fmt.Fprintf(writer, "%s\t%s\t%s\t%s\t%s\t%s\n", certificate.Type, certificate.Name, certificate.Action, certificate.ConfigurationAction, formatChain(certificate.Chain), certificate.Invocation)
+	}
+
+	writer.Flush()
+
+	return 0
+}
+
+// Formats the chain as intermediate-ca -> root-ca, external issuers are marked with a *.
+func formatChain(chain []*certificates.PlannedIssuer) string {
+	if len(chain) == 0 {
+		return "-"
+	}
+
+	var names []string
+
+	for _, issuer := range chain {
+		name := fmt.Sprintf("%s (%s)", issuer.Name, issuer.Type)
+
+		if issuer.External {
+			name += "*"
+		}
+
+		names = append(names, name)
+	}
+
+	return strings.Join(names, " -> ")
+}
@@ -0,0 +1,80 @@
+package commands
+
+import (
+	"flag"
+
+	"git.mfdlabs.local/petko/mfdlabs-ssl-go/pkg"
+	"git.mfdlabs.local/petko/mfdlabs-ssl-go/pkg/backend"
+	"git.mfdlabs.local/petko/mfdlabs-ssl-go/pkg/certificates"
+)
+
+var renewCommand = &Command{
+	Name:        "renew",
+	Usage:       "[options] <configuration file> [name...]",
+	Description: "Renews the certificates of the configuration that expire soon, and issues the certificates of renewed certificate authorities again.",
+}
+
+func init() {
+	renewCommand.Run = runRenew
+	register(renewCommand)
+}
+
+func runRenew(args []string) int {
+	flags := flag.NewFlagSet(renewCommand.Name, flag.ExitOnError)
+	window := flags.Int("window", certificates.DefaultRenewalWindow, "Certificates that expire within this many days are renewed.")
+	force := flags.Bool("force", false, "Renew the certificates even if they don't expire within the window.")
+	dryRun := flags.Bool("dryRun", false, "Only print the certificates that would be renewed.")
+	backendName := flags.String("backend", "", "The backend used to renew the certificates. Overrides the backend of the configuration file, defaults to native.")
+	outputDirectory := flags.String("outputDirectory", "", "The directory the certificates are written to. Overrides the output directory of the configuration file, defaults to ./bin.")
+
+	flags.Usage = func() {
+		printUsage(renewCommand, flags.PrintDefaults)
+	}
+
+	flags.Parse(args)
+
+	if flags.NArg() < 1 || *window < 0 {
+		flags.Usage()
+		return 2
+	}
+
+	configurationFilePath := flags.Arg(0)
+
+	// Load the configuration file
+	conf, err := pkg.LoadConfiguration(configurationFilePath)
+
+	if err != nil {
+		PrintErrors(err)
+		return 1
+	}
+
+	// The command line takes precedence over the configuration file
+	if *backendName != "" {
+		conf.Backend = *backendName
+	}
+
+	if *outputDirectory != "" {
+		conf.OutputDirectory = *outputDirectory
+	}
+
+	generator, err := backend.GetBackend(conf.Backend)
+
+	if err != nil {
+		PrintErrors(err)
+		return 1
+	}
+
+	err = certificates.Renew(configurationFilePath, conf, generator, &certificates.RenewalOptions{
+		Window: *window,
+		Names:  flags.Args()[1:],
+		Force:  *force,
+		DryRun: *dryRun,
+	})
+
+	if err != nil {
+		PrintErrors(err)
+		return 1
+	}
+
+	return 0
+}
@@ -0,0 +1,72 @@
+package commands
+
+import (
+	"flag"
+
+	"git.mfdlabs.local/petko/mfdlabs-ssl-go/pkg"
+	"git.mfdlabs.local/petko/mfdlabs-ssl-go/pkg/certificates"
+)
+
+var revokeCommand = &Command{
+	Name:        "revoke",
+	Usage:       "[options] <configuration file> [name]",
+	Description: "Revokes a certificate of the configuration, or the serial number given with -serial, and builds the CRL of the certificate authority that issued it.",
+}
+
+func init() {
+	revokeCommand.Run = runRevoke
+	register(revokeCommand)
+}
+
+func runRevoke(args []string) int {
+	flags := flag.NewFlagSet(revokeCommand.Name, flag.ExitOnError)
+	certType := flags.String("type", "", "The type of the certificate to revoke (intermediate, leaf or signed), or of the certificate authority given with -ca. Only needed if the name is used by more than one certificate.")
+	serialNumber := flags.String("serial", "", "The hexadecimal serial number of a certificate to revoke instead of a certificate of the configuration.")
+	caName := flags.String("ca", "", "The name of the certificate authority of the configuration that issued the certificate given with -serial.")
+	reason := flags.String("reason", "unspecified", "The reason the certificate is revoked for, e.g. keyCompromise or superseded.")
+	nextUpdate := flags.Int("nextUpdate", 0, "The number of days the CRL is valid for. Overrides the CRL validity period of the certificate authority, defaults to 30.")
+	outputDirectory := flags.String("outputDirectory", "", "The directory the certificates are written to. Overrides the output directory of the configuration file, defaults to ./bin.")
+
+	flags.Usage = func() {
+		printUsage(revokeCommand, flags.PrintDefaults)
+	}
+
+	flags.Parse(args)
+
+	// Either a name or a serial number with its certificate authority
+	if *serialNumber == "" && flags.NArg() != 2 || *serialNumber != "" && (flags.NArg() != 1 || *caName == "") {
+		flags.Usage()
+		return 2
+	}
+
+	configurationFilePath := flags.Arg(0)
+
+	// Load the configuration file
+	conf, err := pkg.LoadConfiguration(configurationFilePath)
+
+	if err != nil {
+		PrintErrors(err)
+		return 1
+	}
+
+	if *outputDirectory != "" {
+		conf.OutputDirectory = *outputDirectory
+	}
+
+	target := &certificates.RevocationTarget{
+		Name:         flags.Arg(1),
+		Type:         *certType,
+		SerialNumber: *serialNumber,
+		CaName:       *caName,
+		Reason:       *reason,
+	}
+
+	err = certificates.Revoke(configurationFilePath, conf, target, *nextUpdate)
+
+	if err != nil {
+		PrintErrors(err)
+		return 1
+	}
+
+	return 0
+}
@@ -0,0 +1,149 @@
+package commands
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"git.mfdlabs.local/petko/mfdlabs-ssl-go/pkg"
+	"git.mfdlabs.local/petko/mfdlabs-ssl-go/pkg/certificates"
+	"git.mfdlabs.local/petko/mfdlabs-ssl-go/pkg/inventory"
+)
+
+// The format show prints the records in by default.
+const textFormat = "text"
+
+var showCommand = &Command{
+	Name:        "show",
+	Usage:       "[options] <configuration file> <name>",
+	Description: "Shows everything the inventory recorded about a certificate: its serial number, fingerprint, validity, subject alternative names, configuration hash and artifacts.",
+}
+
+func init() {
+	showCommand.Run = runShow
+	register(showCommand)
+}
+
+func runShow(args []string) int {
+	flags := flag.NewFlagSet(showCommand.Name, flag.ExitOnError)
+	certType := flags.String("type", "", "The type of the certificate: root, intermediate, leaf or signed. Only needed if the name is used by more than one certificate.")
+	all := flags.Bool("all", false, "Show every issuance of the certificate, oldest first, instead of only the latest one.")
+	outputDirectory := flags.String("outputDirectory", "", "The directory the certificates are written to. Overrides the output directory of the configuration file, defaults to ./bin.")
+	format := flags.String("format", textFormat, "The format of the certificate, either text or json.")
+
+	flags.Usage = func() {
+		printUsage(showCommand, flags.PrintDefaults)
+	}
+
+	flags.Parse(args)
+
+	if flags.NArg() != 2 || (*format != textFormat && *format != jsonFormat) {
+		flags.Usage()
+		return 2
+	}
+
+	name := flags.Arg(1)
+
+	// Load the configuration file
+	conf, err := pkg.LoadConfiguration(flags.Arg(0))
+
+	if err != nil {
+		PrintErrors(err)
+		return 1
+	}
+
+	if *outputDirectory != "" {
+		conf.OutputDirectory = *outputDirectory
+	}
+
+	records, err := certificates.LoadInventory(conf)
+
+	if err != nil {
+		PrintErrors(err)
+		return 1
+	}
+
+	found := records.Find(*certType, name)
+
+	if len(found) == 0 {
+		PrintErrors(fmt.Errorf("the inventory has no certificate named %s", name))
+		return 1
+	}
+
+	for _, record := range found {
+		if record.Type != found[0].Type {
+			PrintErrors(fmt.Errorf("more than one certificate is named %s, the type of the certificate has to be given", name))
+			return 1
+		}
+	}
+
+	if !*all {
+		found = found[len(found)-1:]
+	}
+
+	if *format == jsonFormat {
+		// A single certificate is printed as an object, its history as an array
+		var value interface{} = found
+		if !*all {
+			value = found[0]
+		}
+
+		err = printJSON(value)
+		if err != nil {
+			PrintErrors(err)
+			return 1
+		}
+
+		return 0
+	}
+
+	for i, record := range found {
+		if i != 0 {
+			fmt.Println()
+		}
+
+		printRecord(record)
+	}
+
+	return 0
+}
+
+func printRecord(record *inventory.Record) {
+	writer := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+
+	field := func(name string, value string) {
+		if value != "" {
+			fmt.Fprintf(writer, "%s:\t%s\n", name, value)
+		}
+	}
+
+	field("Type", record.Type)
+	field("Name", record.Name)
+	field("Issuer", formatIssuer(record))
+	field("Subject", record.Subject)
+	field("Serial number", record.SerialNumber)
+	field("SHA-256 fingerprint", record.Fingerprint)
+	field("Not before", record.NotBefore.Format(time.RFC3339))
+	field("Not after", record.NotAfter.Format(time.RFC3339))
+	field("DNS names", strings.Join(record.DNSNames, ", "))
+	field("Email addresses", strings.Join(record.EmailAddresses, ", "))
+	field("IP addresses", strings.Join(record.IPAddresses, ", "))
+	field("Configuration hash", record.ConfigurationHash)
+	field("Backend", record.Backend)
+	field("Issued at", record.IssuedAt.Format(time.RFC3339))
+
+	if paths := record.Artifacts; paths != nil {
+		field("Private key", paths.PrivateKey)
+		field("Certificate", paths.Certificate)
+		field("Chain", paths.Chain)
+		field("PFX", paths.Pfx)
+		field("Certificate request", paths.CertificateRequest)
+		field("DH parameters", paths.DHParameters)
+		field("Configuration", paths.Configuration)
+	}
+
+	writer.Flush()
+}
@@ -0,0 +1,108 @@
+package commands
+
+import (
+	"flag"
+	"path/filepath"
+	"strings"
+
+	"git.mfdlabs.local/petko/mfdlabs-ssl-go/pkg"
+	"git.mfdlabs.local/petko/mfdlabs-ssl-go/pkg/backend"
+	"git.mfdlabs.local/petko/mfdlabs-ssl-go/pkg/certificates"
+	"git.mfdlabs.local/petko/mfdlabs-ssl-go/pkg/configuration"
+)
+
+var signCommand = &Command{
+	Name:        "sign",
+	Usage:       "[options] <configuration file> [name...]",
+	Description: "Signs the certificate signing requests of the configuration, or the one given with -csr, with a certificate authority of the chain.",
+}
+
+func init() {
+	signCommand.Run = runSign
+	register(signCommand)
+}
+
+func runSign(args []string) int {
+	flags := flag.NewFlagSet(signCommand.Name, flag.ExitOnError)
+	backendName := flags.String("backend", "", "The backend used to sign the requests. Overrides the backend of the configuration file, defaults to native.")
+	outputDirectory := flags.String("outputDirectory", "", "The directory the certificates are written to. Overrides the output directory of the configuration file, defaults to ./bin.")
+	requestPath := flags.String("csr", "", "The path to a PEM or DER encoded certificate signing request to sign instead of the ones of the configuration file.")
+	name := flags.String("name", "", "The name of the certificate signed from -csr, defaults to the name of the request file without its extension.")
+	caName := flags.String("ca", "", "The name of the certificate authority that signs -csr.")
+	caPassword := flags.String("caPassword", "", "The password of the certificate authority that signs -csr, can be a ${{ }} expression like ${{ env.X }}.")
+	isCaRootCa := flags.Bool("caIsRoot", false, "Determines if the certificate authority that signs -csr is a root certificate authority.")
+	validityPeriod := flags.Int("validityPeriod", 0, "The validity period in days of the certificate signed from -csr.")
+
+	flags.Usage = func() {
+		printUsage(signCommand, flags.PrintDefaults)
+	}
+
+	flags.Parse(args)
+
+	if flags.NArg() < 1 || (*requestPath != "" && flags.NArg() != 1) {
+		flags.Usage()
+		return 2
+	}
+
+	configurationFilePath := flags.Arg(0)
+	names := flags.Args()[1:]
+
+	// Load the configuration file
+	conf, err := pkg.LoadConfiguration(configurationFilePath)
+
+	if err != nil {
+		PrintErrors(err)
+		return 1
+	}
+
+	// The command line takes precedence over the configuration file
+	if *backendName != "" {
+		conf.Backend = *backendName
+	}
+
+	if *outputDirectory != "" {
+		conf.OutputDirectory = *outputDirectory
+	}
+
+	// Sign the request of the command line as if it was part of the configuration
+	if *requestPath != "" {
+		// Requests of the configuration are relative to it, this one is relative to the working directory
+		absoluteRequestPath, err := filepath.Abs(*requestPath)
+
+		if err != nil {
+			PrintErrors(err)
+			return 1
+		}
+
+		if *name == "" {
+			*name = strings.TrimSuffix(filepath.Base(*requestPath), filepath.Ext(*requestPath))
+		}
+
+		conf.CertificateSigningRequests = append(conf.CertificateSigningRequests, &configuration.CertificateSigningRequest{
+			SignedCertificateName:                          *name,
+			RequestPath:                                    absoluteRequestPath,
+			LastChainCertificateName:                       *caName,
+			LastChainCertificatePassword:                   *caPassword,
+			IsLastChainCertificateRootCertificateAuthority: *isCaRootCa,
+			ValidityPeriod:                                 *validityPeriod,
+		})
+
+		names = []string{*name}
+	}
+
+	generator, err := backend.GetBackend(conf.Backend)
+
+	if err != nil {
+		PrintErrors(err)
+		return 1
+	}
+
+	err = certificates.Sign(configurationFilePath, conf, generator, names)
+
+	if err != nil {
+		PrintErrors(err)
+		return 1
+	}
+
+	return 0
+}
@@ -0,0 +1,81 @@
+package commands
+
+import (
+	"flag"
+	"os"
+
+	"git.mfdlabs.local/petko/mfdlabs-ssl-go/pkg"
+	"git.mfdlabs.local/petko/mfdlabs-ssl-go/pkg/certificates"
+	"git.mfdlabs.local/petko/mfdlabs-ssl-go/pkg/configuration"
+)
+
+var untrustCommand = &Command{
+	Name:        "untrust",
+	Usage:       "[options] <configuration file> [ca name...]",
+	Description: "Removes the certificate authorities of the configuration from the trust store they were installed into, and rebuilds it. Only what ssl-go installed is removed.",
+}
+
+func init() {
+	untrustCommand.Run = runUntrust
+	register(untrustCommand)
+}
+
+func runUntrust(args []string) int {
+	flags := flag.NewFlagSet(untrustCommand.Name, flag.ExitOnError)
+	all := flags.Bool("all", false, "Remove every certificate authority ssl-go installed into the trust store, even the ones no longer in the configuration.")
+	distribution := flags.String("distribution", "", "The distribution of the trust store: auto, debian, rhel or alpine. Overrides the distribution of the configuration file.")
+	root := flags.String("root", "", "The root filesystem the trust store is in. Overrides the root of the configuration file, defaults to /.")
+	skipUpdate := flags.Bool("skipUpdate", false, "Do not rebuild the trust store once the certificate authorities are removed.")
+	outputDirectory := flags.String("outputDirectory", "", "The directory the certificates are written to. Overrides the output directory of the configuration file, defaults to ./bin.")
+
+	flags.Usage = func() {
+		printUsage(untrustCommand, flags.PrintDefaults)
+	}
+
+	flags.Parse(args)
+
+	if flags.NArg() < 1 || (*all && flags.NArg() > 1) {
+		flags.Usage()
+		return 2
+	}
+
+	configurationFilePath := flags.Arg(0)
+
+	// Load the configuration file
+	conf, err := pkg.LoadConfiguration(configurationFilePath)
+
+	if err != nil {
+		PrintErrors(err)
+		return 1
+	}
+
+	if *outputDirectory != "" {
+		conf.OutputDirectory = *outputDirectory
+	}
+
+	// The command line takes precedence over the configuration file
+	trustStore := configuration.TrustStoreConfiguration{}
+	if conf.TrustStore != nil {
+		trustStore = *conf.TrustStore
+	}
+
+	if *distribution != "" {
+		trustStore.Distribution = *distribution
+	}
+
+	if *root != "" {
+		trustStore.Root = *root
+	}
+
+	trustStore.SkipUpdate = trustStore.SkipUpdate || *skipUpdate
+	conf.TrustStore = &trustStore
+
+	err = certificates.Untrust(configurationFilePath, conf, &certificates.UntrustOptions{Names: flags.Args()[1:], All: *all}, os.Stdout)
+
+	if err != nil {
+		PrintErrors(err)
+		return 1
+	}
+
+	return 0
+}
@@ -0,0 +1,79 @@
+package commands
+
+import (
+	"flag"
+	"os"
+
+	"git.mfdlabs.local/petko/mfdlabs-ssl-go/pkg"
+	"git.mfdlabs.local/petko/mfdlabs-ssl-go/pkg/certificates"
+	"git.mfdlabs.local/petko/mfdlabs-ssl-go/pkg/configuration"
+)
+
+var untrustNssCommand = &Command{
+	Name:        "untrust-nss",
+	Usage:       "[options] <configuration file> [ca name...]",
+	Description: "Removes the certificate authorities of the configuration from the NSS databases of Firefox, Chromium and the NSS tools they were imported into. Only what ssl-go imported is removed.",
+}
+
+func init() {
+	untrustNssCommand.Run = runUntrustNss
+	register(untrustNssCommand)
+}
+
+func runUntrustNss(args []string) int {
+	flags := flag.NewFlagSet(untrustNssCommand.Name, flag.ExitOnError)
+	all := flags.Bool("all", false, "Remove every certificate authority ssl-go imported into the NSS databases, even the ones no longer in the configuration.")
+	home := flags.String("home", "", "The home directory the NSS databases are in. Overrides the home of the configuration file, defaults to $HOME.")
+	method := flags.String("method", "", "How the NSS databases are written: auto, native or certutil. Overrides the method of the configuration file.")
+	outputDirectory := flags.String("outputDirectory", "", "The directory the certificates are written to. Overrides the output directory of the configuration file, defaults to ./bin.")
+
+	flags.Usage = func() {
+		printUsage(untrustNssCommand, flags.PrintDefaults)
+	}
+
+	flags.Parse(args)
+
+	if flags.NArg() < 1 || (*all && flags.NArg() > 1) {
+		flags.Usage()
+		return 2
+	}
+
+	configurationFilePath := flags.Arg(0)
+
+	// Load the configuration file
+	conf, err := pkg.LoadConfiguration(configurationFilePath)
+
+	if err != nil {
+		PrintErrors(err)
+		return 1
+	}
+
+	if *outputDirectory != "" {
+		conf.OutputDirectory = *outputDirectory
+	}
+
+	// The command line takes precedence over the configuration file
+	nssDatabases := configuration.NssConfiguration{}
+	if conf.Nss != nil {
+		nssDatabases = *conf.Nss
+	}
+
+	if *home != "" {
+		nssDatabases.Home = *home
+	}
+
+	if *method != "" {
+		nssDatabases.Method = *method
+	}
+
+	conf.Nss = &nssDatabases
+
+	err = certificates.RemoveFromNssDatabases(configurationFilePath, conf, &certificates.UntrustOptions{Names: flags.Args()[1:], All: *all}, os.Stdout)
+
+	if err != nil {
+		PrintErrors(err)
+		return 1
+	}
+
+	return 0
+}
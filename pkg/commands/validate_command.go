@@ -0,0 +1,67 @@
+package commands
+
+import (
+	"flag"
+	"fmt"
+
+	"git.mfdlabs.local/petko/mfdlabs-ssl-go/pkg"
+	"git.mfdlabs.local/petko/mfdlabs-ssl-go/pkg/certificates"
+)
+
+var validateCommand = &Command{
+	Name:        "validate",
+	Usage:       "[options] <configuration file>",
+	Description: "Resolves every $ref and ${{ }} expression of the configuration and reports every problem with it, without generating anything.",
+}
+
+func init() {
+	validateCommand.Run = runValidate
+	register(validateCommand)
+}
+
+func runValidate(args []string) int {
+	flags := flag.NewFlagSet(validateCommand.Name, flag.ExitOnError)
+	backendName := flags.String("backend", "", "The backend the configuration is validated for, either native or script. Overrides the backend of the configuration file.")
+	outputDirectory := flags.String("outputDirectory", "", "The directory the issuers generated by earlier runs are looked up in. Overrides the output directory of the configuration file, defaults to ./bin.")
+
+	flags.Usage = func() {
+		printUsage(validateCommand, flags.PrintDefaults)
+	}
+
+	flags.Parse(args)
+
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return 2
+	}
+
+	configurationFilePath := flags.Arg(0)
+
+	// Load the configuration file
+	conf, err := pkg.LoadConfiguration(configurationFilePath)
+
+	if err != nil {
+		PrintErrors(err)
+		return 1
+	}
+
+	// The command line takes precedence over the configuration file
+	if *backendName != "" {
+		conf.Backend = *backendName
+	}
+
+	if *outputDirectory != "" {
+		conf.OutputDirectory = *outputDirectory
+	}
+
+	err = certificates.Validate(configurationFilePath, conf)
+
+	if err != nil {
+		PrintErrors(err)
+		return 1
+	}
+
+	fmt.Printf("%s is valid\n", configurationFilePath)
+
+	return 0
+}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"io/ioutil"
 	"os"
+	"path/filepath"
 
 	"git.mfdlabs.local/petko/mfdlabs-ssl-go/pkg/configuration"
 	"gopkg.in/yaml.v2"
@@ -24,15 +25,12 @@ func LoadConfiguration(configurationFilePath string) (*configuration.SslConfigur
 		return &configuration.SslConfiguration{}, fmt.Errorf("the configuration file path is a directory")
 	}
 
-	// Get the file extension
-	fileExtension := fileInfo.Name()[len(fileInfo.Name())-3:]
-
 	// Determine if the file extension is json or yaml
-	if fileExtension == "yml" || fileExtension == "yaml" {
+	if configuration.IsYamlFile(configurationFilePath) {
 		return loadConfigurationYaml(configurationFilePath)
 	}
 
-	if fileExtension == "json" {
+	if filepath.Ext(configurationFilePath) == ".json" {
 		return loadConfigurationJson(configurationFilePath)
 	}
 
@@ -5,9 +5,11 @@ import (
 	"io/ioutil"
 	"os"
 	"strings"
+
+	"git.mfdlabs.local/petko/mfdlabs-ssl-go/pkg/helper"
 )
 
-func getSharedConfigHeader(conf *BaseCertificateConfiguration) string {
+func getSharedConfigHeader(conf *BaseCertificateConfiguration) (string, error) {
 	var configFile string = `[req]
 distinguished_name = issued_to_name
 req_extensions = config_extensions
@@ -18,7 +20,7 @@ prompt = no
 
 	if conf.Country != "" {
 		if len(conf.Country) > 2 {
-			panic("The country code must be 2 characters")
+			return "", &helper.ValidationError{Field: "config.country", Message: "must be 2 characters"}
 		}
 
 		configFile += fmt.Sprintf("countryName = %s\n", conf.Country)
@@ -41,7 +43,7 @@ prompt = no
 	}
 
 	if conf.CommonName == "" {
-		panic("The common name is empty")
+		return "", &helper.ValidationError{Field: "config.commonName", Message: "cannot be empty"}
 	}
 
 	configFile += fmt.Sprintf("commonName = %s\n", conf.CommonName)
@@ -50,11 +52,15 @@ prompt = no
 		configFile += fmt.Sprintf("emailAddress = %s\n", conf.EmailAddress)
 	}
 
-	return configFile + "\n[config_extensions]\n"
+	return configFile + "\n[config_extensions]\n", nil
 }
 
-func getLeafConfigHead(conf *LeafCertificateConfiguration) string {
-	configFile := getSharedConfigHeader(&conf.BaseCertificateConfiguration)
+func getLeafConfigHead(conf *LeafCertificateConfiguration, keyAlgorithm string) (string, error) {
+	configFile, err := getSharedConfigHeader(&conf.BaseCertificateConfiguration)
+
+	if err != nil {
+		return "", err
+	}
 
 	// Check if key usage is not empty
 	if len(conf.KeyUsages) != 0 {
@@ -66,10 +72,11 @@ func getLeafConfigHead(conf *LeafCertificateConfiguration) string {
 			configFile += fmt.Sprintf("keyUsage = %s\n", strings.Join(conf.KeyUsages, ", "))
 		}
 	} else {
+		// The same key usages the native backend issues the certificate with
 		if conf.HasCriticalKeyUsage {
-			configFile += "keyUsage = critical, digitalSignature, keyEncipherment\n"
+			configFile += fmt.Sprintf("keyUsage = critical, %s\n", strings.Join(helper.GetDefaultLeafKeyUsages(keyAlgorithm), ", "))
 		} else {
-			configFile += "keyUsage = digitalSignature, keyEncipherment\n"
+			configFile += fmt.Sprintf("keyUsage = %s\n", strings.Join(helper.GetDefaultLeafKeyUsages(keyAlgorithm), ", "))
 		}
 	}
 
@@ -78,7 +85,7 @@ func getLeafConfigHead(conf *LeafCertificateConfiguration) string {
 		// Check if basic constraints contains CA:TRUE anywhere, as this isn't allowed because this isn't a CA
 		// basic contraints is a list of strings, so we can check for the string CA:TRUE
 		if strings.Contains(strings.Join(conf.BasicConstraints, " "), "CA:TRUE") {
-			panic("Basic constraints cannot contain CA:TRUE")
+			return "", &helper.ValidationError{Field: "config.basicConstraints", Message: "cannot contain CA:TRUE"}
 		}
 
 		if conf.HasCriticalBasicConstraints {
@@ -133,13 +140,22 @@ func getLeafConfigHead(conf *LeafCertificateConfiguration) string {
 		}
 	}
 
+	// Check if the OCSP responders are not empty
+	if len(conf.OcspServers) != 0 {
+		configFile += fmt.Sprintf("authorityInfoAccess = %s\n", getOcspServersValue(conf.OcspServers))
+	}
+
+	if conf.MustStaple {
+		configFile += "tlsfeature = status_request\n"
+	}
+
 	if conf.SubjectAlternativeName != nil {
 		hasDomainNames := len(conf.SubjectAlternativeName.DNSNames) != 0
 		hasEmailAddresses := len(conf.SubjectAlternativeName.EmailAddresses) != 0
 		hasIpAddresses := len(conf.SubjectAlternativeName.IPAddresses) != 0
 
 		if !(hasDomainNames || hasEmailAddresses || hasIpAddresses) {
-			return configFile
+			return configFile, nil
 		}
 
 		if conf.HasCriticalSubjectAltNames {
@@ -170,11 +186,15 @@ func getLeafConfigHead(conf *LeafCertificateConfiguration) string {
 		}
 	}
 
-	return configFile
+	return configFile, nil
 }
 
-func getCaConfigHeader(conf *BaseCertificateConfiguration) string {
-	configFile := getSharedConfigHeader(conf)
+func getCaConfigHeader(conf *BaseCertificateConfiguration) (string, error) {
+	configFile, err := getSharedConfigHeader(conf)
+
+	if err != nil {
+		return "", err
+	}
 
 	// Check if key usage is not empty
 	if len(conf.KeyUsages) != 0 {
@@ -241,16 +261,41 @@ func getCaConfigHeader(conf *BaseCertificateConfiguration) string {
 		}
 	}
 
-	return configFile
+	// Check if the OCSP responders are not empty
+	if len(conf.OcspServers) != 0 {
+		configFile += fmt.Sprintf("authorityInfoAccess = %s\n", getOcspServersValue(conf.OcspServers))
+	}
+
+	return configFile, nil
+}
+
+// Formats the OCSP responders like openssl expects them, e.g. OCSP;URI:http://127.0.0.1:8080
+func getOcspServersValue(ocspServers []string) string {
+	var values []string
+
+	for _, ocspServer := range ocspServers {
+		values = append(values, "OCSP;URI:"+ocspServer)
+	}
+
+	return strings.Join(values, ", ")
 }
 
-func generateLeafCertConfigurationFileIfNotExists(path string, overwrite bool, conf *LeafCertificateConfiguration) error {
+func generateLeafCertConfigurationFileIfNotExists(path string, overwrite bool, conf *LeafCertificateConfiguration, keyAlgorithm string) error {
+	// Nothing to generate the configuration file from
+	if conf == nil {
+		return nil
+	}
+
 	// Check if the configuration file exists or we are overwriting it
 	if _, err := os.Stat(path); os.IsNotExist(err) || overwrite {
 		// Create the configuration file
 
 		// Get the configuration file header
-		configFile := getLeafConfigHead(conf)
+		configFile, err := getLeafConfigHead(conf, keyAlgorithm)
+
+		if err != nil {
+			return err
+		}
 
 		// Write the configuration file
 		return ioutil.WriteFile(path, []byte(configFile), os.FileMode(0644))
@@ -259,26 +304,29 @@ func generateLeafCertConfigurationFileIfNotExists(path string, overwrite bool, c
 	return nil
 }
 
-func GenerateLeafCertificateConfigurationFileIfNotExists(ca *LeafCertificate) error {
-	// Get current path of the executable
-	path, err := os.Getwd()
+func GenerateLeafCertificateConfigurationFileIfNotExists(outputDirectory string, certName string, ca *LeafCertificate) error {
+	configFilePath := helper.GetArtifactPath(outputDirectory, helper.LeafCertificateType, certName, helper.ConfigurationExtension)
+	err := generateLeafCertConfigurationFileIfNotExists(configFilePath, ca.OverwriteExistingConfiguration, ca.Configuration, ca.KeyAlgorithm)
 
-	if err != nil {
-		return err
-	}
-
-	// Format the configuration file path
-	configFilePath := fmt.Sprintf("%s/bin/%s.conf", path, ca.LeafCertificateName)
-	return generateLeafCertConfigurationFileIfNotExists(configFilePath, ca.OverwriteExistingConfiguration, ca.Configuration)
+	return nameValidationError(err, helper.LeafCertificateType, certName)
 }
 
 func generateCaConfigurationFileIfNotExists(path string, overwrite bool, conf *BaseCertificateConfiguration) error {
+	// Nothing to generate the configuration file from
+	if conf == nil {
+		return nil
+	}
+
 	// Check if the configuration file exists or we are overwriting it
 	if _, err := os.Stat(path); os.IsNotExist(err) || overwrite {
 		// Create the configuration file
 
 		// Get the configuration file header
-		configFile := getCaConfigHeader(conf)
+		configFile, err := getCaConfigHeader(conf)
+
+		if err != nil {
+			return err
+		}
 
 		// Write the configuration file
 		return ioutil.WriteFile(path, []byte(configFile), os.FileMode(0644))
@@ -287,28 +335,26 @@ func generateCaConfigurationFileIfNotExists(path string, overwrite bool, conf *B
 	return nil
 }
 
-func GenerateIntermediateCertificateConfigurationFileIfNotExists(ca *IntermediateCertificateAuthority) error {
-	// Get current path of the executable
-	path, err := os.Getwd()
-
-	if err != nil {
-		return err
-	}
+func GenerateIntermediateCertificateConfigurationFileIfNotExists(outputDirectory string, certName string, ca *IntermediateCertificateAuthority) error {
+	configFilePath := helper.GetArtifactPath(outputDirectory, helper.IntermediateCertificateType, certName, helper.ConfigurationExtension)
+	err := generateCaConfigurationFileIfNotExists(configFilePath, ca.OverwriteExistingConfiguration, ca.Configuration)
 
-	// Format the configuration file path
-	configFilePath := fmt.Sprintf("%s/bin/ca-%s.conf", path, ca.IntermediateCertificateAuthorityName)
-	return generateCaConfigurationFileIfNotExists(configFilePath, ca.OverwriteExistingConfiguration, ca.Configuration)
+	return nameValidationError(err, helper.IntermediateCertificateType, certName)
 }
 
-func GenerateRootCertificateConfigurationFileIfNotExists(ca *RootCertificateAuthority) error {
-	// Get current path of the executable
-	path, err := os.Getwd()
+func GenerateRootCertificateConfigurationFileIfNotExists(outputDirectory string, certName string, ca *RootCertificateAuthority) error {
+	configFilePath := helper.GetArtifactPath(outputDirectory, helper.RootCertificateType, certName, helper.ConfigurationExtension)
+	err := generateCaConfigurationFileIfNotExists(configFilePath, ca.OverwriteExistingConfiguration, ca.Configuration)
 
-	if err != nil {
-		return err
+	return nameValidationError(err, helper.RootCertificateType, certName)
+}
+
+// Fills in the certificate of a validation error raised while generating its configuration file.
+func nameValidationError(err error, certType string, certName string) error {
+	if validationErr, ok := err.(*helper.ValidationError); ok {
+		validationErr.CertificateType = certType
+		validationErr.CertificateName = certName
 	}
 
-	// Format the configuration file path
-	configFilePath := fmt.Sprintf("%s/bin/root-ca-%s.conf", path, ca.RootCertificateName)
-	return generateCaConfigurationFileIfNotExists(configFilePath, ca.OverwriteExistingConfiguration, ca.Configuration)
+	return err
 }
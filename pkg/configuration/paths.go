@@ -0,0 +1,104 @@
+package configuration
+
+import (
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"strings"
+
+	"git.mfdlabs.local/petko/mfdlabs-ssl-go/pkg/helper"
+)
+
+// The json names of the certificate lists of the SslConfiguration.
+var certificateListFields = map[string]string{
+	helper.RootCertificateType:         "rootCa",
+	helper.IntermediateCertificateType: "intermediateCa",
+	helper.LeafCertificateType:         "leafCertificate",
+	helper.SignedCertificateType:       "certificateSigningRequest",
+}
+
+// Determines if the file is a yaml file from its extension.
+func IsYamlFile(path string) bool {
+	extension := filepath.Ext(path)
+
+	return extension == ".yml" || extension == ".yaml"
+}
+
+// Gets the path of a field of a certificate in the configuration file, e.g. intermediate_ca[1].ca_chain_password.
+// The field is made of the json names of the fields separated by dots, and is translated to the yaml names for yaml files.
+func GetFieldPath(certType string, index int, field string, isYaml bool) string {
+	segments := []string{certificateListFields[certType]}
+
+	if field != "" {
+		segments = append(segments, strings.Split(field, ".")...)
+	}
+
+	if isYaml {
+		segments = translateToYaml(reflect.TypeOf(SslConfiguration{}), segments)
+	}
+
+	segments[0] = fmt.Sprintf("%s[%d]", segments[0], index)
+
+	return strings.Join(segments, ".")
+}
+
+func translateToYaml(structType reflect.Type, segments []string) []string {
+	translated := make([]string, len(segments))
+	copy(translated, segments)
+
+	for i, segment := range segments {
+		// Elements of lists keep their index, e.g. keystores[0]
+		index := ""
+		if bracket := strings.Index(segment, "["); bracket > 0 {
+			segment, index = segment[:bracket], segment[bracket:]
+		}
+
+		field, ok := findJsonField(structType, segment)
+
+		// Keep the json names of what can't be found
+		if !ok {
+			break
+		}
+
+		if name := getTagName(field.Tag.Get("yaml")); name != "" {
+			translated[i] = name + index
+		}
+
+		structType = field.Type
+
+		for structType.Kind() == reflect.Ptr || structType.Kind() == reflect.Slice {
+			structType = structType.Elem()
+		}
+
+		if structType.Kind() != reflect.Struct {
+			break
+		}
+	}
+
+	return translated
+}
+
+// Finds the field with the json name in the struct, including the fields of embedded structs.
+func findJsonField(structType reflect.Type, name string) (reflect.StructField, bool) {
+	for i := 0; i < structType.NumField(); i++ {
+		field := structType.Field(i)
+
+		if field.Anonymous {
+			if embedded, ok := findJsonField(field.Type, name); ok {
+				return embedded, true
+			}
+
+			continue
+		}
+
+		if getTagName(field.Tag.Get("json")) == name {
+			return field, true
+		}
+	}
+
+	return reflect.StructField{}, false
+}
+
+func getTagName(tag string) string {
+	return strings.Split(tag, ",")[0]
+}
@@ -1,6 +1,13 @@
 package configuration
 
 type SslConfiguration struct {
+	// The backend used to generate the certificates, either native (the default) or script.
+	Backend string `json:"backend" yaml:"backend"`
+
+	// The directory the certificates, their configuration files and the temporary password files are written to.
+	// Relative paths are relative to the current working directory, defaults to ./bin.
+	OutputDirectory string `json:"outputDirectory" yaml:"output_directory"`
+
 	// A list of root certificates to generate the certificate chain.
 	RootCertificateAuthorities []*RootCertificateAuthority `json:"rootCa" yaml:"root_ca"`
 
@@ -9,6 +16,156 @@ type SslConfiguration struct {
 
 	// A list of leaf certificates to generate the certificate chain.
 	LeafCertificateAuthorities []*LeafCertificate `json:"leafCertificate" yaml:"leaf_certificate"`
+
+	// A list of certificate signing requests generated elsewhere, to sign with a certificate authority of the chain.
+	CertificateSigningRequests []*CertificateSigningRequest `json:"certificateSigningRequest" yaml:"certificate_signing_request"`
+
+	// Where the generated passwords of the certificates are kept, a 0600 secrets.json file in the output directory by default.
+	SecretsSink *SecretsSinkConfiguration `json:"secretsSink" yaml:"secrets_sink"`
+
+	// How the certificates are exported as Kubernetes manifests by the k8s command.
+	Kubernetes *KubernetesConfiguration `json:"kubernetes" yaml:"kubernetes"`
+
+	// Where the certificate authorities that should be inserted into the trusted store are installed, the trust
+	// store of the distribution of this system by default.
+	TrustStore *TrustStoreConfiguration `json:"trustStore" yaml:"trust_store"`
+
+	// The NSS databases of Firefox, Chromium and the NSS tools the certificate authorities that should be imported
+	// into them are imported into, the ones in the home of the user by default.
+	Nss *NssConfiguration `json:"nss" yaml:"nss"`
+}
+
+type TrustStoreConfiguration struct {
+	// The distribution whose trust store is used: auto (the default) detects it from /etc/os-release of the root,
+	// debian (and ubuntu) installs into /usr/local/share/ca-certificates, rhel (and fedora) into
+	// /etc/pki/ca-trust/source/anchors, and alpine into /usr/local/share/ca-certificates.
+	Distribution string `json:"distribution" yaml:"distribution"`
+
+	// The root filesystem the trust store is in, e.g. the root of a container image being built. Defaults to /, the
+	// trust store is only rebuilt for /.
+	Root string `json:"root" yaml:"root"`
+
+	// The absolute path of the directory inside the root the certificates are installed into, overrides the one of
+	// the distribution.
+	Directory string `json:"directory" yaml:"directory"`
+
+	// Determines if update-ca-certificates or update-ca-trust is not run after a change.
+	SkipUpdate bool `json:"skipUpdate" yaml:"skip_update"`
+}
+
+type NssConfiguration struct {
+	// The home directory the databases are in, defaults to $HOME.
+	Home string `json:"home" yaml:"home"`
+
+	// The directories of the databases, relative to the home. Defaults to .pki/nssdb, the database of Chromium and
+	// the NSS tools, which is created if it doesn't exist, and the databases of the Firefox profiles of the home.
+	Databases []string `json:"databases" yaml:"databases"`
+
+	// How the databases are written: auto (the default) runs certutil if it is installed, native writes the
+	// databases in-process and certutil always runs it.
+	Method string `json:"method" yaml:"method"`
+
+	// The password of the key databases, empty by default. The databases are changed while the browsers are closed,
+	// as they don't see the changes until they are restarted and may write over them.
+	Password string `json:"password" yaml:"password"`
+}
+
+type KubernetesConfiguration struct {
+	// The namespace of the manifests, defaults to default.
+	Namespace string `json:"namespace" yaml:"namespace"`
+
+	// The name of the TLS secret of a leaf certificate, defaults to {{ dns .Name }}-tls. Like the namespace and the
+	// labels, this is a Go template of the .Name, .Type, .CommonName and .Namespace of the certificate, where dns
+	// turns text into a valid Kubernetes name.
+	SecretName string `json:"secretName" yaml:"secret_name"`
+
+	// The name of the trust bundle config map of a certificate authority, defaults to {{ dns .Name }}-ca.
+	ConfigMapName string `json:"configMapName" yaml:"config_map_name"`
+
+	// The labels of every manifest, app.kubernetes.io/managed-by is ssl-go unless it is set.
+	Labels map[string]string `json:"labels" yaml:"labels"`
+
+	// Determines if a trust bundle config map is exported for every certificate authority.
+	TrustBundles bool `json:"trustBundles" yaml:"trust_bundles"`
+}
+
+type PasswordGenerationConfiguration struct {
+	// Determines if the passwords that are not set are generated.
+	Generate bool `json:"generate" yaml:"generate"`
+
+	// The number of characters of the generated passwords, defaults to 32. Cannot be less than 16.
+	Length int `json:"length" yaml:"length"`
+}
+
+type SecretsSinkConfiguration struct {
+	// The kind of sink: file (the default) is a JSON file only the owner can read, keyring is the same file encrypted
+	// with a passphrase, and provider stores the passwords through a secret provider like vault.
+	Type string `json:"type" yaml:"type"`
+
+	// The path of the file or keyring, relative to the current working directory. Defaults to secrets.json or
+	// secrets.keyring in the output directory.
+	Path string `json:"path" yaml:"path"`
+
+	// The passphrase of the keyring, usually an expression like ${{ env.SSL_GO_KEYRING_PASSPHRASE }}.
+	Passphrase string `json:"passphrase" yaml:"passphrase"`
+
+	// Where a provider stores the passwords, e.g. vault.secret/ssl-go. Every password is a secret under it.
+	Reference string `json:"reference" yaml:"reference"`
+}
+
+type PfxConfiguration struct {
+	// How the private key and the certificates are encrypted: legacy (3DES and 40 bit RC2, for Java 8), 3des (the
+	// default) or aes256 (AES-256-CBC with PBKDF2, for .NET 6 and OpenSSL 3).
+	Encryption string `json:"encryption" yaml:"encryption"`
+
+	// The digest of the MAC: sha1, sha256, sha384 or sha512. Defaults to sha256 with aes256 and sha1 otherwise.
+	MacAlgorithm string `json:"macAlgorithm" yaml:"mac_algorithm"`
+
+	// The iteration count of the encryption and the MAC, defaults to 2048.
+	Iterations int `json:"iterations" yaml:"iterations"`
+
+	// The friendly name (alias) of the private key and its certificate, defaults to the name of the certificate.
+	FriendlyName string `json:"friendlyName" yaml:"friendly_name"`
+
+	// Determines if the certificates of the issuers are in the pfx, defaults to true.
+	IncludeChain *bool `json:"includeChain" yaml:"include_chain"`
+}
+
+type KeystoreConfiguration struct {
+	// keystore (the default) holds the private key and chain under the alias, truststore holds the root certificate
+	// authority of the chain.
+	Type string `json:"type" yaml:"type"`
+
+	// pkcs12 (the default) or jks.
+	Format string `json:"format" yaml:"format"`
+
+	// The path of the store, relative to the output directory of the certificate. Defaults to the name of the
+	// certificate with .keystore.p12, .keystore.jks, .truststore.p12 or .truststore.jks.
+	Path string `json:"path" yaml:"path"`
+
+	// The alias of the entry, defaults to the name of the certificate for keystores and to the common name of the
+	// root certificate authority for truststores.
+	Alias string `json:"alias" yaml:"alias"`
+
+	// The password of the store, and of the private key in it.
+	Password string `json:"password" yaml:"password"`
+}
+
+// The files a server reads the certificate from, laid out the way it wants them. The private key in them is not
+// encrypted, the servers can't be given its password.
+type BundleConfiguration struct {
+	// nginx (<name>.fullchain.pem and <name>.key), haproxy (<name>.pem with the key, certificate and chain), envoy
+	// (<name>.crt with the chain, <name>.key, <name>.ca.crt and the <name>.sds.yaml SDS file) or apache (<name>.crt,
+	// <name>.key and <name>.chain.crt).
+	Profile string `json:"profile" yaml:"profile"`
+
+	// The directory the files are written to, relative to the output directory of the certificate. Defaults to the
+	// name of the profile.
+	Directory string `json:"directory" yaml:"directory"`
+
+	// The directory the server reads the files from, the SDS file of envoy refers to them there. Defaults to the
+	// directory they are written to.
+	MountPath string `json:"mountPath" yaml:"mount_path"`
 }
 
 type RootCertificateAuthority struct {
@@ -25,19 +182,44 @@ type RootCertificateAuthority struct {
 	// The password for the pkcs12 pfx to generate.
 	RootCertificatePfxPassword string `json:"pfxPassword" yaml:"pfx_password"`
 
-	// The private key size to generate.
+	// Generates the password and pfx password that are not set, and keeps them in the secrets sink of the configuration.
+	Passwords *PasswordGenerationConfiguration `json:"passwords" yaml:"passwords"`
+
+	// How the pfx is encoded, only the native backend can change it.
+	Pfx *PfxConfiguration `json:"pfx" yaml:"pfx"`
+
+	// The Java keystores and truststores written next to the pfx.
+	Keystores []*KeystoreConfiguration `json:"keystores" yaml:"keystores"`
+
+	// The algorithm of the private key to generate: rsa (the default), ecdsa or ed25519.
+	KeyAlgorithm string `json:"keyAlgorithm" yaml:"key_algorithm"`
+
+	// The private key size to generate. For ecdsa keys this selects the curve: 256, 384 or 521.
 	PrivateKeySize int `json:"privateKeySize" yaml:"private_key_size"`
 
 	// The validity period of the certificate to generate. This is in days.
 	ValidityPeriod int `json:"validityPeriod" yaml:"validity_period"`
 
-	// Determines if this CA should be added to the trusted root certificate authority store (linux)
+	// The number of days the CRLs of this certificate authority are valid for, their nextUpdate. Defaults to 30.
+	CrlValidityPeriod int `json:"crlValidityPeriod" yaml:"crl_validity_period"`
+
+	// What happens to the private key when the certificate is renewed: rotate (the default) generates a new key, reuse keeps the existing one.
+	RenewalKeyPolicy string `json:"renewalKeyPolicy" yaml:"renewal_key_policy"`
+
+	// Determines if this CA should be added to the trusted root certificate authority store (linux), where the trust store of the configuration says
 	ShouldInsertIntoTrustedStore bool `json:"shouldInsertIntoTrustedStore" yaml:"should_insert_into_trusted_store"`
 
+	// Determines if this CA should be imported into the NSS databases of the configuration, trusted to issue server certificates
+	ShouldImportIntoNssDatabases bool `json:"shouldImportIntoNssDatabases" yaml:"should_import_into_nss_databases"`
+
 	// Determines if we should generate DH Parameters for this certificate
 	GenerateDHParameters bool `json:"generateDHParam" yaml:"generate_dhparam"`
 
+	// The directory the artifacts of this certificate are written to, overrides the output directory of the configuration.
+	OutputDirectory string `json:"outputDirectory" yaml:"output_directory"`
+
 	// Determines if we should overwrite the ssl configuration if there is one that exists already, with the ConfigurationToGenerate member.
+	// Only the script backend reads the configuration file, the native backend keeps an existing one as it is.
 	OverwriteExistingConfiguration bool `json:"overwriteConfig" yaml:"overwrite_config"`
 
 	// A certificate configuration file like openssl.cnf to be generated if the configuration is not found at the bin folder.
@@ -55,7 +237,7 @@ type IntermediateCertificateAuthority struct {
 	// The name of the last certificate in the chain.
 	LastChainCertificateName string `json:"caChainName" yaml:"ca_chain_name"`
 
-	// The password to the last certificate in the chain.
+	// The password to the last certificate in the chain. Defaults to the password of the last certificate in the chain if it's part of the configuration.
 	LastChainCertificatePassword string `json:"caChainPassword" yaml:"ca_chain_password"`
 
 	// The name of the intermediate certificate authority to generate.
@@ -67,22 +249,47 @@ type IntermediateCertificateAuthority struct {
 	// The password for the pkcs12 pfx to generate.
 	IntermediateCertificateAuthorityPfxPassword string `json:"pfxPassword" yaml:"pfx_password"`
 
-	// The private key size to generate.
+	// Generates the password and pfx password that are not set, and keeps them in the secrets sink of the configuration.
+	Passwords *PasswordGenerationConfiguration `json:"passwords" yaml:"passwords"`
+
+	// How the pfx is encoded, only the native backend can change it.
+	Pfx *PfxConfiguration `json:"pfx" yaml:"pfx"`
+
+	// The Java keystores and truststores written next to the pfx.
+	Keystores []*KeystoreConfiguration `json:"keystores" yaml:"keystores"`
+
+	// The algorithm of the private key to generate: rsa (the default), ecdsa or ed25519.
+	KeyAlgorithm string `json:"keyAlgorithm" yaml:"key_algorithm"`
+
+	// The private key size to generate. For ecdsa keys this selects the curve: 256, 384 or 521.
 	PrivateKeySize int `json:"privateKeySize" yaml:"private_key_size"`
 
 	// The validity period of the certificate to generate. This is in days.
 	ValidityPeriod int `json:"validityPeriod" yaml:"validity_period"`
 
-	// Determines if this CA should be added to the trusted root certificate authority store (linux)
+	// The number of days the CRLs of this certificate authority are valid for, their nextUpdate. Defaults to 30.
+	CrlValidityPeriod int `json:"crlValidityPeriod" yaml:"crl_validity_period"`
+
+	// What happens to the private key when the certificate is renewed: rotate (the default) generates a new key, reuse keeps the existing one.
+	RenewalKeyPolicy string `json:"renewalKeyPolicy" yaml:"renewal_key_policy"`
+
+	// Determines if this CA should be added to the trusted root certificate authority store (linux), where the trust store of the configuration says
 	ShouldInsertIntoTrustedStore bool `json:"shouldInsertIntoTrustedStore" yaml:"should_insert_into_trusted_store"`
 
+	// Determines if this CA should be imported into the NSS databases of the configuration, trusted to issue server certificates
+	ShouldImportIntoNssDatabases bool `json:"shouldImportIntoNssDatabases" yaml:"should_import_into_nss_databases"`
+
 	// Determines if we should generate DH Parameters for this certificate
 	GenerateDHParameters bool `json:"generateDHParam" yaml:"generate_dhparam"`
 
 	// Determines if we should keep the certificate request file (.csr)
 	KeepCertificateRequestFile bool `json:"keepCertificateRequestFile" yaml:"keep_certificate_request_file"`
 
+	// The directory the artifacts of this certificate are written to, overrides the output directory of the configuration.
+	OutputDirectory string `json:"outputDirectory" yaml:"output_directory"`
+
 	// Determines if we should overwrite the ssl configuration if there is one that exists already, with the ConfigurationToGenerate member.
+	// Only the script backend reads the configuration file, the native backend keeps an existing one as it is.
 	OverwriteExistingConfiguration bool `json:"overwriteConfig" yaml:"overwrite_config"`
 
 	// A certificate configuration file like openssl.cnf to be generated if the configuration is not found at the bin folder.
@@ -100,7 +307,7 @@ type LeafCertificate struct {
 	// The name of the last certificate in the chain.
 	LastChainCertificateName string `json:"caName" yaml:"ca_name"`
 
-	// The password to the last certificate in the chain.
+	// The password to the last certificate in the chain. Defaults to the password of the last certificate in the chain if it's part of the configuration.
 	LastChainCertificatePassword string `json:"caPassword" yaml:"ca_password"`
 
 	// The name of the leaf certificate to generate.
@@ -112,25 +319,78 @@ type LeafCertificate struct {
 	// The password for the pkcs12 pfx to generate.
 	LeafCertificatePfxPassword string `json:"pfxPassword" yaml:"pfx_password"`
 
-	// The private key size to generate.
+	// Generates the password and pfx password that are not set, and keeps them in the secrets sink of the configuration.
+	Passwords *PasswordGenerationConfiguration `json:"passwords" yaml:"passwords"`
+
+	// How the pfx is encoded, only the native backend can change it.
+	Pfx *PfxConfiguration `json:"pfx" yaml:"pfx"`
+
+	// The Java keystores and truststores written next to the pfx.
+	Keystores []*KeystoreConfiguration `json:"keystores" yaml:"keystores"`
+
+	// The files of the servers the certificate is deployed to, in their layouts.
+	Bundles []*BundleConfiguration `json:"bundles" yaml:"bundles"`
+
+	// The algorithm of the private key to generate: rsa (the default), ecdsa or ed25519.
+	KeyAlgorithm string `json:"keyAlgorithm" yaml:"key_algorithm"`
+
+	// The private key size to generate. For ecdsa keys this selects the curve: 256, 384 or 521.
 	PrivateKeySize int `json:"privateKeySize" yaml:"private_key_size"`
 
 	// The validity period of the certificate to generate. This is in days.
 	ValidityPeriod int `json:"validityPeriod" yaml:"validity_period"`
 
+	// What happens to the private key when the certificate is renewed: rotate (the default) generates a new key, reuse keeps the existing one.
+	RenewalKeyPolicy string `json:"renewalKeyPolicy" yaml:"renewal_key_policy"`
+
 	// Determines if we should generate DH Parameters for this certificate
 	GenerateDHParameters bool `json:"generateDHParam" yaml:"generate_dhparam"`
 
 	// Determines if we should keep the certificate request file (.csr)
 	KeepCertificateRequestFile bool `json:"keepCertificateRequestFile" yaml:"keep_certificate_request_file"`
 
+	// The directory the artifacts of this certificate are written to, overrides the output directory of the configuration.
+	OutputDirectory string `json:"outputDirectory" yaml:"output_directory"`
+
 	// Determines if we should overwrite the ssl configuration if there is one that exists already, with the ConfigurationToGenerate member.
+	// Only the script backend reads the configuration file, the native backend keeps an existing one as it is.
 	OverwriteExistingConfiguration bool `json:"overwriteConfig" yaml:"overwrite_config"`
 
 	// A certificate configuration file like openssl.cnf to be generated if the configuration is not found at the bin folder.
 	Configuration *LeafCertificateConfiguration `json:"config" yaml:"config"`
 }
 
+type CertificateSigningRequest struct {
+	// If this is is specified, it will try to load the generation config from the specified file,
+	// whether it is absolute or relative to the root configuration file.
+	ReferencedConfigurationPath string `json:"$ref" yaml:"$ref"`
+
+	// Determines if the last certificate in the chain is a root certificate authority
+	IsLastChainCertificateRootCertificateAuthority bool `json:"isLastChainRootCA" yaml:"is_ca_root_ca"`
+
+	// The name of the last certificate in the chain.
+	LastChainCertificateName string `json:"caName" yaml:"ca_name"`
+
+	// The password to the last certificate in the chain. Defaults to the password of the last certificate in the chain if it's part of the configuration.
+	LastChainCertificatePassword string `json:"caPassword" yaml:"ca_password"`
+
+	// The name of the certificate to sign, the signed certificate is written to <name>.crt and <name>.chain.crt.
+	SignedCertificateName string `json:"name" yaml:"name"`
+
+	// The path to the PEM or DER encoded certificate signing request, whether it is absolute or relative to the root configuration file.
+	RequestPath string `json:"request" yaml:"request"`
+
+	// The validity period of the certificate to sign. This is in days.
+	ValidityPeriod int `json:"validityPeriod" yaml:"validity_period"`
+
+	// The directory the signed certificate is written to, overrides the output directory of the configuration.
+	OutputDirectory string `json:"outputDirectory" yaml:"output_directory"`
+
+	// The extension policy of the signed certificate, it overrides the subject, subject alternative names and key usages
+	// requested by the certificate signing request. What it doesn't set is taken from the request.
+	Configuration *LeafCertificateConfiguration `json:"config" yaml:"config"`
+}
+
 type BaseCertificateConfiguration struct {
 	// The country of the certificate to generate. The 'C' field in openssl.conf. If not specified it will not be set on the certificate.
 	Country string `json:"country" yaml:"country"`
@@ -147,7 +407,7 @@ type BaseCertificateConfiguration struct {
 	// The organizational unit of the certificate to generate. The 'OU' field in openssl.conf. If not specified it will not be set on the certificate.
 	OrganizationalUnit string `json:"organizationalUnit" yaml:"organizational_unit"`
 
-	// The common name of the certificate to generate. The 'CN' field in openssl.conf. This is required.
+	// The common name of the certificate to generate. The 'CN' field in openssl.conf. This is required, except in the policy of a certificate signing request.
 	CommonName string `json:"commonName" yaml:"common_name"`
 
 	// The email address of the certificate to generate. The 'emailAddress' field in openssl.conf. If not specified it will not be set on the certificate.
@@ -182,6 +442,9 @@ type BaseCertificateConfiguration struct {
 
 	// An array of name constraints to add to the certificate.
 	NameConstraints []string `json:"nameConstraints" yaml:"name_constraints"`
+
+	// An array of OCSP responder URLs to add to the authority information access extension, e.g. the address of ssl-go ocsp serve.
+	OcspServers []string `json:"ocspServers" yaml:"ocsp_servers"`
 }
 
 type LeafCertificateConfiguration struct {
@@ -192,6 +455,9 @@ type LeafCertificateConfiguration struct {
 
 	// The Subject Alternative Name object to add to the certificate. Represents the SubjectAlternativeNameConfiguration struct
 	SubjectAlternativeName *SubjectAlternativeNameConfiguration `json:"subjectAlternativeName" yaml:"subject_alternative_name"`
+
+	// Determines if clients must get a stapled OCSP response with this leaf certificate (the status_request TLS feature).
+	MustStaple bool `json:"mustStaple" yaml:"must_staple"`
 }
 
 type SubjectAlternativeNameConfiguration struct {
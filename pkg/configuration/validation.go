@@ -0,0 +1,75 @@
+package configuration
+
+import (
+	"net"
+	"net/url"
+	"strings"
+
+	"git.mfdlabs.local/petko/mfdlabs-ssl-go/pkg/helper"
+)
+
+// Validates the subject of the certificate configuration, the certificate type and name of the errors are left empty.
+func ValidateBaseCertificateConfiguration(conf *BaseCertificateConfiguration) helper.Errors {
+	return validateBaseCertificateConfiguration(conf, true)
+}
+
+func validateBaseCertificateConfiguration(conf *BaseCertificateConfiguration, requireCommonName bool) helper.Errors {
+	// Without a configuration the certificate is named after the entry
+	if conf == nil {
+		return nil
+	}
+
+	var errs helper.Errors
+
+	if len(conf.Country) > 2 {
+		errs = append(errs, &helper.ValidationError{Field: "config.country", Message: "must be 2 characters"})
+	}
+
+	if requireCommonName && conf.CommonName == "" {
+		errs = append(errs, &helper.ValidationError{Field: "config.commonName", Message: "cannot be empty"})
+	}
+
+	// The responders are reached over plain HTTP, as described by RFC 6960 appendix A
+	for _, ocspServer := range conf.OcspServers {
+		if parsed, err := url.Parse(ocspServer); err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+			errs = append(errs, &helper.ValidationError{Field: "config.ocspServers", Message: "is not an http URL: " + ocspServer})
+		}
+	}
+
+	return errs
+}
+
+// Validates the subject, basic constraints and subject alternative names of the leaf certificate configuration,
+// the certificate type and name of the errors are left empty.
+func ValidateLeafCertificateConfiguration(conf *LeafCertificateConfiguration) helper.Errors {
+	return validateLeafCertificateConfiguration(conf, true)
+}
+
+// Validates the extension policy of a certificate signing request like a leaf certificate configuration, except every
+// attribute of the subject is optional as the ones it doesn't set are taken from the request.
+func ValidateSigningPolicy(conf *LeafCertificateConfiguration) helper.Errors {
+	return validateLeafCertificateConfiguration(conf, false)
+}
+
+func validateLeafCertificateConfiguration(conf *LeafCertificateConfiguration, requireCommonName bool) helper.Errors {
+	if conf == nil {
+		return nil
+	}
+
+	errs := validateBaseCertificateConfiguration(&conf.BaseCertificateConfiguration, requireCommonName)
+
+	// A leaf certificate can't be a certificate authority
+	if strings.Contains(strings.Join(conf.BasicConstraints, " "), "CA:TRUE") {
+		errs = append(errs, &helper.ValidationError{Field: "config.basicConstraints", Message: "cannot contain CA:TRUE"})
+	}
+
+	if conf.SubjectAlternativeName != nil {
+		for _, ipAddress := range conf.SubjectAlternativeName.IPAddresses {
+			if net.ParseIP(ipAddress) == nil {
+				errs = append(errs, &helper.ValidationError{Field: "config.subjectAlternativeName.ipAddresses", Message: "is not an IP address: " + ipAddress})
+			}
+		}
+	}
+
+	return errs
+}
@@ -0,0 +1,78 @@
+package helper
+
+import (
+	"path/filepath"
+)
+
+// The types of certificate in the hierarchy.
+const (
+	RootCertificateType         = "root"
+	IntermediateCertificateType = "intermediate"
+	LeafCertificateType         = "leaf"
+
+	// A leaf certificate signed from a certificate signing request, its private key is never seen.
+	SignedCertificateType = "signed"
+)
+
+// The artifacts produced for every certificate, these match the layout of the generation scripts.
+const (
+	PrivateKeyExtension         = ".key"
+	CertificateExtension        = ".crt"
+	ChainCertificateExtension   = ".chain.crt"
+	PfxExtension                = ".pfx"
+	CertificateRequestExtension = ".csr"
+	DHParametersExtension       = ".dhparam.pem"
+	ConfigurationExtension      = ".conf"
+
+	// The certificates revoked by a certificate authority and its CRL, in DER and PEM.
+	RevocationListExtension = ".revocations.json"
+	CRLExtension            = ".crl"
+	PemCRLExtension         = ".crl.pem"
+
+	// The delegated OCSP responder of a certificate authority, its key is encrypted with the password of the certificate authority.
+	OCSPResponderCertificateExtension = ".ocsp.crt"
+	OCSPResponderPrivateKeyExtension  = ".ocsp.key"
+
+	// The keystores and truststores of a certificate that don't set their path, in the JKS and PKCS#12 formats.
+	JKSKeystoreExtension      = ".keystore.jks"
+	PKCS12KeystoreExtension   = ".keystore.p12"
+	JKSTruststoreExtension    = ".truststore.jks"
+	PKCS12TruststoreExtension = ".truststore.p12"
+
+	// The files of the server bundles of a leaf certificate that aren't the key, certificate or chain: the certificate
+	// with its chain, the key with the certificate and its chain, the root certificate authority and the Envoy SDS file.
+	FullChainExtension       = ".fullchain.pem"
+	CombinedBundleExtension  = ".pem"
+	RootCertificateExtension = ".ca.crt"
+	SecretDiscoveryExtension = ".sds.yaml"
+)
+
+// The directory the artifacts are written to if none is configured, relative to the current working directory.
+const DefaultOutputDirectory = "bin"
+
+// Gets the prefix of every artifact for the given certificate type (root, intermediate or leaf).
+func GetArtifactPrefix(certType string) string {
+	switch certType {
+	case RootCertificateType:
+		return "root-ca-"
+	case IntermediateCertificateType:
+		return "ca-"
+	}
+
+	return ""
+}
+
+// Gets the absolute path of an output directory, relative paths are relative to the current working directory
+// and an empty path selects the default output directory.
+func GetOutputDirectory(outputDirectory string) (string, error) {
+	if outputDirectory == "" {
+		outputDirectory = DefaultOutputDirectory
+	}
+
+	return filepath.Abs(outputDirectory)
+}
+
+// Gets the path of an artifact in the output directory.
+func GetArtifactPath(outputDirectory string, certType string, certName string, extension string) string {
+	return filepath.Join(outputDirectory, GetArtifactPrefix(certType)+certName+extension)
+}
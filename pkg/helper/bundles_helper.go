@@ -0,0 +1,32 @@
+package helper
+
+import (
+	"fmt"
+	"strings"
+)
+
+// The servers a leaf certificate can be bundled for, each one wants its own layout of the key and chain.
+const (
+	NginxBundleProfile   = "nginx"
+	HAProxyBundleProfile = "haproxy"
+	EnvoyBundleProfile   = "envoy"
+	ApacheBundleProfile  = "apache"
+)
+
+// Validates the profile of a bundle, it has to be set.
+func CheckBundleProfile(profile string) (string, error) {
+	switch strings.ToLower(profile) {
+	case NginxBundleProfile:
+		return NginxBundleProfile, nil
+	case HAProxyBundleProfile:
+		return HAProxyBundleProfile, nil
+	case EnvoyBundleProfile:
+		return EnvoyBundleProfile, nil
+	case ApacheBundleProfile, "httpd":
+		return ApacheBundleProfile, nil
+	case "":
+		return "", fmt.Errorf("cannot be empty, must be %s, %s, %s or %s", NginxBundleProfile, HAProxyBundleProfile, EnvoyBundleProfile, ApacheBundleProfile)
+	}
+
+	return "", fmt.Errorf("unknown bundle profile: %s, must be %s, %s, %s or %s", profile, NginxBundleProfile, HAProxyBundleProfile, EnvoyBundleProfile, ApacheBundleProfile)
+}
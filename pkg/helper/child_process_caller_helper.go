@@ -1,28 +1,35 @@
 package helper
 
 import (
-	"fmt"
 	"os"
 	"os/exec"
 )
 
-// A function that executes a raw command and will panic if the command fails
-func ExecuteRawCommand(command string) {
-	// Execute the command
-	err := ExecuteCommand(command)
-
-	// Check if the command failed
-	if err != nil {
-		panic(fmt.Errorf("failed to execute the command: %s, because: %s", command, err))
-	}
+// Executes the command with /bin/sh, the error is an *ExecutionError carrying the exit code.
+func ExecuteCommand(command string) error {
+	return ExecuteCommandInDirectory(command, "")
 }
 
-func ExecuteCommand(command string) error {
+// Executes the command with /bin/sh in the directory, an empty directory is the current working directory.
+func ExecuteCommandInDirectory(command string, directory string) error {
 	// Execute the command
 	cmd := exec.Command("/bin/sh", "-c", command)
+	cmd.Dir = directory
 	cmd.Stdout = os.Stdout
 	cmd.Stderr = os.Stderr
 
 	// Execute the command
-	return cmd.Run()
+	err := cmd.Run()
+
+	if err != nil {
+		exitCode := -1
+
+		if exitErr, ok := err.(*exec.ExitError); ok {
+			exitCode = exitErr.ExitCode()
+		}
+
+		return &ExecutionError{Command: command, ExitCode: exitCode, Err: err}
+	}
+
+	return nil
 }
@@ -0,0 +1,74 @@
+package helper
+
+import (
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+)
+
+// The paths to remove if the process is interrupted or terminated before they are removed normally.
+var cleanup struct {
+	sync.Mutex
+
+	paths   map[string]bool
+	signals chan os.Signal
+}
+
+// Removes the path if the process is interrupted, terminated or hung up before the returned function is called. The
+// signals keep their default behaviour while nothing is registered.
+func RemoveOnSignal(path string) (unregister func()) {
+	cleanup.Lock()
+	defer cleanup.Unlock()
+
+	if cleanup.paths == nil {
+		cleanup.paths = make(map[string]bool)
+	}
+
+	if len(cleanup.paths) == 0 {
+		cleanup.signals = make(chan os.Signal, 1)
+		signal.Notify(cleanup.signals, os.Interrupt, syscall.SIGTERM, syscall.SIGHUP)
+
+		go removeOnSignal(cleanup.signals)
+	}
+
+	cleanup.paths[path] = true
+
+	return func() {
+		cleanup.Lock()
+		defer cleanup.Unlock()
+
+		if !cleanup.paths[path] {
+			return
+		}
+
+		delete(cleanup.paths, path)
+
+		if len(cleanup.paths) == 0 {
+			signal.Stop(cleanup.signals)
+			close(cleanup.signals)
+		}
+	}
+}
+
+func removeOnSignal(signals chan os.Signal) {
+	received, ok := <-signals
+	if !ok {
+		return
+	}
+
+	// The lock is never released, so nothing is registered while the process exits
+	cleanup.Lock()
+
+	for path := range cleanup.paths {
+		os.RemoveAll(path)
+	}
+
+	// Exit like the signal would have
+	code := 1
+	if number, ok := received.(syscall.Signal); ok {
+		code = 128 + int(number)
+	}
+
+	os.Exit(code)
+}
@@ -1,66 +1,154 @@
 package helper
 
 import (
+	"errors"
 	"fmt"
-	"os"
 	"strings"
+
+	"git.mfdlabs.local/petko/mfdlabs-ssl-go/pkg/secrets"
 )
 
-// There's a special kind of var here that does like this:
-// ${{ env.VAR_NAME }}, which is a special kind of var that will be replaced with the value of the environment variable VAR_NAME.
-// This is useful when you want to pass the value of an environment variable to a command.
-// We have to parse this out, and replace it with the value of the environment variable.
-func ReplaceEnvironmentExpression(input string) string {
-	// Check if the input is empty
-	if input == "" {
-		return input
+// Strings of the configuration can contain any number of ${{ }} expressions, each one is replaced with its value:
+//
+//	svc-${{ env.REGION }}-api
+//
+// An expression reads a secret through a provider of the secrets package, e.g. ${{ env.NAME }} or
+// ${{ file./run/secrets/ca }}. Alternatives are separated with ||, the first one that exists and isn't empty is used,
+// and quoted text is used as is, so ${{ env.X || 'dev' }} defaults to dev. $${{ is a literal ${{.
+func ResolveExpressions(input string) (string, error) {
+	// Check if the input contains the special var
+	if !strings.Contains(input, "${{") {
+		return input, nil
 	}
 
-	// Trim the input
-	input = strings.TrimSpace(input)
+	var output strings.Builder
 
-	// Remove spaces from the input
-	input = strings.Replace(input, " ", "", -1)
+	for {
+		start := strings.Index(input, "${{")
+		if start < 0 {
+			output.WriteString(input)
+			break
+		}
 
-	// Check if the input contains the special var
-	if !strings.Contains(input, "${{") {
-		return input
+		// $${{ is escaped, it is written without the first $
+		if start > 0 && input[start-1] == '$' {
+			output.WriteString(input[:start-1] + "${{")
+			input = input[start+3:]
+			continue
+		}
+
+		output.WriteString(input[:start])
+
+		end, err := findExpressionEnd(input[start+3:])
+		if err != nil {
+			return "", err
+		}
+
+		value, err := evaluateExpression(input[start+3 : start+3+end])
+		if err != nil {
+			return "", err
+		}
+
+		output.WriteString(value)
+		input = input[start+3+end+2:]
 	}
 
-	// Split the input into parts
-	parts := strings.Split(input, "${{")
+	return output.String(), nil
+}
+
+// Finds the }} that closes the expression, skipping the ones in quoted text.
+func findExpressionEnd(expression string) (int, error) {
+	var quote byte
 
-	// We now need to get the part in the middle of ${{ }}
-	otherPart := parts[1]
+	for i := 0; i < len(expression); i++ {
+		switch {
+		case quote != 0:
+			if expression[i] == quote {
+				quote = 0
+			}
+		case expression[i] == '\'' || expression[i] == '"':
+			quote = expression[i]
+		case strings.HasPrefix(expression[i:], "}}"):
+			return i, nil
+		}
+	}
+
+	return 0, fmt.Errorf("the expression ${{%s is not closed with }}", expression)
+}
 
-	// Split the middle part into parts
-	middleParts := strings.Split(otherPart, "}}")
+// Evaluates the alternatives of an expression, the part between ${{ and }}.
+func evaluateExpression(expression string) (string, error) {
+	alternatives := splitAlternatives(expression)
 
-	// Get the name of the environment variable
-	middlePart := middleParts[0]
+	var lastErr error
 
-	// Check if the middle part starts with env.
-	if !strings.HasPrefix(middlePart, "env.") {
-		return input
+	for _, alternative := range alternatives {
+		alternative = strings.TrimSpace(alternative)
+
+		if alternative == "" {
+			return "", fmt.Errorf("the expression ${{%s}} has an empty alternative", expression)
+		}
+
+		if literal, ok := parseLiteral(alternative); ok {
+			return literal, nil
+		}
+
+		value, ok, err := secrets.Resolve(alternative)
+		if !ok {
+			return "", fmt.Errorf("unknown expression %s, it has to start with one of %s followed by a dot", alternative, strings.Join(secrets.Names(), ", "))
+		}
+
+		// Only missing or empty values fall back to the next alternative, failures are reported
+		if err != nil && !errors.Is(err, secrets.ErrNotFound) {
+			return "", err
+		}
+
+		if err == nil && value != "" {
+			return value, nil
+		}
+
+		lastErr = err
 	}
 
-	// Get the env var name
-	envVarName := middlePart[4:]
+	return "", lastErr
+}
+
+// Splits the expression at the || that aren't in quoted text.
+func splitAlternatives(expression string) []string {
+	var alternatives []string
+	var quote byte
+
+	start := 0
+
+	for i := 0; i < len(expression); i++ {
+		switch {
+		case quote != 0:
+			if expression[i] == quote {
+				quote = 0
+			}
+		case expression[i] == '\'' || expression[i] == '"':
+			quote = expression[i]
+		case strings.HasPrefix(expression[i:], "||"):
+			alternatives = append(alternatives, expression[start:i])
+			start = i + 2
+			i++
+		}
+	}
+
+	return append(alternatives, expression[start:])
+}
 
-	// Check if the env var name is empty
-	if envVarName == "" {
-		return input
+// Parses quoted text like 'dev' or "dev", ok is false if the alternative isn't quoted.
+func parseLiteral(alternative string) (string, bool) {
+	if len(alternative) < 2 {
+		return "", false
 	}
 
-	// Get the env var value
-	envVarValue, isPresent := os.LookupEnv(envVarName)
+	quote := alternative[0]
 
-	// Check if the env var value is empty, if so, return it but warn the user
-	if !isPresent {
-		fmt.Printf("Warning: Unkown environment variable: %s\n", envVarName)
-		return ""
+	if (quote != '\'' && quote != '"') || alternative[len(alternative)-1] != quote {
+		return "", false
 	}
 
-	// Replace the env var value with the env var name
-	return envVarValue
+	return alternative[1 : len(alternative)-1], true
 }
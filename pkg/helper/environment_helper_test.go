@@ -0,0 +1,70 @@
+package helper
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestResolveExpressions(t *testing.T) {
+	setEnvironmentVariable(t, "SSL_GO_TEST_REGION", "eu")
+	setEnvironmentVariable(t, "SSL_GO_TEST_EMPTY", "")
+	os.Unsetenv("SSL_GO_TEST_UNSET")
+
+	tests := []struct {
+		name  string
+		input string
+		value string
+		err   string
+	}{
+		{name: "no expression", input: "plain ${ text }", value: "plain ${ text }"},
+		{name: "expression", input: "svc-${{ env.SSL_GO_TEST_REGION }}-api", value: "svc-eu-api"},
+		{name: "without spaces", input: "${{env.SSL_GO_TEST_REGION}}", value: "eu"},
+		{name: "several expressions", input: "${{ env.SSL_GO_TEST_REGION }}/${{ env.SSL_GO_TEST_UNSET || 'default' }}", value: "eu/default"},
+		{name: "default of an unset variable", input: "${{ env.SSL_GO_TEST_UNSET || 'dev' }}", value: "dev"},
+		{name: "default of an empty variable", input: `${{ env.SSL_GO_TEST_EMPTY || "dev" }}`, value: "dev"},
+		{name: "first existing alternative", input: "${{ env.SSL_GO_TEST_UNSET || env.SSL_GO_TEST_REGION || 'dev' }}", value: "eu"},
+		{name: "empty default", input: "a${{ env.SSL_GO_TEST_UNSET || '' }}b", value: "ab"},
+		{name: "quoted }}", input: "${{ env.SSL_GO_TEST_UNSET || 'a}}b' }}", value: "a}}b"},
+		{name: "quoted ||", input: `${{ env.SSL_GO_TEST_UNSET || "a||b" }}`, value: "a||b"},
+		{name: "escaped", input: "$${{ env.SSL_GO_TEST_REGION }}", value: "${{ env.SSL_GO_TEST_REGION }}"},
+		{name: "escaped before an expression", input: "$${{ x }} ${{ env.SSL_GO_TEST_REGION }}", value: "${{ x }} eu"},
+		{name: "unclosed", input: "${{ env.SSL_GO_TEST_REGION", err: "is not closed with }}"},
+		{name: "unclosed quote", input: "${{ 'dev }}", err: "is not closed with }}"},
+		{name: "empty alternative", input: "${{ env.SSL_GO_TEST_UNSET || }}", err: "has an empty alternative"},
+		{name: "unknown provider", input: "${{ nope.NAME }}", err: "unknown expression nope.NAME"},
+		{name: "no alternative exists", input: "${{ env.SSL_GO_TEST_UNSET }}", err: "SSL_GO_TEST_UNSET"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			value, err := ResolveExpressions(test.input)
+
+			if test.err != "" {
+				if err == nil || !strings.Contains(err.Error(), test.err) {
+					t.Fatalf("expected an error containing %q, got %q, %v", test.err, value, err)
+				}
+
+				return
+			}
+
+			if err != nil || value != test.value {
+				t.Fatalf("expected %q, got %q, %v", test.value, value, err)
+			}
+		})
+	}
+}
+
+func setEnvironmentVariable(t *testing.T, name string, value string) {
+	previous, isPresent := os.LookupEnv(name)
+
+	os.Setenv(name, value)
+
+	t.Cleanup(func() {
+		if isPresent {
+			os.Setenv(name, previous)
+		} else {
+			os.Unsetenv(name)
+		}
+	})
+}
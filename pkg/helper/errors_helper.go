@@ -0,0 +1,94 @@
+package helper
+
+import (
+	"fmt"
+	"strings"
+)
+
+// A ValidationError reports a configuration field of a certificate that is not valid.
+type ValidationError struct {
+	// The type of the certificate: root, intermediate or leaf.
+	CertificateType string
+
+	// The name of the certificate, empty if the name itself could not be resolved.
+	CertificateName string
+
+	// The field that is not valid, as it is named in the json configuration.
+	Field string
+
+	// What is wrong with the field.
+	Message string
+
+	// Where the field is in the configuration file, e.g. intermediate_ca[1].password. Empty if it isn't known.
+	Path string
+}
+
+func NewValidationError(certType string, certName string, field string, message string) *ValidationError {
+	return &ValidationError{
+		CertificateType: certType,
+		CertificateName: certName,
+		Field:           field,
+		Message:         message,
+	}
+}
+
+func (err *ValidationError) Error() string {
+	if err.CertificateName == "" {
+		return fmt.Sprintf("a %s certificate has an invalid %s: %s", err.CertificateType, err.Field, err.Message)
+	}
+
+	return fmt.Sprintf("the %s certificate %s has an invalid %s: %s", err.CertificateType, err.CertificateName, err.Field, err.Message)
+}
+
+// Gets the path of the field in the configuration file, e.g. intermediate_ca[1].password, the field itself if the path isn't known.
+func (err *ValidationError) Location() string {
+	if err.Path == "" {
+		return err.Field
+	}
+
+	return err.Path
+}
+
+// An ExecutionError reports a command that could not be run or exited with a non-zero exit code.
+type ExecutionError struct {
+	Command string
+
+	// The exit code of the command, -1 if it could not be started or was killed by a signal.
+	ExitCode int
+
+	Err error
+}
+
+func (err *ExecutionError) Error() string {
+	return fmt.Sprintf("failed to execute the command: %s, because: %s", err.Command, err.Err)
+}
+
+func (err *ExecutionError) Unwrap() error {
+	return err.Err
+}
+
+// Errors is a list of errors that are reported together.
+type Errors []error
+
+func (errs Errors) Error() string {
+	messages := make([]string, len(errs))
+
+	for i, err := range errs {
+		messages[i] = err.Error()
+	}
+
+	return strings.Join(messages, "\n")
+}
+
+// Appends the error to the list, flattening it if it's a list itself.
+func AppendError(errs Errors, err error) Errors {
+	if err == nil {
+		return errs
+	}
+
+	if list, ok := err.(Errors); ok {
+		return append(errs, list...)
+	}
+
+	return append(errs, err)
+}
@@ -0,0 +1,81 @@
+package helper
+
+import (
+	"fmt"
+	"strings"
+)
+
+// The key algorithms a certificate can be generated with.
+const (
+	RSAKeyAlgorithm     = "rsa"
+	ECDSAKeyAlgorithm   = "ecdsa"
+	Ed25519KeyAlgorithm = "ed25519"
+)
+
+// Validates the key size against the key algorithm, an empty algorithm is RSA and an empty (0) key size is
+// the default size of the algorithm. For ECDSA the key size selects the curve (P-256, P-384 or P-521).
+func CheckKeyAlgorithm(keyAlgorithm string, keySize int) (string, int, error) {
+	keyAlgorithm = strings.ToLower(keyAlgorithm)
+
+	switch keyAlgorithm {
+	case "", RSAKeyAlgorithm:
+		// If the key length is not set, set it to 2048
+		if keySize == 0 {
+			keySize = 2048
+		}
+
+		// If the key length is not 1024, 2048 or 4096, error out
+		if keySize != 1024 && keySize != 2048 && keySize != 4096 {
+			return "", 0, fmt.Errorf("the key length must be 1024, 2048 or 4096 for RSA keys")
+		}
+
+		return RSAKeyAlgorithm, keySize, nil
+	case ECDSAKeyAlgorithm:
+		// If the key length is not set, use P-256
+		if keySize == 0 {
+			keySize = 256
+		}
+
+		if keySize != 256 && keySize != 384 && keySize != 521 {
+			return "", 0, fmt.Errorf("the key length must be 256, 384 or 521 for ECDSA keys")
+		}
+
+		return ECDSAKeyAlgorithm, keySize, nil
+	case Ed25519KeyAlgorithm:
+		// Ed25519 keys have a fixed size
+		if keySize != 0 && keySize != 256 {
+			return "", 0, fmt.Errorf("the key length of Ed25519 keys is fixed, it must be 256 or not set")
+		}
+
+		return Ed25519KeyAlgorithm, 256, nil
+	}
+
+	return "", 0, fmt.Errorf("unknown key algorithm: %s, must be %s, %s or %s", keyAlgorithm, RSAKeyAlgorithm, ECDSAKeyAlgorithm, Ed25519KeyAlgorithm)
+}
+
+// Gets the key usages of a leaf certificate without configured ones. Only RSA keys can be used for key encipherment.
+func GetDefaultLeafKeyUsages(keyAlgorithm string) []string {
+	if keyAlgorithm == "" || strings.ToLower(keyAlgorithm) == RSAKeyAlgorithm {
+		return []string{"digitalSignature", "keyEncipherment"}
+	}
+
+	return []string{"digitalSignature"}
+}
+
+// What happens to the private key of a certificate when it is renewed.
+const (
+	RotateRenewalKeyPolicy = "rotate"
+	ReuseRenewalKeyPolicy  = "reuse"
+)
+
+// Validates the renewal key policy, an empty policy rotates the key.
+func CheckRenewalKeyPolicy(policy string) (string, error) {
+	switch strings.ToLower(policy) {
+	case "", RotateRenewalKeyPolicy:
+		return RotateRenewalKeyPolicy, nil
+	case ReuseRenewalKeyPolicy:
+		return ReuseRenewalKeyPolicy, nil
+	}
+
+	return "", fmt.Errorf("unknown renewal key policy: %s, must be %s or %s", policy, RotateRenewalKeyPolicy, ReuseRenewalKeyPolicy)
+}
@@ -0,0 +1,51 @@
+package helper
+
+import (
+	"fmt"
+	"strings"
+)
+
+// What a keystore of a certificate holds: its private key and chain, or the root of its chain to trust.
+const (
+	KeystoreType   = "keystore"
+	TruststoreType = "truststore"
+)
+
+// The formats a keystore can be written in.
+const (
+	PKCS12KeystoreFormat = "pkcs12"
+	JKSKeystoreFormat    = "jks"
+)
+
+// Validates the type of a keystore, an empty type is a keystore.
+func CheckKeystoreType(keystoreType string) (string, error) {
+	switch strings.ToLower(keystoreType) {
+	case "", KeystoreType:
+		return KeystoreType, nil
+	case TruststoreType:
+		return TruststoreType, nil
+	}
+
+	return "", fmt.Errorf("unknown keystore type: %s, must be %s or %s", keystoreType, KeystoreType, TruststoreType)
+}
+
+// Validates the format of a keystore, an empty format is PKCS#12 which keytool defaults to since Java 9.
+func CheckKeystoreFormat(format string) (string, error) {
+	switch strings.ToLower(format) {
+	case "", PKCS12KeystoreFormat, "p12", "pfx":
+		return PKCS12KeystoreFormat, nil
+	case JKSKeystoreFormat:
+		return JKSKeystoreFormat, nil
+	}
+
+	return "", fmt.Errorf("unknown keystore format: %s, must be %s or %s", format, PKCS12KeystoreFormat, JKSKeystoreFormat)
+}
+
+// Gets the extension of a keystore that doesn't set its path.
+func GetKeystoreExtension(keystoreType string, format string) string {
+	if keystoreType == TruststoreType {
+		return Ternary(format == JKSKeystoreFormat, JKSTruststoreExtension, PKCS12TruststoreExtension).(string)
+	}
+
+	return Ternary(format == JKSKeystoreFormat, JKSKeystoreExtension, PKCS12KeystoreExtension).(string)
+}
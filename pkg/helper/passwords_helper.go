@@ -2,33 +2,58 @@ package helper
 
 import (
 	"fmt"
+	"io/ioutil"
 	"os"
+	"path/filepath"
+	"runtime"
 )
 
-func WritePasswordFile(certType string, certName string, passwordType string, password string) (string, error) {
-	// Get cwd
-	cwd, err := os.Getwd()
+// A private directory the passwords handed to the generation scripts are written to, so they never touch the output
+// directory. It's on a tmpfs where there is one, and removed even if the process is interrupted.
+type SecretDirectory struct {
+	Path string
 
+	unregister func()
+}
+
+// Creates a new secret directory that only the current user can access.
+func NewSecretDirectory() (*SecretDirectory, error) {
+	path, err := ioutil.TempDir(getSecretDirectoryBase(), "ssl-go-secrets-")
+
+	// Fall back to the temporary directory if the tmpfs can't be written to
 	if err != nil {
-		return "", err
+		path, err = ioutil.TempDir("", "ssl-go-secrets-")
 	}
 
-	// Check if the cwd is empty
-	if cwd == "" {
-		return "", fmt.Errorf("failed to get the current working directory")
+	if err != nil {
+		return nil, err
 	}
 
-	fileName := fmt.Sprintf("%s/bin/%s_%s_%s.txt", cwd, certType, certName, passwordType)
-	file, err := os.Create(fileName)
+	// TempDir creates the directory with 0700, the umask can only remove permissions
+	err = os.Chmod(path, 0700)
+	if err != nil {
+		os.RemoveAll(path)
+		return nil, err
+	}
+
+	return &SecretDirectory{Path: path, unregister: RemoveOnSignal(path)}, nil
+}
+
+// Writes the password to a new file only the current user can read, returning its path.
+func (directory *SecretDirectory) WritePasswordFile(certType string, certName string, passwordType string, password string) (string, error) {
+	fileName := filepath.Join(directory.Path, fmt.Sprintf("%s_%s_%s.txt", certType, certName, passwordType))
+	file, err := os.OpenFile(fileName, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
 
 	if err != nil {
 		return "", err
 	}
 
-	defer file.Close()
-
 	_, err = file.WriteString(password)
 
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+
 	if err != nil {
 		return "", err
 	}
@@ -36,8 +61,20 @@ func WritePasswordFile(certType string, certName string, passwordType string, pa
 	return fileName, nil
 }
 
-func DeleteTmpPasswords(fileNames []string) {
-	for _, fileName := range fileNames {
-		os.Remove(fileName)
+// Removes the directory and every password in it.
+func (directory *SecretDirectory) Remove() error {
+	defer directory.unregister()
+
+	return os.RemoveAll(directory.Path)
+}
+
+// Gets the directory the secret directories are created in, the shared memory tmpfs on Linux.
+func getSecretDirectoryBase() string {
+	if runtime.GOOS == "linux" {
+		if info, err := os.Stat("/dev/shm"); err == nil && info.IsDir() {
+			return "/dev/shm"
+		}
 	}
+
+	return ""
 }
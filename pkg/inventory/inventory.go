@@ -0,0 +1,221 @@
+// Package inventory records every certificate ssl-go issues in a JSON file in the output directory, so the
+// certificates can be queried without parsing the output directory.
+package inventory
+
+import (
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"git.mfdlabs.local/petko/mfdlabs-ssl-go/pkg/revocation"
+)
+
+// The name of the inventory file in the output directory.
+const FileName = "inventory.json"
+
+// The version of the inventory file format.
+const currentVersion = 1
+
+// Every certificate issued into an output directory, in the order they were issued. A certificate that is issued
+// again gets a new record, the previous ones are kept as its history.
+type Inventory struct {
+	path string
+
+	Version      int       `json:"version"`
+	Certificates []*Record `json:"certificates"`
+}
+
+// An issued certificate.
+type Record struct {
+	// The type of the certificate: root, intermediate, leaf or signed.
+	Type string `json:"type"`
+	Name string `json:"name"`
+
+	// The certificate authority that issued the certificate, empty for root certificate authorities.
+	ParentType string `json:"parentType,omitempty"`
+	ParentName string `json:"parentName,omitempty"`
+
+	// The serial number in upper case hexadecimal, like the revocation lists store it.
+	SerialNumber string `json:"serialNumber"`
+
+	// The SHA-256 fingerprint of the certificate, formatted like openssl x509 -fingerprint -sha256 prints it.
+	Fingerprint string `json:"fingerprint"`
+
+	Subject   string    `json:"subject"`
+	NotBefore time.Time `json:"notBefore"`
+	NotAfter  time.Time `json:"notAfter"`
+
+	// The subject alternative names of the certificate.
+	DNSNames       []string `json:"dnsNames,omitempty"`
+	EmailAddresses []string `json:"emailAddresses,omitempty"`
+	IPAddresses    []string `json:"ipAddresses,omitempty"`
+
+	// The SHA-256 hash of the configuration the certificate was issued from, passwords left out. Two records with the
+	// same hash were issued from the same configuration.
+	ConfigurationHash string `json:"configurationHash"`
+
+	// The backend that issued the certificate and when.
+	Backend  string    `json:"backend"`
+	IssuedAt time.Time `json:"issuedAt"`
+
+	Artifacts *ArtifactPaths `json:"artifacts"`
+}
+
+// The absolute paths of the artifacts of a certificate, the ones that weren't written are empty.
+type ArtifactPaths struct {
+	PrivateKey         string `json:"privateKey,omitempty"`
+	Certificate        string `json:"certificate,omitempty"`
+	Chain              string `json:"chain,omitempty"`
+	Pfx                string `json:"pfx,omitempty"`
+	CertificateRequest string `json:"certificateRequest,omitempty"`
+	DHParameters       string `json:"dhParameters,omitempty"`
+	Configuration      string `json:"configuration,omitempty"`
+}
+
+// Gets the path of the inventory of an output directory.
+func GetPath(outputDirectory string) string {
+	return filepath.Join(outputDirectory, FileName)
+}
+
+// Loads the inventory of an output directory, an output directory without one has an empty inventory.
+func Load(outputDirectory string) (*Inventory, error) {
+	path := GetPath(outputDirectory)
+	inventory := &Inventory{path: path, Version: currentVersion}
+
+	content, err := ioutil.ReadFile(path)
+
+	if os.IsNotExist(err) {
+		return inventory, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	err = json.Unmarshal(content, inventory)
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse the inventory %s: %s", path, err)
+	}
+
+	if inventory.Version > currentVersion {
+		return nil, fmt.Errorf("the inventory %s was written by a newer version of ssl-go (version %d)", path, inventory.Version)
+	}
+
+	inventory.Version = currentVersion
+
+	return inventory, nil
+}
+
+// Writes the inventory to its file. The file is replaced at once, so an interrupted write never loses the records.
+func (inventory *Inventory) Save() error {
+	content, err := json.MarshalIndent(inventory, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	err = os.MkdirAll(filepath.Dir(inventory.path), 0755)
+	if err != nil {
+		return err
+	}
+
+	file, err := ioutil.TempFile(filepath.Dir(inventory.path), "."+FileName+"-")
+	if err != nil {
+		return err
+	}
+
+	defer os.Remove(file.Name())
+
+	_, err = file.Write(append(content, '\n'))
+
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+
+	if err != nil {
+		return err
+	}
+
+	err = os.Chmod(file.Name(), 0644)
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(file.Name(), inventory.path)
+}
+
+// Records an issued certificate.
+func (inventory *Inventory) Add(record *Record) {
+	inventory.Certificates = append(inventory.Certificates, record)
+}
+
+// Gets the latest record of every certificate, in the order the certificates were first issued.
+func (inventory *Inventory) Latest() []*Record {
+	var latest []*Record
+
+	indexes := make(map[string]int)
+
+	for _, record := range inventory.Certificates {
+		key := record.Type + "/" + record.Name
+
+		if index, ok := indexes[key]; ok {
+			latest[index] = record
+			continue
+		}
+
+		indexes[key] = len(latest)
+		latest = append(latest, record)
+	}
+
+	return latest
+}
+
+// Gets every record of the certificates with the name, oldest first. An empty type matches every type.
+func (inventory *Inventory) Find(certType string, name string) []*Record {
+	var records []*Record
+
+	for _, record := range inventory.Certificates {
+		if record.Name == name && (certType == "" || record.Type == certType) {
+			records = append(records, record)
+		}
+	}
+
+	return records
+}
+
+// Creates the record of an issued certificate, the fields that don't come from the certificate are left empty.
+func NewRecord(certificate *x509.Certificate) *Record {
+	record := &Record{
+		SerialNumber:   revocation.FormatSerialNumber(certificate.SerialNumber),
+		Fingerprint:    FormatFingerprint(certificate),
+		Subject:        certificate.Subject.String(),
+		NotBefore:      certificate.NotBefore.UTC(),
+		NotAfter:       certificate.NotAfter.UTC(),
+		DNSNames:       certificate.DNSNames,
+		EmailAddresses: certificate.EmailAddresses,
+	}
+
+	for _, ip := range certificate.IPAddresses {
+		record.IPAddresses = append(record.IPAddresses, ip.String())
+	}
+
+	return record
+}
+
+// Formats the SHA-256 fingerprint of the certificate as colon separated upper case hexadecimal.
+func FormatFingerprint(certificate *x509.Certificate) string {
+	sum := sha256.Sum256(certificate.Raw)
+	parts := make([]string, len(sum))
+
+	for i, b := range sum {
+		parts[i] = fmt.Sprintf("%02X", b)
+	}
+
+	return strings.Join(parts, ":")
+}
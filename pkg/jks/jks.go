@@ -0,0 +1,197 @@
+// Package jks implements an encoder for the Java KeyStore (JKS) format of the Sun provider.
+package jks
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/binary"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf16"
+)
+
+const (
+	magic   = 0xfeedfeed
+	version = 2
+
+	privateKeyTag         = 1
+	trustedCertificateTag = 2
+
+	// The integrity check of the keystore is keyed with this text after the password, like the JDK does.
+	whitener = "Mighty Aphrodite"
+)
+
+// The algorithm of the key protection of the Sun provider.
+var oidKeyProtector = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 42, 2, 17, 1, 1}
+
+type encryptedPrivateKeyInfo struct {
+	Algorithm     pkix.AlgorithmIdentifier
+	EncryptedData []byte
+}
+
+// A private key with its certificate chain, the certificate of the key comes first.
+type PrivateKeyEntry struct {
+	Alias      string
+	PrivateKey interface{}
+	Chain      []*x509.Certificate
+
+	// The password the key is protected with, usually the password of the keystore.
+	Password string
+}
+
+// A certificate that is trusted, like a root certificate authority in a truststore.
+type TrustedCertificateEntry struct {
+	Alias       string
+	Certificate *x509.Certificate
+}
+
+// Encode produces a JKS keystore with the entries, protected with the password. Aliases are lower cased like
+// keytool does.
+func Encode(password string, keys []*PrivateKeyEntry, certificates []*TrustedCertificateEntry) ([]byte, error) {
+	var out bytes.Buffer
+
+	timestamp := time.Now().UnixNano() / int64(time.Millisecond)
+
+	writeUint32(&out, magic)
+	writeUint32(&out, version)
+	writeUint32(&out, uint32(len(keys)+len(certificates)))
+
+	for _, entry := range keys {
+		if len(entry.Chain) == 0 {
+			return nil, fmt.Errorf("the private key %s has no certificate", entry.Alias)
+		}
+
+		pkcs8, err := x509.MarshalPKCS8PrivateKey(entry.PrivateKey)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal the private key %s: %s", entry.Alias, err)
+		}
+
+		protected, err := protectKey(pkcs8, entry.Password)
+		if err != nil {
+			return nil, err
+		}
+
+		writeUint32(&out, privateKeyTag)
+		writeUTF(&out, strings.ToLower(entry.Alias))
+		writeUint64(&out, uint64(timestamp))
+		writeBytes(&out, protected)
+		writeUint32(&out, uint32(len(entry.Chain)))
+
+		for _, certificate := range entry.Chain {
+			writeCertificate(&out, certificate)
+		}
+	}
+
+	for _, entry := range certificates {
+		writeUint32(&out, trustedCertificateTag)
+		writeUTF(&out, strings.ToLower(entry.Alias))
+		writeUint64(&out, uint64(timestamp))
+		writeCertificate(&out, entry.Certificate)
+	}
+
+	// The digest covers the password, the whitener and everything written so far
+	digest := sha1.New()
+	digest.Write(encodePassword(password))
+	digest.Write([]byte(whitener))
+	digest.Write(out.Bytes())
+
+	out.Write(digest.Sum(nil))
+
+	return out.Bytes(), nil
+}
+
+// Protects the PKCS#8 key like sun.security.provider.KeyProtector: the key is XORed with a SHA-1 keystream of the
+// password and a random salt, followed by a SHA-1 check of the password and the key.
+func protectKey(pkcs8 []byte, password string) ([]byte, error) {
+	encodedPassword := encodePassword(password)
+
+	salt := make([]byte, sha1.Size)
+
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+
+	encrypted := make([]byte, len(pkcs8))
+	digest := salt
+
+	for offset := 0; offset < len(pkcs8); offset += sha1.Size {
+		hash := sha1.New()
+		hash.Write(encodedPassword)
+		hash.Write(digest)
+		digest = hash.Sum(nil)
+
+		for i := 0; i < sha1.Size && offset+i < len(pkcs8); i++ {
+			encrypted[offset+i] = pkcs8[offset+i] ^ digest[i]
+		}
+	}
+
+	check := sha1.New()
+	check.Write(encodedPassword)
+	check.Write(pkcs8)
+
+	protected := append(append(append([]byte{}, salt...), encrypted...), check.Sum(nil)...)
+
+	return asn1.Marshal(encryptedPrivateKeyInfo{
+		Algorithm:     pkix.AlgorithmIdentifier{Algorithm: oidKeyProtector, Parameters: asn1.NullRawValue},
+		EncryptedData: protected,
+	})
+}
+
+// The JDK uses the UTF-16 big endian code units of the password as its bytes.
+func encodePassword(password string) []byte {
+	var encoded []byte
+
+	for _, char := range utf16.Encode([]rune(password)) {
+		encoded = append(encoded, byte(char>>8), byte(char))
+	}
+
+	return encoded
+}
+
+func writeCertificate(out *bytes.Buffer, certificate *x509.Certificate) {
+	writeUTF(out, "X.509")
+	writeBytes(out, certificate.Raw)
+}
+
+func writeUint32(out *bytes.Buffer, value uint32) {
+	var buffer [4]byte
+	binary.BigEndian.PutUint32(buffer[:], value)
+	out.Write(buffer[:])
+}
+
+func writeUint64(out *bytes.Buffer, value uint64) {
+	var buffer [8]byte
+	binary.BigEndian.PutUint64(buffer[:], value)
+	out.Write(buffer[:])
+}
+
+func writeBytes(out *bytes.Buffer, value []byte) {
+	writeUint32(out, uint32(len(value)))
+	out.Write(value)
+}
+
+// Writes the text like DataOutput.writeUTF: a 16 bit length followed by the modified UTF-8 of its UTF-16 code units.
+func writeUTF(out *bytes.Buffer, value string) {
+	var encoded []byte
+
+	for _, char := range utf16.Encode([]rune(value)) {
+		switch {
+		case char >= 0x01 && char <= 0x7f:
+			encoded = append(encoded, byte(char))
+		case char <= 0x7ff:
+			encoded = append(encoded, byte(0xc0|char>>6), byte(0x80|char&0x3f))
+		default:
+			encoded = append(encoded, byte(0xe0|char>>12), byte(0x80|(char>>6)&0x3f), byte(0x80|char&0x3f))
+		}
+	}
+
+	var length [2]byte
+	binary.BigEndian.PutUint16(length[:], uint16(len(encoded)))
+	out.Write(length[:])
+	out.Write(encoded)
+}
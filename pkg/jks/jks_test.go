@@ -0,0 +1,348 @@
+package jks
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/big"
+	"testing"
+	"time"
+	"unicode/utf16"
+)
+
+// An empty keystore is only the header and the digest, so its bytes are known. The digests were computed with
+// sha1sum over the UTF-16BE password, "Mighty Aphrodite" and the header.
+func TestEncodeEmpty(t *testing.T) {
+	tests := []struct {
+		password string
+		digest   string
+	}{
+		{password: "changeit", digest: "e2686e45fb43dfa4d992dd41ceb6b21c6330d792"},
+		{password: "é\U0001f600", digest: "c5ca9ab0a18f97212c952d412edd36548fa3e387"},
+	}
+
+	for _, test := range tests {
+		data, err := Encode(test.password, nil, nil)
+		if err != nil {
+			t.Fatalf("failed to encode: %s", err)
+		}
+
+		expected := "feedfeed" + "00000002" + "00000000" + test.digest
+
+		if hex.EncodeToString(data) != expected {
+			t.Errorf("%q: expected %s, got %x", test.password, expected, data)
+		}
+	}
+}
+
+func TestEncodeParseBack(t *testing.T) {
+	caKey, caCertificate := createCertificate(t, "ssl-go test root", nil, nil)
+	leafKey, leafCertificate := createCertificate(t, "leaf.ssl-go.test", caKey, caCertificate)
+
+	before := time.Now().Add(-time.Second)
+
+	data, err := Encode("storepass", []*PrivateKeyEntry{
+		{Alias: "MyLeaf", PrivateKey: leafKey, Chain: []*x509.Certificate{leafCertificate, caCertificate}, Password: "keypass"},
+	}, []*TrustedCertificateEntry{
+		{Alias: "Café Root", Certificate: caCertificate},
+	})
+	if err != nil {
+		t.Fatalf("failed to encode: %s", err)
+	}
+
+	entries, err := decodeKeystore(data, "storepass")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(entries) != 2 {
+		t.Fatalf("expected 2 entries, got %d", len(entries))
+	}
+
+	key, trusted := entries[0], entries[1]
+
+	if key.tag != privateKeyTag || key.alias != "myleaf" {
+		t.Fatalf("expected the private key myleaf first, got tag %d with alias %q", key.tag, key.alias)
+	}
+
+	if trusted.tag != trustedCertificateTag || trusted.alias != "café root" {
+		t.Fatalf("expected the trusted certificate café root second, got tag %d with alias %q", trusted.tag, trusted.alias)
+	}
+
+	for _, entry := range entries {
+		if entry.timestamp.Before(before) || entry.timestamp.After(time.Now()) {
+			t.Fatalf("the entry %s was stamped %s", entry.alias, entry.timestamp)
+		}
+	}
+
+	privateKey, err := recoverKey(key.protectedKey, "keypass")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !leafKey.Equal(privateKey) {
+		t.Fatal("the recovered private key is not the one that was protected")
+	}
+
+	if _, err := recoverKey(key.protectedKey, "storepass"); err == nil {
+		t.Fatal("expected the private key not to be recovered with the keystore password")
+	}
+
+	if len(key.certificates) != 2 || !key.certificates[0].Equal(leafCertificate) || !key.certificates[1].Equal(caCertificate) {
+		t.Fatal("the chain of the private key is not the leaf followed by its certificate authority")
+	}
+
+	if len(trusted.certificates) != 1 || !trusted.certificates[0].Equal(caCertificate) {
+		t.Fatal("the trusted certificate is not the certificate authority")
+	}
+
+	if _, err := decodeKeystore(data, "keypass"); err == nil {
+		t.Fatal("expected the digest not to match with the wrong password")
+	}
+
+	data[len(data)/2] ^= 1
+
+	if _, err := decodeKeystore(data, "storepass"); err == nil {
+		t.Fatal("expected the digest not to match a modified keystore")
+	}
+}
+
+func TestEncodeWithoutChain(t *testing.T) {
+	key, _ := createCertificate(t, "leaf.ssl-go.test", nil, nil)
+
+	_, err := Encode("storepass", []*PrivateKeyEntry{{Alias: "leaf", PrivateKey: key, Password: "storepass"}}, nil)
+	if err == nil {
+		t.Fatal("expected a private key without a certificate to fail")
+	}
+}
+
+// An entry of a keystore as the JDK reads it.
+type decodedEntry struct {
+	tag          uint32
+	alias        string
+	timestamp    time.Time
+	protectedKey []byte
+	certificates []*x509.Certificate
+}
+
+// Reads the keystore like sun.security.provider.JavaKeyStore.engineLoad, checking its digest against the password.
+func decodeKeystore(data []byte, password string) ([]*decodedEntry, error) {
+	if len(data) < 12+sha1.Size {
+		return nil, errors.New("the keystore is truncated")
+	}
+
+	content, digest := data[:len(data)-sha1.Size], data[len(data)-sha1.Size:]
+
+	expected := sha1.New()
+	expected.Write(encodePassword(password))
+	expected.Write([]byte(whitener))
+	expected.Write(content)
+
+	if !bytes.Equal(expected.Sum(nil), digest) {
+		return nil, errors.New("the keystore has been tampered with, or the password is wrong")
+	}
+
+	in := bytes.NewReader(content)
+
+	var header struct {
+		Magic, Version, Count uint32
+	}
+
+	if err := binary.Read(in, binary.BigEndian, &header); err != nil {
+		return nil, err
+	}
+
+	if header.Magic != magic || header.Version != version {
+		return nil, fmt.Errorf("unexpected magic %x or version %d", header.Magic, header.Version)
+	}
+
+	var entries []*decodedEntry
+
+	for i := uint32(0); i < header.Count; i++ {
+		entry := &decodedEntry{}
+
+		var timestamp uint64
+
+		if err := binary.Read(in, binary.BigEndian, &entry.tag); err != nil {
+			return nil, err
+		}
+
+		entry.alias = readUTF(in)
+
+		if err := binary.Read(in, binary.BigEndian, &timestamp); err != nil {
+			return nil, err
+		}
+
+		entry.timestamp = time.Unix(0, int64(timestamp)*int64(time.Millisecond))
+
+		count := uint32(1)
+
+		switch entry.tag {
+		case privateKeyTag:
+			entry.protectedKey = readBytes(in)
+
+			if err := binary.Read(in, binary.BigEndian, &count); err != nil {
+				return nil, err
+			}
+		case trustedCertificateTag:
+		default:
+			return nil, fmt.Errorf("unexpected tag %d", entry.tag)
+		}
+
+		for j := uint32(0); j < count; j++ {
+			if certificateType := readUTF(in); certificateType != "X.509" {
+				return nil, fmt.Errorf("unexpected certificate type %q", certificateType)
+			}
+
+			certificate, err := x509.ParseCertificate(readBytes(in))
+			if err != nil {
+				return nil, err
+			}
+
+			entry.certificates = append(entry.certificates, certificate)
+		}
+
+		entries = append(entries, entry)
+	}
+
+	if in.Len() != 0 {
+		return nil, fmt.Errorf("%d bytes follow the entries", in.Len())
+	}
+
+	return entries, nil
+}
+
+// Recovers the private key like sun.security.provider.KeyProtector.recover, which checks the SHA-1 of the password
+// and the key that follows it.
+func recoverKey(protectedKey []byte, password string) (*ecdsa.PrivateKey, error) {
+	var info encryptedPrivateKeyInfo
+
+	if _, err := asn1.Unmarshal(protectedKey, &info); err != nil {
+		return nil, err
+	}
+
+	if !info.Algorithm.Algorithm.Equal(oidKeyProtector) {
+		return nil, fmt.Errorf("unexpected key protection %s", info.Algorithm.Algorithm)
+	}
+
+	protected := info.EncryptedData
+	if len(protected) < 2*sha1.Size {
+		return nil, errors.New("the protected key is truncated")
+	}
+
+	salt, encrypted, check := protected[:sha1.Size], protected[sha1.Size:len(protected)-sha1.Size], protected[len(protected)-sha1.Size:]
+
+	encodedPassword := encodePassword(password)
+	key := make([]byte, len(encrypted))
+	digest := salt
+
+	for offset := 0; offset < len(encrypted); offset += sha1.Size {
+		digest = sha1Sum(encodedPassword, digest)
+
+		for i := 0; i < sha1.Size && offset+i < len(encrypted); i++ {
+			key[offset+i] = encrypted[offset+i] ^ digest[i]
+		}
+	}
+
+	if !bytes.Equal(sha1Sum(encodedPassword, key), check) {
+		return nil, errors.New("cannot recover the key, the password is wrong")
+	}
+
+	privateKey, err := x509.ParsePKCS8PrivateKey(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return privateKey.(*ecdsa.PrivateKey), nil
+}
+
+func sha1Sum(parts ...[]byte) []byte {
+	hash := sha1.New()
+
+	for _, part := range parts {
+		hash.Write(part)
+	}
+
+	return hash.Sum(nil)
+}
+
+func readBytes(in *bytes.Reader) []byte {
+	var length uint32
+	binary.Read(in, binary.BigEndian, &length)
+
+	value := make([]byte, length)
+	in.Read(value)
+
+	return value
+}
+
+// Reads the text like DataInput.readUTF, only the one to three byte forms writeUTF produces.
+func readUTF(in *bytes.Reader) string {
+	var length uint16
+	binary.Read(in, binary.BigEndian, &length)
+
+	encoded := make([]byte, length)
+	in.Read(encoded)
+
+	var chars []uint16
+
+	for i := 0; i < len(encoded); {
+		switch {
+		case encoded[i] < 0x80:
+			chars = append(chars, uint16(encoded[i]))
+			i++
+		case encoded[i] < 0xe0:
+			chars = append(chars, uint16(encoded[i]&0x1f)<<6|uint16(encoded[i+1]&0x3f))
+			i += 2
+		default:
+			chars = append(chars, uint16(encoded[i]&0x0f)<<12|uint16(encoded[i+1]&0x3f)<<6|uint16(encoded[i+2]&0x3f))
+			i += 3
+		}
+	}
+
+	return string(utf16.Decode(chars))
+}
+
+// Creates a certificate for the key, self-signed when there's no issuer.
+func createCertificate(t *testing.T, commonName string, issuerKey *ecdsa.PrivateKey, issuer *x509.Certificate) (*ecdsa.PrivateKey, *x509.Certificate) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: commonName},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  issuer == nil,
+		BasicConstraintsValid: true,
+	}
+
+	if issuer == nil {
+		issuer, issuerKey = template, key
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, issuer, &key.PublicKey, issuerKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	certificate, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return key, certificate
+}
@@ -0,0 +1,156 @@
+package nss
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// Runs certutil on the database, the password is passed in a file so it isn't on the command line.
+func runCertutil(options *Options, database string, args ...string) (string, error) {
+	if _, err := exec.LookPath("certutil"); err != nil {
+		return "", fmt.Errorf("certutil is not installed")
+	}
+
+	args = append([]string{"-d", "sql:" + database}, args...)
+
+	passwordFile, err := ioutil.TempFile("", "ssl-go-nss-*")
+	if err != nil {
+		return "", err
+	}
+
+	defer os.Remove(passwordFile.Name())
+
+	_, err = passwordFile.WriteString(options.Password + "\n")
+	if closeErr := passwordFile.Close(); err == nil {
+		err = closeErr
+	}
+
+	if err != nil {
+		return "", err
+	}
+
+	args = append(args, "-f", passwordFile.Name())
+
+	var stdout, stderr bytes.Buffer
+
+	cmd := exec.Command("certutil", args...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	err = cmd.Run()
+	if err != nil {
+		if message := strings.TrimSpace(stderr.String()); message != "" {
+			return "", fmt.Errorf("certutil failed on %s: %s", database, message)
+		}
+
+		return "", fmt.Errorf("certutil failed on %s: %s", database, err)
+	}
+
+	return stdout.String(), nil
+}
+
+// Creates the database if it doesn't exist, with the password of the options.
+func createWithCertutil(options *Options, database string) error {
+	if Exists(database) {
+		return nil
+	}
+
+	err := os.MkdirAll(database, 0700)
+	if err != nil {
+		return err
+	}
+
+	if options.Password == "" {
+		_, err = runCertutil(options, database, "-N", "--empty-password")
+	} else {
+		_, err = runCertutil(options, database, "-N")
+	}
+
+	return err
+}
+
+func importWithCertutil(options *Options, database string, nickname string, certificate *x509.Certificate) error {
+	err := createWithCertutil(options, database)
+	if err != nil {
+		return err
+	}
+
+	// certutil adds another certificate under the nickname instead of replacing it
+	_, err = removeWithCertutil(options, database, nickname)
+	if err != nil {
+		return err
+	}
+
+	file, err := ioutil.TempFile("", "ssl-go-nss-*.crt")
+	if err != nil {
+		return err
+	}
+
+	defer os.Remove(file.Name())
+
+	err = pem.Encode(file, &pem.Block{Type: "CERTIFICATE", Bytes: certificate.Raw})
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+
+	if err != nil {
+		return err
+	}
+
+	_, err = runCertutil(options, database, "-A", "-n", nickname, "-t", "C,,", "-i", file.Name())
+
+	return err
+}
+
+func removeWithCertutil(options *Options, database string, nickname string) (bool, error) {
+	removed := false
+
+	for {
+		nicknames, err := listWithCertutil(options, database)
+		if err != nil {
+			return false, err
+		}
+
+		if !containsName(nicknames, nickname) {
+			return removed, nil
+		}
+
+		_, err = runCertutil(options, database, "-D", "-n", nickname)
+		if err != nil {
+			return false, err
+		}
+
+		removed = true
+	}
+}
+
+func listWithCertutil(options *Options, database string) ([]string, error) {
+	output, err := runCertutil(options, database, "-L")
+	if err != nil {
+		return nil, err
+	}
+
+	var nicknames []string
+
+	// The nickname is followed by the trust attributes, after a header and an empty line
+	scanner := bufio.NewScanner(strings.NewReader(output))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+
+		separator := strings.LastIndexAny(line, " \t")
+		if separator < 0 || strings.Count(line[separator+1:], ",") != 2 {
+			continue
+		}
+
+		nicknames = append(nicknames, strings.TrimSpace(line[:separator]))
+	}
+
+	return nicknames, nil
+}
@@ -0,0 +1,507 @@
+package nss
+
+import (
+	"bytes"
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"git.mfdlabs.local/petko/mfdlabs-ssl-go/pkg/sqlite"
+)
+
+const (
+	certificateDatabaseName = "cert9.db"
+	keyDatabaseName         = "key4.db"
+
+	// The Berkeley DB database of the NSS versions before 3.35, only certutil can read it.
+	legacyDatabaseName = "cert8.db"
+)
+
+// The columns of the object tables, the id and the PKCS#11 attributes NSS knows as a<type in hex>.
+const objectColumns = "id PRIMARY KEY UNIQUE ON CONFLICT ABORT, a0, a1, a2, a3, a10, a11, a12, a80, a81, a82, a83, " +
+	"a84, a85, a86, a87, a88, a89, a8a, a8b, a90, a100, a101, a102, a103, a104, a105, a106, a107, a108, a109, a10a, " +
+	"a10b, a10c, a110, a111, a120, a121, a122, a123, a124, a125, a126, a127, a128, a129, a130, a131, a132, a133, " +
+	"a134, a160, a161, a162, a163, a164, a165, a166, a170, a180, a181, a200, a201, a202, a210, a300, a301, a302, " +
+	"a400, a401, a402, a403, a404, a405, a406, a480, a481, a482, a500, a501, a502, a503, a40000211, a40000212, " +
+	"a80000001, ace534351, ace534352, ace534353, ace534354, ace534355, ace534356, ace534357, ace534358, ace534364, " +
+	"ace534365, ace534366, ace534367, ace534368, ace534369, ace534373, ace534374, ace536351, ace536352, ace536353, " +
+	"ace536354, ace536355, ace536356, ace536357, ace536358, ace536359, ace53635a, ace53635b, ace53635c, ace53635d, " +
+	"ace53635e, ace53635f, ace536360, ace5363b4, ace5363b5, ad5a0db00"
+
+// The names and attributes of the indexes NSS creates on the object tables.
+var objectIndexes = []struct {
+	name      string
+	attribute uint32
+}{
+	{"issuer", attributeIssuer},
+	{"subject", attributeSubject},
+	{"label", attributeLabel},
+	{"ckaid", attributeID},
+}
+
+const metadataTableSQL = "CREATE TABLE metaData (id PRIMARY KEY UNIQUE ON CONFLICT REPLACE, item1, item2)"
+
+// The PKCS#11 attributes of the certificate and trust objects.
+const (
+	attributeClass                = 0x0
+	attributeToken                = 0x1
+	attributePrivate              = 0x2
+	attributeLabel                = 0x3
+	attributeValue                = 0x11
+	attributeCertificateType      = 0x80
+	attributeIssuer               = 0x81
+	attributeSerialNumber         = 0x82
+	attributeSubject              = 0x101
+	attributeID                   = 0x102
+	attributeModifiable           = 0x170
+	attributeTrustServerAuth      = 0xce536358
+	attributeTrustClientAuth      = 0xce536359
+	attributeTrustEmailProtection = 0xce53635a
+	attributeTrustCodeSigning     = 0xce53635b
+	attributeTrustStepUpApproved  = 0xce536360
+	attributeCertificateSHA1Hash  = 0xce5363b4
+	attributeCertificateMD5Hash   = 0xce5363b5
+)
+
+const (
+	classCertificate = 0x1
+	classTrust       = 0xce534353
+)
+
+const (
+	trustedDelegator = 0xce534352
+	mustVerifyTrust  = 0xce534353
+	validDelegator   = 0xce53435b
+)
+
+// The trust attributes are signed with the key database, so they can't be changed without the password.
+var signedAttributes = []uint32{
+	attributeTrustServerAuth,
+	attributeTrustClientAuth,
+	attributeTrustEmailProtection,
+	attributeTrustCodeSigning,
+	attributeTrustStepUpApproved,
+	attributeCertificateSHA1Hash,
+	attributeCertificateMD5Hash,
+}
+
+// How NSS stores an attribute that is set but empty.
+var explicitEmptyValue = []byte{0xa5, 0x00, 0x5a}
+
+// The NSS database of a directory, opened to be changed.
+type nativeDatabase struct {
+	directory string
+
+	certificates *sqlite.Database
+	keys         *sqlite.Database
+
+	objects  *sqlite.Object
+	metadata *sqlite.Object
+
+	passwordKey []byte
+	iterations  int
+}
+
+// Opens the database of the directory, creating it if it doesn't exist. The password is checked, or set if the
+// database has none yet.
+func openNatively(directory string, password string) (*nativeDatabase, error) {
+	if !Exists(directory) {
+		if _, err := os.Stat(filepath.Join(directory, legacyDatabaseName)); err == nil {
+			return nil, fmt.Errorf("%s has a legacy %s database, it can only be changed with certutil", directory, legacyDatabaseName)
+		}
+	}
+
+	database := &nativeDatabase{directory: directory, iterations: getIterations(password)}
+
+	var err error
+
+	database.certificates, err = openSqlite(filepath.Join(directory, certificateDatabaseName), "nssPublic")
+	if err != nil {
+		return nil, err
+	}
+
+	database.keys, err = openSqlite(filepath.Join(directory, keyDatabaseName), "nssPrivate")
+	if err != nil {
+		return nil, err
+	}
+
+	database.objects = database.certificates.Table("nssPublic")
+	if database.objects == nil {
+		return nil, fmt.Errorf("%s is not an NSS certificate database", filepath.Join(directory, certificateDatabaseName))
+	}
+
+	database.metadata = database.keys.Table("metaData")
+	if database.metadata == nil {
+		database.metadata, err = database.keys.CreateTable(metadataTableSQL)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err = database.unlock(password)
+	if err != nil {
+		return nil, err
+	}
+
+	return database, nil
+}
+
+// Reads an SQLite database, or creates it with the object table of the name and its indexes.
+func openSqlite(path string, table string) (*sqlite.Database, error) {
+	if _, err := os.Stat(path); err == nil {
+		return sqlite.Read(path)
+	}
+
+	database := sqlite.New()
+
+	_, err := database.CreateTable(fmt.Sprintf("CREATE TABLE %s (%s)", table, objectColumns))
+	if err != nil {
+		return nil, err
+	}
+
+	for _, index := range objectIndexes {
+		err = database.CreateIndex(fmt.Sprintf("CREATE INDEX %s ON %s (a%x)", index.name, table, index.attribute))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return database, nil
+}
+
+// Checks the password against the password entry of the key database, the entry is added if there is none.
+func (database *nativeDatabase) unlock(password string) error {
+	for _, row := range database.metadata.Rows {
+		if database.metadata.Value(row, "id") != "password" {
+			continue
+		}
+
+		globalSalt, _ := database.metadata.Value(row, "item1").([]byte)
+		check, _ := database.metadata.Value(row, "item2").([]byte)
+
+		database.passwordKey = getPasswordKey(globalSalt, password)
+
+		ok, err := checkPassword(database.passwordKey, check)
+		if err != nil {
+			return fmt.Errorf("failed to check the password of the NSS database %s: %s", database.directory, err)
+		}
+
+		if !ok {
+			return fmt.Errorf("the password of the NSS database %s is wrong", database.directory)
+		}
+
+		return nil
+	}
+
+	globalSalt := make([]byte, 20)
+
+	if _, err := rand.Read(globalSalt); err != nil {
+		return err
+	}
+
+	database.passwordKey = getPasswordKey(globalSalt, password)
+
+	check, err := encryptPasswordCheck(database.passwordKey, database.iterations)
+	if err != nil {
+		return err
+	}
+
+	database.metadata.Insert([]interface{}{"password", globalSalt, check})
+
+	return nil
+}
+
+// Writes the databases, the key database first so the signatures of the trust are there before it is.
+func (database *nativeDatabase) save() error {
+	err := os.MkdirAll(database.directory, 0700)
+	if err != nil {
+		return err
+	}
+
+	err = database.keys.Write(filepath.Join(database.directory, keyDatabaseName), 0600)
+	if err == nil {
+		err = database.certificates.Write(filepath.Join(database.directory, certificateDatabaseName), 0600)
+	}
+
+	// A running Firefox or Chromium keeps the databases open, certutil changes them through SQLite
+	if errors.Is(err, sqlite.ErrInUse) {
+		return fmt.Errorf("%s, close the applications using the NSS database or use the %s method", err, CertutilMethod)
+	}
+
+	return err
+}
+
+func (database *nativeDatabase) value(row *sqlite.Row, attribute uint32) []byte {
+	value, _ := database.objects.Value(row, attributeColumn(attribute)).([]byte)
+
+	return value
+}
+
+func (database *nativeDatabase) isClass(row *sqlite.Row, class uint32) bool {
+	return bytes.Equal(database.value(row, attributeClass), ulongValue(class))
+}
+
+// Removes the certificates the function returns true for, with their trust and its signatures. Returns the number
+// of removed certificates.
+func (database *nativeDatabase) removeCertificates(match func(row *sqlite.Row) bool) int {
+	var removed [][2][]byte
+
+	for _, row := range database.objects.Rows {
+		if database.isClass(row, classCertificate) && match(row) {
+			removed = append(removed, [2][]byte{database.value(row, attributeIssuer), database.value(row, attributeSerialNumber)})
+		}
+	}
+
+	if len(removed) == 0 {
+		return 0
+	}
+
+	ids := make(map[int64]bool)
+
+	database.objects.Delete(func(row *sqlite.Row) bool {
+		if !database.isClass(row, classCertificate) && !database.isClass(row, classTrust) {
+			return false
+		}
+
+		for _, certificate := range removed {
+			if bytes.Equal(database.value(row, attributeIssuer), certificate[0]) && bytes.Equal(database.value(row, attributeSerialNumber), certificate[1]) {
+				id, _ := database.objects.Value(row, "id").(int64)
+				ids[id] = true
+
+				return true
+			}
+		}
+
+		return false
+	})
+
+	database.metadata.Delete(func(row *sqlite.Row) bool {
+		id, _ := database.metadata.Value(row, "id").(string)
+
+		for objectID := range ids {
+			if strings.HasPrefix(id, fmt.Sprintf("sig_cert_%08x_", uint32(objectID))) {
+				return true
+			}
+		}
+
+		return false
+	})
+
+	return len(removed)
+}
+
+// Gets an unused object id, the next id is left free for the trust of the certificate.
+func (database *nativeDatabase) newObjectID() (uint32, error) {
+	used := make(map[int64]bool)
+
+	for _, row := range database.objects.Rows {
+		if id, ok := database.objects.Value(row, "id").(int64); ok {
+			used[id] = true
+		}
+	}
+
+	var random [4]byte
+
+	for {
+		if _, err := rand.Read(random[:]); err != nil {
+			return 0, err
+		}
+
+		// Like the ids NSS picks, the top bits of the handles are for the type of the object
+		id := binary.BigEndian.Uint32(random[:]) & 0x3fffffff
+		if id != 0 && !used[int64(id)] && !used[int64(id)+1] {
+			return id, nil
+		}
+	}
+}
+
+// Adds an object with the attributes, the trust attributes are signed.
+func (database *nativeDatabase) addObject(id uint32, attributes map[uint32][]byte) error {
+	values := make([]interface{}, len(database.objects.Columns))
+	values[database.objects.ColumnIndex("id")] = int64(id)
+
+	for attribute, value := range attributes {
+		column := database.objects.ColumnIndex(attributeColumn(attribute))
+		if column < 0 {
+			return fmt.Errorf("the NSS database %s has no %s column", database.directory, attributeColumn(attribute))
+		}
+
+		values[column] = value
+	}
+
+	database.objects.Insert(values)
+
+	for _, attribute := range signedAttributes {
+		value, ok := attributes[attribute]
+		if !ok {
+			continue
+		}
+
+		signature, err := signAttribute(database.passwordKey, database.iterations, id, attribute, value)
+		if err != nil {
+			return err
+		}
+
+		name := fmt.Sprintf("sig_cert_%08x_%08x", id, attribute)
+
+		// A signature left by an object that is gone would conflict with it
+		database.metadata.Delete(func(row *sqlite.Row) bool {
+			return database.metadata.Value(row, "id") == name
+		})
+
+		database.metadata.Insert([]interface{}{name, signature, nil})
+	}
+
+	return nil
+}
+
+func importNatively(options *Options, directory string, nickname string, certificate *x509.Certificate) error {
+	database, err := openNatively(directory, options.Password)
+	if err != nil {
+		return err
+	}
+
+	serialNumber, err := asn1.Marshal(certificate.SerialNumber)
+	if err != nil {
+		return err
+	}
+
+	database.removeCertificates(func(row *sqlite.Row) bool {
+		return string(database.value(row, attributeLabel)) == nickname ||
+			(bytes.Equal(database.value(row, attributeIssuer), certificate.RawIssuer) && bytes.Equal(database.value(row, attributeSerialNumber), serialNumber))
+	})
+
+	id, err := database.newObjectID()
+	if err != nil {
+		return err
+	}
+
+	err = database.addObject(id, map[uint32][]byte{
+		attributeClass:           ulongValue(classCertificate),
+		attributeToken:           {1},
+		attributePrivate:         {0},
+		attributeLabel:           []byte(nickname),
+		attributeValue:           certificate.Raw,
+		attributeCertificateType: ulongValue(0),
+		attributeIssuer:          certificate.RawIssuer,
+		attributeSerialNumber:    serialNumber,
+		attributeSubject:         certificate.RawSubject,
+		attributeID:              getKeyID(certificate),
+		attributeModifiable:      {1},
+	})
+
+	if err != nil {
+		return err
+	}
+
+	sha1Hash := sha1.Sum(certificate.Raw)
+	md5Hash := md5.Sum(certificate.Raw)
+
+	// The trust of C,,: a certificate authority for servers, the other uses have to be verified
+	err = database.addObject(id+1, map[uint32][]byte{
+		attributeClass:                ulongValue(classTrust),
+		attributeToken:                {1},
+		attributePrivate:              {0},
+		attributeLabel:                explicitEmptyValue,
+		attributeIssuer:               certificate.RawIssuer,
+		attributeSerialNumber:         serialNumber,
+		attributeModifiable:           {1},
+		attributeTrustServerAuth:      ulongValue(trustedDelegator),
+		attributeTrustClientAuth:      ulongValue(validDelegator),
+		attributeTrustEmailProtection: ulongValue(mustVerifyTrust),
+		attributeTrustCodeSigning:     ulongValue(mustVerifyTrust),
+		attributeTrustStepUpApproved:  {0},
+		attributeCertificateSHA1Hash:  sha1Hash[:],
+		attributeCertificateMD5Hash:   md5Hash[:],
+	})
+
+	if err != nil {
+		return err
+	}
+
+	return database.save()
+}
+
+func removeNatively(options *Options, directory string, nickname string) (bool, error) {
+	database, err := openNatively(directory, options.Password)
+	if err != nil {
+		return false, err
+	}
+
+	removed := database.removeCertificates(func(row *sqlite.Row) bool {
+		return string(database.value(row, attributeLabel)) == nickname
+	})
+
+	if removed == 0 {
+		return false, nil
+	}
+
+	return true, database.save()
+}
+
+// Lists the nicknames of the certificates, only the certificate database is read so no password is needed.
+func listNatively(directory string) ([]string, error) {
+	certificates, err := sqlite.Read(filepath.Join(directory, certificateDatabaseName))
+	if err != nil {
+		return nil, err
+	}
+
+	objects := certificates.Table("nssPublic")
+	if objects == nil {
+		return nil, fmt.Errorf("%s is not an NSS certificate database", filepath.Join(directory, certificateDatabaseName))
+	}
+
+	var nicknames []string
+
+	for _, row := range objects.Rows {
+		class, _ := objects.Value(row, attributeColumn(attributeClass)).([]byte)
+		label, _ := objects.Value(row, attributeColumn(attributeLabel)).([]byte)
+
+		if bytes.Equal(class, ulongValue(classCertificate)) {
+			nicknames = append(nicknames, string(label))
+		}
+	}
+
+	return nicknames, nil
+}
+
+func attributeColumn(attribute uint32) string {
+	return fmt.Sprintf("a%x", attribute)
+}
+
+// NSS stores the CK_ULONG attributes in 4 bytes, in network order.
+func ulongValue(value uint32) []byte {
+	var encoded [4]byte
+	binary.BigEndian.PutUint32(encoded[:], value)
+
+	return encoded[:]
+}
+
+// Gets the CKA_ID NSS gives the certificate, the SHA-1 of the modulus of an RSA key and of the public key otherwise.
+func getKeyID(certificate *x509.Certificate) []byte {
+	if key, ok := certificate.PublicKey.(*rsa.PublicKey); ok {
+		hash := sha1.Sum(key.N.Bytes())
+		return hash[:]
+	}
+
+	var publicKeyInfo struct {
+		Algorithm asn1.RawValue
+		PublicKey asn1.BitString
+	}
+
+	// The public key of a certificate that was parsed always decodes
+	asn1.Unmarshal(certificate.RawSubjectPublicKeyInfo, &publicKeyInfo)
+
+	hash := sha1.Sum(publicKeyInfo.PublicKey.Bytes)
+
+	return hash[:]
+}
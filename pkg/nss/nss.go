@@ -0,0 +1,205 @@
+// Package nss imports certificate authorities into NSS databases, the cert9.db and key4.db databases Firefox,
+// Chromium and the other NSS applications trust certificates from, and removes the ones it imported.
+package nss
+
+import (
+	"crypto/x509"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// How the databases are written. Auto uses certutil if it is installed, and writes the databases natively otherwise.
+// certutil is preferred: it changes the databases through SQLite like the applications do, while the native method
+// refuses to change a database another process, like a running Firefox, has open.
+const (
+	AutoMethod     = "auto"
+	NativeMethod   = "native"
+	CertutilMethod = "certutil"
+)
+
+// Every imported certificate has a nickname starting with it, so the certificates of the user are never touched.
+const nicknamePrefix = "ssl-go-"
+
+// The shared database of the user, read by Chromium and the NSS tools.
+const sharedDatabase = ".pki/nssdb"
+
+// The directories holding the Firefox profiles, every profile has its own database.
+var profileDirectories = []string{
+	".mozilla/firefox",
+	"snap/firefox/common/.mozilla/firefox",
+}
+
+// Where certificate authorities are imported.
+type Options struct {
+	// The method the databases are written with, never auto.
+	Method string
+
+	// The absolute path of the home directory the databases are in.
+	Home string
+
+	// The absolute paths of the directories of the databases.
+	Databases []string
+
+	// The password of the key databases, empty for the databases without one.
+	Password string
+}
+
+// Gets the options of the NSS databases. An empty method is auto and an empty home is the home of the user. The
+// databases are relative to the home, with no databases the shared database and the ones of the Firefox profiles
+// in the home are used.
+func GetOptions(method string, home string, databases []string, password string) (*Options, error) {
+	method, err := CheckMethod(method)
+	if err != nil {
+		return nil, err
+	}
+
+	if method == AutoMethod {
+		method = NativeMethod
+
+		if _, err := exec.LookPath("certutil"); err == nil {
+			method = CertutilMethod
+		}
+	}
+
+	if home == "" {
+		home, err = os.UserHomeDir()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	home, err = filepath.Abs(home)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(databases) == 0 {
+		databases = Discover(home)
+	}
+
+	options := &Options{Method: method, Home: home, Password: password}
+
+	for _, database := range databases {
+		if !filepath.IsAbs(database) {
+			database = filepath.Join(home, database)
+		}
+
+		options.Databases = append(options.Databases, filepath.Clean(database))
+	}
+
+	return options, nil
+}
+
+// Validates the name of a method, an empty name is auto.
+func CheckMethod(name string) (string, error) {
+	switch strings.ToLower(name) {
+	case "", AutoMethod:
+		return AutoMethod, nil
+	case NativeMethod:
+		return NativeMethod, nil
+	case CertutilMethod:
+		return CertutilMethod, nil
+	}
+
+	return "", fmt.Errorf("unknown NSS method: %s, must be %s, %s or %s", name, AutoMethod, NativeMethod, CertutilMethod)
+}
+
+// Finds the databases of the home: the shared database, created if it doesn't exist, and the databases of the
+// Firefox profiles that have one.
+func Discover(home string) []string {
+	databases := []string{filepath.Join(home, sharedDatabase)}
+
+	for _, directory := range profileDirectories {
+		matches, _ := filepath.Glob(filepath.Join(home, directory, "*", certificateDatabaseName))
+
+		sort.Strings(matches)
+
+		for _, match := range matches {
+			databases = append(databases, filepath.Dir(match))
+		}
+	}
+
+	return databases
+}
+
+// Gets the nickname a certificate is imported under, the name is its artifact name like root-ca-myroot.
+func GetNickname(name string) string {
+	return nicknamePrefix + name
+}
+
+// Imports the certificate into the database under the name, replacing the certificate imported under it and any
+// copy of the certificate under another nickname. The certificate is trusted to issue server certificates, like
+// certutil -t C,, trusts it. The database is created if it doesn't exist.
+func Import(options *Options, database string, name string, certificate *x509.Certificate) error {
+	if options.Method == CertutilMethod {
+		return importWithCertutil(options, database, GetNickname(name), certificate)
+	}
+
+	return importNatively(options, database, GetNickname(name), certificate)
+}
+
+// Removes the certificate imported under the name, removed is false if none is.
+func Remove(options *Options, database string, name string) (removed bool, err error) {
+	if !Exists(database) {
+		return false, nil
+	}
+
+	if options.Method == CertutilMethod {
+		return removeWithCertutil(options, database, GetNickname(name))
+	}
+
+	return removeNatively(options, database, GetNickname(name))
+}
+
+// Gets the names of every certificate imported into the database, sorted.
+func Imported(options *Options, database string) ([]string, error) {
+	if !Exists(database) {
+		return nil, nil
+	}
+
+	var nicknames []string
+	var err error
+
+	if options.Method == CertutilMethod {
+		nicknames, err = listWithCertutil(options, database)
+	} else {
+		nicknames, err = listNatively(database)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	var names []string
+
+	for _, nickname := range nicknames {
+		if strings.HasPrefix(nickname, nicknamePrefix) && !containsName(names, nickname[len(nicknamePrefix):]) {
+			names = append(names, nickname[len(nicknamePrefix):])
+		}
+	}
+
+	sort.Strings(names)
+
+	return names, nil
+}
+
+// Determines if the directory has an NSS database.
+func Exists(database string) bool {
+	_, err := os.Stat(filepath.Join(database, certificateDatabaseName))
+
+	return err == nil
+}
+
+func containsName(names []string, name string) bool {
+	for _, existing := range names {
+		if existing == name {
+			return true
+		}
+	}
+
+	return false
+}
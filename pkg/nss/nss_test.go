@@ -0,0 +1,378 @@
+package nss
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"math/big"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"git.mfdlabs.local/petko/mfdlabs-ssl-go/pkg/sqlite"
+)
+
+// The password checks were encrypted with openssl: the key is openssl kdf PBKDF2 with SHA-256 of the SHA-1 of the
+// global salt and the password, and the check is openssl enc -aes-256-cbc of "password-check" with that key.
+func TestCheckPassword(t *testing.T) {
+	tests := []struct {
+		name       string
+		password   string
+		iterations int
+		encrypted  string
+	}{
+		{name: "password", password: "nss-password", iterations: passwordIterations, encrypted: "9879d4739fca4df55ad499385fb33d74"},
+		{name: "no password", password: "", iterations: emptyPasswordIterations, encrypted: "72d6bf6b8bda48baeb0b37b01b035ce3"},
+	}
+
+	globalSalt := decodeHex(t, "0102030405060708090a0b0c0d0e0f1011121314")
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			kdfParams, _ := asn1.Marshal(pbkdf2Params{
+				Salt:       bytes.Repeat([]byte{0xaa}, 32),
+				Iterations: test.iterations,
+				KeyLength:  32,
+				Prf:        pkix.AlgorithmIdentifier{Algorithm: oidHmacWithSHA256},
+			})
+
+			params, _ := asn1.Marshal(pbes2Params{
+				KeyDerivationFunc: pkix.AlgorithmIdentifier{Algorithm: oidPBKDF2, Parameters: asn1.RawValue{FullBytes: kdfParams}},
+				EncryptionScheme:  pkix.AlgorithmIdentifier{Algorithm: oidAES256CBC, Parameters: asn1.RawValue{FullBytes: decodeHex(t, "040e1111111111111111111111111111")}},
+			})
+
+			check, _ := asn1.Marshal(protectedValue{
+				Algorithm: pkix.AlgorithmIdentifier{Algorithm: oidPBES2, Parameters: asn1.RawValue{FullBytes: params}},
+				Value:     decodeHex(t, test.encrypted),
+			})
+
+			ok, err := checkPassword(getPasswordKey(globalSalt, test.password), check)
+			if err != nil || !ok {
+				t.Fatalf("expected the password to be right, got %t, %v", ok, err)
+			}
+
+			ok, err = checkPassword(getPasswordKey(globalSalt, test.password+"x"), check)
+			if err != nil || ok {
+				t.Fatalf("expected the password to be wrong, got %t, %v", ok, err)
+			}
+		})
+	}
+}
+
+func TestNativeRoundTrip(t *testing.T) {
+	for _, password := range []string{"", "nss-password"} {
+		t.Run(fmt.Sprintf("password %q", password), func(t *testing.T) {
+			directory := filepath.Join(t.TempDir(), "nssdb")
+			options := &Options{Method: NativeMethod, Password: password}
+
+			root := createAuthority(t, "ssl-go test root")
+			intermediate := createAuthority(t, "ssl-go test intermediate")
+
+			if removed, err := Remove(options, directory, "root-ca-myroot"); removed || err != nil {
+				t.Fatalf("expected nothing to be removed from a missing database, got %t, %v", removed, err)
+			}
+
+			for _, name := range []string{"root-ca-myroot", "ca-myca", "root-ca-myroot"} {
+				certificate := root
+				if name == "ca-myca" {
+					certificate = intermediate
+				}
+
+				if err := Import(options, directory, name, certificate); err != nil {
+					t.Fatalf("failed to import %s: %s", name, err)
+				}
+			}
+
+			checkImported(t, options, directory, []string{"ca-myca", "root-ca-myroot"})
+			checkDatabase(t, directory, password, map[string]*x509.Certificate{"ssl-go-ca-myca": intermediate, "ssl-go-root-ca-myroot": root})
+
+			// The same certificate under another name replaces the one imported before
+			if err := Import(options, directory, "root-ca-renamed", root); err != nil {
+				t.Fatal(err)
+			}
+
+			checkImported(t, options, directory, []string{"ca-myca", "root-ca-renamed"})
+
+			if err := Import(&Options{Method: NativeMethod, Password: password + "wrong"}, directory, "root-ca-other", root); err == nil {
+				t.Fatal("expected the import to fail with the wrong password")
+			}
+
+			if removed, err := Remove(options, directory, "root-ca-renamed"); !removed || err != nil {
+				t.Fatalf("expected root-ca-renamed to be removed, got %t, %v", removed, err)
+			}
+
+			if removed, err := Remove(options, directory, "root-ca-renamed"); removed || err != nil {
+				t.Fatalf("expected root-ca-renamed to be gone, got %t, %v", removed, err)
+			}
+
+			checkImported(t, options, directory, []string{"ca-myca"})
+			checkDatabase(t, directory, password, map[string]*x509.Certificate{"ssl-go-ca-myca": intermediate})
+			checkWithSqlite(t, directory, 2, 1+len(signedAttributes))
+		})
+	}
+}
+
+// The databases SQLite creates itself, like the ones of Firefox, are read and written back.
+func TestNativeImportIntoSqliteDatabase(t *testing.T) {
+	if _, err := exec.LookPath("sqlite3"); err != nil {
+		t.Skip("sqlite3 is not installed")
+	}
+
+	directory := t.TempDir()
+
+	for name, table := range map[string]string{certificateDatabaseName: "nssPublic", keyDatabaseName: "nssPrivate"} {
+		statements := fmt.Sprintf("PRAGMA page_size = 1024; CREATE TABLE %s (%s);", table, objectColumns)
+
+		for _, index := range objectIndexes {
+			statements += fmt.Sprintf("CREATE INDEX %s ON %s (a%x);", index.name, table, index.attribute)
+		}
+
+		if name == keyDatabaseName {
+			statements += metadataTableSQL + ";"
+		}
+
+		runSqlite(t, filepath.Join(directory, name), statements)
+	}
+
+	options := &Options{Method: NativeMethod}
+	root := createAuthority(t, "ssl-go test root")
+
+	if err := Import(options, directory, "root-ca-myroot", root); err != nil {
+		t.Fatalf("failed to import into the database SQLite created: %s", err)
+	}
+
+	checkDatabase(t, directory, "", map[string]*x509.Certificate{"ssl-go-root-ca-myroot": root})
+	checkWithSqlite(t, directory, 2, 1+len(signedAttributes))
+}
+
+func checkImported(t *testing.T, options *Options, directory string, expected []string) {
+	t.Helper()
+
+	names, err := Imported(options, directory)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(names, expected) {
+		t.Fatalf("expected %v to be imported, got %v", expected, names)
+	}
+}
+
+// Checks every certificate is in cert9.db with the trust of C,, and that key4.db signs the trust with the password.
+func checkDatabase(t *testing.T, directory string, password string, expected map[string]*x509.Certificate) {
+	t.Helper()
+
+	certificates, err := sqlite.Read(filepath.Join(directory, certificateDatabaseName))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	keys, err := sqlite.Read(filepath.Join(directory, keyDatabaseName))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	objects := certificates.Table("nssPublic")
+	metadata := keys.Table("metaData")
+
+	signatures := make(map[string][]byte)
+	var passwordKey []byte
+
+	for _, row := range metadata.Rows {
+		id, _ := metadata.Value(row, "id").(string)
+		item1, _ := metadata.Value(row, "item1").([]byte)
+		item2, _ := metadata.Value(row, "item2").([]byte)
+
+		if id == "password" {
+			passwordKey = getPasswordKey(item1, password)
+
+			if ok, err := checkPassword(passwordKey, item2); !ok || err != nil {
+				t.Fatalf("the password check of key4.db doesn't decrypt: %t, %v", ok, err)
+			}
+		} else {
+			signatures[id] = item1
+		}
+	}
+
+	if passwordKey == nil {
+		t.Fatal("key4.db has no password entry")
+	}
+
+	value := func(row *sqlite.Row, attribute uint32) []byte {
+		value, _ := objects.Value(row, attributeColumn(attribute)).([]byte)
+		return value
+	}
+
+	found := 0
+
+	for _, row := range objects.Rows {
+		if !bytes.Equal(value(row, attributeClass), ulongValue(classCertificate)) {
+			continue
+		}
+
+		certificate, ok := expected[string(value(row, attributeLabel))]
+		if !ok || !bytes.Equal(value(row, attributeValue), certificate.Raw) {
+			t.Fatalf("unexpected certificate %q in cert9.db", value(row, attributeLabel))
+		}
+
+		found++
+
+		for _, trust := range objects.Rows {
+			if !bytes.Equal(value(trust, attributeClass), ulongValue(classTrust)) || !bytes.Equal(value(trust, attributeSerialNumber), value(row, attributeSerialNumber)) {
+				continue
+			}
+
+			if !bytes.Equal(value(trust, attributeTrustServerAuth), ulongValue(trustedDelegator)) {
+				t.Fatalf("%s is not trusted to issue server certificates", certificate.Subject)
+			}
+
+			id, _ := objects.Value(trust, "id").(int64)
+
+			for _, attribute := range signedAttributes {
+				name := fmt.Sprintf("sig_cert_%08x_%08x", id, attribute)
+
+				if !verifySignature(t, signatures[name], passwordKey, uint32(id), attribute, value(trust, attribute)) {
+					t.Fatalf("the signature %s doesn't match", name)
+				}
+
+				delete(signatures, name)
+			}
+
+			found++
+		}
+	}
+
+	if found != 2*len(expected) {
+		t.Fatalf("expected %d certificates with their trust in cert9.db, found %d objects", len(expected), found)
+	}
+
+	if len(signatures) != 0 {
+		t.Fatalf("key4.db has signatures left by removed objects: %d", len(signatures))
+	}
+}
+
+// Verifies the PBMAC1 signature like sftkdb_VerifyAttribute.
+func verifySignature(t *testing.T, signature []byte, passwordKey []byte, objectID uint32, attribute uint32, value []byte) bool {
+	t.Helper()
+
+	var protected protectedValue
+	var params pbmac1Params
+
+	if _, err := asn1.Unmarshal(signature, &protected); err != nil || !protected.Algorithm.Algorithm.Equal(oidPBMAC1) {
+		t.Fatalf("the signature is not PBMAC1: %v", err)
+	}
+
+	if _, err := asn1.Unmarshal(protected.Algorithm.Parameters.FullBytes, &params); err != nil || !params.MessageAuthScheme.Algorithm.Equal(oidHmacWithSHA256) {
+		t.Fatalf("the signature is not an HMAC-SHA256: %v", err)
+	}
+
+	key, err := deriveKey(params.KeyDerivationFunc, passwordKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var header [8]byte
+	binary.BigEndian.PutUint32(header[:4], objectID)
+	binary.BigEndian.PutUint32(header[4:], attribute)
+
+	mac := hmac.New(sha256.New, key)
+	mac.Write(header[:])
+	mac.Write(value)
+
+	return hmac.Equal(mac.Sum(nil), protected.Value)
+}
+
+// Has SQLite check the integrity of the databases and count their objects, when it's installed.
+func checkWithSqlite(t *testing.T, directory string, objects int, metadata int) {
+	t.Helper()
+
+	if _, err := exec.LookPath("sqlite3"); err != nil {
+		return
+	}
+
+	tests := []struct {
+		name  string
+		query string
+		count int
+	}{
+		{name: certificateDatabaseName, query: "SELECT count(*) FROM nssPublic", count: objects},
+		{name: keyDatabaseName, query: "SELECT count(*) FROM metaData", count: metadata},
+	}
+
+	for _, test := range tests {
+		path := filepath.Join(directory, test.name)
+
+		if output := runSqlite(t, path, "PRAGMA integrity_check"); output != "ok" {
+			t.Fatalf("SQLite found %s corrupt: %s", test.name, output)
+		}
+
+		if output := runSqlite(t, path, test.query); output != fmt.Sprint(test.count) {
+			t.Fatalf("expected %d rows in %s, SQLite counted %s", test.count, test.name, output)
+		}
+	}
+}
+
+func runSqlite(t *testing.T, path string, statements string) string {
+	t.Helper()
+
+	output, err := exec.Command("sqlite3", path, statements).CombinedOutput()
+	if err != nil {
+		t.Fatalf("sqlite3 failed: %s\n%s", err, output)
+	}
+
+	return strings.TrimSpace(string(output))
+}
+
+// Creates a self-signed certificate authority, only its certificate is imported into the databases.
+func createAuthority(t *testing.T, commonName string) *x509.Certificate {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: commonName},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	certificate, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return certificate
+}
+
+func decodeHex(t *testing.T, value string) []byte {
+	t.Helper()
+
+	decoded, err := hex.DecodeString(value)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return decoded
+}
@@ -0,0 +1,240 @@
+package nss
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/binary"
+	"fmt"
+
+	"golang.org/x/crypto/pbkdf2"
+)
+
+var (
+	oidPBES2          = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 5, 13}
+	oidPBKDF2         = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 5, 12}
+	oidPBMAC1         = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 5, 14}
+	oidHmacWithSHA256 = asn1.ObjectIdentifier{1, 2, 840, 113549, 2, 9}
+	oidAES256CBC      = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 1, 42}
+)
+
+// The value the password entry of the key database decrypts to.
+const passwordCheck = "password-check"
+
+// NSS derives its keys with a single iteration when the database has no password.
+const (
+	emptyPasswordIterations = 1
+	passwordIterations      = 10000
+)
+
+type pbkdf2Params struct {
+	Salt       []byte
+	Iterations int
+	KeyLength  int
+	Prf        pkix.AlgorithmIdentifier
+}
+
+type pbes2Params struct {
+	KeyDerivationFunc pkix.AlgorithmIdentifier
+	EncryptionScheme  pkix.AlgorithmIdentifier
+}
+
+type pbmac1Params struct {
+	KeyDerivationFunc pkix.AlgorithmIdentifier
+	MessageAuthScheme pkix.AlgorithmIdentifier
+}
+
+// The encrypted password check and the signatures of the attributes share the layout.
+type protectedValue struct {
+	Algorithm pkix.AlgorithmIdentifier
+	Value     []byte
+}
+
+// The key the keys of the database are derived from, the SHA-1 of the global salt of the database and the password.
+func getPasswordKey(globalSalt []byte, password string) []byte {
+	hash := sha1.New()
+	hash.Write(globalSalt)
+	hash.Write([]byte(password))
+
+	return hash.Sum(nil)
+}
+
+func getIterations(password string) int {
+	if password == "" {
+		return emptyPasswordIterations
+	}
+
+	return passwordIterations
+}
+
+// Encodes the PBKDF2 parameters with a new salt, returning the key they derive from the password key.
+func newKeyDerivation(passwordKey []byte, iterations int) (pkix.AlgorithmIdentifier, []byte, error) {
+	salt := make([]byte, 32)
+
+	if _, err := rand.Read(salt); err != nil {
+		return pkix.AlgorithmIdentifier{}, nil, err
+	}
+
+	params, err := asn1.Marshal(pbkdf2Params{
+		Salt:       salt,
+		Iterations: iterations,
+		KeyLength:  32,
+		Prf:        pkix.AlgorithmIdentifier{Algorithm: oidHmacWithSHA256},
+	})
+
+	if err != nil {
+		return pkix.AlgorithmIdentifier{}, nil, err
+	}
+
+	algorithm := pkix.AlgorithmIdentifier{Algorithm: oidPBKDF2, Parameters: asn1.RawValue{FullBytes: params}}
+
+	return algorithm, pbkdf2.Key(passwordKey, salt, iterations, 32, sha256.New), nil
+}
+
+// Derives the key of the PBKDF2 parameters from the password key.
+func deriveKey(algorithm pkix.AlgorithmIdentifier, passwordKey []byte) ([]byte, error) {
+	var params pbkdf2Params
+
+	if !algorithm.Algorithm.Equal(oidPBKDF2) {
+		return nil, fmt.Errorf("the key derivation %s is not supported", algorithm.Algorithm)
+	}
+
+	if _, err := asn1.Unmarshal(algorithm.Parameters.FullBytes, &params); err != nil {
+		return nil, err
+	}
+
+	if !params.Prf.Algorithm.Equal(oidHmacWithSHA256) || params.KeyLength != 32 || params.Iterations < 1 {
+		return nil, fmt.Errorf("the PBKDF2 parameters are not supported")
+	}
+
+	return pbkdf2.Key(passwordKey, params.Salt, params.Iterations, params.KeyLength, sha256.New), nil
+}
+
+// Encrypts the password check, AES-256-CBC with a key derived by PBKDF2 from the password key.
+func encryptPasswordCheck(passwordKey []byte, iterations int) ([]byte, error) {
+	keyDerivation, key, err := newKeyDerivation(passwordKey, iterations)
+	if err != nil {
+		return nil, err
+	}
+
+	// NSS keeps the DER of the IV, so the IV is the tag and the length of an OCTET STRING of 14 bytes
+	random := make([]byte, aes.BlockSize-2)
+
+	if _, err := rand.Read(random); err != nil {
+		return nil, err
+	}
+
+	encodedIv, err := asn1.Marshal(random)
+	if err != nil {
+		return nil, err
+	}
+
+	params, err := asn1.Marshal(pbes2Params{
+		KeyDerivationFunc: keyDerivation,
+		EncryptionScheme:  pkix.AlgorithmIdentifier{Algorithm: oidAES256CBC, Parameters: asn1.RawValue{FullBytes: encodedIv}},
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	encrypted := pad([]byte(passwordCheck), block.BlockSize())
+	cipher.NewCBCEncrypter(block, encodedIv).CryptBlocks(encrypted, encrypted)
+
+	return asn1.Marshal(protectedValue{
+		Algorithm: pkix.AlgorithmIdentifier{Algorithm: oidPBES2, Parameters: asn1.RawValue{FullBytes: params}},
+		Value:     encrypted,
+	})
+}
+
+// Determines if the password key decrypts the password check.
+func checkPassword(passwordKey []byte, encoded []byte) (bool, error) {
+	var value protectedValue
+	var params pbes2Params
+
+	if _, err := asn1.Unmarshal(encoded, &value); err != nil {
+		return false, err
+	}
+
+	if !value.Algorithm.Algorithm.Equal(oidPBES2) {
+		return false, fmt.Errorf("the password encryption %s is not supported", value.Algorithm.Algorithm)
+	}
+
+	if _, err := asn1.Unmarshal(value.Algorithm.Parameters.FullBytes, &params); err != nil {
+		return false, err
+	}
+
+	iv := params.EncryptionScheme.Parameters.FullBytes
+
+	if !params.EncryptionScheme.Algorithm.Equal(oidAES256CBC) || len(iv) != aes.BlockSize {
+		return false, fmt.Errorf("the password encryption %s is not supported", params.EncryptionScheme.Algorithm)
+	}
+
+	key, err := deriveKey(params.KeyDerivationFunc, passwordKey)
+	if err != nil {
+		return false, err
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return false, err
+	}
+
+	if len(value.Value) == 0 || len(value.Value)%block.BlockSize() != 0 {
+		return false, fmt.Errorf("the password check is corrupt")
+	}
+
+	decrypted := make([]byte, len(value.Value))
+	cipher.NewCBCDecrypter(block, iv).CryptBlocks(decrypted, value.Value)
+
+	// A wrong password decrypts to garbage, with or without valid padding
+	return bytes.Equal(decrypted, pad([]byte(passwordCheck), block.BlockSize())), nil
+}
+
+// Signs an attribute of an object, an HMAC-SHA256 over the object id, the attribute type and the value.
+func signAttribute(passwordKey []byte, iterations int, objectID uint32, attribute uint32, value []byte) ([]byte, error) {
+	keyDerivation, key, err := newKeyDerivation(passwordKey, iterations)
+	if err != nil {
+		return nil, err
+	}
+
+	params, err := asn1.Marshal(pbmac1Params{
+		KeyDerivationFunc: keyDerivation,
+		MessageAuthScheme: pkix.AlgorithmIdentifier{Algorithm: oidHmacWithSHA256},
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	var header [8]byte
+	binary.BigEndian.PutUint32(header[:4], objectID)
+	binary.BigEndian.PutUint32(header[4:], attribute)
+
+	mac := hmac.New(sha256.New, key)
+	mac.Write(header[:])
+	mac.Write(value)
+
+	return asn1.Marshal(protectedValue{
+		Algorithm: pkix.AlgorithmIdentifier{Algorithm: oidPBMAC1, Parameters: asn1.RawValue{FullBytes: params}},
+		Value:     mac.Sum(nil),
+	})
+}
+
+// Applies PKCS#7 padding to the data, returning a new slice.
+func pad(data []byte, blockSize int) []byte {
+	padding := blockSize - len(data)%blockSize
+
+	return append(append([]byte{}, data...), bytes.Repeat([]byte{byte(padding)}, padding)...)
+}
@@ -0,0 +1,104 @@
+package pkcs12
+
+import (
+	"hash"
+	"math/big"
+	"unicode/utf16"
+)
+
+// Converts the password to a null terminated BMPString (UTF-16BE), as required by RFC 7292, appendix B.1.
+func bmpStringZeroTerminated(password string) []byte {
+	if password == "" {
+		return []byte{}
+	}
+
+	encoded := utf16.Encode([]rune(password))
+	out := make([]byte, 0, len(encoded)*2+2)
+
+	for _, char := range encoded {
+		out = append(out, byte(char>>8), byte(char))
+	}
+
+	return append(out, 0, 0)
+}
+
+var one = big.NewInt(1)
+
+// The key derivation function from RFC 7292, appendix B.2.
+// u is the hash output size and v is the hash block size, both in bytes.
+func deriveKey(hashFunction func() hash.Hash, u int, v int, salt []byte, password []byte, iterations int, id byte, size int) []byte {
+	// Construct the diversifier D
+	D := make([]byte, v)
+	for i := range D {
+		D[i] = id
+	}
+
+	// Concatenate copies of the salt and the password to build I
+	S := fillWithRepeats(salt, v)
+	P := fillWithRepeats(password, v)
+	I := append(S, P...)
+
+	c := (size + u - 1) / u
+	A := make([]byte, c*u)
+
+	for i := 0; i < c; i++ {
+		// Hash D || I iterations times
+		h := hashFunction()
+		h.Write(D)
+		h.Write(I)
+		Ai := h.Sum(nil)
+
+		for j := 1; j < iterations; j++ {
+			h = hashFunction()
+			h.Write(Ai)
+			Ai = h.Sum(nil)
+		}
+
+		copy(A[i*u:], Ai)
+
+		if i < c-1 {
+			// Concatenate copies of Ai to build B, then treat each v byte block of I as an integer
+			// and set it to (Ij + B + 1) mod 2^(v*8)
+			B := fillWithRepeats(Ai, v)[:v]
+			Bbi := new(big.Int).SetBytes(B)
+			Ij := new(big.Int)
+
+			for j := 0; j < len(I)/v; j++ {
+				Ij.SetBytes(I[j*v : (j+1)*v])
+				Ij.Add(Ij, Bbi)
+				Ij.Add(Ij, one)
+
+				Ijb := Ij.Bytes()
+				if len(Ijb) > v {
+					Ijb = Ijb[len(Ijb)-v:]
+				}
+
+				if len(Ijb) < v {
+					padded := make([]byte, v)
+					copy(padded[v-len(Ijb):], Ijb)
+					Ijb = padded
+				}
+
+				copy(I[j*v:(j+1)*v], Ijb)
+			}
+		}
+	}
+
+	return A[:size]
+}
+
+// Repeats the pattern until it fills a multiple of v bytes.
+func fillWithRepeats(pattern []byte, v int) []byte {
+	if len(pattern) == 0 {
+		return nil
+	}
+
+	outputLength := v * ((len(pattern) + v - 1) / v)
+	out := make([]byte, 0, outputLength)
+
+	for len(out) < outputLength {
+		out = append(out, pattern...)
+	}
+
+	return out[:outputLength]
+}
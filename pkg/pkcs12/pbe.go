@@ -0,0 +1,161 @@
+package pkcs12
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/des"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+
+	"golang.org/x/crypto/pbkdf2"
+)
+
+var (
+	oidPbeWithSHAAnd3KeyTripleDESCBC = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 12, 1, 3}
+	oidPbeWithSHAAnd40BitRC2CBC      = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 12, 1, 6}
+	oidPBES2                         = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 5, 13}
+	oidPBKDF2                        = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 5, 12}
+	oidHmacWithSHA256                = asn1.ObjectIdentifier{1, 2, 840, 113549, 2, 9}
+	oidAES256CBC                     = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 1, 42}
+)
+
+type pbeParams struct {
+	Salt       []byte
+	Iterations int
+}
+
+type pbes2Params struct {
+	KeyDerivationFunc pkix.AlgorithmIdentifier
+	EncryptionScheme  pkix.AlgorithmIdentifier
+}
+
+type pbkdf2Params struct {
+	Salt       []byte
+	Iterations int
+	Prf        pkix.AlgorithmIdentifier
+}
+
+// The password based encryption schemes of the bags.
+const (
+	tripleDESScheme = iota
+	rc2Scheme
+	aes256Scheme
+)
+
+// Encrypts the data with the scheme, returning the algorithm identifier used.
+func encryptPbe(data []byte, password string, scheme int, iterations int) (pkix.AlgorithmIdentifier, []byte, error) {
+	if scheme == aes256Scheme {
+		return encryptPbes2(data, password, iterations)
+	}
+
+	salt := make([]byte, 8)
+
+	if _, err := rand.Read(salt); err != nil {
+		return pkix.AlgorithmIdentifier{}, nil, err
+	}
+
+	params, err := asn1.Marshal(pbeParams{Salt: salt, Iterations: iterations})
+
+	if err != nil {
+		return pkix.AlgorithmIdentifier{}, nil, err
+	}
+
+	encodedPassword := bmpStringZeroTerminated(password)
+
+	var block cipher.Block
+	var oid asn1.ObjectIdentifier
+
+	if scheme == rc2Scheme {
+		key := deriveKey(sha1.New, 20, 64, salt, encodedPassword, iterations, 1, 5)
+		block, oid = newRC2Cipher(key, 40), oidPbeWithSHAAnd40BitRC2CBC
+	} else {
+		key := deriveKey(sha1.New, 20, 64, salt, encodedPassword, iterations, 1, 24)
+
+		block, err = des.NewTripleDESCipher(key)
+
+		if err != nil {
+			return pkix.AlgorithmIdentifier{}, nil, err
+		}
+
+		oid = oidPbeWithSHAAnd3KeyTripleDESCBC
+	}
+
+	iv := deriveKey(sha1.New, 20, 64, salt, encodedPassword, iterations, 2, block.BlockSize())
+
+	encrypted := pad(data, block.BlockSize())
+	cipher.NewCBCEncrypter(block, iv).CryptBlocks(encrypted, encrypted)
+
+	algorithm := pkix.AlgorithmIdentifier{
+		Algorithm:  oid,
+		Parameters: asn1.RawValue{FullBytes: params},
+	}
+
+	return algorithm, encrypted, nil
+}
+
+// Encrypts the data with PBES2 from RFC 8018, AES-256-CBC with a key derived by PBKDF2 with HMAC-SHA256. Unlike the
+// PKCS#12 schemes, PBES2 derives the key from the UTF-8 password.
+func encryptPbes2(data []byte, password string, iterations int) (pkix.AlgorithmIdentifier, []byte, error) {
+	salt := make([]byte, 16)
+	iv := make([]byte, aes.BlockSize)
+
+	if _, err := rand.Read(salt); err != nil {
+		return pkix.AlgorithmIdentifier{}, nil, err
+	}
+
+	if _, err := rand.Read(iv); err != nil {
+		return pkix.AlgorithmIdentifier{}, nil, err
+	}
+
+	kdfParams, err := asn1.Marshal(pbkdf2Params{
+		Salt:       salt,
+		Iterations: iterations,
+		Prf:        pkix.AlgorithmIdentifier{Algorithm: oidHmacWithSHA256, Parameters: asn1.NullRawValue},
+	})
+
+	if err != nil {
+		return pkix.AlgorithmIdentifier{}, nil, err
+	}
+
+	encodedIv, err := asn1.Marshal(iv)
+
+	if err != nil {
+		return pkix.AlgorithmIdentifier{}, nil, err
+	}
+
+	params, err := asn1.Marshal(pbes2Params{
+		KeyDerivationFunc: pkix.AlgorithmIdentifier{Algorithm: oidPBKDF2, Parameters: asn1.RawValue{FullBytes: kdfParams}},
+		EncryptionScheme:  pkix.AlgorithmIdentifier{Algorithm: oidAES256CBC, Parameters: asn1.RawValue{FullBytes: encodedIv}},
+	})
+
+	if err != nil {
+		return pkix.AlgorithmIdentifier{}, nil, err
+	}
+
+	block, err := aes.NewCipher(pbkdf2.Key([]byte(password), salt, iterations, 32, sha256.New))
+
+	if err != nil {
+		return pkix.AlgorithmIdentifier{}, nil, err
+	}
+
+	encrypted := pad(data, block.BlockSize())
+	cipher.NewCBCEncrypter(block, iv).CryptBlocks(encrypted, encrypted)
+
+	algorithm := pkix.AlgorithmIdentifier{
+		Algorithm:  oidPBES2,
+		Parameters: asn1.RawValue{FullBytes: params},
+	}
+
+	return algorithm, encrypted, nil
+}
+
+// Applies PKCS#7 padding to the data, returning a new slice.
+func pad(data []byte, blockSize int) []byte {
+	padding := blockSize - len(data)%blockSize
+
+	return append(append([]byte{}, data...), bytes.Repeat([]byte{byte(padding)}, padding)...)
+}
@@ -0,0 +1,531 @@
+// Package pkcs12 implements a minimal PKCS#12 (PFX) encoder, as described in RFC 7292.
+package pkcs12
+
+import (
+	"crypto"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	_ "crypto/sha256"
+	_ "crypto/sha512"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"fmt"
+	"strings"
+	"unicode/utf16"
+)
+
+const (
+	// The iteration count used by openssl for both the encryption and the MAC.
+	DefaultIterations = 2048
+
+	// The universal tag of the ASN.1 BMPString type.
+	tagBMPString = 30
+)
+
+var (
+	oidDataContentType          = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 1}
+	oidEncryptedDataContentType = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 6}
+	oidPkcs8ShroudedKeyBag      = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 12, 10, 1, 2}
+	oidCertBag                  = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 12, 10, 1, 3}
+	oidCertTypeX509Certificate  = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 22, 1}
+	oidFriendlyName             = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 20}
+	oidLocalKeyID               = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 21}
+	oidSHA1                     = asn1.ObjectIdentifier{1, 3, 14, 3, 2, 26}
+	oidJavaTrustedKeyUsage      = asn1.ObjectIdentifier{2, 16, 840, 1, 113894, 746875, 1, 1}
+	oidAnyExtendedKeyUsage      = asn1.ObjectIdentifier{2, 5, 29, 37, 0}
+	oidSHA256                   = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 1}
+	oidSHA384                   = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 2}
+	oidSHA512                   = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 3}
+)
+
+// The encryption profiles of a PFX.
+const (
+	// The private key is encrypted with 3DES and the certificates with 40 bit RC2, like OpenSSL 1.x does. For old
+	// consumers like Java 8.
+	LegacyEncryption = "legacy"
+
+	// The private key and the certificates are encrypted with 3DES, the default.
+	TripleDESEncryption = "3des"
+
+	// The private key and the certificates are encrypted with AES-256-CBC and a PBKDF2 key, like OpenSSL 3 does. For
+	// modern consumers like .NET 6.
+	AES256Encryption = "aes256"
+)
+
+// The digests the MAC of a PFX can be computed with.
+const (
+	SHA1MacAlgorithm   = "sha1"
+	SHA256MacAlgorithm = "sha256"
+	SHA384MacAlgorithm = "sha384"
+	SHA512MacAlgorithm = "sha512"
+)
+
+var macAlgorithms = map[string]struct {
+	hash crypto.Hash
+	oid  asn1.ObjectIdentifier
+}{
+	SHA1MacAlgorithm:   {crypto.SHA1, oidSHA1},
+	SHA256MacAlgorithm: {crypto.SHA256, oidSHA256},
+	SHA384MacAlgorithm: {crypto.SHA384, oidSHA384},
+	SHA512MacAlgorithm: {crypto.SHA512, oidSHA512},
+}
+
+// Options controls how a PFX is encoded, the zero value encrypts everything with 3DES and adds a SHA-1 MAC.
+type Options struct {
+	// LegacyEncryption, TripleDESEncryption (the default) or AES256Encryption.
+	Encryption string
+
+	// The digest of the MAC, SHA-256 for AES256Encryption and SHA-1 otherwise by default.
+	MacAlgorithm string
+
+	// The iteration count of the encryption and the MAC, DefaultIterations by default.
+	Iterations int
+
+	// The friendly name of the private key and its certificate, none if empty.
+	FriendlyName string
+}
+
+// Validates the encryption profile, an empty profile is TripleDESEncryption.
+func CheckEncryption(encryption string) (string, error) {
+	switch strings.ToLower(encryption) {
+	case "", TripleDESEncryption:
+		return TripleDESEncryption, nil
+	case LegacyEncryption:
+		return LegacyEncryption, nil
+	case AES256Encryption:
+		return AES256Encryption, nil
+	}
+
+	return "", fmt.Errorf("unknown pfx encryption: %s, must be %s, %s or %s", encryption, LegacyEncryption, TripleDESEncryption, AES256Encryption)
+}
+
+// Validates the MAC algorithm, an empty algorithm is the default of the encryption profile.
+func CheckMacAlgorithm(macAlgorithm string, encryption string) (string, error) {
+	macAlgorithm = strings.ToLower(strings.ReplaceAll(macAlgorithm, "-", ""))
+
+	if macAlgorithm == "" {
+		if encryption == AES256Encryption {
+			return SHA256MacAlgorithm, nil
+		}
+
+		return SHA1MacAlgorithm, nil
+	}
+
+	if _, ok := macAlgorithms[macAlgorithm]; !ok {
+		return "", fmt.Errorf("unknown pfx MAC algorithm: %s, must be %s, %s, %s or %s", macAlgorithm, SHA1MacAlgorithm, SHA256MacAlgorithm, SHA384MacAlgorithm, SHA512MacAlgorithm)
+	}
+
+	return macAlgorithm, nil
+}
+
+type pfxPdu struct {
+	Version  int
+	AuthSafe contentInfo
+	MacData  macData `asn1:"optional"`
+}
+
+type contentInfo struct {
+	ContentType asn1.ObjectIdentifier
+	Content     asn1.RawValue `asn1:"tag:0,explicit,optional"`
+}
+
+type encryptedData struct {
+	Version              int
+	EncryptedContentInfo encryptedContentInfo
+}
+
+type encryptedContentInfo struct {
+	ContentType                asn1.ObjectIdentifier
+	ContentEncryptionAlgorithm pkix.AlgorithmIdentifier
+	EncryptedContent           []byte `asn1:"tag:0,optional"`
+}
+
+type safeBag struct {
+	Id         asn1.ObjectIdentifier
+	Value      asn1.RawValue     `asn1:"tag:0,explicit"`
+	Attributes []pkcs12Attribute `asn1:"set,optional"`
+}
+
+type pkcs12Attribute struct {
+	Id    asn1.ObjectIdentifier
+	Value asn1.RawValue `asn1:"set"`
+}
+
+type certBag struct {
+	Id   asn1.ObjectIdentifier
+	Data []byte `asn1:"tag:0,explicit"`
+}
+
+type encryptedPrivateKeyInfo struct {
+	AlgorithmIdentifier pkix.AlgorithmIdentifier
+	EncryptedData       []byte
+}
+
+type macData struct {
+	Mac        digestInfo
+	MacSalt    []byte
+	Iterations int `asn1:"optional,default:1"`
+}
+
+type digestInfo struct {
+	Algorithm pkix.AlgorithmIdentifier
+	Digest    []byte
+}
+
+// Encode produces a DER encoded PFX containing the private key, its certificate and the CA certificates.
+// By default the key and the certificates are encrypted with pbeWithSHAAnd3-KeyTripleDES-CBC and the PFX is
+// protected with a SHA-1 HMAC, which every PKCS#12 consumer understands.
+func Encode(privateKey interface{}, certificate *x509.Certificate, caCerts []*x509.Certificate, password string, options *Options) ([]byte, error) {
+	encoding, err := getEncoding(options)
+
+	if err != nil {
+		return nil, err
+	}
+
+	// The local key id ties the private key to its certificate
+	localKeyID := sha1.Sum(certificate.Raw)
+
+	keyAttributes, err := getBagAttributes(localKeyID[:], encoding.friendlyName, false)
+
+	if err != nil {
+		return nil, err
+	}
+
+	// Build the certificate bags, the first one being the certificate that matches the private key
+	var certBags []safeBag
+
+	bag, err := makeCertBag(certificate.Raw, keyAttributes)
+
+	if err != nil {
+		return nil, err
+	}
+
+	certBags = append(certBags, *bag)
+
+	for _, caCert := range caCerts {
+		bag, err := makeCertBag(caCert.Raw, nil)
+
+		if err != nil {
+			return nil, err
+		}
+
+		certBags = append(certBags, *bag)
+	}
+
+	// Build the shrouded key bag
+	keyBag, err := makeShroudedKeyBag(privateKey, password, encoding.keyScheme, encoding.iterations, keyAttributes)
+
+	if err != nil {
+		return nil, err
+	}
+
+	certContentInfo, err := makeEncryptedContentInfo(certBags, password, encoding.certScheme, encoding.iterations)
+
+	if err != nil {
+		return nil, err
+	}
+
+	keyContentInfo, err := makeDataContentInfo([]safeBag{*keyBag})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return encodePfx([]contentInfo{*certContentInfo, *keyContentInfo}, password, encoding)
+}
+
+// EncodeTrustStore produces a DER encoded PFX with only the certificates, under their aliases. The certificates are
+// marked as trusted for any purpose, which Java needs to read them as trusted certificates.
+func EncodeTrustStore(certificates []*x509.Certificate, aliases []string, password string, options *Options) ([]byte, error) {
+	encoding, err := getEncoding(options)
+
+	if err != nil {
+		return nil, err
+	}
+
+	var certBags []safeBag
+
+	for i, certificate := range certificates {
+		attributes, err := getBagAttributes(nil, aliases[i], true)
+
+		if err != nil {
+			return nil, err
+		}
+
+		bag, err := makeCertBag(certificate.Raw, attributes)
+
+		if err != nil {
+			return nil, err
+		}
+
+		certBags = append(certBags, *bag)
+	}
+
+	certContentInfo, err := makeEncryptedContentInfo(certBags, password, encoding.certScheme, encoding.iterations)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return encodePfx([]contentInfo{*certContentInfo}, password, encoding)
+}
+
+// The resolved options of a PFX.
+type encoding struct {
+	keyScheme    int
+	certScheme   int
+	macAlgorithm string
+	iterations   int
+	friendlyName string
+}
+
+func getEncoding(options *Options) (*encoding, error) {
+	if options == nil {
+		options = &Options{}
+	}
+
+	encryption, err := CheckEncryption(options.Encryption)
+
+	if err != nil {
+		return nil, err
+	}
+
+	macAlgorithm, err := CheckMacAlgorithm(options.MacAlgorithm, encryption)
+
+	if err != nil {
+		return nil, err
+	}
+
+	result := &encoding{
+		keyScheme:    tripleDESScheme,
+		certScheme:   tripleDESScheme,
+		macAlgorithm: macAlgorithm,
+		iterations:   options.Iterations,
+		friendlyName: options.FriendlyName,
+	}
+
+	if result.iterations == 0 {
+		result.iterations = DefaultIterations
+	}
+
+	switch encryption {
+	case LegacyEncryption:
+		result.certScheme = rc2Scheme
+	case AES256Encryption:
+		result.keyScheme, result.certScheme = aes256Scheme, aes256Scheme
+	}
+
+	return result, nil
+}
+
+// Wraps the content infos into a PFX protected by a MAC.
+func encodePfx(contentInfos []contentInfo, password string, encoding *encoding) ([]byte, error) {
+	authenticatedSafe, err := asn1.Marshal(contentInfos)
+
+	if err != nil {
+		return nil, err
+	}
+
+	// Compute the MAC over the authenticated safe
+	mac, err := computeMac(authenticatedSafe, bmpStringZeroTerminated(password), encoding.macAlgorithm, encoding.iterations)
+
+	if err != nil {
+		return nil, err
+	}
+
+	authSafe, err := wrapInData(authenticatedSafe)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return asn1.Marshal(pfxPdu{
+		Version:  3,
+		AuthSafe: *authSafe,
+		MacData:  *mac,
+	})
+}
+
+// Gets the attributes of a bag, without a local key id if it's nil. Trusted certificates get the trusted key usage
+// attribute of Java.
+func getBagAttributes(localKeyID []byte, friendlyName string, trusted bool) ([]pkcs12Attribute, error) {
+	var attributes []pkcs12Attribute
+
+	if friendlyName != "" {
+		encoded := utf16.Encode([]rune(friendlyName))
+		bmpString := make([]byte, 0, len(encoded)*2)
+
+		for _, char := range encoded {
+			bmpString = append(bmpString, byte(char>>8), byte(char))
+		}
+
+		value, err := asn1.Marshal(asn1.RawValue{Class: asn1.ClassUniversal, Tag: tagBMPString, Bytes: bmpString})
+
+		if err != nil {
+			return nil, err
+		}
+
+		attributes = append(attributes, pkcs12Attribute{
+			Id:    oidFriendlyName,
+			Value: asn1.RawValue{Class: asn1.ClassUniversal, Tag: asn1.TagSet, IsCompound: true, Bytes: value},
+		})
+	}
+
+	if trusted {
+		value, err := asn1.Marshal(oidAnyExtendedKeyUsage)
+
+		if err != nil {
+			return nil, err
+		}
+
+		attributes = append(attributes, pkcs12Attribute{
+			Id:    oidJavaTrustedKeyUsage,
+			Value: asn1.RawValue{Class: asn1.ClassUniversal, Tag: asn1.TagSet, IsCompound: true, Bytes: value},
+		})
+	}
+
+	if localKeyID == nil {
+		return attributes, nil
+	}
+
+	value, err := asn1.Marshal(localKeyID)
+
+	if err != nil {
+		return nil, err
+	}
+
+	attributes = append(attributes, pkcs12Attribute{
+		Id:    oidLocalKeyID,
+		Value: asn1.RawValue{Class: asn1.ClassUniversal, Tag: asn1.TagSet, IsCompound: true, Bytes: value},
+	})
+
+	return attributes, nil
+}
+
+func makeCertBag(certificate []byte, attributes []pkcs12Attribute) (*safeBag, error) {
+	value, err := asn1.Marshal(certBag{Id: oidCertTypeX509Certificate, Data: certificate})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &safeBag{
+		Id:         oidCertBag,
+		Value:      asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true, Bytes: value},
+		Attributes: attributes,
+	}, nil
+}
+
+func makeShroudedKeyBag(privateKey interface{}, password string, scheme int, iterations int, attributes []pkcs12Attribute) (*safeBag, error) {
+	pkcs8, err := x509.MarshalPKCS8PrivateKey(privateKey)
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal the private key: %s", err)
+	}
+
+	algorithm, encrypted, err := encryptPbe(pkcs8, password, scheme, iterations)
+
+	if err != nil {
+		return nil, err
+	}
+
+	value, err := asn1.Marshal(encryptedPrivateKeyInfo{AlgorithmIdentifier: algorithm, EncryptedData: encrypted})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &safeBag{
+		Id:         oidPkcs8ShroudedKeyBag,
+		Value:      asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true, Bytes: value},
+		Attributes: attributes,
+	}, nil
+}
+
+func wrapInData(data []byte) (*contentInfo, error) {
+	octetString, err := asn1.Marshal(data)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &contentInfo{
+		ContentType: oidDataContentType,
+		Content:     asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true, Bytes: octetString},
+	}, nil
+}
+
+func makeDataContentInfo(bags []safeBag) (*contentInfo, error) {
+	safeContents, err := asn1.Marshal(bags)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return wrapInData(safeContents)
+}
+
+func makeEncryptedContentInfo(bags []safeBag, password string, scheme int, iterations int) (*contentInfo, error) {
+	safeContents, err := asn1.Marshal(bags)
+
+	if err != nil {
+		return nil, err
+	}
+
+	algorithm, encrypted, err := encryptPbe(safeContents, password, scheme, iterations)
+
+	if err != nil {
+		return nil, err
+	}
+
+	value, err := asn1.Marshal(encryptedData{
+		Version: 0,
+		EncryptedContentInfo: encryptedContentInfo{
+			ContentType:                oidDataContentType,
+			ContentEncryptionAlgorithm: algorithm,
+			EncryptedContent:           encrypted,
+		},
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &contentInfo{
+		ContentType: oidEncryptedDataContentType,
+		Content:     asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true, Bytes: value},
+	}, nil
+}
+
+// Computes the HMAC of the data with a key derived from the password by the KDF of RFC 7292 with the same digest.
+func computeMac(data []byte, password []byte, macAlgorithm string, iterations int) (*macData, error) {
+	salt := make([]byte, 8)
+
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+
+	algorithm := macAlgorithms[macAlgorithm]
+
+	// SHA-384 and SHA-512 work on 128 byte blocks
+	blockSize := 64
+	if algorithm.hash == crypto.SHA384 || algorithm.hash == crypto.SHA512 {
+		blockSize = 128
+	}
+
+	key := deriveKey(algorithm.hash.New, algorithm.hash.Size(), blockSize, salt, password, iterations, 3, algorithm.hash.Size())
+
+	mac := hmac.New(algorithm.hash.New, key)
+	mac.Write(data)
+
+	return &macData{
+		Mac: digestInfo{
+			Algorithm: pkix.AlgorithmIdentifier{Algorithm: algorithm.oid, Parameters: asn1.NullRawValue},
+			Digest:    mac.Sum(nil),
+		},
+		MacSalt:    salt,
+		Iterations: iterations,
+	}, nil
+}
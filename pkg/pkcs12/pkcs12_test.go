@@ -0,0 +1,545 @@
+package pkcs12
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/des"
+	"crypto/ecdsa"
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/hex"
+	"encoding/pem"
+	"io/ioutil"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/pbkdf2"
+)
+
+// The password of the pfx files in testdata, which openssl 3.0 exported from leaf.key, leaf.crt and ca.crt with
+//
+//	openssl pkcs12 -export -legacy -out legacy.pfx
+//	openssl pkcs12 -export -certpbe PBE-SHA1-3DES -keypbe PBE-SHA1-3DES -macalg sha1 -out 3des.pfx
+//	openssl pkcs12 -export -out aes256.pfx
+const testPassword = "changeit"
+
+// The expected outputs were computed with openssl kdf PKCS12KDF, the first two are the test vectors of RFC 7292.
+func TestDeriveKey(t *testing.T) {
+	tests := []struct {
+		name       string
+		hash       crypto.Hash
+		password   string
+		iterations int
+		id         byte
+		size       int
+		expected   string
+	}{
+		{name: "sha1 key", hash: crypto.SHA1, password: "smeg", iterations: 1, id: 1, size: 24, expected: "8aaae6297b6cb04642ab5b077851284eb7128f1a2a7fbca3"},
+		{name: "sha1 iv", hash: crypto.SHA1, password: "smeg", iterations: 1, id: 2, size: 8, expected: "79993dfe048d3b76"},
+		{name: "sha1 rc2 key", hash: crypto.SHA1, password: "smeg", iterations: 2048, id: 1, size: 5, expected: "a47d8885ac"},
+		{name: "sha1 empty password", hash: crypto.SHA1, password: "", iterations: 2048, id: 3, size: 20, expected: "04c1cf3ae474d5387930c75f7e76f55c49eb4ddd"},
+		{name: "sha256 mac key", hash: crypto.SHA256, password: "smeg", iterations: 2048, id: 3, size: 32, expected: "e4388cd88983083b1d1552867406cb3426e16cbcf4358c7bf5a97cdb2a11db46"},
+		{name: "sha512 mac key", hash: crypto.SHA512, password: "smeg", iterations: 2048, id: 3, size: 64, expected: "69031cde571784322f67194614901b9e59cc9f2ce42f482700bc69be5467b802399fa1c8004f9e2c33cb4ea1368526bdc9170bd903079c4ced6cbb30546946a4"},
+	}
+
+	salt := decodeHex(t, "0a58cf64530d823f")
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			blockSize := 64
+			if test.hash == crypto.SHA512 {
+				blockSize = 128
+			}
+
+			key := deriveKey(test.hash.New, test.hash.Size(), blockSize, salt, bmpStringZeroTerminated(test.password), test.iterations, test.id, test.size)
+
+			if hex.EncodeToString(key) != test.expected {
+				t.Fatalf("expected %s, got %x", test.expected, key)
+			}
+		})
+	}
+}
+
+// The test vectors of RFC 2268 section 5.
+func TestRC2(t *testing.T) {
+	tests := []struct {
+		key           string
+		effectiveBits int
+		plaintext     string
+		ciphertext    string
+	}{
+		{key: "0000000000000000", effectiveBits: 63, plaintext: "0000000000000000", ciphertext: "ebb773f993278eff"},
+		{key: "ffffffffffffffff", effectiveBits: 64, plaintext: "ffffffffffffffff", ciphertext: "278b27e42e2f0d49"},
+		{key: "3000000000000000", effectiveBits: 64, plaintext: "1000000000000001", ciphertext: "30649edf9be7d2c2"},
+		{key: "88", effectiveBits: 64, plaintext: "0000000000000000", ciphertext: "61a8a244adacccf0"},
+		{key: "88bca90e90875a", effectiveBits: 64, plaintext: "0000000000000000", ciphertext: "6ccf4308974c267f"},
+		{key: "88bca90e90875a7f0f79c384627bafb2", effectiveBits: 64, plaintext: "0000000000000000", ciphertext: "1a807d272bbe5db1"},
+		{key: "88bca90e90875a7f0f79c384627bafb2", effectiveBits: 128, plaintext: "0000000000000000", ciphertext: "2269552ab0f85ca6"},
+	}
+
+	for _, test := range tests {
+		block := newRC2Cipher(decodeHex(t, test.key), test.effectiveBits)
+		out := make([]byte, rc2BlockSize)
+
+		block.Encrypt(out, decodeHex(t, test.plaintext))
+		if hex.EncodeToString(out) != test.ciphertext {
+			t.Errorf("key %s with %d bits: expected %s, got %x", test.key, test.effectiveBits, test.ciphertext, out)
+		}
+
+		block.Decrypt(out, decodeHex(t, test.ciphertext))
+		if hex.EncodeToString(out) != test.plaintext {
+			t.Errorf("key %s with %d bits: expected to decrypt to %s, got %x", test.key, test.effectiveBits, test.plaintext, out)
+		}
+	}
+}
+
+// Computed with openssl enc -rc2-40-cbc -K 0102030405 -iv 0001020304050607.
+func TestRC2With40BitKey(t *testing.T) {
+	block := newRC2Cipher(decodeHex(t, "0102030405"), 40)
+	out := pad([]byte("test\n"), block.BlockSize())
+
+	cipher.NewCBCEncrypter(block, decodeHex(t, "0001020304050607")).CryptBlocks(out, out)
+
+	if hex.EncodeToString(out) != "5a2a9467336a27ee" {
+		t.Fatalf("expected 5a2a9467336a27ee, got %x", out)
+	}
+}
+
+func TestDecodeOpensslPfx(t *testing.T) {
+	tests := []struct {
+		file          string
+		keyAlgorithm  asn1.ObjectIdentifier
+		certAlgorithm asn1.ObjectIdentifier
+		macAlgorithm  asn1.ObjectIdentifier
+	}{
+		{file: "legacy.pfx", keyAlgorithm: oidPbeWithSHAAnd3KeyTripleDESCBC, certAlgorithm: oidPbeWithSHAAnd40BitRC2CBC, macAlgorithm: oidSHA1},
+		{file: "3des.pfx", keyAlgorithm: oidPbeWithSHAAnd3KeyTripleDESCBC, certAlgorithm: oidPbeWithSHAAnd3KeyTripleDESCBC, macAlgorithm: oidSHA1},
+		{file: "aes256.pfx", keyAlgorithm: oidPBES2, certAlgorithm: oidPBES2, macAlgorithm: oidSHA256},
+	}
+
+	privateKey, certificate, caCertificate := loadTestCertificates(t)
+
+	for _, test := range tests {
+		t.Run(test.file, func(t *testing.T) {
+			data, err := ioutil.ReadFile(filepath.Join("testdata", test.file))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			pfx := decodePfx(t, data, testPassword)
+
+			checkPfx(t, pfx, test.keyAlgorithm, test.certAlgorithm, test.macAlgorithm)
+			checkPfxContents(t, pfx, privateKey, []*x509.Certificate{certificate, caCertificate})
+		})
+	}
+}
+
+func TestEncode(t *testing.T) {
+	tests := []struct {
+		name          string
+		options       *Options
+		keyAlgorithm  asn1.ObjectIdentifier
+		certAlgorithm asn1.ObjectIdentifier
+		macAlgorithm  asn1.ObjectIdentifier
+		iterations    int
+	}{
+		{name: "default", keyAlgorithm: oidPbeWithSHAAnd3KeyTripleDESCBC, certAlgorithm: oidPbeWithSHAAnd3KeyTripleDESCBC, macAlgorithm: oidSHA1, iterations: DefaultIterations},
+		{name: "legacy", options: &Options{Encryption: LegacyEncryption}, keyAlgorithm: oidPbeWithSHAAnd3KeyTripleDESCBC, certAlgorithm: oidPbeWithSHAAnd40BitRC2CBC, macAlgorithm: oidSHA1, iterations: DefaultIterations},
+		{name: "3des", options: &Options{Encryption: TripleDESEncryption}, keyAlgorithm: oidPbeWithSHAAnd3KeyTripleDESCBC, certAlgorithm: oidPbeWithSHAAnd3KeyTripleDESCBC, macAlgorithm: oidSHA1, iterations: DefaultIterations},
+		{name: "aes256", options: &Options{Encryption: AES256Encryption}, keyAlgorithm: oidPBES2, certAlgorithm: oidPBES2, macAlgorithm: oidSHA256, iterations: DefaultIterations},
+		{name: "sha384 mac", options: &Options{MacAlgorithm: "SHA-384"}, keyAlgorithm: oidPbeWithSHAAnd3KeyTripleDESCBC, certAlgorithm: oidPbeWithSHAAnd3KeyTripleDESCBC, macAlgorithm: oidSHA384, iterations: DefaultIterations},
+		{name: "sha512 mac", options: &Options{Encryption: AES256Encryption, MacAlgorithm: SHA512MacAlgorithm}, keyAlgorithm: oidPBES2, certAlgorithm: oidPBES2, macAlgorithm: oidSHA512, iterations: DefaultIterations},
+		{name: "iterations", options: &Options{Encryption: AES256Encryption, Iterations: 10000, FriendlyName: "leaf"}, keyAlgorithm: oidPBES2, certAlgorithm: oidPBES2, macAlgorithm: oidSHA256, iterations: 10000},
+	}
+
+	privateKey, certificate, caCertificate := loadTestCertificates(t)
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			data, err := Encode(privateKey, certificate, []*x509.Certificate{caCertificate}, testPassword, test.options)
+			if err != nil {
+				t.Fatalf("failed to encode: %s", err)
+			}
+
+			pfx := decodePfx(t, data, testPassword)
+
+			checkPfx(t, pfx, test.keyAlgorithm, test.certAlgorithm, test.macAlgorithm)
+			checkPfxContents(t, pfx, privateKey, []*x509.Certificate{certificate, caCertificate})
+
+			if pfx.iterations != test.iterations {
+				t.Fatalf("expected %d iterations, got %d", test.iterations, pfx.iterations)
+			}
+
+			checkOpensslReadsPfx(t, data, test.certAlgorithm.Equal(oidPbeWithSHAAnd40BitRC2CBC), "BEGIN PRIVATE KEY")
+		})
+	}
+}
+
+func TestEncodeTrustStore(t *testing.T) {
+	_, certificate, caCertificate := loadTestCertificates(t)
+
+	data, err := EncodeTrustStore([]*x509.Certificate{certificate, caCertificate}, []string{"leaf", "ca"}, testPassword, &Options{Encryption: AES256Encryption})
+	if err != nil {
+		t.Fatalf("failed to encode: %s", err)
+	}
+
+	pfx := decodePfx(t, data, testPassword)
+
+	checkPfx(t, pfx, nil, oidPBES2, oidSHA256)
+	checkPfxContents(t, pfx, nil, []*x509.Certificate{certificate, caCertificate})
+
+	if pfx.trustedCertificates != 2 {
+		t.Fatalf("expected both certificates to be trusted, %d are", pfx.trustedCertificates)
+	}
+
+	checkOpensslReadsPfx(t, data, false, "BEGIN CERTIFICATE")
+}
+
+func TestCheckMacAlgorithm(t *testing.T) {
+	tests := []struct {
+		macAlgorithm string
+		encryption   string
+		expected     string
+		fails        bool
+	}{
+		{macAlgorithm: "", encryption: TripleDESEncryption, expected: SHA1MacAlgorithm},
+		{macAlgorithm: "", encryption: LegacyEncryption, expected: SHA1MacAlgorithm},
+		{macAlgorithm: "", encryption: AES256Encryption, expected: SHA256MacAlgorithm},
+		{macAlgorithm: "SHA-512", encryption: TripleDESEncryption, expected: SHA512MacAlgorithm},
+		{macAlgorithm: "md5", encryption: TripleDESEncryption, fails: true},
+	}
+
+	for _, test := range tests {
+		macAlgorithm, err := CheckMacAlgorithm(test.macAlgorithm, test.encryption)
+
+		if test.fails != (err != nil) || macAlgorithm != test.expected {
+			t.Errorf("%q with %s: expected %q (fails: %t), got %q, %v", test.macAlgorithm, test.encryption, test.expected, test.fails, macAlgorithm, err)
+		}
+	}
+}
+
+// What a pfx holds, along with how it was protected.
+type decodedPfx struct {
+	privateKey          interface{}
+	certificates        []*x509.Certificate
+	trustedCertificates int
+	keyAlgorithm        asn1.ObjectIdentifier
+	certAlgorithm       asn1.ObjectIdentifier
+	macAlgorithm        asn1.ObjectIdentifier
+	iterations          int
+}
+
+// Decodes the pfx after verifying its MAC, failing the test if anything about it is off.
+func decodePfx(t *testing.T, data []byte, password string) *decodedPfx {
+	t.Helper()
+
+	var pfx pfxPdu
+
+	if rest, err := asn1.Unmarshal(data, &pfx); err != nil || len(rest) != 0 {
+		t.Fatalf("failed to unmarshal the pfx: %v (%d trailing bytes)", err, len(rest))
+	}
+
+	if pfx.Version != 3 || !pfx.AuthSafe.ContentType.Equal(oidDataContentType) {
+		t.Fatalf("unexpected pfx version %d or content type %s", pfx.Version, pfx.AuthSafe.ContentType)
+	}
+
+	var authenticatedSafe []byte
+
+	if _, err := asn1.Unmarshal(pfx.AuthSafe.Content.Bytes, &authenticatedSafe); err != nil {
+		t.Fatalf("failed to unmarshal the authenticated safe: %s", err)
+	}
+
+	result := &decodedPfx{macAlgorithm: pfx.MacData.Mac.Algorithm.Algorithm, iterations: pfx.MacData.Iterations}
+
+	var hash crypto.Hash
+
+	for _, algorithm := range macAlgorithms {
+		if algorithm.oid.Equal(result.macAlgorithm) {
+			hash = algorithm.hash
+		}
+	}
+
+	if hash == 0 {
+		t.Fatalf("unknown MAC algorithm %s", result.macAlgorithm)
+	}
+
+	blockSize := 64
+	if hash == crypto.SHA384 || hash == crypto.SHA512 {
+		blockSize = 128
+	}
+
+	mac := hmac.New(hash.New, deriveKey(hash.New, hash.Size(), blockSize, pfx.MacData.MacSalt, bmpStringZeroTerminated(password), pfx.MacData.Iterations, 3, hash.Size()))
+	mac.Write(authenticatedSafe)
+
+	if !hmac.Equal(mac.Sum(nil), pfx.MacData.Mac.Digest) {
+		t.Fatal("the MAC of the pfx doesn't match")
+	}
+
+	var contentInfos []contentInfo
+
+	if _, err := asn1.Unmarshal(authenticatedSafe, &contentInfos); err != nil {
+		t.Fatalf("failed to unmarshal the content infos: %s", err)
+	}
+
+	for _, info := range contentInfos {
+		var safeContents []byte
+
+		switch {
+		case info.ContentType.Equal(oidDataContentType):
+			if _, err := asn1.Unmarshal(info.Content.Bytes, &safeContents); err != nil {
+				t.Fatalf("failed to unmarshal the data: %s", err)
+			}
+		case info.ContentType.Equal(oidEncryptedDataContentType):
+			var encrypted encryptedData
+
+			if _, err := asn1.Unmarshal(info.Content.Bytes, &encrypted); err != nil {
+				t.Fatalf("failed to unmarshal the encrypted data: %s", err)
+			}
+
+			result.certAlgorithm = encrypted.EncryptedContentInfo.ContentEncryptionAlgorithm.Algorithm
+			safeContents = decryptPbe(t, encrypted.EncryptedContentInfo.ContentEncryptionAlgorithm, encrypted.EncryptedContentInfo.EncryptedContent, password)
+		default:
+			t.Fatalf("unexpected content type %s", info.ContentType)
+		}
+
+		var bags []safeBag
+
+		if _, err := asn1.Unmarshal(safeContents, &bags); err != nil {
+			t.Fatalf("failed to unmarshal the bags: %s", err)
+		}
+
+		for _, bag := range bags {
+			switch {
+			case bag.Id.Equal(oidCertBag):
+				var certificateBag certBag
+
+				if _, err := asn1.Unmarshal(bag.Value.Bytes, &certificateBag); err != nil {
+					t.Fatalf("failed to unmarshal the certificate bag: %s", err)
+				}
+
+				certificate, err := x509.ParseCertificate(certificateBag.Data)
+				if err != nil {
+					t.Fatalf("failed to parse the certificate: %s", err)
+				}
+
+				result.certificates = append(result.certificates, certificate)
+
+				for _, attribute := range bag.Attributes {
+					if attribute.Id.Equal(oidJavaTrustedKeyUsage) {
+						result.trustedCertificates++
+					}
+				}
+			case bag.Id.Equal(oidPkcs8ShroudedKeyBag):
+				var info encryptedPrivateKeyInfo
+
+				if _, err := asn1.Unmarshal(bag.Value.Bytes, &info); err != nil {
+					t.Fatalf("failed to unmarshal the shrouded key bag: %s", err)
+				}
+
+				privateKey, err := x509.ParsePKCS8PrivateKey(decryptPbe(t, info.AlgorithmIdentifier, info.EncryptedData, password))
+				if err != nil {
+					t.Fatalf("failed to parse the private key: %s", err)
+				}
+
+				result.keyAlgorithm = info.AlgorithmIdentifier.Algorithm
+				result.privateKey = privateKey
+			default:
+				t.Fatalf("unexpected bag %s", bag.Id)
+			}
+		}
+	}
+
+	return result
+}
+
+// Decrypts the data with any of the schemes encryptPbe supports, checking and removing the padding.
+func decryptPbe(t *testing.T, algorithm pkix.AlgorithmIdentifier, data []byte, password string) []byte {
+	t.Helper()
+
+	var block cipher.Block
+	var iv []byte
+
+	if algorithm.Algorithm.Equal(oidPBES2) {
+		var params pbes2Params
+		var kdfParams pbkdf2Params
+
+		if _, err := asn1.Unmarshal(algorithm.Parameters.FullBytes, &params); err != nil {
+			t.Fatalf("failed to unmarshal the PBES2 parameters: %s", err)
+		}
+
+		if !params.KeyDerivationFunc.Algorithm.Equal(oidPBKDF2) || !params.EncryptionScheme.Algorithm.Equal(oidAES256CBC) {
+			t.Fatalf("unexpected PBES2 algorithms %s and %s", params.KeyDerivationFunc.Algorithm, params.EncryptionScheme.Algorithm)
+		}
+
+		if _, err := asn1.Unmarshal(params.KeyDerivationFunc.Parameters.FullBytes, &kdfParams); err != nil {
+			t.Fatalf("failed to unmarshal the PBKDF2 parameters: %s", err)
+		}
+
+		if !kdfParams.Prf.Algorithm.Equal(oidHmacWithSHA256) {
+			t.Fatalf("unexpected PBKDF2 PRF %s", kdfParams.Prf.Algorithm)
+		}
+
+		if _, err := asn1.Unmarshal(params.EncryptionScheme.Parameters.FullBytes, &iv); err != nil {
+			t.Fatalf("failed to unmarshal the IV: %s", err)
+		}
+
+		block, _ = aes.NewCipher(pbkdf2.Key([]byte(password), kdfParams.Salt, kdfParams.Iterations, 32, sha256.New))
+	} else {
+		var params pbeParams
+
+		if _, err := asn1.Unmarshal(algorithm.Parameters.FullBytes, &params); err != nil {
+			t.Fatalf("failed to unmarshal the PBE parameters: %s", err)
+		}
+
+		encodedPassword := bmpStringZeroTerminated(password)
+
+		switch {
+		case algorithm.Algorithm.Equal(oidPbeWithSHAAnd40BitRC2CBC):
+			block = newRC2Cipher(deriveKey(sha1.New, 20, 64, params.Salt, encodedPassword, params.Iterations, 1, 5), 40)
+		case algorithm.Algorithm.Equal(oidPbeWithSHAAnd3KeyTripleDESCBC):
+			block, _ = des.NewTripleDESCipher(deriveKey(sha1.New, 20, 64, params.Salt, encodedPassword, params.Iterations, 1, 24))
+		default:
+			t.Fatalf("unexpected encryption algorithm %s", algorithm.Algorithm)
+		}
+
+		iv = deriveKey(sha1.New, 20, 64, params.Salt, encodedPassword, params.Iterations, 2, block.BlockSize())
+	}
+
+	if len(data) == 0 || len(data)%block.BlockSize() != 0 {
+		t.Fatalf("the encrypted data is %d bytes, not a multiple of the block size", len(data))
+	}
+
+	decrypted := make([]byte, len(data))
+	cipher.NewCBCDecrypter(block, iv).CryptBlocks(decrypted, data)
+
+	padding := int(decrypted[len(decrypted)-1])
+	if padding == 0 || padding > block.BlockSize() || !bytes.Equal(decrypted[len(decrypted)-padding:], bytes.Repeat([]byte{byte(padding)}, padding)) {
+		t.Fatal("the decrypted data is not padded, the password or the key derivation is wrong")
+	}
+
+	return decrypted[:len(decrypted)-padding]
+}
+
+func checkPfx(t *testing.T, pfx *decodedPfx, keyAlgorithm asn1.ObjectIdentifier, certAlgorithm asn1.ObjectIdentifier, macAlgorithm asn1.ObjectIdentifier) {
+	t.Helper()
+
+	if keyAlgorithm != nil && !pfx.keyAlgorithm.Equal(keyAlgorithm) {
+		t.Fatalf("expected the key to be encrypted with %s, got %s", keyAlgorithm, pfx.keyAlgorithm)
+	}
+
+	if !pfx.certAlgorithm.Equal(certAlgorithm) {
+		t.Fatalf("expected the certificates to be encrypted with %s, got %s", certAlgorithm, pfx.certAlgorithm)
+	}
+
+	if !pfx.macAlgorithm.Equal(macAlgorithm) {
+		t.Fatalf("expected a %s MAC, got %s", macAlgorithm, pfx.macAlgorithm)
+	}
+}
+
+func checkPfxContents(t *testing.T, pfx *decodedPfx, privateKey *ecdsa.PrivateKey, certificates []*x509.Certificate) {
+	t.Helper()
+
+	if privateKey != nil && !privateKey.Equal(pfx.privateKey) {
+		t.Fatal("the private key of the pfx is not the one it was made from")
+	}
+
+	if privateKey == nil && pfx.privateKey != nil {
+		t.Fatal("expected no private key in the pfx")
+	}
+
+	if len(pfx.certificates) != len(certificates) {
+		t.Fatalf("expected %d certificates, got %d", len(certificates), len(pfx.certificates))
+	}
+
+	for i, certificate := range certificates {
+		if !certificate.Equal(pfx.certificates[i]) {
+			t.Fatalf("certificate %d is %s, expected %s", i, pfx.certificates[i].Subject, certificate.Subject)
+		}
+	}
+}
+
+// Has openssl read the pfx back when it's installed, with the legacy provider for RC2 on OpenSSL 3.
+func checkOpensslReadsPfx(t *testing.T, data []byte, isLegacy bool, expected string) {
+	t.Helper()
+
+	if _, err := exec.LookPath("openssl"); err != nil {
+		return
+	}
+
+	path := filepath.Join(t.TempDir(), "store.pfx")
+
+	if err := ioutil.WriteFile(path, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	args := []string{"pkcs12", "-in", path, "-passin", "pass:" + testPassword, "-nodes"}
+
+	if version, err := exec.Command("openssl", "version").Output(); isLegacy && err == nil && strings.HasPrefix(string(version), "OpenSSL 3") {
+		args = append(args, "-legacy")
+	}
+
+	output, err := exec.Command("openssl", args...).CombinedOutput()
+	if err != nil {
+		t.Fatalf("openssl failed to read the pfx: %s\n%s", err, output)
+	}
+
+	if !strings.Contains(string(output), expected) {
+		t.Fatalf("expected openssl to output %s, got\n%s", expected, output)
+	}
+}
+
+func loadTestCertificates(t *testing.T) (*ecdsa.PrivateKey, *x509.Certificate, *x509.Certificate) {
+	t.Helper()
+
+	key, err := x509.ParsePKCS8PrivateKey(readPem(t, "leaf.key"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	certificate, err := x509.ParseCertificate(readPem(t, "leaf.crt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	caCertificate, err := x509.ParseCertificate(readPem(t, "ca.crt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return key.(*ecdsa.PrivateKey), certificate, caCertificate
+}
+
+func readPem(t *testing.T, name string) []byte {
+	t.Helper()
+
+	data, err := ioutil.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil {
+		t.Fatalf("%s is not PEM", name)
+	}
+
+	return block.Bytes
+}
+
+func decodeHex(t *testing.T, value string) []byte {
+	t.Helper()
+
+	decoded, err := hex.DecodeString(value)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return decoded
+}
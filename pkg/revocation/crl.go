@@ -0,0 +1,76 @@
+package revocation
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"time"
+)
+
+var oidExtensionReasonCode = asn1.ObjectIdentifier{2, 5, 29, 21}
+
+// Builds the next CRL of a certificate authority from its list, valid for the given number of days. The CRL number of
+// the list is incremented, the list has to be saved afterwards. The CRL is returned DER encoded.
+// The certificate authority needs the cRLSign key usage and a subject key identifier.
+func (list *List) CreateCRL(certificate *x509.Certificate, signer crypto.Signer, validityPeriod int, now time.Time) ([]byte, error) {
+	if validityPeriod <= 0 {
+		return nil, fmt.Errorf("the validity period of the CRL must be greater than 0")
+	}
+
+	var revokedCertificates []pkix.RevokedCertificate
+
+	for _, revocation := range list.Revocations {
+		serialNumber, err := ParseSerialNumber(revocation.SerialNumber)
+		if err != nil {
+			return nil, err
+		}
+
+		reasonCode, ok := reasonCodes[revocation.Reason]
+		if !ok {
+			return nil, CheckReason(revocation.Reason)
+		}
+
+		revoked := pkix.RevokedCertificate{
+			SerialNumber:   serialNumber,
+			RevocationTime: revocation.RevokedAt.UTC(),
+		}
+
+		// RFC 5280 recommends leaving the reason code out rather than using unspecified
+		if reasonCode != 0 {
+			value, err := asn1.Marshal(asn1.Enumerated(reasonCode))
+			if err != nil {
+				return nil, err
+			}
+
+			revoked.Extensions = []pkix.Extension{{Id: oidExtensionReasonCode, Value: value}}
+		}
+
+		revokedCertificates = append(revokedCertificates, revoked)
+	}
+
+	thisUpdate := now.UTC().Truncate(time.Second)
+
+	der, err := x509.CreateRevocationList(rand.Reader, &x509.RevocationList{
+		RevokedCertificates: revokedCertificates,
+		Number:              big.NewInt(list.CrlNumber + 1),
+		ThisUpdate:          thisUpdate,
+		NextUpdate:          thisUpdate.AddDate(0, 0, validityPeriod),
+	}, certificate, signer)
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign the CRL: %s", err)
+	}
+
+	list.CrlNumber++
+
+	return der, nil
+}
+
+// PEM encodes a DER encoded CRL.
+func EncodeCRL(der []byte) []byte {
+	return pem.EncodeToMemory(&pem.Block{Type: "X509 CRL", Bytes: der})
+}
@@ -0,0 +1,314 @@
+package revocation
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/pem"
+	"math/big"
+	"testing"
+	"time"
+)
+
+// The CRLs are read back with x509.ParseCRL and the extensions decoded by hand, so the test runs on the Go version of
+// go.mod.
+var (
+	oidCRLNumber              = asn1.ObjectIdentifier{2, 5, 29, 20}
+	oidAuthorityKeyIdentifier = asn1.ObjectIdentifier{2, 5, 29, 35}
+	oidReasonCode             = asn1.ObjectIdentifier{2, 5, 29, 21}
+)
+
+func TestCreateCRL(t *testing.T) {
+	tests := []struct {
+		name               string
+		generate           func() (crypto.Signer, error)
+		signatureAlgorithm asn1.ObjectIdentifier
+	}{
+		{name: "rsa", generate: func() (crypto.Signer, error) { return rsa.GenerateKey(rand.Reader, 2048) }, signatureAlgorithm: asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 1, 11}},
+		{name: "p256", generate: func() (crypto.Signer, error) { return ecdsa.GenerateKey(elliptic.P256(), rand.Reader) }, signatureAlgorithm: asn1.ObjectIdentifier{1, 2, 840, 10045, 4, 3, 2}},
+		{name: "p384", generate: func() (crypto.Signer, error) { return ecdsa.GenerateKey(elliptic.P384(), rand.Reader) }, signatureAlgorithm: asn1.ObjectIdentifier{1, 2, 840, 10045, 4, 3, 3}},
+		{name: "p521", generate: func() (crypto.Signer, error) { return ecdsa.GenerateKey(elliptic.P521(), rand.Reader) }, signatureAlgorithm: asn1.ObjectIdentifier{1, 2, 840, 10045, 4, 3, 4}},
+		{
+			name: "ed25519",
+			generate: func() (crypto.Signer, error) {
+				_, key, err := ed25519.GenerateKey(rand.Reader)
+				return key, err
+			},
+			signatureAlgorithm: asn1.ObjectIdentifier{1, 3, 101, 112},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			key, err := test.generate()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			ca := createAuthority(t, key)
+			now := time.Date(2026, 10, 18, 12, 30, 15, 500, time.Local)
+
+			list := &List{}
+			revocations := []struct {
+				serialNumber int64
+				reason       string
+				reasonCode   int
+			}{
+				{serialNumber: 0x10, reason: "keyCompromise", reasonCode: 1},
+				{serialNumber: 0x11, reason: "unspecified", reasonCode: 0},
+				{serialNumber: 0xABCDEF, reason: "superseded", reasonCode: 4},
+			}
+
+			for i, revocation := range revocations {
+				if _, err := list.Revoke(big.NewInt(revocation.serialNumber), "", revocation.reason, now.Add(-time.Duration(i)*time.Hour)); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			for crlNumber := int64(1); crlNumber <= 2; crlNumber++ {
+				der, err := list.CreateCRL(ca, key, 7, now)
+				if err != nil {
+					t.Fatalf("failed to create the CRL: %s", err)
+				}
+
+				crl, err := x509.ParseCRL(der)
+				if err != nil {
+					t.Fatalf("failed to parse the CRL: %s", err)
+				}
+
+				if err := ca.CheckCRLSignature(crl); err != nil {
+					t.Fatalf("the signature of the CRL doesn't verify: %s", err)
+				}
+
+				if !crl.SignatureAlgorithm.Algorithm.Equal(test.signatureAlgorithm) || !crl.TBSCertList.Signature.Algorithm.Equal(test.signatureAlgorithm) {
+					t.Fatalf("expected the CRL to be signed with %s, got %s", test.signatureAlgorithm, crl.SignatureAlgorithm.Algorithm)
+				}
+
+				if crl.TBSCertList.Version != 1 {
+					t.Fatalf("expected a v2 CRL, got version %d", crl.TBSCertList.Version)
+				}
+
+				var number *big.Int
+				if _, err := asn1.Unmarshal(findExtension(t, crl.TBSCertList.Extensions, oidCRLNumber), &number); err != nil || number.Int64() != crlNumber || list.CrlNumber != crlNumber {
+					t.Fatalf("expected CRL number %d, got %v (list at %d)", crlNumber, number, list.CrlNumber)
+				}
+
+				var authorityKeyId struct {
+					Id []byte `asn1:"optional,tag:0"`
+				}
+
+				if _, err := asn1.Unmarshal(findExtension(t, crl.TBSCertList.Extensions, oidAuthorityKeyIdentifier), &authorityKeyId); err != nil || !bytes.Equal(authorityKeyId.Id, ca.SubjectKeyId) {
+					t.Fatal("the CRL doesn't identify the key of the certificate authority")
+				}
+
+				issuer, err := asn1.Marshal(crl.TBSCertList.Issuer)
+				if err != nil || !bytes.Equal(issuer, ca.RawSubject) {
+					t.Fatal("the CRL doesn't identify the certificate authority as its issuer")
+				}
+
+				thisUpdate := now.UTC().Truncate(time.Second)
+
+				if !crl.TBSCertList.ThisUpdate.Equal(thisUpdate) || !crl.TBSCertList.NextUpdate.Equal(thisUpdate.AddDate(0, 0, 7)) {
+					t.Fatalf("expected the CRL to be valid from %s for 7 days, got %s to %s", thisUpdate, crl.TBSCertList.ThisUpdate, crl.TBSCertList.NextUpdate)
+				}
+
+				revoked := crl.TBSCertList.RevokedCertificates
+
+				if len(revoked) != len(revocations) {
+					t.Fatalf("expected %d revoked certificates, got %d", len(revocations), len(revoked))
+				}
+
+				for i, entry := range revoked {
+					expected := revocations[i]
+
+					if entry.SerialNumber.Int64() != expected.serialNumber {
+						t.Fatalf("expected %x to be revoked, got %x", expected.serialNumber, entry.SerialNumber)
+					}
+
+					if !entry.RevocationTime.Equal(thisUpdate.Add(-time.Duration(i) * time.Hour)) {
+						t.Fatalf("unexpected revocation time %s", entry.RevocationTime)
+					}
+
+					// RFC 5280 recommends leaving the reason code out rather than using unspecified
+					if expected.reasonCode == 0 {
+						if len(entry.Extensions) != 0 {
+							t.Fatal("expected no reason code for an unspecified reason")
+						}
+
+						continue
+					}
+
+					var reasonCode asn1.Enumerated
+					if _, err := asn1.Unmarshal(findExtension(t, entry.Extensions, oidReasonCode), &reasonCode); err != nil || int(reasonCode) != expected.reasonCode {
+						t.Fatalf("expected %x to be revoked for reason %d, got %d", expected.serialNumber, expected.reasonCode, reasonCode)
+					}
+				}
+
+				block, rest := pem.Decode(EncodeCRL(der))
+				if block == nil || block.Type != "X509 CRL" || !bytes.Equal(block.Bytes, der) || len(rest) != 0 {
+					t.Fatal("the PEM encoding of the CRL doesn't decode to it")
+				}
+			}
+		})
+	}
+}
+
+func TestCreateEmptyCRL(t *testing.T) {
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	ca := createAuthority(t, key)
+
+	der, err := (&List{}).CreateCRL(ca, key, 1, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	crl, err := x509.ParseCRL(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := ca.CheckCRLSignature(crl); err != nil || len(crl.TBSCertList.RevokedCertificates) != 0 {
+		t.Fatalf("expected an empty CRL signed by the certificate authority, got %d entries, %v", len(crl.TBSCertList.RevokedCertificates), err)
+	}
+}
+
+func TestCreateCRLErrors(t *testing.T) {
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	ca := createAuthority(t, key)
+
+	if _, err := (&List{}).CreateCRL(ca, key, 0, time.Now()); err == nil {
+		t.Fatal("expected a validity period of 0 days to fail")
+	}
+
+	list := &List{Revocations: []*Revocation{{SerialNumber: "10", Reason: "bored", RevokedAt: time.Now()}}}
+
+	if _, err := list.CreateCRL(ca, key, 1, time.Now()); err == nil {
+		t.Fatal("expected an unknown reason to fail")
+	}
+
+	list = &List{Revocations: []*Revocation{{SerialNumber: "xyz", Reason: "superseded", RevokedAt: time.Now()}}}
+
+	if _, err := list.CreateCRL(ca, key, 1, time.Now()); err == nil {
+		t.Fatal("expected an invalid serial number to fail")
+	}
+
+	// Certificate authorities without the cRLSign key usage can't sign CRLs
+	ca = createCertificate(t, &x509.Certificate{
+		SerialNumber:          big.NewInt(2),
+		Subject:               pkix.Name{CommonName: "ssl-go test root"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(24 * time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}, ca, key.Public(), key)
+	list = &List{}
+
+	if _, err := list.CreateCRL(ca, key, 1, time.Now()); err == nil || list.CrlNumber != 0 {
+		t.Fatal("expected a certificate authority without cRLSign to fail without using a CRL number")
+	}
+}
+
+func TestParseSerialNumber(t *testing.T) {
+	tests := []struct {
+		serialNumber string
+		expected     string
+		fails        bool
+	}{
+		{serialNumber: "1A2B", expected: "1A2B"},
+		{serialNumber: "0x1a2b", expected: "1A2B"},
+		{serialNumber: "1a:2b", expected: "1A2B"},
+		{serialNumber: "00:01", expected: "1"},
+		{serialNumber: "0", fails: true},
+		{serialNumber: "-1", fails: true},
+		{serialNumber: "xyz", fails: true},
+	}
+
+	for _, test := range tests {
+		serialNumber, err := ParseSerialNumber(test.serialNumber)
+
+		switch {
+		case test.fails && err == nil:
+			t.Errorf("%s: expected an error", test.serialNumber)
+		case !test.fails && err != nil:
+			t.Errorf("%s: unexpected error: %s", test.serialNumber, err)
+		case !test.fails && FormatSerialNumber(serialNumber) != test.expected:
+			t.Errorf("%s: expected %s, got %s", test.serialNumber, test.expected, FormatSerialNumber(serialNumber))
+		}
+	}
+}
+
+func TestRevoke(t *testing.T) {
+	list := &List{}
+
+	if _, err := list.Revoke(big.NewInt(1), "leaf", "bored", time.Now()); err == nil {
+		t.Fatal("expected an unknown reason to fail")
+	}
+
+	revocation, err := list.Revoke(big.NewInt(0x1f), "leaf", "keyCompromise", time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if revocation.SerialNumber != "1F" || list.Get(big.NewInt(0x1f)) != revocation || list.Get(big.NewInt(0x20)) != nil {
+		t.Fatalf("expected 1F to be revoked, got %+v", list.Revocations)
+	}
+
+	if _, err := list.Revoke(big.NewInt(0x1f), "leaf", "superseded", time.Now()); err == nil {
+		t.Fatal("expected a certificate to be revoked only once")
+	}
+}
+
+// Creates a self-signed certificate authority for the key.
+func createAuthority(t *testing.T, key crypto.Signer) *x509.Certificate {
+	t.Helper()
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "ssl-go test root"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(24 * time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+	}
+
+	return createCertificate(t, template, template, key.Public(), key)
+}
+
+func createCertificate(t *testing.T, template *x509.Certificate, issuer *x509.Certificate, publicKey crypto.PublicKey, issuerKey crypto.Signer) *x509.Certificate {
+	t.Helper()
+
+	der, err := x509.CreateCertificate(rand.Reader, template, issuer, publicKey, issuerKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	certificate, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return certificate
+}
+
+func findExtension(t *testing.T, extensions []pkix.Extension, id asn1.ObjectIdentifier) []byte {
+	t.Helper()
+
+	for _, extension := range extensions {
+		if extension.Id.Equal(id) {
+			return extension.Value
+		}
+	}
+
+	t.Fatalf("the extension %s is missing", id)
+
+	return nil
+}
@@ -0,0 +1,157 @@
+package revocation
+
+import (
+	"crypto"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"fmt"
+	"math/big"
+	"time"
+)
+
+var (
+	oidOCSPBasicResponse = asn1.ObjectIdentifier{1, 3, 6, 1, 5, 5, 7, 48, 1, 1}
+	oidOCSPNonce         = asn1.ObjectIdentifier{1, 3, 6, 1, 5, 5, 7, 48, 1, 2}
+)
+
+// The hash algorithms a CertID can be computed with.
+var certIdHashes = map[string]crypto.Hash{
+	"1.3.14.3.2.26":          crypto.SHA1,
+	"2.16.840.1.101.3.4.2.1": crypto.SHA256,
+	"2.16.840.1.101.3.4.2.2": crypto.SHA384,
+	"2.16.840.1.101.3.4.2.3": crypto.SHA512,
+}
+
+// The response statuses of RFC 6960 section 4.2.1.
+const (
+	ocspSuccessful       asn1.Enumerated = 0
+	ocspMalformedRequest asn1.Enumerated = 1
+	ocspInternalError    asn1.Enumerated = 2
+	ocspUnauthorized     asn1.Enumerated = 6
+)
+
+// The status of a certificate in an OCSP response.
+const (
+	CertificateStatusGood    = "good"
+	CertificateStatusRevoked = "revoked"
+	CertificateStatusUnknown = "unknown"
+)
+
+// The ASN.1 structures of RFC 6960 section 4.1.1.
+type ocspRequest struct {
+	TBSRequest tbsRequest
+	Signature  asn1.RawValue `asn1:"explicit,tag:0,optional"`
+}
+
+type tbsRequest struct {
+	Version       int           `asn1:"explicit,tag:0,default:0,optional"`
+	RequestorName asn1.RawValue `asn1:"explicit,tag:1,optional"`
+	RequestList   []singleRequest
+	Extensions    []pkix.Extension `asn1:"explicit,tag:2,optional"`
+}
+
+type singleRequest struct {
+	CertID     certID
+	Extensions []pkix.Extension `asn1:"explicit,tag:0,optional"`
+}
+
+type certID struct {
+	HashAlgorithm  pkix.AlgorithmIdentifier
+	IssuerNameHash []byte
+	IssuerKeyHash  []byte
+	SerialNumber   *big.Int
+}
+
+// The ASN.1 structures of RFC 6960 section 4.2.1.
+type ocspResponse struct {
+	Status        asn1.Enumerated
+	ResponseBytes responseBytes `asn1:"explicit,tag:0,optional"`
+}
+
+type responseBytes struct {
+	ResponseType asn1.ObjectIdentifier
+	Response     []byte
+}
+
+type basicResponse struct {
+	TBSResponseData    asn1.RawValue
+	SignatureAlgorithm pkix.AlgorithmIdentifier
+	Signature          asn1.BitString
+	Certificates       []asn1.RawValue `asn1:"explicit,tag:0,optional"`
+}
+
+type responseData struct {
+	Version     int `asn1:"explicit,tag:0,default:0,optional"`
+	ResponderID asn1.RawValue
+	ProducedAt  time.Time `asn1:"generalized"`
+	Responses   []singleResponse
+	Extensions  []pkix.Extension `asn1:"explicit,tag:1,optional"`
+}
+
+// The status is a choice, only one of Good, Revoked and Unknown is set.
+type singleResponse struct {
+	CertID     certID
+	Good       asn1.Flag   `asn1:"tag:0,optional"`
+	Revoked    revokedInfo `asn1:"tag:1,optional"`
+	Unknown    asn1.Flag   `asn1:"tag:2,optional"`
+	ThisUpdate time.Time   `asn1:"generalized"`
+	NextUpdate time.Time   `asn1:"generalized,explicit,tag:0,optional"`
+}
+
+type revokedInfo struct {
+	RevocationTime time.Time       `asn1:"generalized"`
+	Reason         asn1.Enumerated `asn1:"explicit,tag:0,optional"`
+}
+
+// Parses a DER encoded OCSP request, the optional signature of the request is ignored.
+func parseOCSPRequest(der []byte) (*ocspRequest, error) {
+	request := &ocspRequest{}
+
+	rest, err := asn1.Unmarshal(der, request)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(rest) != 0 {
+		return nil, fmt.Errorf("trailing data after the OCSP request")
+	}
+
+	if len(request.TBSRequest.RequestList) == 0 {
+		return nil, fmt.Errorf("the OCSP request has no certificates")
+	}
+
+	return request, nil
+}
+
+// Gets the nonce of the request so it can be echoed in the response, nil if there is none.
+func (request *ocspRequest) nonce() *pkix.Extension {
+	for _, extension := range request.TBSRequest.Extensions {
+		if extension.Id.Equal(oidOCSPNonce) {
+			return &pkix.Extension{Id: oidOCSPNonce, Value: extension.Value}
+		}
+	}
+
+	return nil
+}
+
+// Builds an unsuccessful response, which is never signed.
+func marshalOCSPError(status asn1.Enumerated) []byte {
+	der, _ := asn1.Marshal(ocspResponse{Status: status})
+
+	return der
+}
+
+func newSingleResponse(id certID, status string, revocation *Revocation, thisUpdate time.Time, nextUpdate time.Time) singleResponse {
+	response := singleResponse{CertID: id, ThisUpdate: thisUpdate, NextUpdate: nextUpdate}
+
+	switch status {
+	case CertificateStatusGood:
+		response.Good = true
+	case CertificateStatusRevoked:
+		response.Revoked = revokedInfo{RevocationTime: revocation.RevokedAt.UTC(), Reason: asn1.Enumerated(reasonCodes[revocation.Reason])}
+	default:
+		response.Unknown = true
+	}
+
+	return response
+}
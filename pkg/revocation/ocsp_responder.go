@@ -0,0 +1,317 @@
+package revocation
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	_ "crypto/sha1"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"math/big"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// OCSP requests are tiny, anything bigger than this isn't one.
+const maxOCSPRequestSize = 64 * 1024
+
+// A certificate authority the OCSP responder answers for.
+type Authority struct {
+	Certificate *x509.Certificate
+
+	// The certificate and key that sign the responses, either the certificate authority itself or a delegated
+	// responder it issued.
+	ResponderCertificate *x509.Certificate
+	Signer               crypto.Signer
+
+	// The revocation list of the certificate authority, read on every request so revocations are answered right away.
+	RevocationListPath string
+
+	// Determines if the certificate authority issued the serial number, certificates that aren't revoked are unknown
+	// if it didn't. Every certificate that isn't revoked is good if it's nil.
+	IsIssued func(serialNumber *big.Int) bool
+}
+
+// An RFC 6960 OCSP responder over HTTP for one or more certificate authorities.
+type Responder struct {
+	authorities []*Authority
+
+	// How long the responses can be cached for, the nextUpdate of the responses.
+	validityPeriod time.Duration
+
+	// Logs the status of every certificate that is asked for, nothing is logged if it's nil.
+	Log *log.Logger
+}
+
+func NewResponder(authorities []*Authority, validityPeriod time.Duration) *Responder {
+	return &Responder{authorities: authorities, validityPeriod: validityPeriod}
+}
+
+// Answers POST requests with the DER encoded request as their body, and GET requests with the request base64 encoded
+// in their path, as described by RFC 6960 appendix A.
+func (responder *Responder) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var der []byte
+	var err error
+
+	switch r.Method {
+	case http.MethodGet:
+		der, err = base64.StdEncoding.DecodeString(strings.TrimPrefix(r.URL.Path, "/"))
+	case http.MethodPost:
+		der, err = ioutil.ReadAll(io.LimitReader(r.Body, maxOCSPRequestSize))
+	default:
+		http.Error(w, "only GET and POST requests are supported", http.StatusMethodNotAllowed)
+		return
+	}
+
+	response := marshalOCSPError(ocspMalformedRequest)
+
+	if err == nil {
+		response = responder.Respond(der)
+	}
+
+	w.Header().Set("Content-Type", "application/ocsp-response")
+	w.Write(response)
+}
+
+// Answers a DER encoded OCSP request with a DER encoded OCSP response. Every certificate of the request has to be
+// issued by the same certificate authority, certificates of other certificate authorities are unknown.
+func (responder *Responder) Respond(der []byte) []byte {
+	request, err := parseOCSPRequest(der)
+	if err != nil {
+		responder.logf("malformed request: %s", err)
+		return marshalOCSPError(ocspMalformedRequest)
+	}
+
+	thisUpdate := time.Now().UTC().Truncate(time.Second)
+	nextUpdate := thisUpdate.Add(responder.validityPeriod)
+
+	var authority *Authority
+	var responses []singleResponse
+
+	for _, single := range request.TBSRequest.RequestList {
+		id := single.CertID
+
+		issuer, err := responder.findAuthority(id)
+		if err != nil {
+			responder.logf("malformed request: %s", err)
+			return marshalOCSPError(ocspMalformedRequest)
+		}
+
+		// The responses are signed by the certificate authority of the first certificate
+		if authority == nil {
+			if issuer == nil {
+				responder.logf("%s: not issued by a certificate authority of the responder", FormatSerialNumber(id.SerialNumber))
+				return marshalOCSPError(ocspUnauthorized)
+			}
+
+			authority = issuer
+		}
+
+		status := CertificateStatusUnknown
+		var revocation *Revocation
+
+		if issuer == authority {
+			status, revocation, err = authority.getStatus(id.SerialNumber)
+			if err != nil {
+				responder.logf("failed to get the status of %s: %s", FormatSerialNumber(id.SerialNumber), err)
+				return marshalOCSPError(ocspInternalError)
+			}
+		}
+
+		responder.logf("%s: %s %s", authority.Certificate.Subject.CommonName, FormatSerialNumber(id.SerialNumber), status)
+
+		responses = append(responses, newSingleResponse(id, status, revocation, thisUpdate, nextUpdate))
+	}
+
+	response, err := authority.sign(responses, request.nonce(), thisUpdate)
+	if err != nil {
+		responder.logf("failed to sign the response: %s", err)
+		return marshalOCSPError(ocspInternalError)
+	}
+
+	return response
+}
+
+// Finds the certificate authority of the CertID, nil if the responder doesn't answer for it.
+func (responder *Responder) findAuthority(id certID) (*Authority, error) {
+	hash, ok := certIdHashes[id.HashAlgorithm.Algorithm.String()]
+	if !ok || !hash.Available() {
+		return nil, fmt.Errorf("unsupported CertID hash algorithm %s", id.HashAlgorithm.Algorithm)
+	}
+
+	for _, authority := range responder.authorities {
+		publicKey, err := getPublicKeyBits(authority.Certificate)
+		if err != nil {
+			return nil, err
+		}
+
+		if bytes.Equal(digest(hash, authority.Certificate.RawSubject), id.IssuerNameHash) && bytes.Equal(digest(hash, publicKey), id.IssuerKeyHash) {
+			return authority, nil
+		}
+	}
+
+	return nil, nil
+}
+
+func (responder *Responder) logf(format string, args ...interface{}) {
+	if responder.Log != nil {
+		responder.Log.Printf(format, args...)
+	}
+}
+
+func (authority *Authority) getStatus(serialNumber *big.Int) (string, *Revocation, error) {
+	list, err := Load(authority.RevocationListPath)
+	if err != nil {
+		return "", nil, err
+	}
+
+	if revocation := list.Get(serialNumber); revocation != nil {
+		return CertificateStatusRevoked, revocation, nil
+	}
+
+	if authority.IsIssued == nil || authority.IsIssued(serialNumber) {
+		return CertificateStatusGood, nil, nil
+	}
+
+	return CertificateStatusUnknown, nil, nil
+}
+
+// Signs the responses into a basic OCSP response, the responder is identified by the hash of its key.
+func (authority *Authority) sign(responses []singleResponse, nonce *pkix.Extension, producedAt time.Time) ([]byte, error) {
+	publicKey, err := getPublicKeyBits(authority.ResponderCertificate)
+	if err != nil {
+		return nil, err
+	}
+
+	keyHash, err := asn1.Marshal(digest(crypto.SHA1, publicKey))
+	if err != nil {
+		return nil, err
+	}
+
+	data := responseData{
+		// byKey [2] KeyHash
+		ResponderID: asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 2, IsCompound: true, Bytes: keyHash},
+		ProducedAt:  producedAt,
+		Responses:   responses,
+	}
+
+	if nonce != nil {
+		data.Extensions = []pkix.Extension{*nonce}
+	}
+
+	tbs, err := asn1.Marshal(data)
+	if err != nil {
+		return nil, err
+	}
+
+	signatureAlgorithm, signature, err := sign(authority.Signer, tbs)
+	if err != nil {
+		return nil, err
+	}
+
+	basic := basicResponse{
+		TBSResponseData:    asn1.RawValue{FullBytes: tbs},
+		SignatureAlgorithm: signatureAlgorithm,
+		Signature:          signature,
+	}
+
+	// Clients need the delegated responder to check the signature, they already have the certificate authority
+	if authority.ResponderCertificate != authority.Certificate {
+		basic.Certificates = []asn1.RawValue{{FullBytes: authority.ResponderCertificate.Raw}}
+	}
+
+	basicDer, err := asn1.Marshal(basic)
+	if err != nil {
+		return nil, err
+	}
+
+	return asn1.Marshal(ocspResponse{
+		Status:        ocspSuccessful,
+		ResponseBytes: responseBytes{ResponseType: oidOCSPBasicResponse, Response: basicDer},
+	})
+}
+
+// Gets the subjectPublicKey bits of the certificate, which is what the key hashes of OCSP are computed over.
+func getPublicKeyBits(certificate *x509.Certificate) ([]byte, error) {
+	var info struct {
+		Algorithm pkix.AlgorithmIdentifier
+		PublicKey asn1.BitString
+	}
+
+	if _, err := asn1.Unmarshal(certificate.RawSubjectPublicKeyInfo, &info); err != nil {
+		return nil, fmt.Errorf("failed to parse the public key of %s: %s", certificate.Subject.CommonName, err)
+	}
+
+	return info.PublicKey.Bytes, nil
+}
+
+func digest(hash crypto.Hash, data []byte) []byte {
+	hasher := hash.New()
+	hasher.Write(data)
+
+	return hasher.Sum(nil)
+}
+
+var (
+	oidSignatureSHA256WithRSA   = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 1, 11}
+	oidSignatureECDSAWithSHA256 = asn1.ObjectIdentifier{1, 2, 840, 10045, 4, 3, 2}
+	oidSignatureECDSAWithSHA384 = asn1.ObjectIdentifier{1, 2, 840, 10045, 4, 3, 3}
+	oidSignatureECDSAWithSHA512 = asn1.ObjectIdentifier{1, 2, 840, 10045, 4, 3, 4}
+	oidSignatureEd25519         = asn1.ObjectIdentifier{1, 3, 101, 112}
+)
+
+// Gets the signature algorithm and hash used with the key of a certificate authority, the hash grows with the curve
+// for ECDSA keys.
+func getSignatureAlgorithm(signer crypto.Signer) (pkix.AlgorithmIdentifier, crypto.Hash, error) {
+	switch publicKey := signer.Public().(type) {
+	case *rsa.PublicKey:
+		return pkix.AlgorithmIdentifier{Algorithm: oidSignatureSHA256WithRSA, Parameters: asn1.NullRawValue}, crypto.SHA256, nil
+	case *ecdsa.PublicKey:
+		switch publicKey.Curve.Params().BitSize {
+		case 384:
+			return pkix.AlgorithmIdentifier{Algorithm: oidSignatureECDSAWithSHA384}, crypto.SHA384, nil
+		case 521:
+			return pkix.AlgorithmIdentifier{Algorithm: oidSignatureECDSAWithSHA512}, crypto.SHA512, nil
+		}
+
+		return pkix.AlgorithmIdentifier{Algorithm: oidSignatureECDSAWithSHA256}, crypto.SHA256, nil
+	case ed25519.PublicKey:
+		// Ed25519 signs the message itself
+		return pkix.AlgorithmIdentifier{Algorithm: oidSignatureEd25519}, crypto.Hash(0), nil
+	}
+
+	return pkix.AlgorithmIdentifier{}, 0, fmt.Errorf("unsupported private key type %T", signer)
+}
+
+// Signs the DER encoded data with the key of a certificate authority.
+func sign(signer crypto.Signer, data []byte) (pkix.AlgorithmIdentifier, asn1.BitString, error) {
+	algorithm, hash, err := getSignatureAlgorithm(signer)
+	if err != nil {
+		return algorithm, asn1.BitString{}, err
+	}
+
+	digest := data
+
+	if hash != 0 {
+		hasher := hash.New()
+		hasher.Write(data)
+		digest = hasher.Sum(nil)
+	}
+
+	signature, err := signer.Sign(rand.Reader, digest, hash)
+	if err != nil {
+		return algorithm, asn1.BitString{}, err
+	}
+
+	return algorithm, asn1.BitString{Bytes: signature, BitLength: len(signature) * 8}, nil
+}
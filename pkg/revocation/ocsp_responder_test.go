@@ -0,0 +1,282 @@
+package revocation
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/base64"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/ocsp"
+)
+
+// A certificate authority with a good, a revoked and an unknown leaf, answered for by a responder.
+type responderTest struct {
+	ca        *x509.Certificate
+	responder *Responder
+
+	good    *x509.Certificate
+	revoked *x509.Certificate
+	unknown *x509.Certificate
+
+	revokedAt time.Time
+}
+
+func newResponderTest(t *testing.T, delegate bool) *responderTest {
+	t.Helper()
+
+	caKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	ca := createAuthority(t, caKey)
+
+	test := &responderTest{ca: ca, revokedAt: time.Now().Add(-time.Hour).UTC().Truncate(time.Second)}
+
+	leafKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+
+	test.good = createCertificate(t, leafTemplate(0x10), ca, leafKey.Public(), caKey)
+	test.revoked = createCertificate(t, leafTemplate(0x11), ca, leafKey.Public(), caKey)
+	test.unknown = createCertificate(t, leafTemplate(0x12), ca, leafKey.Public(), caKey)
+
+	list := &List{}
+	if _, err := list.Revoke(test.revoked.SerialNumber, "revoked", "keyCompromise", test.revokedAt); err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(t.TempDir(), "revocations.json")
+	if err := list.Save(path); err != nil {
+		t.Fatal(err)
+	}
+
+	authority := &Authority{
+		Certificate:          ca,
+		ResponderCertificate: ca,
+		Signer:               caKey,
+		RevocationListPath:   path,
+		IsIssued: func(serialNumber *big.Int) bool {
+			return serialNumber.Cmp(test.unknown.SerialNumber) != 0
+		},
+	}
+
+	if delegate {
+		responderKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+
+		template := leafTemplate(0x20)
+		template.Subject.CommonName = "ssl-go test responder"
+		template.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageOCSPSigning}
+
+		authority.ResponderCertificate = createCertificate(t, template, ca, responderKey.Public(), caKey)
+		authority.Signer = responderKey
+	}
+
+	test.responder = NewResponder([]*Authority{authority}, time.Hour)
+
+	return test
+}
+
+func TestResponderRespond(t *testing.T) {
+	for _, delegate := range []bool{false, true} {
+		test := newResponderTest(t, delegate)
+
+		cases := []struct {
+			name        string
+			certificate *x509.Certificate
+			hash        crypto.Hash
+			status      int
+		}{
+			{name: "good", certificate: test.good, hash: crypto.SHA1, status: ocsp.Good},
+			{name: "good sha256", certificate: test.good, hash: crypto.SHA256, status: ocsp.Good},
+			{name: "revoked", certificate: test.revoked, hash: crypto.SHA1, status: ocsp.Revoked},
+			{name: "unknown", certificate: test.unknown, hash: crypto.SHA384, status: ocsp.Unknown},
+		}
+
+		for _, c := range cases {
+			name := c.name
+			if delegate {
+				name += " delegated"
+			}
+
+			t.Run(name, func(t *testing.T) {
+				request, err := ocsp.CreateRequest(c.certificate, test.ca, &ocsp.RequestOptions{Hash: c.hash})
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				// The signature, and the delegation of the responder, are checked against the certificate authority
+				response, err := ocsp.ParseResponseForCert(test.responder.Respond(request), c.certificate, test.ca)
+				if err != nil {
+					t.Fatalf("failed to parse the response: %s", err)
+				}
+
+				if response.Status != c.status || response.SerialNumber.Cmp(c.certificate.SerialNumber) != 0 {
+					t.Fatalf("expected %x to be %d, got %x %d", c.certificate.SerialNumber, c.status, response.SerialNumber, response.Status)
+				}
+
+				if response.IssuerHash != c.hash {
+					t.Fatalf("expected the CertID to be hashed with %s, got %s", c.hash, response.IssuerHash)
+				}
+
+				if response.NextUpdate.Sub(response.ThisUpdate) != time.Hour {
+					t.Fatalf("expected the response to be valid for an hour, got %s to %s", response.ThisUpdate, response.NextUpdate)
+				}
+
+				if c.status == ocsp.Revoked && (!response.RevokedAt.Equal(test.revokedAt) || response.RevocationReason != ocsp.KeyCompromise) {
+					t.Fatalf("expected a revocation for key compromise at %s, got %s for %d", test.revokedAt, response.RevokedAt, response.RevocationReason)
+				}
+
+				if delegate != (response.Certificate != nil) {
+					t.Fatalf("expected the responder certificate to be included only when it is delegated")
+				}
+			})
+		}
+	}
+}
+
+func TestResponderServeHTTP(t *testing.T) {
+	test := newResponderTest(t, false)
+	server := httptest.NewServer(test.responder)
+	defer server.Close()
+
+	request, err := ocsp.CreateRequest(test.revoked, test.ca, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	get := func() (*http.Response, error) {
+		return http.Get(server.URL + "/" + base64.StdEncoding.EncodeToString(request))
+	}
+
+	post := func() (*http.Response, error) {
+		return http.Post(server.URL, "application/ocsp-request", bytes.NewReader(request))
+	}
+
+	for name, send := range map[string]func() (*http.Response, error){"GET": get, "POST": post} {
+		t.Run(name, func(t *testing.T) {
+			httpResponse, err := send()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			defer httpResponse.Body.Close()
+
+			body, err := ioutil.ReadAll(httpResponse.Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if httpResponse.Header.Get("Content-Type") != "application/ocsp-response" {
+				t.Fatalf("unexpected content type %s", httpResponse.Header.Get("Content-Type"))
+			}
+
+			response, err := ocsp.ParseResponseForCert(body, test.revoked, test.ca)
+			if err != nil || response.Status != ocsp.Revoked {
+				t.Fatalf("expected the certificate to be revoked, got %v", err)
+			}
+		})
+	}
+}
+
+func TestResponderErrors(t *testing.T) {
+	test := newResponderTest(t, false)
+
+	otherKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	other := createAuthority(t, otherKey)
+
+	otherRequest, err := ocsp.CreateRequest(createCertificate(t, leafTemplate(0x10), other, otherKey.Public(), otherKey), other, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		request []byte
+		status  ocsp.ResponseStatus
+	}{
+		{name: "malformed", request: []byte("not a request"), status: ocsp.Malformed},
+		{name: "no certificates", request: marshalRequest(t, nil, nil), status: ocsp.Malformed},
+		{name: "other certificate authority", request: otherRequest, status: ocsp.Unauthorized},
+	}
+
+	for _, c := range tests {
+		t.Run(c.name, func(t *testing.T) {
+			_, err := ocsp.ParseResponse(test.responder.Respond(c.request), nil)
+
+			if responseError, ok := err.(ocsp.ResponseError); !ok || responseError.Status != c.status {
+				t.Fatalf("expected the status %s, got %v", c.status, err)
+			}
+		})
+	}
+}
+
+func TestResponderEchoesNonce(t *testing.T) {
+	test := newResponderTest(t, false)
+
+	request, err := ocsp.CreateRequest(test.good, test.ca, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	parsed, err := parseOCSPRequest(request)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	nonce, _ := asn1.Marshal([]byte("a nonce of the client"))
+
+	der := test.responder.Respond(marshalRequest(t, parsed.TBSRequest.RequestList, []pkix.Extension{{Id: oidOCSPNonce, Value: nonce}}))
+
+	if _, err := ocsp.ParseResponseForCert(der, test.good, test.ca); err != nil {
+		t.Fatal(err)
+	}
+
+	// The ocsp package skips the extensions of the response, so they are read from the response data
+	var response ocspResponse
+	var basic basicResponse
+	var data responseData
+
+	if _, err := asn1.Unmarshal(der, &response); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := asn1.Unmarshal(response.ResponseBytes.Response, &basic); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := asn1.Unmarshal(basic.TBSResponseData.FullBytes, &data); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(data.Extensions) != 1 || !data.Extensions[0].Id.Equal(oidOCSPNonce) || !bytes.Equal(data.Extensions[0].Value, nonce) {
+		t.Fatalf("expected the nonce to be echoed, got %v", data.Extensions)
+	}
+}
+
+func marshalRequest(t *testing.T, requests []singleRequest, extensions []pkix.Extension) []byte {
+	t.Helper()
+
+	der, err := asn1.Marshal(ocspRequest{TBSRequest: tbsRequest{RequestList: requests, Extensions: extensions}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return der
+}
+
+func leafTemplate(serialNumber int64) *x509.Certificate {
+	return &x509.Certificate{
+		SerialNumber: big.NewInt(serialNumber),
+		Subject:      pkix.Name{CommonName: "leaf.ssl-go.test"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+	}
+}
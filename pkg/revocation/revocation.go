@@ -0,0 +1,153 @@
+// Package revocation records the certificates revoked by a certificate authority and builds the CRLs of the
+// certificate authorities from them.
+package revocation
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// The reasons a certificate can be revoked for, as defined by RFC 5280 section 5.3.1.
+var reasonCodes = map[string]int{
+	"unspecified":          0,
+	"keyCompromise":        1,
+	"cACompromise":         2,
+	"affiliationChanged":   3,
+	"superseded":           4,
+	"cessationOfOperation": 5,
+	"certificateHold":      6,
+	"removeFromCRL":        8,
+	"privilegeWithdrawn":   9,
+	"aACompromise":         10,
+}
+
+// The certificates revoked by a certificate authority, stored next to it in its output directory.
+type List struct {
+	// The number of the last CRL built from the list, incremented every time a CRL is built.
+	CrlNumber int64 `json:"crlNumber"`
+
+	Revocations []*Revocation `json:"revocations"`
+}
+
+// A revoked certificate.
+type Revocation struct {
+	// The serial number of the certificate, in upper case hexadecimal.
+	SerialNumber string `json:"serialNumber"`
+
+	// The name of the certificate in the configuration, empty if it was revoked by serial number.
+	Name string `json:"name,omitempty"`
+
+	// One of the reasons of RFC 5280, e.g. keyCompromise.
+	Reason string `json:"reason"`
+
+	RevokedAt time.Time `json:"revokedAt"`
+}
+
+// Checks that the reason is one of the reasons of RFC 5280.
+func CheckReason(reason string) error {
+	if _, ok := reasonCodes[reason]; ok {
+		return nil
+	}
+
+	var reasons []string
+
+	for name := range reasonCodes {
+		reasons = append(reasons, name)
+	}
+
+	sort.Strings(reasons)
+
+	return fmt.Errorf("unknown revocation reason: %s, must be one of %s", reason, strings.Join(reasons, ", "))
+}
+
+// Parses a hexadecimal serial number, optionally prefixed with 0x or separated by colons like openssl prints them.
+func ParseSerialNumber(serialNumber string) (*big.Int, error) {
+	hex := strings.TrimPrefix(strings.ToLower(strings.Replace(serialNumber, ":", "", -1)), "0x")
+
+	value, ok := new(big.Int).SetString(hex, 16)
+	if !ok || value.Sign() <= 0 {
+		return nil, fmt.Errorf("invalid serial number: %s, must be a positive hexadecimal number", serialNumber)
+	}
+
+	return value, nil
+}
+
+// Formats a serial number the way it is stored in the list.
+func FormatSerialNumber(serialNumber *big.Int) string {
+	return strings.ToUpper(serialNumber.Text(16))
+}
+
+// Loads the list from its file, a missing file is an empty list.
+func Load(path string) (*List, error) {
+	content, err := ioutil.ReadFile(path)
+
+	if os.IsNotExist(err) {
+		return &List{}, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	list := &List{}
+
+	err = json.Unmarshal(content, list)
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse the revocation list %s: %s", path, err)
+	}
+
+	return list, nil
+}
+
+// Writes the list to its file.
+func (list *List) Save(path string) error {
+	content, err := json.MarshalIndent(list, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(path, append(content, '\n'), 0644)
+}
+
+// Gets the revocation of the serial number, nil if it isn't revoked.
+func (list *List) Get(serialNumber *big.Int) *Revocation {
+	formatted := FormatSerialNumber(serialNumber)
+
+	for _, revocation := range list.Revocations {
+		if revocation.SerialNumber == formatted {
+			return revocation
+		}
+	}
+
+	return nil
+}
+
+// Records the revocation of a certificate, a certificate can only be revoked once.
+func (list *List) Revoke(serialNumber *big.Int, name string, reason string, revokedAt time.Time) (*Revocation, error) {
+	err := CheckReason(reason)
+	if err != nil {
+		return nil, err
+	}
+
+	if existing := list.Get(serialNumber); existing != nil {
+		return nil, fmt.Errorf("the certificate with serial number %s was already revoked at %s", existing.SerialNumber, existing.RevokedAt.Format(time.RFC3339))
+	}
+
+	revocation := &Revocation{
+		SerialNumber: FormatSerialNumber(serialNumber),
+		Name:         name,
+		Reason:       reason,
+		RevokedAt:    revokedAt.UTC().Truncate(time.Second),
+	}
+
+	list.Revocations = append(list.Revocations, revocation)
+
+	return revocation, nil
+}
@@ -0,0 +1,102 @@
+package secrets
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+func init() {
+	Register("sops", ProviderFunc(resolveSopsSecret))
+	Register("age", ProviderFunc(resolveAgeSecret))
+}
+
+// ${{ sops./path/secrets.yaml#a.b }} is the value at a.b of a file encrypted with sops, or the whole decrypted file
+// if there is no #. The sops binary decrypts it, with the keys it's configured with.
+func resolveSopsSecret(reference string) (string, error) {
+	path, field := splitField(reference)
+
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		return "", notFound("the sops file %s does not exist", path)
+	}
+
+	args := []string{"--decrypt"}
+
+	if field != "" {
+		args = append(args, "--extract", getSopsExtractPath(field))
+	}
+
+	value, err := runDecryption("sops", append(args, path)...)
+	if err != nil {
+		return "", fmt.Errorf("failed to decrypt the sops file %s: %s", path, err)
+	}
+
+	return value, nil
+}
+
+// ${{ age./path/secret.age }} is the content of a file encrypted with age, without its trailing line break. The
+// identity is read from the file of SSL_GO_AGE_IDENTITY_FILE, or SOPS_AGE_KEY_FILE.
+func resolveAgeSecret(path string) (string, error) {
+	identity := os.Getenv("SSL_GO_AGE_IDENTITY_FILE")
+	if identity == "" {
+		identity = os.Getenv("SOPS_AGE_KEY_FILE")
+	}
+
+	if identity == "" {
+		return "", fmt.Errorf("neither SSL_GO_AGE_IDENTITY_FILE nor SOPS_AGE_KEY_FILE is set, one of them has to be the path of the age identity")
+	}
+
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		return "", notFound("the age file %s does not exist", path)
+	}
+
+	value, err := runDecryption("age", "--decrypt", "--identity", identity, path)
+	if err != nil {
+		return "", fmt.Errorf("failed to decrypt the age file %s: %s", path, err)
+	}
+
+	return value, nil
+}
+
+// Converts a.b.0 to the ["a"]["b"][0] path sops --extract takes.
+func getSopsExtractPath(field string) string {
+	var path strings.Builder
+
+	for _, key := range strings.Split(field, ".") {
+		if _, err := strconv.Atoi(key); err == nil {
+			path.WriteString("[" + key + "]")
+			continue
+		}
+
+		path.WriteString("[" + strconv.Quote(key) + "]")
+	}
+
+	return path.String()
+}
+
+// Runs the decryption tool, returning what it decrypted without its trailing line break.
+func runDecryption(tool string, args ...string) (string, error) {
+	if _, err := exec.LookPath(tool); err != nil {
+		return "", fmt.Errorf("%s is not installed", tool)
+	}
+
+	var stdout, stderr bytes.Buffer
+
+	cmd := exec.Command(tool, args...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	err := cmd.Run()
+	if err != nil {
+		if message := strings.TrimSpace(stderr.String()); message != "" {
+			return "", fmt.Errorf("%s", message)
+		}
+
+		return "", err
+	}
+
+	return trimLineBreak(stdout.String()), nil
+}
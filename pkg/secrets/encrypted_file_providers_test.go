@@ -0,0 +1,154 @@
+package secrets
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestSopsProvider(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the stubs are written for /bin/sh")
+	}
+
+	encrypted := filepath.Join(t.TempDir(), "secrets.yaml")
+	if err := ioutil.WriteFile(encrypted, []byte("encrypted"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name      string
+		reference string
+		stub      string
+		value     string
+		args      []string
+		notFound  bool
+		err       string
+	}{
+		{name: "whole file", reference: encrypted, stub: "echo decrypted", value: "decrypted", args: []string{"--decrypt", encrypted}},
+		{name: "field", reference: encrypted + "#database.users.0", stub: "echo user", value: "user", args: []string{"--decrypt", "--extract", `["database"]["users"][0]`, encrypted}},
+		{name: "missing file", reference: encrypted + ".missing", stub: "echo unused", notFound: true},
+		{name: "failure", reference: encrypted, stub: "echo 'no key could decrypt the data' >&2; exit 128", err: "no key could decrypt the data"},
+		{name: "not installed", reference: encrypted, err: "sops is not installed"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			argsPath := stubTool(t, "sops", test.stub)
+
+			value, err := resolveSopsSecret(test.reference)
+
+			switch {
+			case test.notFound:
+				if !errors.Is(err, ErrNotFound) {
+					t.Fatalf("expected ErrNotFound, got %v", err)
+				}
+			case test.err != "":
+				if err == nil || !strings.Contains(err.Error(), test.err) {
+					t.Fatalf("expected an error containing %q, got %v", test.err, err)
+				}
+			case err != nil || value != test.value:
+				t.Fatalf("expected %q, got %q, %v", test.value, value, err)
+			default:
+				checkStubArgs(t, argsPath, test.args)
+			}
+		})
+	}
+}
+
+func TestAgeProvider(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the stubs are written for /bin/sh")
+	}
+
+	encrypted := filepath.Join(t.TempDir(), "secret.age")
+	if err := ioutil.WriteFile(encrypted, []byte("encrypted"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name             string
+		path             string
+		identity         string
+		sopsIdentity     string
+		stub             string
+		value            string
+		expectedIdentity string
+		notFound         bool
+		err              string
+	}{
+		{name: "identity", path: encrypted, identity: "/keys/age.txt", stub: "echo age-secret", value: "age-secret", expectedIdentity: "/keys/age.txt"},
+		{name: "sops identity", path: encrypted, sopsIdentity: "/keys/sops.txt", stub: "printf age-secret", value: "age-secret", expectedIdentity: "/keys/sops.txt"},
+		{name: "identity over sops identity", path: encrypted, identity: "/keys/age.txt", sopsIdentity: "/keys/sops.txt", stub: "echo age-secret", value: "age-secret", expectedIdentity: "/keys/age.txt"},
+		{name: "no identity", path: encrypted, stub: "echo unused", err: "SSL_GO_AGE_IDENTITY_FILE"},
+		{name: "missing file", path: encrypted + ".missing", identity: "/keys/age.txt", stub: "echo unused", notFound: true},
+		{name: "failure", path: encrypted, identity: "/keys/age.txt", stub: "echo 'no identity matched any of the recipients' >&2; exit 1", err: "no identity matched any of the recipients"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			argsPath := stubTool(t, "age", test.stub)
+			setEnvironmentVariable(t, "SSL_GO_AGE_IDENTITY_FILE", test.identity)
+			setEnvironmentVariable(t, "SOPS_AGE_KEY_FILE", test.sopsIdentity)
+
+			value, err := resolveAgeSecret(test.path)
+
+			switch {
+			case test.notFound:
+				if !errors.Is(err, ErrNotFound) {
+					t.Fatalf("expected ErrNotFound, got %v", err)
+				}
+			case test.err != "":
+				if err == nil || !strings.Contains(err.Error(), test.err) {
+					t.Fatalf("expected an error containing %q, got %v", test.err, err)
+				}
+			case err != nil || value != test.value:
+				t.Fatalf("expected %q, got %q, %v", test.value, value, err)
+			default:
+				checkStubArgs(t, argsPath, []string{"--decrypt", "--identity", test.expectedIdentity, test.path})
+			}
+		})
+	}
+}
+
+// Puts a stub of the tool running the script first on the PATH, and returns the path of the file the stub writes its
+// arguments to. Without a script the tool isn't on the PATH at all.
+func stubTool(t *testing.T, name string, script string) string {
+	t.Helper()
+
+	directory := t.TempDir()
+	argsPath := filepath.Join(directory, "args")
+
+	if script == "" {
+		setEnvironmentVariable(t, "PATH", directory)
+		return argsPath
+	}
+
+	stub := "#!/bin/sh\nprintf '%s\\n' \"$@\" > '" + argsPath + "'\n" + script + "\n"
+
+	err := ioutil.WriteFile(filepath.Join(directory, name), []byte(stub), 0755)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	setEnvironmentVariable(t, "PATH", directory+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	return argsPath
+}
+
+func checkStubArgs(t *testing.T, argsPath string, expected []string) {
+	t.Helper()
+
+	content, err := ioutil.ReadFile(argsPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if args := strings.TrimSuffix(string(content), "\n"); args != strings.Join(expected, "\n") {
+		t.Fatalf("expected the arguments %q, got %q", expected, strings.Split(args, "\n"))
+	}
+}
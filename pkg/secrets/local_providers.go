@@ -0,0 +1,86 @@
+package secrets
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+func init() {
+	Register("env", ProviderFunc(resolveEnvironmentVariable))
+	Register("file", &fileProvider{})
+	Register("exec", ProviderFunc(resolveCommand))
+}
+
+// ${{ env.NAME }} is the value of the environment variable NAME.
+func resolveEnvironmentVariable(name string) (string, error) {
+	value, isPresent := os.LookupEnv(name)
+
+	if !isPresent {
+		return "", notFound("the environment variable %s is not set", name)
+	}
+
+	return value, nil
+}
+
+// ${{ file./path }} is the content of the file, without its trailing line break. Relative paths are relative to the
+// working directory.
+type fileProvider struct{}
+
+func (provider *fileProvider) Resolve(path string) (string, error) {
+	content, err := ioutil.ReadFile(path)
+
+	if os.IsNotExist(err) {
+		return "", notFound("the secret file %s does not exist", path)
+	}
+
+	if err != nil {
+		return "", fmt.Errorf("failed to read the secret file: %s", err)
+	}
+
+	return trimLineBreak(string(content)), nil
+}
+
+// Writes the secret to the file, only the owner can read it.
+func (provider *fileProvider) Store(path string, value string) error {
+	err := os.MkdirAll(filepath.Dir(path), 0700)
+	if err != nil {
+		return err
+	}
+
+	return writePrivateFile(path, []byte(value))
+}
+
+// ${{ exec.command }} is the output of the command run with the shell, without its trailing line break. The command
+// has to succeed, what it writes to stderr is shown.
+func resolveCommand(command string) (string, error) {
+	var cmd *exec.Cmd
+
+	if runtime.GOOS == "windows" {
+		cmd = exec.Command("cmd", "/C", command)
+	} else {
+		cmd = exec.Command("/bin/sh", "-c", command)
+	}
+
+	var stdout bytes.Buffer
+
+	cmd.Stdout = &stdout
+	cmd.Stderr = os.Stderr
+
+	err := cmd.Run()
+	if err != nil {
+		return "", fmt.Errorf("the secret command %s failed: %s", command, err)
+	}
+
+	return trimLineBreak(stdout.String()), nil
+}
+
+// Secrets are usually written with a line break after them, which is never part of the secret.
+func trimLineBreak(value string) string {
+	return strings.TrimSuffix(strings.TrimSuffix(value, "\n"), "\r")
+}
@@ -0,0 +1,174 @@
+package secrets
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestEnvironmentVariableProvider(t *testing.T) {
+	setEnvironmentVariable(t, "SSL_GO_TEST_SECRET", "env-secret")
+	setEnvironmentVariable(t, "SSL_GO_TEST_EMPTY", "")
+	os.Unsetenv("SSL_GO_TEST_UNSET")
+
+	tests := []struct {
+		name     string
+		variable string
+		value    string
+		notFound bool
+	}{
+		{name: "set", variable: "SSL_GO_TEST_SECRET", value: "env-secret"},
+		{name: "set but empty", variable: "SSL_GO_TEST_EMPTY", value: ""},
+		{name: "not set", variable: "SSL_GO_TEST_UNSET", notFound: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			value, err := resolveEnvironmentVariable(test.variable)
+
+			if test.notFound {
+				if !errors.Is(err, ErrNotFound) {
+					t.Fatalf("expected ErrNotFound, got %v", err)
+				}
+
+				return
+			}
+
+			if err != nil || value != test.value {
+				t.Fatalf("expected %q, got %q, %v", test.value, value, err)
+			}
+		})
+	}
+}
+
+func TestFileProvider(t *testing.T) {
+	directory := t.TempDir()
+
+	crlf := filepath.Join(directory, "crlf.txt")
+	if err := ioutil.WriteFile(crlf, []byte("crlf-secret\r\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		path     string
+		value    string
+		notFound bool
+		fails    bool
+	}{
+		{name: "trailing line break", path: "testdata/secret.txt", value: "file-secret"},
+		{name: "trailing crlf", path: crlf, value: "crlf-secret"},
+		{name: "only the last line break", path: "testdata/multiline.txt", value: "first line\nsecond line"},
+		{name: "empty", path: "testdata/empty.txt", value: ""},
+		{name: "missing", path: "testdata/missing.txt", notFound: true},
+		{name: "directory", path: "testdata", fails: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			value, err := (&fileProvider{}).Resolve(test.path)
+
+			switch {
+			case test.notFound:
+				if !errors.Is(err, ErrNotFound) {
+					t.Fatalf("expected ErrNotFound, got %v", err)
+				}
+			case test.fails:
+				if err == nil || errors.Is(err, ErrNotFound) {
+					t.Fatalf("expected an error other than ErrNotFound, got %v", err)
+				}
+			case err != nil || value != test.value:
+				t.Fatalf("expected %q, got %q, %v", test.value, value, err)
+			}
+		})
+	}
+}
+
+func TestFileProviderStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "nested", "secret")
+
+	err := (&fileProvider{}).Store(path, "stored")
+	if err != nil {
+		t.Fatalf("failed to store: %s", err)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if runtime.GOOS != "windows" && info.Mode().Perm() != 0600 {
+		t.Fatalf("expected the secret file to be 0600, got %o", info.Mode().Perm())
+	}
+
+	value, err := (&fileProvider{}).Resolve(path)
+	if err != nil || value != "stored" {
+		t.Fatalf("expected to read back the stored value, got %q, %v", value, err)
+	}
+}
+
+func TestCommandProvider(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the commands are written for /bin/sh")
+	}
+
+	tests := []struct {
+		name    string
+		command string
+		value   string
+		fails   bool
+	}{
+		{name: "output", command: "echo exec-secret", value: "exec-secret"},
+		{name: "without line break", command: "printf exec-secret", value: "exec-secret"},
+		{name: "reads a fixture", command: "cat testdata/secret.txt", value: "file-secret"},
+		{name: "stderr is not the secret", command: "echo shown >&2; echo exec-secret", value: "exec-secret"},
+		{name: "fails", command: "exit 3", fails: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			value, err := resolveCommand(test.command)
+
+			if test.fails {
+				if err == nil {
+					t.Fatalf("expected the command to fail, got %q", value)
+				}
+
+				return
+			}
+
+			if err != nil || value != test.value {
+				t.Fatalf("expected %q, got %q, %v", test.value, value, err)
+			}
+		})
+	}
+}
+
+func TestResolveExpression(t *testing.T) {
+	setEnvironmentVariable(t, "SSL_GO_TEST_EXPRESSION", "resolved")
+
+	tests := []struct {
+		name       string
+		expression string
+		value      string
+		ok         bool
+	}{
+		{name: "registered provider", expression: "env.SSL_GO_TEST_EXPRESSION", value: "resolved", ok: true},
+		{name: "spaces", expression: "  env.SSL_GO_TEST_EXPRESSION ", value: "resolved", ok: true},
+		{name: "unknown provider", expression: "unknown.NAME", ok: false},
+		{name: "no provider", expression: "NAME", ok: false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			value, ok, err := Resolve(test.expression)
+
+			if err != nil || ok != test.ok || value != test.value {
+				t.Fatalf("expected %q, %t, got %q, %t, %v", test.value, test.ok, value, ok, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,178 @@
+// Package secrets resolves the ${{ <provider>.<reference> }} expressions of the configuration, e.g. ${{ env.NAME }}
+// or ${{ vault.secret/ssl#password }}, through the provider registered under the name.
+package secrets
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// The error of a secret that doesn't exist, e.g. an environment variable that isn't set. Expressions fall back to their
+// next alternative on it, so providers wrap it with notFound and report every other failure as is.
+var ErrNotFound = errors.New("not found")
+
+type notFoundError struct {
+	message string
+}
+
+func (err *notFoundError) Error() string {
+	return err.message
+}
+
+func (err *notFoundError) Is(target error) bool {
+	return target == ErrNotFound
+}
+
+func notFound(format string, args ...interface{}) error {
+	return &notFoundError{message: fmt.Sprintf(format, args...)}
+}
+
+// A Provider reads secrets from a source, like the environment or a Vault server.
+type Provider interface {
+	// Reads the secret of the reference, the part of the expression after the name of the provider and the dot.
+	Resolve(reference string) (string, error)
+}
+
+// A Store is a Provider secrets can also be written to.
+type Store interface {
+	Provider
+
+	// Writes the secret of the reference, replacing it if it exists.
+	Store(reference string, value string) error
+}
+
+// Adapts a function to a Provider.
+type ProviderFunc func(reference string) (string, error)
+
+func (f ProviderFunc) Resolve(reference string) (string, error) {
+	return f(reference)
+}
+
+type resolution struct {
+	value string
+	err   error
+}
+
+var registry = struct {
+	sync.Mutex
+
+	providers map[string]Provider
+
+	// Every expression is only resolved once, so commands aren't run and servers aren't asked again for every field
+	// that is validated twice
+	resolved map[string]*resolution
+}{
+	providers: make(map[string]Provider),
+	resolved:  make(map[string]*resolution),
+}
+
+// Registers the provider under the name, replacing the provider already registered under it.
+func Register(name string, provider Provider) {
+	registry.Lock()
+	defer registry.Unlock()
+
+	registry.providers[name] = provider
+
+	// The previous provider may have resolved differently
+	registry.resolved = make(map[string]*resolution)
+}
+
+// Gets the names of the registered providers, sorted.
+func Names() []string {
+	registry.Lock()
+	defer registry.Unlock()
+
+	names := make([]string, 0, len(registry.providers))
+	for name := range registry.providers {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	return names
+}
+
+// Resolves an expression like env.NAME, the part between ${{ and }}. ok is false if no provider is registered
+// under the name before the first dot.
+func Resolve(expression string) (value string, ok bool, err error) {
+	expression = strings.TrimSpace(expression)
+
+	dot := strings.Index(expression, ".")
+	if dot < 0 {
+		return "", false, nil
+	}
+
+	name, reference := expression[:dot], strings.TrimSpace(expression[dot+1:])
+
+	registry.Lock()
+	provider, ok := registry.providers[name]
+	cached := registry.resolved[expression]
+	registry.Unlock()
+
+	if !ok {
+		return "", false, nil
+	}
+
+	if cached != nil {
+		return cached.value, true, cached.err
+	}
+
+	if reference == "" {
+		err = fmt.Errorf("the %s expression has nothing after %s.", name, name)
+	} else {
+		value, err = provider.Resolve(reference)
+	}
+
+	registry.Lock()
+	registry.resolved[expression] = &resolution{value: value, err: err}
+	registry.Unlock()
+
+	return value, true, err
+}
+
+// Writes a secret through the provider of an expression like vault.secret/ssl#password, the provider has to be a Store.
+func StoreSecret(expression string, value string) error {
+	expression = strings.TrimSpace(expression)
+
+	name, reference := expression, ""
+	if dot := strings.Index(expression, "."); dot >= 0 {
+		name, reference = expression[:dot], strings.TrimSpace(expression[dot+1:])
+	}
+
+	registry.Lock()
+	provider, ok := registry.providers[name]
+	registry.Unlock()
+
+	if !ok {
+		return fmt.Errorf("unknown secret provider %s", name)
+	}
+
+	store, ok := provider.(Store)
+	if !ok {
+		return fmt.Errorf("the %s secret provider cannot store secrets", name)
+	}
+
+	err := store.Store(reference, value)
+	if err != nil {
+		return err
+	}
+
+	registry.Lock()
+	registry.resolved[expression] = &resolution{value: value}
+	registry.Unlock()
+
+	return nil
+}
+
+// Splits a reference like path#field into its path and field, the field is empty if there is none.
+func splitField(reference string) (string, string) {
+	index := strings.LastIndex(reference, "#")
+	if index < 0 {
+		return reference, ""
+	}
+
+	return reference[:index], reference[index+1:]
+}
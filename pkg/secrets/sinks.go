@@ -0,0 +1,318 @@
+package secrets
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"golang.org/x/crypto/pbkdf2"
+)
+
+// A Sink keeps secrets that ssl-go generated, under keys like root/myroot/password.
+type Sink interface {
+	// Reads the secret of the key, ok is false if the sink doesn't have it.
+	Get(key string) (value string, ok bool, err error)
+
+	// Writes the secret of the key, replacing it if it exists.
+	Put(key string, value string) error
+}
+
+// The key derivation of keyrings, PBKDF2 with HMAC-SHA256.
+const (
+	keyringVersion    = 1
+	keyringKdf        = "pbkdf2-sha256"
+	keyringIterations = 600000
+	keyringSaltSize   = 16
+)
+
+// The encrypted form of the secrets of a keyring.
+type keyringFile struct {
+	Version    int    `json:"version"`
+	Kdf        string `json:"kdf"`
+	Iterations int    `json:"iterations"`
+	Salt       []byte `json:"salt"`
+	Nonce      []byte `json:"nonce"`
+	Ciphertext []byte `json:"ciphertext"`
+}
+
+// Keeps the secrets in a JSON file, read once and written again on every change.
+type documentSink struct {
+	sync.Mutex
+
+	path    string
+	secrets map[string]string
+
+	decode func(content []byte) (map[string]string, error)
+	encode func(secrets map[string]string) ([]byte, error)
+}
+
+// Creates a sink that keeps the secrets in a plain JSON file only the owner can read.
+func NewFileSink(path string) Sink {
+	return &documentSink{
+		path: path,
+		decode: func(content []byte) (map[string]string, error) {
+			var secrets map[string]string
+
+			err := json.Unmarshal(content, &secrets)
+
+			return secrets, err
+		},
+		encode: func(secrets map[string]string) ([]byte, error) {
+			content, err := json.MarshalIndent(secrets, "", "  ")
+
+			return append(content, '\n'), err
+		},
+	}
+}
+
+// Creates a sink that keeps the secrets in a file encrypted with AES-256-GCM, with a key derived from the passphrase.
+func NewKeyringSink(path string, passphrase string) (Sink, error) {
+	if passphrase == "" {
+		return nil, fmt.Errorf("the keyring %s needs a passphrase", path)
+	}
+
+	var salt, key []byte
+	var iterations int
+
+	return &documentSink{
+		path: path,
+		decode: func(content []byte) (map[string]string, error) {
+			var keyring keyringFile
+
+			err := json.Unmarshal(content, &keyring)
+			if err != nil {
+				return nil, err
+			}
+
+			if keyring.Version != keyringVersion || keyring.Kdf != keyringKdf {
+				return nil, fmt.Errorf("unsupported keyring version %d with %s", keyring.Version, keyring.Kdf)
+			}
+
+			// The key is kept and written back, a keyring is never weakened
+			if keyring.Iterations < keyringIterations {
+				return nil, fmt.Errorf("the keyring derives its key with %d iterations, at least %d are needed", keyring.Iterations, keyringIterations)
+			}
+
+			salt = keyring.Salt
+			iterations = keyring.Iterations
+			key = pbkdf2.Key([]byte(passphrase), salt, iterations, 32, sha256.New)
+
+			aead, err := newKeyringCipher(key)
+			if err != nil {
+				return nil, err
+			}
+
+			plaintext, err := aead.Open(nil, keyring.Nonce, keyring.Ciphertext, nil)
+			if err != nil {
+				return nil, fmt.Errorf("the passphrase is wrong or the keyring was modified")
+			}
+
+			var secrets map[string]string
+
+			err = json.Unmarshal(plaintext, &secrets)
+
+			return secrets, err
+		},
+		encode: func(secrets map[string]string) ([]byte, error) {
+			// The key is only derived again for a new keyring
+			if key == nil {
+				salt = make([]byte, keyringSaltSize)
+
+				if _, err := rand.Read(salt); err != nil {
+					return nil, err
+				}
+
+				iterations = keyringIterations
+				key = pbkdf2.Key([]byte(passphrase), salt, iterations, 32, sha256.New)
+			}
+
+			aead, err := newKeyringCipher(key)
+			if err != nil {
+				return nil, err
+			}
+
+			plaintext, err := json.Marshal(secrets)
+			if err != nil {
+				return nil, err
+			}
+
+			nonce := make([]byte, aead.NonceSize())
+
+			if _, err := rand.Read(nonce); err != nil {
+				return nil, err
+			}
+
+			content, err := json.MarshalIndent(&keyringFile{
+				Version:    keyringVersion,
+				Kdf:        keyringKdf,
+				Iterations: iterations,
+				Salt:       salt,
+				Nonce:      nonce,
+				Ciphertext: aead.Seal(nil, nonce, plaintext, nil),
+			}, "", "  ")
+
+			return append(content, '\n'), err
+		},
+	}, nil
+}
+
+func (sink *documentSink) Get(key string) (string, bool, error) {
+	sink.Lock()
+	defer sink.Unlock()
+
+	err := sink.load()
+	if err != nil {
+		return "", false, err
+	}
+
+	value, ok := sink.secrets[key]
+
+	return value, ok, nil
+}
+
+func (sink *documentSink) Put(key string, value string) error {
+	sink.Lock()
+	defer sink.Unlock()
+
+	err := sink.load()
+	if err != nil {
+		return err
+	}
+
+	sink.secrets[key] = value
+
+	content, err := sink.encode(sink.secrets)
+	if err != nil {
+		return err
+	}
+
+	err = os.MkdirAll(filepath.Dir(sink.path), 0700)
+	if err != nil {
+		return err
+	}
+
+	return writePrivateFile(sink.path, content)
+}
+
+func (sink *documentSink) load() error {
+	if sink.secrets != nil {
+		return nil
+	}
+
+	content, err := ioutil.ReadFile(sink.path)
+
+	if os.IsNotExist(err) {
+		sink.secrets = make(map[string]string)
+		return nil
+	}
+
+	if err != nil {
+		return err
+	}
+
+	secrets, err := sink.decode(content)
+	if err != nil {
+		return fmt.Errorf("failed to read the secrets of %s: %s", sink.path, err)
+	}
+
+	if secrets == nil {
+		secrets = make(map[string]string)
+	}
+
+	sink.secrets = secrets
+
+	return nil
+}
+
+// Keeps every secret at <reference>/<key> through a secret provider that can store secrets.
+type providerSink struct {
+	reference string
+}
+
+// Creates a sink that stores the secrets through the provider of the reference, e.g. vault.secret/ssl-go.
+func NewProviderSink(reference string) (Sink, error) {
+	reference = strings.TrimSuffix(strings.TrimSpace(reference), "/")
+
+	dot := strings.Index(reference, ".")
+	if dot < 0 {
+		return nil, fmt.Errorf("the reference %s has to start with a secret provider and a dot, like vault.secret/ssl-go", reference)
+	}
+
+	registry.Lock()
+	provider, ok := registry.providers[reference[:dot]]
+	registry.Unlock()
+
+	if !ok {
+		return nil, fmt.Errorf("unknown secret provider %s", reference[:dot])
+	}
+
+	if _, ok := provider.(Store); !ok {
+		return nil, fmt.Errorf("the %s secret provider cannot store secrets", reference[:dot])
+	}
+
+	return &providerSink{reference: reference}, nil
+}
+
+func (sink *providerSink) Get(key string) (string, bool, error) {
+	value, _, err := Resolve(sink.reference + "/" + key)
+
+	if errors.Is(err, ErrNotFound) {
+		return "", false, nil
+	}
+
+	if err != nil {
+		return "", false, err
+	}
+
+	return value, true, nil
+}
+
+func (sink *providerSink) Put(key string, value string) error {
+	return StoreSecret(sink.reference+"/"+key, value)
+}
+
+func newKeyringCipher(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(block)
+}
+
+// Writes the file so only the owner can read it at any point, replacing it at once.
+func writePrivateFile(path string, content []byte) error {
+	file, err := ioutil.TempFile(filepath.Dir(path), "."+filepath.Base(path)+"-")
+	if err != nil {
+		return err
+	}
+
+	defer os.Remove(file.Name())
+
+	_, err = file.Write(content)
+
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+
+	if err != nil {
+		return err
+	}
+
+	// TempFile creates the file with 0600, the umask can only remove permissions
+	err = os.Chmod(file.Name(), 0600)
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(file.Name(), path)
+}
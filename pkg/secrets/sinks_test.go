@@ -0,0 +1,282 @@
+package secrets
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/pbkdf2"
+)
+
+func TestSinkRoundTrip(t *testing.T) {
+	tests := []struct {
+		name string
+		open func(path string) (Sink, error)
+	}{
+		{name: "file", open: func(path string) (Sink, error) { return NewFileSink(path), nil }},
+		{name: "keyring", open: func(path string) (Sink, error) { return NewKeyringSink(path, "passphrase") }},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "secrets", "sink")
+
+			sink, err := test.open(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if _, ok, err := sink.Get("root/myroot/password"); ok || err != nil {
+				t.Fatalf("expected an empty sink, got %t, %v", ok, err)
+			}
+
+			for key, value := range map[string]string{"root/myroot/password": "first", "leaf/myleaf/pfxPassword": "second"} {
+				if err := sink.Put(key, value); err != nil {
+					t.Fatalf("failed to put %s: %s", key, err)
+				}
+			}
+
+			// Replacing a secret keeps the others
+			if err := sink.Put("root/myroot/password", "replaced"); err != nil {
+				t.Fatal(err)
+			}
+
+			info, err := os.Stat(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if runtime.GOOS != "windows" && info.Mode().Perm() != 0600 {
+				t.Fatalf("expected the sink to be 0600, got %o", info.Mode().Perm())
+			}
+
+			reopened, err := test.open(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			for key, expected := range map[string]string{"root/myroot/password": "replaced", "leaf/myleaf/pfxPassword": "second"} {
+				value, ok, err := reopened.Get(key)
+				if err != nil || !ok || value != expected {
+					t.Fatalf("expected %s to be %q, got %q, %t, %v", key, expected, value, ok, err)
+				}
+			}
+		})
+	}
+}
+
+func TestFileSinkIsPlainJSON(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "secrets.json")
+
+	if err := NewFileSink(path).Put("root/myroot/password", "plain"); err != nil {
+		t.Fatal(err)
+	}
+
+	var secrets map[string]string
+
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := json.Unmarshal(content, &secrets); err != nil || secrets["root/myroot/password"] != "plain" {
+		t.Fatalf("expected a JSON object with the secret, got %s, %v", content, err)
+	}
+}
+
+func TestKeyringSink(t *testing.T) {
+	directory := t.TempDir()
+	path := filepath.Join(directory, "secrets.keyring")
+
+	sink, err := NewKeyringSink(path, "passphrase")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := sink.Put("root/myroot/password", "encrypted"); err != nil {
+		t.Fatal(err)
+	}
+
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if strings.Contains(string(content), "encrypted") || strings.Contains(string(content), "root/myroot") {
+		t.Fatalf("the keyring holds the secret in the clear: %s", content)
+	}
+
+	var keyring keyringFile
+
+	if err := json.Unmarshal(content, &keyring); err != nil {
+		t.Fatal(err)
+	}
+
+	// Writes a copy of the keyring changed by modify, returning its path
+	variant := func(name string, modify func(keyring *keyringFile)) string {
+		copied := keyring
+		copied.Ciphertext = append([]byte(nil), keyring.Ciphertext...)
+		modify(&copied)
+
+		content, err := json.Marshal(&copied)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		variantPath := filepath.Join(directory, name)
+		if err := ioutil.WriteFile(variantPath, content, 0600); err != nil {
+			t.Fatal(err)
+		}
+
+		return variantPath
+	}
+
+	tests := []struct {
+		name       string
+		path       string
+		passphrase string
+		fails      string
+	}{
+		{name: "right passphrase", path: path, passphrase: "passphrase"},
+		{name: "wrong passphrase", path: path, passphrase: "wrong", fails: "the passphrase is wrong"},
+		{name: "modified", path: variant("modified", func(keyring *keyringFile) { keyring.Ciphertext[0] ^= 1 }), passphrase: "passphrase", fails: "the passphrase is wrong"},
+		{name: "too few iterations", path: variant("weak", func(keyring *keyringFile) { keyring.Iterations = 1000 }), passphrase: "passphrase", fails: "iterations"},
+		{name: "unknown version", path: variant("version", func(keyring *keyringFile) { keyring.Version = 2 }), passphrase: "passphrase", fails: "unsupported keyring version"},
+		{name: "unknown kdf", path: variant("kdf", func(keyring *keyringFile) { keyring.Kdf = "scrypt" }), passphrase: "passphrase", fails: "unsupported keyring version"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			sink, err := NewKeyringSink(test.path, test.passphrase)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			value, ok, err := sink.Get("root/myroot/password")
+
+			if test.fails != "" {
+				if err == nil || !strings.Contains(err.Error(), test.fails) {
+					t.Fatalf("expected an error with %q, got %q, %v", test.fails, value, err)
+				}
+
+				return
+			}
+
+			if err != nil || !ok || value != "encrypted" {
+				t.Fatalf("expected the secret, got %q, %t, %v", value, ok, err)
+			}
+		})
+	}
+}
+
+func TestKeyringSinkKeepsItsIterations(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "secrets.keyring")
+
+	// A keyring written with more iterations than the default is read and written back with them
+	salt := []byte("0123456789abcdef")
+	iterations := keyringIterations + 1
+
+	aead, err := newKeyringCipher(pbkdf2.Key([]byte("passphrase"), salt, iterations, 32, sha256.New))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	nonce := make([]byte, aead.NonceSize())
+	content, err := json.Marshal(&keyringFile{
+		Version:    keyringVersion,
+		Kdf:        keyringKdf,
+		Iterations: iterations,
+		Salt:       salt,
+		Nonce:      nonce,
+		Ciphertext: aead.Seal(nil, nonce, []byte(`{"root/myroot/password":"existing"}`), nil),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := ioutil.WriteFile(path, content, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	sink, err := NewKeyringSink(path, "passphrase")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := sink.Put("leaf/myleaf/password", "added"); err != nil {
+		t.Fatal(err)
+	}
+
+	content, err = ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var keyring keyringFile
+
+	if err := json.Unmarshal(content, &keyring); err != nil {
+		t.Fatal(err)
+	}
+
+	if keyring.Iterations != iterations {
+		t.Fatalf("expected the keyring to keep %d iterations, got %d", iterations, keyring.Iterations)
+	}
+
+	reopened, err := NewKeyringSink(path, "passphrase")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for key, expected := range map[string]string{"root/myroot/password": "existing", "leaf/myleaf/password": "added"} {
+		value, ok, err := reopened.Get(key)
+		if err != nil || !ok || value != expected {
+			t.Fatalf("expected %s to be %q, got %q, %t, %v", key, expected, value, ok, err)
+		}
+	}
+}
+
+func TestKeyringSinkNeedsPassphrase(t *testing.T) {
+	if _, err := NewKeyringSink(filepath.Join(t.TempDir(), "secrets.keyring"), ""); err == nil {
+		t.Fatal("expected a keyring without a passphrase to be rejected")
+	}
+}
+
+func TestProviderSink(t *testing.T) {
+	vault := startFakeVault(t, map[string]map[string]interface{}{})
+
+	// The cache of resolved expressions would outlive the fake server
+	Register("vault", &vaultProvider{})
+
+	sink, err := NewProviderSink("vault.kv2/ssl-go/")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok, err := sink.Get("root/myroot/password"); ok || err != nil {
+		t.Fatalf("expected a missing secret, got %t, %v", ok, err)
+	}
+
+	if err := sink.Put("root/myroot/password", "stored"); err != nil {
+		t.Fatal(err)
+	}
+
+	if vault.secrets["kv2/ssl-go/root/myroot/password"]["value"] != "stored" {
+		t.Fatalf("expected the secret in Vault, got %v", vault.secrets)
+	}
+
+	value, ok, err := sink.Get("root/myroot/password")
+	if err != nil || !ok || value != "stored" {
+		t.Fatalf("expected the stored secret, got %q, %t, %v", value, ok, err)
+	}
+
+	for _, reference := range []string{"ssl-go", "unknown.path", "env.NAME"} {
+		if _, err := NewProviderSink(reference); err == nil {
+			t.Fatalf("expected the reference %s to be rejected", reference)
+		}
+	}
+}
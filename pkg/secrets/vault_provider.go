@@ -0,0 +1,267 @@
+package secrets
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+func init() {
+	Register("vault", &vaultProvider{})
+}
+
+type vaultResponse struct {
+	Data   json.RawMessage `json:"data"`
+	Errors []string        `json:"errors"`
+}
+
+type vaultMount struct {
+	Path    string `json:"path"`
+	Options struct {
+		Version string `json:"version"`
+	} `json:"options"`
+}
+
+// ${{ vault.mount/path#field }} is a field of a secret of a KV secrets engine, version 1 or 2, like vault kv get
+// -field=field mount/path reads it. The server and token are read from VAULT_ADDR, VAULT_TOKEN (or ~/.vault-token),
+// VAULT_NAMESPACE and VAULT_CACERT like the vault CLI does.
+type vaultProvider struct{}
+
+func (provider *vaultProvider) Resolve(reference string) (string, error) {
+	path, field := splitField(reference)
+	path = strings.Trim(path, "/")
+
+	client, err := newVaultClient()
+	if err != nil {
+		return "", err
+	}
+
+	data, err := client.readSecret(path)
+	if err != nil {
+		return "", err
+	}
+
+	if field == "" {
+		if len(data) != 1 {
+			return "", fmt.Errorf("the Vault secret %s has %d fields, choose one with %s#<field>", path, len(data), path)
+		}
+
+		for name := range data {
+			field = name
+		}
+	}
+
+	value, ok := data[field]
+	if !ok {
+		return "", notFound("the Vault secret %s has no field %s", path, field)
+	}
+
+	text, ok := value.(string)
+	if !ok {
+		return "", fmt.Errorf("the field %s of the Vault secret %s is not text", field, path)
+	}
+
+	return text, nil
+}
+
+// Writes the field of the secret, value by default, keeping its other fields.
+func (provider *vaultProvider) Store(reference string, value string) error {
+	path, field := splitField(reference)
+	path = strings.Trim(path, "/")
+
+	if field == "" {
+		field = "value"
+	}
+
+	client, err := newVaultClient()
+	if err != nil {
+		return err
+	}
+
+	data, err := client.readSecret(path)
+	if errors.Is(err, ErrNotFound) {
+		data, err = make(map[string]interface{}), nil
+	}
+
+	if err != nil {
+		return err
+	}
+
+	data[field] = value
+
+	writePath, version := client.getSecretPath(path)
+
+	var body interface{} = data
+	if version == "2" {
+		body = map[string]interface{}{"data": data}
+	}
+
+	err = client.send(http.MethodPost, writePath, body, nil)
+	if err != nil {
+		return fmt.Errorf("failed to write the Vault secret %s: %s", path, err)
+	}
+
+	return nil
+}
+
+type vaultClient struct {
+	address   string
+	token     string
+	namespace string
+	http      *http.Client
+}
+
+func newVaultClient() (*vaultClient, error) {
+	address := os.Getenv("VAULT_ADDR")
+	if address == "" {
+		return nil, fmt.Errorf("VAULT_ADDR is not set, it has to be the address of the Vault server")
+	}
+
+	token := os.Getenv("VAULT_TOKEN")
+
+	if token == "" {
+		home, err := os.UserHomeDir()
+		if err == nil {
+			content, err := ioutil.ReadFile(filepath.Join(home, ".vault-token"))
+			if err == nil {
+				token = strings.TrimSpace(string(content))
+			}
+		}
+	}
+
+	if token == "" {
+		return nil, fmt.Errorf("VAULT_TOKEN is not set and there is no ~/.vault-token")
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+
+	if caPath := os.Getenv("VAULT_CACERT"); caPath != "" {
+		content, err := ioutil.ReadFile(caPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read VAULT_CACERT: %s", err)
+		}
+
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(content) {
+			return nil, fmt.Errorf("VAULT_CACERT %s has no PEM certificates", caPath)
+		}
+
+		transport.TLSClientConfig = &tls.Config{RootCAs: pool}
+	}
+
+	return &vaultClient{
+		address:   strings.TrimSuffix(address, "/"),
+		token:     token,
+		namespace: os.Getenv("VAULT_NAMESPACE"),
+		http:      &http.Client{Transport: transport, Timeout: 30 * time.Second},
+	}, nil
+}
+
+// Reads the fields of a secret of a KV secrets engine.
+func (client *vaultClient) readSecret(path string) (map[string]interface{}, error) {
+	readPath, version := client.getSecretPath(path)
+
+	var data map[string]interface{}
+
+	err := client.send(http.MethodGet, readPath, nil, &data)
+
+	if errors.Is(err, ErrNotFound) {
+		return nil, notFound("the Vault secret %s does not exist", path)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the Vault secret %s: %s", path, err)
+	}
+
+	if version == "2" {
+		data, _ = data["data"].(map[string]interface{})
+	}
+
+	if data == nil {
+		return nil, notFound("the Vault secret %s does not exist", path)
+	}
+
+	return data, nil
+}
+
+// Gets the API path of a secret and the version of its KV secrets engine. Version 2 secrets are at
+// <mount>/data/<path>, the mount tells which version it is.
+func (client *vaultClient) getSecretPath(path string) (string, string) {
+	var mount vaultMount
+
+	err := client.send(http.MethodGet, "sys/internal/ui/mounts/"+path, nil, &mount)
+	if err == nil && mount.Options.Version == "2" {
+		return mount.Path + "data/" + strings.TrimPrefix(path, mount.Path), "2"
+	}
+
+	return path, "1"
+}
+
+// Sends a request to /v1/<path> with the JSON body, and reads the data of the response if it's not nil.
+func (client *vaultClient) send(method string, path string, body interface{}, data interface{}) error {
+	var content io.Reader
+
+	if body != nil {
+		encoded, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+
+		content = bytes.NewReader(encoded)
+	}
+
+	request, err := http.NewRequest(method, client.address+"/v1/"+path, content)
+	if err != nil {
+		return err
+	}
+
+	request.Header.Set("X-Vault-Token", client.token)
+
+	if client.namespace != "" {
+		request.Header.Set("X-Vault-Namespace", client.namespace)
+	}
+
+	response, err := client.http.Do(request)
+	if err != nil {
+		return err
+	}
+
+	defer response.Body.Close()
+
+	var result vaultResponse
+
+	err = json.NewDecoder(response.Body).Decode(&result)
+
+	if response.StatusCode == http.StatusNotFound {
+		return notFound("%s", response.Status)
+	}
+
+	// Writes answer 204 without a body
+	if response.StatusCode != http.StatusOK && response.StatusCode != http.StatusNoContent {
+		if len(result.Errors) != 0 {
+			return fmt.Errorf("%s: %s", response.Status, strings.Join(result.Errors, ", "))
+		}
+
+		return fmt.Errorf("%s", response.Status)
+	}
+
+	if data == nil {
+		return nil
+	}
+
+	if err != nil {
+		return fmt.Errorf("failed to parse the response: %s", err)
+	}
+
+	return json.Unmarshal(result.Data, data)
+}
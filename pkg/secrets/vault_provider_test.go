@@ -0,0 +1,226 @@
+package secrets
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+)
+
+const testVaultToken = "s.test-token"
+
+// Stands in for a Vault server with a KV version 1 engine mounted at kv1/ and a version 2 engine at kv2/.
+type fakeVault struct {
+	sync.Mutex
+
+	secrets map[string]map[string]interface{}
+}
+
+func (vault *fakeVault) ServeHTTP(writer http.ResponseWriter, request *http.Request) {
+	vault.Lock()
+	defer vault.Unlock()
+
+	if request.Header.Get("X-Vault-Token") != testVaultToken {
+		writeVaultResponse(writer, http.StatusForbidden, map[string]interface{}{"errors": []string{"permission denied"}})
+		return
+	}
+
+	path := strings.TrimPrefix(request.URL.Path, "/v1/")
+
+	if strings.HasPrefix(path, "sys/internal/ui/mounts/") {
+		mount := strings.SplitN(strings.TrimPrefix(path, "sys/internal/ui/mounts/"), "/", 2)[0] + "/"
+		version := map[string]string{"kv1/": "1", "kv2/": "2"}[mount]
+
+		if version == "" {
+			writeVaultResponse(writer, http.StatusBadRequest, map[string]interface{}{"errors": []string{"no mount"}})
+			return
+		}
+
+		writeVaultResponse(writer, http.StatusOK, map[string]interface{}{"data": map[string]interface{}{"path": mount, "options": map[string]string{"version": version}}})
+		return
+	}
+
+	// Version 2 secrets are read and written at <mount>/data/<path>, their fields under data
+	isVersion2 := strings.HasPrefix(path, "kv2/data/")
+	if isVersion2 {
+		path = "kv2/" + strings.TrimPrefix(path, "kv2/data/")
+	} else if !strings.HasPrefix(path, "kv1/") {
+		writeVaultResponse(writer, http.StatusNotFound, map[string]interface{}{"errors": []string{}})
+		return
+	}
+
+	switch request.Method {
+	case http.MethodGet:
+		data, ok := vault.secrets[path]
+		if !ok {
+			writeVaultResponse(writer, http.StatusNotFound, map[string]interface{}{"errors": []string{}})
+			return
+		}
+
+		if isVersion2 {
+			writeVaultResponse(writer, http.StatusOK, map[string]interface{}{"data": map[string]interface{}{"data": data, "metadata": map[string]interface{}{"version": 1}}})
+			return
+		}
+
+		writeVaultResponse(writer, http.StatusOK, map[string]interface{}{"data": data})
+	case http.MethodPost:
+		var body map[string]interface{}
+
+		err := json.NewDecoder(request.Body).Decode(&body)
+		if err != nil {
+			writeVaultResponse(writer, http.StatusBadRequest, map[string]interface{}{"errors": []string{err.Error()}})
+			return
+		}
+
+		if isVersion2 {
+			body, _ = body["data"].(map[string]interface{})
+		}
+
+		vault.secrets[path] = body
+
+		writer.WriteHeader(http.StatusNoContent)
+	default:
+		writer.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func writeVaultResponse(writer http.ResponseWriter, status int, body interface{}) {
+	writer.Header().Set("Content-Type", "application/json")
+	writer.WriteHeader(status)
+
+	json.NewEncoder(writer).Encode(body)
+}
+
+// Starts the fake Vault and points the provider at it.
+func startFakeVault(t *testing.T, secrets map[string]map[string]interface{}) *fakeVault {
+	vault := &fakeVault{secrets: secrets}
+	server := httptest.NewServer(vault)
+
+	t.Cleanup(server.Close)
+
+	setEnvironmentVariable(t, "VAULT_ADDR", server.URL)
+	setEnvironmentVariable(t, "VAULT_TOKEN", testVaultToken)
+	setEnvironmentVariable(t, "VAULT_NAMESPACE", "")
+
+	return vault
+}
+
+func setEnvironmentVariable(t *testing.T, name string, value string) {
+	previous, isPresent := os.LookupEnv(name)
+
+	os.Setenv(name, value)
+
+	t.Cleanup(func() {
+		if isPresent {
+			os.Setenv(name, previous)
+		} else {
+			os.Unsetenv(name)
+		}
+	})
+}
+
+func TestVaultProviderResolve(t *testing.T) {
+	startFakeVault(t, map[string]map[string]interface{}{
+		"kv1/ssl":    {"password": "v1-password", "other": "v1-other"},
+		"kv1/single": {"token": "v1-only"},
+		"kv2/ssl":    {"password": "v2-password", "other": "v2-other"},
+		"kv2/number": {"port": 8200},
+	})
+
+	tests := []struct {
+		name      string
+		reference string
+		value     string
+		notFound  bool
+		fails     bool
+	}{
+		{name: "v1 field", reference: "kv1/ssl#password", value: "v1-password"},
+		{name: "v1 only field", reference: "kv1/single", value: "v1-only"},
+		{name: "v1 leading slash", reference: "/kv1/ssl#other", value: "v1-other"},
+		{name: "v1 missing secret", reference: "kv1/missing#password", notFound: true},
+		{name: "v1 missing field", reference: "kv1/ssl#missing", notFound: true},
+		{name: "v1 ambiguous field", reference: "kv1/ssl", fails: true},
+		{name: "v2 field", reference: "kv2/ssl#password", value: "v2-password"},
+		{name: "v2 missing secret", reference: "kv2/missing#password", notFound: true},
+		{name: "v2 missing field", reference: "kv2/ssl#missing", notFound: true},
+		{name: "v2 field not text", reference: "kv2/number#port", fails: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			value, err := (&vaultProvider{}).Resolve(test.reference)
+
+			switch {
+			case test.notFound:
+				if !errors.Is(err, ErrNotFound) {
+					t.Fatalf("expected ErrNotFound, got %v", err)
+				}
+			case test.fails:
+				if err == nil || errors.Is(err, ErrNotFound) {
+					t.Fatalf("expected an error other than ErrNotFound, got %v", err)
+				}
+			case err != nil:
+				t.Fatalf("unexpected error: %s", err)
+			case value != test.value:
+				t.Fatalf("expected %q, got %q", test.value, value)
+			}
+		})
+	}
+}
+
+func TestVaultProviderStore(t *testing.T) {
+	tests := []struct {
+		name      string
+		reference string
+		path      string
+		field     string
+	}{
+		{name: "v1 new secret", reference: "kv1/new#password", path: "kv1/new", field: "password"},
+		{name: "v1 existing secret", reference: "kv1/ssl#password", path: "kv1/ssl", field: "password"},
+		{name: "v1 default field", reference: "kv1/default", path: "kv1/default", field: "value"},
+		{name: "v2 new secret", reference: "kv2/new#password", path: "kv2/new", field: "password"},
+		{name: "v2 existing secret", reference: "kv2/ssl#password", path: "kv2/ssl", field: "password"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			vault := startFakeVault(t, map[string]map[string]interface{}{
+				"kv1/ssl": {"password": "old", "other": "kept"},
+				"kv2/ssl": {"password": "old", "other": "kept"},
+			})
+
+			provider := &vaultProvider{}
+
+			err := provider.Store(test.reference, "stored")
+			if err != nil {
+				t.Fatalf("failed to store: %s", err)
+			}
+
+			if value := vault.secrets[test.path][test.field]; value != "stored" {
+				t.Fatalf("expected the field %s of %s to be stored, got %v", test.field, test.path, vault.secrets[test.path])
+			}
+
+			if (test.path == "kv1/ssl" || test.path == "kv2/ssl") && vault.secrets[test.path]["other"] != "kept" {
+				t.Fatalf("the other fields of %s were not kept: %v", test.path, vault.secrets[test.path])
+			}
+
+			value, err := provider.Resolve(test.path + "#" + test.field)
+			if err != nil || value != "stored" {
+				t.Fatalf("expected to read back the stored value, got %q, %v", value, err)
+			}
+		})
+	}
+}
+
+func TestVaultProviderWithoutServer(t *testing.T) {
+	setEnvironmentVariable(t, "VAULT_ADDR", "")
+
+	_, err := (&vaultProvider{}).Resolve("kv1/ssl#password")
+	if err == nil || errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected an error without VAULT_ADDR, got %v", err)
+	}
+}
@@ -0,0 +1,738 @@
+package sqlite
+
+import (
+	"encoding/binary"
+	"fmt"
+	"sort"
+)
+
+// The types of b-tree pages.
+const (
+	indexInteriorPage = 0x02
+	tableInteriorPage = 0x05
+	indexLeafPage     = 0x0a
+	tableLeafPage     = 0x0d
+)
+
+// The size of the database header, at the start of the first page.
+const databaseHeaderSize = 100
+
+// The version of SQLite written into new databases, the file format is the same since 3.0.0.
+const sqliteVersionNumber = 3040001
+
+// The most payload of a cell kept on its page, the rest goes to overflow pages.
+func maxLocalPayload(usable int, isTable bool) int {
+	if isTable {
+		return usable - 35
+	}
+
+	return (usable-12)*64/255 - 23
+}
+
+// The part of a payload of size that is kept on the page of its cell.
+func localPayloadSize(usable int, size int, isTable bool) int {
+	maxLocal := maxLocalPayload(usable, isTable)
+	if size <= maxLocal {
+		return size
+	}
+
+	minLocal := (usable-12)*32/255 - 23
+	local := minLocal + (size-minLocal)%(usable-4)
+
+	if local > maxLocal {
+		return minLocal
+	}
+
+	return local
+}
+
+func parse(content []byte) (*Database, error) {
+	if len(content) < databaseHeaderSize || string(content[:16]) != headerString {
+		return nil, fmt.Errorf("not a SQLite 3 database")
+	}
+
+	database := &Database{}
+	copy(database.header[:], content)
+
+	database.PageSize = int(binary.BigEndian.Uint16(content[16:]))
+	if database.PageSize == 1 {
+		database.PageSize = 65536
+	}
+
+	switch {
+	case content[20] != 0:
+		return nil, fmt.Errorf("pages with reserved space are not supported")
+	case content[18] == 2 || content[19] == 2:
+		return nil, fmt.Errorf("write-ahead log databases are not supported")
+	case binary.BigEndian.Uint32(content[52:]) != 0:
+		return nil, fmt.Errorf("auto vacuum is not supported")
+	case binary.BigEndian.Uint32(content[56:]) > 1:
+		return nil, fmt.Errorf("only UTF-8 databases are supported")
+	case database.PageSize < 512 || database.PageSize&(database.PageSize-1) != 0 || len(content)%database.PageSize != 0:
+		return nil, errCorrupt
+	}
+
+	reader := &treeReader{content: content, pageSize: database.PageSize, visited: make(map[int]bool)}
+
+	// The schema is the table with the root page 1: type, name, tbl_name, rootpage and sql
+	var schema []*Row
+
+	err := reader.readTable(1, func(row *Row) {
+		schema = append(schema, row)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	tables := make(map[string][][]string)
+
+	for _, row := range schema {
+		values := append(row.Values, nil, nil, nil, nil, nil)
+
+		objectType, _ := values[0].(string)
+		name, _ := values[1].(string)
+		tableName, _ := values[2].(string)
+		rootPage, _ := values[3].(int64)
+		sql, _ := values[4].(string)
+
+		object := &Object{Type: objectType, Name: name, TableName: tableName, SQL: sql}
+
+		switch {
+		case objectType == "table":
+			_, columns, unique, err := parseCreateTable(sql)
+			if err != nil {
+				return nil, err
+			}
+
+			object.Columns = columns
+			tables[name] = unique
+
+			err = reader.readTable(int(rootPage), func(row *Row) {
+				object.Rows = append(object.Rows, row)
+			})
+			if err != nil {
+				return nil, err
+			}
+		case objectType == "index" && sql == "":
+			// The indexes of constraints are numbered from 1 in the order of the constraints
+			var number int
+			if _, err := fmt.Sscanf(name[len("sqlite_autoindex_"+tableName+"_"):], "%d", &number); err != nil || number < 1 || number > len(tables[tableName]) {
+				return nil, fmt.Errorf("unsupported index %s", name)
+			}
+
+			object.Columns = tables[tableName][number-1]
+		case objectType == "index":
+			index, err := parseCreateIndex(sql)
+			if err != nil {
+				return nil, err
+			}
+
+			object.Columns = index.Columns
+		}
+
+		database.Objects = append(database.Objects, object)
+	}
+
+	return database, nil
+}
+
+type treeReader struct {
+	content  []byte
+	pageSize int
+
+	// Pages are only read once, so a corrupt database can't loop
+	visited map[int]bool
+}
+
+func (reader *treeReader) page(number int) ([]byte, int, error) {
+	if number < 1 || number*reader.pageSize > len(reader.content) || reader.visited[number] {
+		return nil, 0, errCorrupt
+	}
+
+	reader.visited[number] = true
+
+	page := reader.content[(number-1)*reader.pageSize : number*reader.pageSize]
+
+	if number == 1 {
+		return page, databaseHeaderSize, nil
+	}
+
+	return page, 0, nil
+}
+
+func (reader *treeReader) readTable(number int, visit func(row *Row)) error {
+	page, offset, err := reader.page(number)
+	if err != nil {
+		return err
+	}
+
+	pageType := page[offset]
+	cellCount := int(binary.BigEndian.Uint16(page[offset+3:]))
+
+	headerSize := 8
+	if pageType == tableInteriorPage {
+		headerSize = 12
+	} else if pageType != tableLeafPage {
+		return errCorrupt
+	}
+
+	for i := 0; i < cellCount; i++ {
+		pointer := offset + headerSize + i*2
+		if pointer+2 > len(page) {
+			return errCorrupt
+		}
+
+		cellOffset := int(binary.BigEndian.Uint16(page[pointer:]))
+		if cellOffset >= len(page) {
+			return errCorrupt
+		}
+
+		cell := page[cellOffset:]
+
+		if pageType == tableInteriorPage {
+			if len(cell) < 4 {
+				return errCorrupt
+			}
+
+			err = reader.readTable(int(binary.BigEndian.Uint32(cell)), visit)
+			if err != nil {
+				return err
+			}
+
+			continue
+		}
+
+		size, n, err := readVarint(cell)
+		if err != nil {
+			return err
+		}
+
+		rowid, m, err := readVarint(cell[n:])
+		if err != nil {
+			return err
+		}
+
+		payload, err := reader.readPayload(cell[n+m:], int(size))
+		if err != nil {
+			return err
+		}
+
+		values, err := decodeRecord(payload)
+		if err != nil {
+			return err
+		}
+
+		visit(&Row{ID: rowid, Values: values})
+	}
+
+	if pageType == tableInteriorPage {
+		return reader.readTable(int(binary.BigEndian.Uint32(page[offset+8:])), visit)
+	}
+
+	return nil
+}
+
+// Reads a payload of a table leaf cell, following its overflow pages.
+func (reader *treeReader) readPayload(cell []byte, size int) ([]byte, error) {
+	local := localPayloadSize(reader.pageSize, size, true)
+	if len(cell) < local {
+		return nil, errCorrupt
+	}
+
+	payload := append([]byte{}, cell[:local]...)
+
+	if local == size {
+		return payload, nil
+	}
+
+	if len(cell) < local+4 {
+		return nil, errCorrupt
+	}
+
+	next := int(binary.BigEndian.Uint32(cell[local:]))
+
+	for len(payload) < size {
+		page, _, err := reader.page(next)
+		if err != nil {
+			return nil, err
+		}
+
+		chunk := page[4:]
+		if remaining := size - len(payload); remaining < len(chunk) {
+			chunk = chunk[:remaining]
+		}
+
+		payload = append(payload, chunk...)
+		next = int(binary.BigEndian.Uint32(page))
+	}
+
+	return payload, nil
+}
+
+// Lays the pages of a database out, every b-tree is built again from its rows.
+type treeWriter struct {
+	pageSize int
+	pages    [][]byte
+}
+
+// Gets a new page, its number is its position from 1.
+func (writer *treeWriter) allocate() int {
+	writer.pages = append(writer.pages, make([]byte, writer.pageSize))
+
+	return len(writer.pages)
+}
+
+// Encodes a payload for a cell, the part that doesn't fit is written to overflow pages.
+func (writer *treeWriter) payloadCell(prefix []byte, payload []byte, isTable bool) []byte {
+	local := localPayloadSize(writer.pageSize, len(payload), isTable)
+
+	cell := append(prefix, payload[:local]...)
+	if local == len(payload) {
+		return cell
+	}
+
+	rest := payload[local:]
+	first := writer.allocate()
+	number := first
+
+	for {
+		chunk := rest
+		if len(chunk) > writer.pageSize-4 {
+			chunk = chunk[:writer.pageSize-4]
+		}
+
+		rest = rest[len(chunk):]
+		copy(writer.pages[number-1][4:], chunk)
+
+		if len(rest) == 0 {
+			break
+		}
+
+		next := writer.allocate()
+		binary.BigEndian.PutUint32(writer.pages[number-1], uint32(next))
+		number = next
+	}
+
+	return append(cell, byte(first>>24), byte(first>>16), byte(first>>8), byte(first))
+}
+
+// Writes a b-tree page, page 1 starts after the database header.
+func (writer *treeWriter) writePage(number int, pageType byte, cells [][]byte, rightChild int) {
+	page := writer.pages[number-1]
+
+	offset := 0
+	if number == 1 {
+		offset = databaseHeaderSize
+	}
+
+	headerSize := 8
+	if pageType == tableInteriorPage || pageType == indexInteriorPage {
+		headerSize = 12
+		binary.BigEndian.PutUint32(page[offset+8:], uint32(rightChild))
+	}
+
+	page[offset] = pageType
+	binary.BigEndian.PutUint16(page[offset+3:], uint16(len(cells)))
+
+	end := len(page)
+
+	for i, cell := range cells {
+		end -= len(cell)
+		copy(page[end:], cell)
+		binary.BigEndian.PutUint16(page[offset+headerSize+i*2:], uint16(end))
+	}
+
+	// 0 is 65536
+	binary.BigEndian.PutUint16(page[offset+5:], uint16(end))
+}
+
+// The space for cells on a page, the first page of the schema leaves room for the database header everywhere.
+func (writer *treeWriter) capacity(isInterior bool, root int) int {
+	capacity := writer.pageSize - 8
+	if isInterior {
+		capacity -= 4
+	}
+
+	if root == 1 {
+		capacity -= databaseHeaderSize
+	}
+
+	return capacity
+}
+
+// Gets the number of the page of a level of a tree, the top page is the root.
+func (writer *treeWriter) levelPage(count int, root int) int {
+	if count == 1 && root != 0 {
+		return root
+	}
+
+	return writer.allocate()
+}
+
+type tableChild struct {
+	page   int
+	maxKey int64
+}
+
+// Writes the b-tree of a table and returns its root page, which is root if it's not 0.
+func (writer *treeWriter) writeTable(rows []*Row, root int) (int, error) {
+	sorted := append([]*Row{}, rows...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].ID < sorted[j].ID })
+
+	type leaf struct {
+		cells  [][]byte
+		maxKey int64
+	}
+
+	leaves := []*leaf{{}}
+	capacity := writer.capacity(false, root)
+	used := 0
+
+	for i, row := range sorted {
+		if i != 0 && row.ID == sorted[i-1].ID {
+			return 0, fmt.Errorf("duplicate rowid %d", row.ID)
+		}
+
+		payload, err := encodeRecord(row.Values)
+		if err != nil {
+			return 0, err
+		}
+
+		prefix := appendVarint(appendVarint(nil, int64(len(payload))), row.ID)
+		cell := writer.payloadCell(prefix, payload, true)
+
+		current := leaves[len(leaves)-1]
+		if len(current.cells) != 0 && used+len(cell)+2 > capacity {
+			current = &leaf{}
+			leaves = append(leaves, current)
+			used = 0
+		}
+
+		current.cells = append(current.cells, cell)
+		current.maxKey = row.ID
+		used += len(cell) + 2
+	}
+
+	children := make([]tableChild, len(leaves))
+
+	for i, leaf := range leaves {
+		number := writer.levelPage(len(leaves), root)
+		writer.writePage(number, tableLeafPage, leaf.cells, 0)
+
+		children[i] = tableChild{page: number, maxKey: leaf.maxKey}
+	}
+
+	for len(children) > 1 {
+		children = writer.writeTableLevel(children, root)
+	}
+
+	return children[0].page, nil
+}
+
+// Writes the interior pages over the children and returns them as the children of the next level.
+func (writer *treeWriter) writeTableLevel(children []tableChild, root int) []tableChild {
+	type interior struct {
+		cells [][]byte
+		right tableChild
+	}
+
+	var pages []*interior
+	current := &interior{}
+	capacity := writer.capacity(true, root)
+	used := 0
+
+	for i, child := range children {
+		if i == len(children)-1 {
+			current.right = child
+			break
+		}
+
+		cell := appendVarint([]byte{byte(child.page >> 24), byte(child.page >> 16), byte(child.page >> 8), byte(child.page)}, child.maxKey)
+
+		if used+len(cell)+2 <= capacity {
+			current.cells = append(current.cells, cell)
+			used += len(cell) + 2
+			continue
+		}
+
+		// The child closes the page, unless the next page would only have the last child
+		if i == len(children)-2 {
+			last := children[i-1]
+			current.cells = current.cells[:len(current.cells)-1]
+			current.right = last
+			pages = append(pages, current)
+
+			current = &interior{cells: [][]byte{cell}}
+			used = len(cell) + 2
+			continue
+		}
+
+		current.right = child
+		pages = append(pages, current)
+
+		current = &interior{}
+		used = 0
+	}
+
+	pages = append(pages, current)
+
+	parents := make([]tableChild, len(pages))
+
+	for i, page := range pages {
+		number := writer.levelPage(len(pages), root)
+		writer.writePage(number, tableInteriorPage, page.cells, page.right.page)
+
+		parents[i] = tableChild{page: number, maxKey: page.right.maxKey}
+	}
+
+	return parents
+}
+
+// An entry of an index, the values of its columns followed by the rowid.
+type indexEntry struct {
+	values []interface{}
+	cell   []byte
+}
+
+// Writes the b-tree of an index over the rows of its table and returns its root page.
+func (writer *treeWriter) writeIndex(columns []int, rows []*Row) (int, error) {
+	entries := make([]*indexEntry, len(rows))
+
+	for i, row := range rows {
+		values := make([]interface{}, 0, len(columns)+1)
+		for _, column := range columns {
+			values = append(values, valueAt(row, column))
+		}
+
+		entries[i] = &indexEntry{values: append(values, row.ID)}
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool {
+		return compareRecords(entries[i].values, entries[j].values) < 0
+	})
+
+	for _, entry := range entries {
+		payload, err := encodeRecord(entry.values)
+		if err != nil {
+			return 0, err
+		}
+
+		entry.cell = writer.payloadCell(appendVarint(nil, int64(len(payload))), payload, false)
+	}
+
+	// Every entry is in a single page, the entries between the leaves are in the pages above them
+	var leaves [][]*indexEntry
+	var dividers []*indexEntry
+	var current []*indexEntry
+
+	capacity := writer.capacity(false, 0)
+	used := 0
+
+	for i, entry := range entries {
+		if used+len(entry.cell)+2 <= capacity {
+			current = append(current, entry)
+			used += len(entry.cell) + 2
+			continue
+		}
+
+		if i == len(entries)-1 {
+			dividers = append(dividers, current[len(current)-1])
+			leaves = append(leaves, current[:len(current)-1])
+
+			current = []*indexEntry{entry}
+			used = len(entry.cell) + 2
+			continue
+		}
+
+		leaves = append(leaves, current)
+		dividers = append(dividers, entry)
+
+		current = nil
+		used = 0
+	}
+
+	leaves = append(leaves, current)
+
+	children := make([]int, len(leaves))
+
+	for i, leaf := range leaves {
+		cells := make([][]byte, len(leaf))
+		for j, entry := range leaf {
+			cells[j] = entry.cell
+		}
+
+		children[i] = writer.levelPage(len(leaves), 0)
+		writer.writePage(children[i], indexLeafPage, cells, 0)
+	}
+
+	for len(children) > 1 {
+		children, dividers = writer.writeIndexLevel(children, dividers)
+	}
+
+	return children[0], nil
+}
+
+// Writes the interior pages over the children, divided by the entries, and returns the children and the dividers of
+// the next level.
+func (writer *treeWriter) writeIndexLevel(children []int, dividers []*indexEntry) ([]int, []*indexEntry) {
+	type interior struct {
+		cells [][]byte
+		right int
+	}
+
+	var pages []*interior
+	var parentDividers []*indexEntry
+
+	current := &interior{}
+	capacity := writer.capacity(true, 0)
+	used := 0
+
+	for i, divider := range dividers {
+		child := children[i]
+		cell := append([]byte{byte(child >> 24), byte(child >> 16), byte(child >> 8), byte(child)}, divider.cell...)
+
+		if used+len(cell)+2 <= capacity {
+			current.cells = append(current.cells, cell)
+			used += len(cell) + 2
+			continue
+		}
+
+		if i == len(dividers)-1 {
+			current.cells = current.cells[:len(current.cells)-1]
+			current.right = children[i-1]
+			pages = append(pages, current)
+			parentDividers = append(parentDividers, dividers[i-1])
+
+			current = &interior{cells: [][]byte{cell}}
+			used = len(cell) + 2
+			continue
+		}
+
+		current.right = child
+		pages = append(pages, current)
+		parentDividers = append(parentDividers, divider)
+
+		current = &interior{}
+		used = 0
+	}
+
+	current.right = children[len(children)-1]
+	pages = append(pages, current)
+
+	parents := make([]int, len(pages))
+
+	for i, page := range pages {
+		parents[i] = writer.levelPage(len(pages), 0)
+		writer.writePage(parents[i], indexInteriorPage, page.cells, page.right)
+	}
+
+	return parents, parentDividers
+}
+
+func valueAt(row *Row, column int) interface{} {
+	if column < len(row.Values) {
+		return row.Values[column]
+	}
+
+	return nil
+}
+
+func compareRecords(a []interface{}, b []interface{}) int {
+	for i := 0; i < len(a) && i < len(b); i++ {
+		if result := compareValues(a[i], b[i]); result != 0 {
+			return result
+		}
+	}
+
+	return len(a) - len(b)
+}
+
+// Encodes the database, the schema is on page 1 and every table and index is laid out from its rows.
+func (database *Database) encode() ([]byte, error) {
+	writer := &treeWriter{pageSize: database.PageSize}
+
+	// Page 1 is taken by the schema
+	writer.allocate()
+
+	var schema []*Row
+
+	for i, object := range database.Objects {
+		var rootPage int64
+
+		switch object.Type {
+		case "table":
+			root, err := writer.writeTable(object.Rows, 0)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %v", object.Name, err)
+			}
+
+			rootPage = int64(root)
+		case "index":
+			table := database.Table(object.TableName)
+			if table == nil {
+				return nil, fmt.Errorf("%s: no table %s", object.Name, object.TableName)
+			}
+
+			columns := make([]int, len(object.Columns))
+			for j, column := range object.Columns {
+				columns[j] = table.ColumnIndex(column)
+				if columns[j] < 0 {
+					return nil, fmt.Errorf("%s: no column %s", object.Name, column)
+				}
+			}
+
+			root, err := writer.writeIndex(columns, table.Rows)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %v", object.Name, err)
+			}
+
+			rootPage = int64(root)
+		}
+
+		values := []interface{}{object.Type, object.Name, object.TableName, rootPage, nil}
+		if object.SQL != "" {
+			values[4] = object.SQL
+		}
+
+		schema = append(schema, &Row{ID: int64(i + 1), Values: values})
+	}
+
+	_, err := writer.writeTable(schema, 1)
+	if err != nil {
+		return nil, err
+	}
+
+	header := database.header
+	copy(header[:], headerString)
+
+	if database.PageSize == 65536 {
+		binary.BigEndian.PutUint16(header[16:], 1)
+	} else {
+		binary.BigEndian.PutUint16(header[16:], uint16(database.PageSize))
+	}
+
+	changeCounter := binary.BigEndian.Uint32(header[24:]) + 1
+
+	binary.BigEndian.PutUint32(header[24:], changeCounter)
+	binary.BigEndian.PutUint32(header[28:], uint32(len(writer.pages)))
+
+	// There are no free pages, every page is laid out again
+	binary.BigEndian.PutUint32(header[32:], 0)
+	binary.BigEndian.PutUint32(header[36:], 0)
+
+	binary.BigEndian.PutUint32(header[40:], binary.BigEndian.Uint32(header[40:])+1)
+	binary.BigEndian.PutUint32(header[92:], changeCounter)
+
+	if binary.BigEndian.Uint32(header[96:]) == 0 {
+		binary.BigEndian.PutUint32(header[96:], sqliteVersionNumber)
+	}
+
+	copy(writer.pages[0], header[:])
+
+	content := make([]byte, 0, len(writer.pages)*database.PageSize)
+	for _, page := range writer.pages {
+		content = append(content, page...)
+	}
+
+	return content, nil
+}
@@ -0,0 +1,81 @@
+package sqlite
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+)
+
+const journalSuffix = "-journal"
+
+// The magic number every rollback journal starts with.
+var journalMagic = []byte{0xd9, 0xd5, 0x05, 0xf9, 0x20, 0xa1, 0x63, 0xd7}
+
+// The size of the journal header, SQLite reads the sector size from it.
+const journalSectorSize = 512
+
+// Writes the rollback journal of a write: the original pages, which SQLite writes back and truncates the database to
+// if it finds the journal next to it.
+func writeJournal(path string, original []byte, pageSize int) error {
+	pageCount := len(original) / pageSize
+
+	var nonce [4]byte
+
+	if _, err := rand.Read(nonce[:]); err != nil {
+		return err
+	}
+
+	header := make([]byte, journalSectorSize)
+	copy(header, journalMagic)
+	binary.BigEndian.PutUint32(header[8:], uint32(pageCount))
+	copy(header[12:], nonce[:])
+	binary.BigEndian.PutUint32(header[16:], uint32(pageCount))
+	binary.BigEndian.PutUint32(header[20:], journalSectorSize)
+	binary.BigEndian.PutUint32(header[24:], uint32(pageSize))
+
+	content := make([]byte, 0, len(header)+pageCount*(pageSize+8))
+	content = append(content, header...)
+
+	for i := 0; i < pageCount; i++ {
+		page := original[i*pageSize : (i+1)*pageSize]
+
+		// The checksum only samples every 200th byte from the end of the page
+		checksum := binary.BigEndian.Uint32(nonce[:])
+		for j := pageSize - 200; j > 0; j -= 200 {
+			checksum += uint32(page[j])
+		}
+
+		content = appendUint32(content, uint32(i+1))
+		content = append(content, page...)
+		content = appendUint32(content, checksum)
+	}
+
+	file, err := os.OpenFile(path+journalSuffix, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+
+	_, err = file.Write(content)
+	if err == nil {
+		err = file.Sync()
+	}
+
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+
+	if err != nil {
+		os.Remove(path + journalSuffix)
+		return err
+	}
+
+	return syncDirectory(filepath.Dir(path))
+}
+
+func appendUint32(out []byte, value uint32) []byte {
+	var encoded [4]byte
+	binary.BigEndian.PutUint32(encoded[:], value)
+
+	return append(out, encoded[:]...)
+}
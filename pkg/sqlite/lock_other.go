@@ -0,0 +1,16 @@
+//go:build !darwin && !dragonfly && !freebsd && !linux && !netbsd && !openbsd
+// +build !darwin,!dragonfly,!freebsd,!linux,!netbsd,!openbsd
+
+package sqlite
+
+import "os"
+
+// SQLite doesn't lock the database with fcntl on the other systems, so only the check for processes that have the
+// database open keeps writes apart there.
+func lockFile(file *os.File, exclusive bool) error {
+	return nil
+}
+
+func syncDirectory(path string) error {
+	return nil
+}
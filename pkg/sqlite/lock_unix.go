@@ -0,0 +1,40 @@
+//go:build darwin || dragonfly || freebsd || linux || netbsd || openbsd
+// +build darwin dragonfly freebsd linux netbsd openbsd
+
+package sqlite
+
+import (
+	"io"
+	"os"
+	"syscall"
+)
+
+// The bytes SQLite locks in the database file, past the end of any database this package reads: the pending byte,
+// the reserved byte and the 510 shared bytes.
+const (
+	pendingByte = 0x40000000
+	lockedSize  = 512
+)
+
+// Takes the shared lock of SQLite, which readers share and a writer doesn't, or its exclusive lock. It is released
+// when any descriptor of the file is closed, so the file must only be opened once while it is locked.
+func lockFile(file *os.File, exclusive bool) error {
+	lock := syscall.Flock_t{Type: syscall.F_RDLCK, Whence: io.SeekStart, Start: pendingByte, Len: lockedSize}
+	if exclusive {
+		lock.Type = syscall.F_WRLCK
+	}
+
+	return syscall.FcntlFlock(file.Fd(), syscall.F_SETLK, &lock)
+}
+
+// Makes a file created in the directory durable, like SQLite does after creating a journal.
+func syncDirectory(path string) error {
+	directory, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+
+	defer directory.Close()
+
+	return directory.Sync()
+}
@@ -0,0 +1,52 @@
+package sqlite
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Finds another process that has the file open from the file descriptors in /proc, the pid is 0 if there is none.
+// The processes of other users can't be looked at, their writes are only kept apart by the locks.
+func findOpeningProcess(path string) (pid int, name string) {
+	path, err := filepath.Abs(path)
+	if err == nil {
+		path, err = filepath.EvalSymlinks(path)
+	}
+
+	if err != nil {
+		return 0, ""
+	}
+
+	processes, err := ioutil.ReadDir("/proc")
+	if err != nil {
+		return 0, ""
+	}
+
+	for _, process := range processes {
+		pid, err := strconv.Atoi(process.Name())
+		if err != nil || pid == os.Getpid() {
+			continue
+		}
+
+		descriptors, err := ioutil.ReadDir(filepath.Join("/proc", process.Name(), "fd"))
+		if err != nil {
+			continue
+		}
+
+		for _, descriptor := range descriptors {
+			target, err := os.Readlink(filepath.Join("/proc", process.Name(), "fd", descriptor.Name()))
+			if err != nil || target != path {
+				continue
+			}
+
+			comm, _ := ioutil.ReadFile(filepath.Join("/proc", process.Name(), "comm"))
+
+			return pid, strings.TrimSpace(string(comm))
+		}
+	}
+
+	return 0, ""
+}
@@ -0,0 +1,9 @@
+//go:build !linux
+// +build !linux
+
+package sqlite
+
+// The processes that have a file open are only known on Linux.
+func findOpeningProcess(path string) (pid int, name string) {
+	return 0, ""
+}
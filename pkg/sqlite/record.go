@@ -0,0 +1,284 @@
+package sqlite
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"math"
+)
+
+// Reads a varint, big-endian with 7 bits in every byte but the ninth, which has 8.
+func readVarint(data []byte) (int64, int, error) {
+	var value uint64
+
+	for i := 0; i < 9; i++ {
+		if i >= len(data) {
+			return 0, 0, errCorrupt
+		}
+
+		if i == 8 {
+			return int64(value<<8 | uint64(data[i])), 9, nil
+		}
+
+		value = value<<7 | uint64(data[i]&0x7f)
+
+		if data[i]&0x80 == 0 {
+			return int64(value), i + 1, nil
+		}
+	}
+
+	return 0, 0, errCorrupt
+}
+
+func appendVarint(out []byte, v int64) []byte {
+	value := uint64(v)
+
+	if value > 0x00ffffffffffffff {
+		var buf [9]byte
+
+		buf[8] = byte(value)
+		value >>= 8
+
+		for i := 7; i >= 0; i-- {
+			buf[i] = byte(value&0x7f) | 0x80
+			value >>= 7
+		}
+
+		return append(out, buf[:]...)
+	}
+
+	var buf [9]byte
+	n := 0
+
+	for {
+		buf[n] = byte(value & 0x7f)
+		n++
+		value >>= 7
+
+		if value == 0 {
+			break
+		}
+	}
+
+	// The bytes were written least significant first
+	for i := n - 1; i >= 0; i-- {
+		b := buf[i]
+		if i != 0 {
+			b |= 0x80
+		}
+
+		out = append(out, b)
+	}
+
+	return out
+}
+
+func varintLength(v int64) int {
+	return len(appendVarint(nil, v))
+}
+
+// Decodes a record into its values: nil, int64, float64, string or []byte.
+func decodeRecord(payload []byte) ([]interface{}, error) {
+	headerSize, n, err := readVarint(payload)
+	if err != nil {
+		return nil, err
+	}
+
+	if headerSize > int64(len(payload)) || headerSize < int64(n) {
+		return nil, errCorrupt
+	}
+
+	header := payload[n:headerSize]
+	body := payload[headerSize:]
+
+	var values []interface{}
+
+	for len(header) != 0 {
+		serialType, n, err := readVarint(header)
+		if err != nil {
+			return nil, err
+		}
+
+		header = header[n:]
+
+		value, size, err := decodeValue(serialType, body)
+		if err != nil {
+			return nil, err
+		}
+
+		body = body[size:]
+		values = append(values, value)
+	}
+
+	return values, nil
+}
+
+func decodeValue(serialType int64, body []byte) (interface{}, int, error) {
+	integerSizes := map[int64]int{1: 1, 2: 2, 3: 3, 4: 4, 5: 6, 6: 8}
+
+	switch {
+	case serialType == 0:
+		return nil, 0, nil
+	case serialType == 8:
+		return int64(0), 0, nil
+	case serialType == 9:
+		return int64(1), 0, nil
+	case serialType == 7:
+		if len(body) < 8 {
+			return nil, 0, errCorrupt
+		}
+
+		return math.Float64frombits(binary.BigEndian.Uint64(body)), 8, nil
+	case serialType >= 1 && serialType <= 6:
+		size := integerSizes[serialType]
+		if len(body) < size {
+			return nil, 0, errCorrupt
+		}
+
+		// Sign extend from the first byte
+		value := int64(int8(body[0]))
+		for _, b := range body[1:size] {
+			value = value<<8 | int64(b)
+		}
+
+		return value, size, nil
+	case serialType >= 12:
+		size := int((serialType - 12) / 2)
+		if len(body) < size {
+			return nil, 0, errCorrupt
+		}
+
+		if serialType%2 == 0 {
+			return append([]byte{}, body[:size]...), size, nil
+		}
+
+		return string(body[:size]), size, nil
+	}
+
+	return nil, 0, fmt.Errorf("unsupported serial type %d", serialType)
+}
+
+func encodeRecord(values []interface{}) ([]byte, error) {
+	var header, body []byte
+
+	for _, value := range values {
+		switch value := value.(type) {
+		case nil:
+			header = appendVarint(header, 0)
+		case int64:
+			serialType, encoded := encodeInteger(value)
+			header = appendVarint(header, serialType)
+			body = append(body, encoded...)
+		case float64:
+			header = appendVarint(header, 7)
+			body = append(body, make([]byte, 8)...)
+			binary.BigEndian.PutUint64(body[len(body)-8:], math.Float64bits(value))
+		case string:
+			header = appendVarint(header, int64(len(value))*2+13)
+			body = append(body, value...)
+		case []byte:
+			header = appendVarint(header, int64(len(value))*2+12)
+			body = append(body, value...)
+		default:
+			return nil, fmt.Errorf("unsupported value of type %T", value)
+		}
+	}
+
+	// The size of the header includes its own varint
+	headerSize := len(header) + 1
+	for headerSize != len(header)+varintLength(int64(headerSize)) {
+		headerSize = len(header) + varintLength(int64(headerSize))
+	}
+
+	record := appendVarint(nil, int64(headerSize))
+	record = append(record, header...)
+
+	return append(record, body...), nil
+}
+
+func encodeInteger(value int64) (int64, []byte) {
+	switch {
+	case value == 0:
+		return 8, nil
+	case value == 1:
+		return 9, nil
+	case value >= -128 && value <= 127:
+		return 1, []byte{byte(value)}
+	case value >= -32768 && value <= 32767:
+		return 2, []byte{byte(value >> 8), byte(value)}
+	case value >= -8388608 && value <= 8388607:
+		return 3, []byte{byte(value >> 16), byte(value >> 8), byte(value)}
+	case value >= math.MinInt32 && value <= math.MaxInt32:
+		return 4, []byte{byte(value >> 24), byte(value >> 16), byte(value >> 8), byte(value)}
+	case value >= -(1<<47) && value < 1<<47:
+		return 5, []byte{byte(value >> 40), byte(value >> 32), byte(value >> 24), byte(value >> 16), byte(value >> 8), byte(value)}
+	}
+
+	encoded := make([]byte, 8)
+	binary.BigEndian.PutUint64(encoded, uint64(value))
+
+	return 6, encoded
+}
+
+// Compares two values the way SQLite orders them with the binary collation: NULL, then numbers, then text, then blobs.
+func compareValues(a interface{}, b interface{}) int {
+	classA, classB := valueClass(a), valueClass(b)
+	if classA != classB {
+		return classA - classB
+	}
+
+	switch a := a.(type) {
+	case nil:
+		return 0
+	case string:
+		return bytes.Compare([]byte(a), []byte(b.(string)))
+	case []byte:
+		return bytes.Compare(a, b.([]byte))
+	}
+
+	// Integers are compared exactly, as floats they may lose precision
+	if i, ok := a.(int64); ok {
+		if j, ok := b.(int64); ok {
+			switch {
+			case i < j:
+				return -1
+			case i > j:
+				return 1
+			}
+
+			return 0
+		}
+	}
+
+	x, y := toFloat(a), toFloat(b)
+
+	switch {
+	case x < y:
+		return -1
+	case x > y:
+		return 1
+	}
+
+	return 0
+}
+
+func valueClass(value interface{}) int {
+	switch value.(type) {
+	case nil:
+		return 0
+	case int64, float64:
+		return 1
+	case string:
+		return 2
+	}
+
+	return 3
+}
+
+func toFloat(value interface{}) float64 {
+	if i, ok := value.(int64); ok {
+		return float64(i)
+	}
+
+	return value.(float64)
+}
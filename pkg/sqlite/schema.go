@@ -0,0 +1,173 @@
+package sqlite
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Parses the name, the column names and the columns of the UNIQUE and PRIMARY KEY constraints of a CREATE TABLE
+// statement.
+func parseCreateTable(sql string) (string, []string, [][]string, error) {
+	open, end := strings.Index(sql, "("), strings.LastIndex(sql, ")")
+	if open < 0 || end < open {
+		return "", nil, nil, fmt.Errorf("unsupported table: %s", sql)
+	}
+
+	words := strings.Fields(sql[:open])
+	if len(words) < 3 || !strings.EqualFold(words[0], "CREATE") {
+		return "", nil, nil, fmt.Errorf("unsupported table: %s", sql)
+	}
+
+	name := unquoteIdentifier(words[len(words)-1])
+
+	var columns []string
+	var unique [][]string
+
+	addUnique := func(indexColumns []string) {
+		for _, existing := range unique {
+			if strings.EqualFold(strings.Join(existing, ","), strings.Join(indexColumns, ",")) {
+				return
+			}
+		}
+
+		unique = append(unique, indexColumns)
+	}
+
+	for _, definition := range splitTopLevel(sql[open+1 : end]) {
+		fields := strings.Fields(definition)
+		if len(fields) == 0 {
+			continue
+		}
+
+		upper := strings.ToUpper(definition)
+
+		switch strings.ToUpper(fields[0]) {
+		case "CONSTRAINT", "PRIMARY", "UNIQUE", "CHECK", "FOREIGN":
+			if strings.Contains(upper, "PRIMARY KEY") || strings.HasPrefix(strings.TrimSpace(upper), "UNIQUE") {
+				constraintColumns, err := parseColumnList(definition)
+				if err != nil {
+					return "", nil, nil, err
+				}
+
+				addUnique(constraintColumns)
+			}
+
+			continue
+		}
+
+		column := unquoteIdentifier(fields[0])
+		columns = append(columns, column)
+
+		// A rowid alias has no value of its own in the records
+		if len(fields) > 1 && strings.ToUpper(fields[1]) == "INTEGER" && strings.Contains(upper, "PRIMARY KEY") {
+			return "", nil, nil, fmt.Errorf("the table %s has an INTEGER PRIMARY KEY, which is not supported", name)
+		}
+
+		if strings.Contains(upper, "PRIMARY KEY") || strings.Contains(upper, "UNIQUE") {
+			addUnique([]string{column})
+		}
+	}
+
+	if strings.Contains(strings.ToUpper(sql[end:]), "WITHOUT ROWID") {
+		return "", nil, nil, fmt.Errorf("the table %s is WITHOUT ROWID, which is not supported", name)
+	}
+
+	return name, columns, unique, nil
+}
+
+// Parses a CREATE INDEX statement on plain columns.
+func parseCreateIndex(sql string) (*Object, error) {
+	open := strings.Index(sql, "(")
+	if open < 0 {
+		return nil, fmt.Errorf("unsupported index: %s", sql)
+	}
+
+	words := strings.Fields(sql[:open])
+
+	on := -1
+	for i, word := range words {
+		if strings.EqualFold(word, "ON") {
+			on = i
+		}
+	}
+
+	if on < 1 || on != len(words)-2 || strings.Contains(strings.ToUpper(sql[open:]), "WHERE") {
+		return nil, fmt.Errorf("unsupported index: %s", sql)
+	}
+
+	columns, err := parseColumnList(sql)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Object{
+		Type:      "index",
+		Name:      unquoteIdentifier(words[on-1]),
+		TableName: unquoteIdentifier(words[on+1]),
+		SQL:       sql,
+		Columns:   columns,
+	}, nil
+}
+
+// Parses the plain column names between the first parentheses of the text.
+func parseColumnList(text string) ([]string, error) {
+	open, end := strings.Index(text, "("), strings.Index(text, ")")
+	if open < 0 || end < open {
+		return nil, fmt.Errorf("no column list in %s", text)
+	}
+
+	var columns []string
+
+	for _, column := range splitTopLevel(text[open+1 : end]) {
+		fields := strings.Fields(column)
+		if len(fields) != 1 {
+			return nil, fmt.Errorf("unsupported indexed column %s", strings.TrimSpace(column))
+		}
+
+		columns = append(columns, unquoteIdentifier(fields[0]))
+	}
+
+	return columns, nil
+}
+
+// Splits the text at the commas that aren't in parentheses or quotes.
+func splitTopLevel(text string) []string {
+	var parts []string
+
+	depth, start := 0, 0
+	var quote rune
+
+	for i, c := range text {
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"' || c == '`':
+			quote = c
+		case c == '[':
+			quote = ']'
+		case c == '(':
+			depth++
+		case c == ')':
+			depth--
+		case c == ',' && depth == 0:
+			parts = append(parts, text[start:i])
+			start = i + 1
+		}
+	}
+
+	return append(parts, text[start:])
+}
+
+func unquoteIdentifier(identifier string) string {
+	if len(identifier) >= 2 {
+		first, last := identifier[0], identifier[len(identifier)-1]
+
+		if (first == '"' && last == '"') || (first == '`' && last == '`') || (first == '[' && last == ']') {
+			return identifier[1 : len(identifier)-1]
+		}
+	}
+
+	return identifier
+}
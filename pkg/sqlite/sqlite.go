@@ -0,0 +1,299 @@
+// Package sqlite reads and writes whole SQLite 3 database files, enough for small databases like the NSS certificate
+// and key databases: rowid tables and their indexes, without a write-ahead log or auto vacuum. Nothing is parsed
+// but the column names of the tables and indexes, the indexes are built again from the rows when the database is
+// written.
+package sqlite
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+)
+
+var errCorrupt = errors.New("the database file is corrupt")
+
+const headerString = "SQLite format 3\x00"
+
+// The page size of new databases, the default of SQLite.
+const defaultPageSize = 4096
+
+// A database, every table with all of its rows.
+type Database struct {
+	PageSize int
+
+	// The objects of the schema in the order they were created: tables, indexes, views and triggers.
+	Objects []*Object
+
+	header [100]byte
+}
+
+// An object of the schema.
+type Object struct {
+	// table, index, view or trigger.
+	Type string
+
+	Name      string
+	TableName string
+
+	// The statement that created the object, empty for the indexes of the UNIQUE and PRIMARY KEY constraints.
+	SQL string
+
+	// The names of the columns of a table, or of the columns an index is on.
+	Columns []string
+
+	// The rows of a table, ordered by their rowid.
+	Rows []*Row
+}
+
+type Row struct {
+	ID int64
+
+	// The values of the columns: nil, int64, float64, string or []byte.
+	Values []interface{}
+}
+
+// Creates an empty database.
+func New() *Database {
+	database := &Database{PageSize: defaultPageSize}
+
+	copy(database.header[:], headerString)
+
+	// File format 1 (legacy journal), no reserved space, the payload fractions, schema format 4 and UTF-8
+	database.header[18], database.header[19] = 1, 1
+	database.header[21], database.header[22], database.header[23] = 64, 32, 32
+	binary.BigEndian.PutUint32(database.header[44:], 4)
+	binary.BigEndian.PutUint32(database.header[56:], 1)
+
+	return database
+}
+
+// ErrInUse is returned when another process is writing the database, or has it open while it would be written.
+var ErrInUse = errors.New("the database is in use")
+
+// Reads a database file, which must not have a write-ahead log or hot journal waiting to be replayed. It is read with
+// the shared lock of SQLite, so it can't be read while another process writes it.
+func Read(path string) (*Database, error) {
+	err := checkJournals(path)
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	defer file.Close()
+
+	err = lockFile(file, false)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s is locked by another process: %s", ErrInUse, path, err)
+	}
+
+	// Closing another descriptor of the file would release the lock, so the content is read from this one
+	content, err := ioutil.ReadAll(file)
+	if err != nil {
+		return nil, err
+	}
+
+	database, err := parse(content)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %s", path, err)
+	}
+
+	return database, nil
+}
+
+func checkJournals(path string) error {
+	for _, suffix := range []string{"-wal", journalSuffix} {
+		if info, err := os.Stat(path + suffix); err == nil && info.Size() != 0 {
+			return fmt.Errorf("%w: %s is in use or was not closed cleanly, %s%s exists", ErrInUse, path, path, suffix)
+		}
+	}
+
+	return nil
+}
+
+// Gets the table with the name, nil if there is none.
+func (database *Database) Table(name string) *Object {
+	for _, object := range database.Objects {
+		if object.Type == "table" && strings.EqualFold(object.Name, name) {
+			return object
+		}
+	}
+
+	return nil
+}
+
+// Creates a table from its CREATE TABLE statement, with the automatic indexes of its constraints.
+func (database *Database) CreateTable(sql string) (*Object, error) {
+	name, columns, unique, err := parseCreateTable(sql)
+	if err != nil {
+		return nil, err
+	}
+
+	if database.Table(name) != nil {
+		return nil, fmt.Errorf("the table %s already exists", name)
+	}
+
+	table := &Object{Type: "table", Name: name, TableName: name, SQL: sql, Columns: columns}
+	database.Objects = append(database.Objects, table)
+
+	for i, indexColumns := range unique {
+		database.Objects = append(database.Objects, &Object{
+			Type:      "index",
+			Name:      fmt.Sprintf("sqlite_autoindex_%s_%d", name, i+1),
+			TableName: name,
+			Columns:   indexColumns,
+		})
+	}
+
+	return table, nil
+}
+
+// Creates an index from its CREATE INDEX statement.
+func (database *Database) CreateIndex(sql string) error {
+	index, err := parseCreateIndex(sql)
+	if err != nil {
+		return err
+	}
+
+	if database.Table(index.TableName) == nil {
+		return fmt.Errorf("the table %s of the index %s does not exist", index.TableName, index.Name)
+	}
+
+	database.Objects = append(database.Objects, index)
+
+	return nil
+}
+
+// Gets the index of a column of the table, -1 if it doesn't have it.
+func (table *Object) ColumnIndex(name string) int {
+	for i, column := range table.Columns {
+		if strings.EqualFold(column, name) {
+			return i
+		}
+	}
+
+	return -1
+}
+
+// Adds a row with the next rowid, the values are in the order of the columns.
+func (table *Object) Insert(values []interface{}) *Row {
+	id := int64(1)
+	if len(table.Rows) != 0 {
+		id = table.Rows[len(table.Rows)-1].ID + 1
+	}
+
+	row := &Row{ID: id, Values: values}
+	table.Rows = append(table.Rows, row)
+
+	return row
+}
+
+// Removes the rows the function returns true for, and returns their number.
+func (table *Object) Delete(match func(row *Row) bool) int {
+	kept := table.Rows[:0]
+
+	for _, row := range table.Rows {
+		if !match(row) {
+			kept = append(kept, row)
+		}
+	}
+
+	deleted := len(table.Rows) - len(kept)
+	table.Rows = kept
+
+	return deleted
+}
+
+// Gets the value of the column of the row, nil if the row has fewer values.
+func (table *Object) Value(row *Row, column string) interface{} {
+	index := table.ColumnIndex(column)
+	if index < 0 || index >= len(row.Values) {
+		return nil
+	}
+
+	return row.Values[index]
+}
+
+// Writes the database to the path in place, like SQLite does, so the processes that have it open see the change. The
+// file is locked with the exclusive lock of SQLite and the original pages are kept in a rollback journal until the
+// database is written, so SQLite rolls an interrupted write back. The database isn't written while another process
+// has it open, or if it changed since it was read.
+func (database *Database) Write(path string, mode os.FileMode) error {
+	content, err := database.encode()
+	if err != nil {
+		return err
+	}
+
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, mode)
+	if err != nil {
+		return err
+	}
+
+	defer file.Close()
+
+	err = lockFile(file, true)
+	if err != nil {
+		return fmt.Errorf("%w: %s is locked by another process: %s", ErrInUse, path, err)
+	}
+
+	pid, name := findOpeningProcess(path)
+	if pid != 0 {
+		return fmt.Errorf("%w: %s is open in %s, process %d", ErrInUse, path, name, pid)
+	}
+
+	err = checkJournals(path)
+	if err != nil {
+		return err
+	}
+
+	original, err := ioutil.ReadAll(file)
+	if err != nil {
+		return err
+	}
+
+	if len(original) != 0 {
+		// The change counter changes with every write, an empty database wasn't read so it has none
+		if len(original) < databaseHeaderSize || !bytes.Equal(original[24:28], database.header[24:28]) {
+			return fmt.Errorf("%s changed since it was read", path)
+		}
+
+		err = writeJournal(path, original, database.PageSize)
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err = file.WriteAt(content, 0)
+	if err == nil {
+		err = file.Truncate(int64(len(content)))
+	}
+
+	if err == nil {
+		err = file.Sync()
+	}
+
+	if err == nil {
+		err = file.Chmod(mode)
+	}
+
+	if err != nil {
+		return fmt.Errorf("failed to write %s, SQLite rolls it back from %s%s: %s", path, path, journalSuffix, err)
+	}
+
+	// Like SQLite, the journal is only removed once the database is written
+	err = os.Remove(path + journalSuffix)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	copy(database.header[:], content)
+
+	return nil
+}
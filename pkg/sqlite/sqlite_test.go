@@ -0,0 +1,304 @@
+package sqlite
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestWriteReadBack(t *testing.T) {
+	database := New()
+
+	table, err := database.CreateTable("CREATE TABLE objects (id PRIMARY KEY UNIQUE ON CONFLICT ABORT, name, value)")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := database.CreateIndex("CREATE INDEX name ON objects (name)"); err != nil {
+		t.Fatal(err)
+	}
+
+	values := []interface{}{nil, int64(0), int64(1), int64(-129), int64(1) << 40, int64(-1) << 62, 1.5, "", "text", []byte{}, bytes.Repeat([]byte{0xab}, 10000)}
+
+	// Enough rows for interior pages in the table and the indexes
+	for i := 0; i < 1000; i++ {
+		table.Insert([]interface{}{int64(i), fmt.Sprintf("name-%04d", i), values[i%len(values)]})
+	}
+
+	table.Delete(func(row *Row) bool {
+		return row.ID%7 == 0
+	})
+
+	path := filepath.Join(t.TempDir(), "test.db")
+
+	if err := database.Write(path, 0600); err != nil {
+		t.Fatalf("failed to write the database: %s", err)
+	}
+
+	read, err := Read(path)
+	if err != nil {
+		t.Fatalf("failed to read the database back: %s", err)
+	}
+
+	readTable := read.Table("OBJECTS")
+	if readTable == nil || !reflect.DeepEqual(readTable.Columns, table.Columns) || len(readTable.Rows) != len(table.Rows) {
+		t.Fatalf("expected the table to be read back, got %+v", readTable)
+	}
+
+	for i, row := range table.Rows {
+		if !reflect.DeepEqual(readTable.Rows[i], row) {
+			t.Fatalf("expected the row %+v, got %+v", row, readTable.Rows[i])
+		}
+	}
+
+	if len(read.Objects) != 3 || read.Objects[1].Name != "sqlite_autoindex_objects_1" || read.Objects[2].SQL != "CREATE INDEX name ON objects (name)" {
+		t.Fatalf("unexpected schema %+v, %+v", read.Objects[1], read.Objects[2])
+	}
+
+	if _, err := ioutil.ReadFile(path + journalSuffix); err == nil {
+		t.Fatal("expected the journal to be removed")
+	}
+
+	if !hasSqlite() {
+		return
+	}
+
+	checkIntegrity(t, path)
+
+	if output := runSqlite(t, path, "SELECT count(*), sum(length(value)) FROM objects; SELECT id FROM objects WHERE name = 'name-0500'"); output != "858|783588\n500\n" {
+		t.Fatalf("unexpected sqlite3 output %q", output)
+	}
+}
+
+// The databases SQLite writes itself are read, and written back so SQLite reads them.
+func TestReadSqliteDatabase(t *testing.T) {
+	if !hasSqlite() {
+		t.Skip("sqlite3 is not installed")
+	}
+
+	path := filepath.Join(t.TempDir(), "test.db")
+
+	runSqlite(t, path, `PRAGMA page_size = 1024;
+		CREATE TABLE "objects" (id PRIMARY KEY, name TEXT UNIQUE, value, CHECK (id >= 0));
+		CREATE INDEX value ON objects (value);
+		WITH RECURSIVE n(i) AS (SELECT 1 UNION ALL SELECT i + 1 FROM n WHERE i < 500)
+		INSERT INTO objects SELECT i, 'name-' || i, CASE i % 4 WHEN 0 THEN zeroblob(i * 10) WHEN 1 THEN i * 1.5 WHEN 2 THEN -i ELSE NULL END FROM n;
+		DELETE FROM objects WHERE id % 9 = 0;`)
+
+	database, err := Read(path)
+	if err != nil {
+		t.Fatalf("failed to read the database sqlite3 wrote: %s", err)
+	}
+
+	table := database.Table("objects")
+	if table == nil || len(table.Rows) != 445 || database.PageSize != 1024 {
+		t.Fatalf("expected 445 rows with 1024 byte pages, got %+v", database)
+	}
+
+	expected := map[int64]interface{}{
+		4:   make([]byte, 40),
+		5:   7.5,
+		6:   int64(-6),
+		7:   nil,
+		500: make([]byte, 5000),
+	}
+
+	for _, row := range table.Rows {
+		if value, ok := expected[row.ID]; ok && !reflect.DeepEqual(table.Value(row, "value"), value) {
+			t.Fatalf("expected %v in row %d, got %v", value, row.ID, table.Value(row, "value"))
+		}
+	}
+
+	table.Insert([]interface{}{nil, "added", "value"})
+	table.Delete(func(row *Row) bool {
+		return row.ID < 100
+	})
+
+	if err := database.Write(path, 0600); err != nil {
+		t.Fatalf("failed to write the database back: %s", err)
+	}
+
+	checkIntegrity(t, path)
+
+	if output := runSqlite(t, path, "SELECT count(*), max(rowid) FROM objects; SELECT rowid, id IS NULL FROM objects WHERE name = 'added'"); output != "358|501\n501|1\n" {
+		t.Fatalf("unexpected sqlite3 output %q", output)
+	}
+}
+
+func TestWriteRefusesDatabasesInUse(t *testing.T) {
+	if !hasSqlite() {
+		t.Skip("sqlite3 is not installed")
+	}
+
+	path := filepath.Join(t.TempDir(), "test.db")
+	runSqlite(t, path, "CREATE TABLE objects (id, value); INSERT INTO objects VALUES (1, 'value')")
+
+	database, err := Read(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if runtime.GOOS == "linux" {
+		stop := holdSqlite(t, path, "SELECT count(*) FROM objects;")
+
+		err = database.Write(path, 0600)
+		if !errors.Is(err, ErrInUse) || !strings.Contains(err.Error(), "is open in sqlite3") {
+			t.Fatalf("expected the database not to be written while sqlite3 has it open, got %v", err)
+		}
+
+		stop()
+	}
+
+	stop := holdSqlite(t, path, "BEGIN EXCLUSIVE; SELECT 'locked';")
+
+	err = database.Write(path, 0600)
+	if !errors.Is(err, ErrInUse) || !strings.Contains(err.Error(), "is locked by another process") {
+		t.Fatalf("expected the database not to be written while sqlite3 writes it, got %v", err)
+	}
+
+	if _, err := Read(path); !errors.Is(err, ErrInUse) {
+		t.Fatalf("expected the database not to be read while sqlite3 writes it, got %v", err)
+	}
+
+	stop()
+
+	// The database sqlite3 changed since it was read isn't overwritten
+	runSqlite(t, path, "INSERT INTO objects VALUES (2, 'value')")
+
+	err = database.Write(path, 0600)
+	if err == nil || !strings.Contains(err.Error(), "changed since it was read") {
+		t.Fatalf("expected the changed database not to be written, got %v", err)
+	}
+
+	if output := runSqlite(t, path, "SELECT count(*) FROM objects"); output != "2\n" {
+		t.Fatalf("expected the database to be left as it is, got %q", output)
+	}
+
+	database, err = Read(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := database.Write(path, 0600); err != nil {
+		t.Fatalf("failed to write the database once it is no longer in use: %s", err)
+	}
+}
+
+// A write interrupted after the journal was written is rolled back by SQLite.
+func TestJournalRollsBack(t *testing.T) {
+	if !hasSqlite() {
+		t.Skip("sqlite3 is not installed")
+	}
+
+	path := filepath.Join(t.TempDir(), "test.db")
+	runSqlite(t, path, "PRAGMA page_size = 512; CREATE TABLE objects (id, value); INSERT INTO objects VALUES (1, randomblob(2000))")
+
+	original, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	database, err := Read(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := writeJournal(path, original, database.PageSize); err != nil {
+		t.Fatal(err)
+	}
+
+	table := database.Table("objects")
+	for i := 0; i < 20; i++ {
+		table.Insert([]interface{}{int64(i), bytes.Repeat([]byte{byte(i)}, 1000)})
+	}
+
+	content, err := database.encode()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Half of the new database is written when the write is interrupted
+	if err := ioutil.WriteFile(path, content[:len(content)/2], 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	if output := runSqlite(t, path, "SELECT count(*) FROM objects"); output != "1\n" {
+		t.Fatalf("expected sqlite3 to roll the write back, got %q", output)
+	}
+
+	rolledBack, err := ioutil.ReadFile(path)
+	if err != nil || !bytes.Equal(rolledBack, original) {
+		t.Fatalf("expected the original database back, %v", err)
+	}
+}
+
+func hasSqlite() bool {
+	_, err := exec.LookPath("sqlite3")
+
+	return err == nil
+}
+
+func runSqlite(t *testing.T, path string, sql string) string {
+	t.Helper()
+
+	output, err := exec.Command("sqlite3", path, sql).CombinedOutput()
+	if err != nil {
+		t.Fatalf("sqlite3 failed: %s: %s", err, output)
+	}
+
+	return string(output)
+}
+
+func checkIntegrity(t *testing.T, path string) {
+	t.Helper()
+
+	if output := runSqlite(t, path, "PRAGMA integrity_check"); output != "ok\n" {
+		t.Fatalf("sqlite3 found the database corrupt: %s", output)
+	}
+}
+
+// Keeps sqlite3 running on the database once it printed the result of the statements, until stop is called.
+func holdSqlite(t *testing.T, path string, sql string) (stop func()) {
+	t.Helper()
+
+	cmd := exec.Command("sqlite3", path)
+
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+
+	stop = func() {
+		stdin.Close()
+		cmd.Wait()
+	}
+
+	t.Cleanup(stop)
+
+	if _, err := stdin.Write([]byte(sql + "\n")); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := bufio.NewReader(stdout).ReadString('\n'); err != nil {
+		t.Fatalf("sqlite3 failed on %s: %s", sql, err)
+	}
+
+	return stop
+}
@@ -0,0 +1,121 @@
+#!/bin/sh
+# Generates an intermediate certificate authority into ./bin with openssl, signed by a certificate authority in ./bin.
+#
+# Usage: generate-intermediate-ca.sh <name> <password> <pfx password> <issuer name> <issuer password>
+#            <issuer is root> <trust store> <skip dhparam> <keep csr> <days> <key size>
+#
+# A password is either the password itself or @ followed by the path of a file holding it. The issuer is
+# bin/root-ca-<issuer name> when <issuer is root> is YES and bin/ca-<issuer name> otherwise. The subject and
+# extensions are read from bin/ca-<name>.conf when it exists. Writes bin/ca-<name>.{key,crt,chain.crt,pfx},
+# bin/ca-<name>.csr when <keep csr> is YES and bin/ca-<name>.dhparam.pem when <skip dhparam> is NO. The certificate
+# never outlives its issuer. With <trust store> YES the certificate is installed into the Debian trust store.
+
+set -eu
+
+if [ "$#" -ne 11 ]; then
+	echo "usage: $0 <name> <password> <pfx password> <issuer name> <issuer password> <issuer is root> <trust store> <skip dhparam> <keep csr> <days> <key size>" >&2
+	exit 2
+fi
+
+name=$1
+issuer_name=$4
+issuer_is_root=$6
+trust_store=$7
+skip_dhparam=$8
+keep_csr=$9
+days=${10}
+key_size=${11}
+
+# Passwords are handed to openssl in the environment, so they are never on a command line and a file can hold
+# more than one of them
+read_password() {
+	case "$1" in
+	@*) cat "${1#@}" ;;
+	*) printf '%s' "$1" ;;
+	esac
+}
+
+SSL_GO_PASSWORD=$(read_password "$2")
+SSL_GO_PFX_PASSWORD=$(read_password "$3")
+SSL_GO_ISSUER_PASSWORD=$(read_password "$5")
+export SSL_GO_PASSWORD SSL_GO_PFX_PASSWORD SSL_GO_ISSUER_PASSWORD
+
+password=env:SSL_GO_PASSWORD
+pfx_password=env:SSL_GO_PFX_PASSWORD
+issuer_password=env:SSL_GO_ISSUER_PASSWORD
+
+base="bin/ca-$name"
+
+if [ "$issuer_is_root" = "YES" ]; then
+	issuer="bin/root-ca-$issuer_name"
+else
+	issuer="bin/ca-$issuer_name"
+fi
+
+if [ ! -f "$issuer.key" ] || [ ! -f "$issuer.crt" ] || [ ! -f "$issuer.chain.crt" ]; then
+	echo "the issuer $issuer_name was not generated, $issuer.{key,crt,chain.crt} are missing" >&2
+	exit 1
+fi
+
+# Go can only decrypt the traditional PEM encryption, which OpenSSL 3 no longer writes by default
+traditional=""
+if openssl rsa -help 2>&1 | grep -q -- "-traditional"; then
+	traditional="-traditional"
+fi
+
+echo "Generating the private key of the intermediate certificate authority $name"
+openssl genrsa "$key_size" 2>/dev/null | openssl rsa -aes256 -passout "$password" $traditional -out "$base.key"
+chmod 600 "$base.key"
+
+extensions=$(mktemp)
+trap 'rm -f "$extensions"' EXIT
+
+if [ -f "$base.conf" ]; then
+	openssl req -new -sha256 -key "$base.key" -passin "$password" -config "$base.conf" -out "$base.csr"
+	set -- -extfile "$base.conf" -extensions config_extensions
+else
+	openssl req -new -sha256 -key "$base.key" -passin "$password" -subj "/CN=$name" -out "$base.csr"
+	printf 'basicConstraints = critical, CA:TRUE\nkeyUsage = critical, keyCertSign, cRLSign\n' >"$extensions"
+	set -- -extfile "$extensions"
+fi
+
+# Cut the validity short at the expiry of the issuer, where date can parse it. The minute of margin covers the time
+# until the certificate is signed
+issuer_expiry=$(date -d "$(openssl x509 -in "$issuer.crt" -noout -enddate | cut -d= -f2)" +%s 2>/dev/null || true)
+if [ -n "$issuer_expiry" ]; then
+	remaining=$(((issuer_expiry - $(date +%s) - 60) / 86400))
+
+	if [ "$remaining" -lt 1 ]; then
+		echo "the issuer $issuer_name expires within a day" >&2
+		exit 1
+	fi
+
+	if [ "$remaining" -lt "$days" ]; then
+		days=$remaining
+	fi
+fi
+
+echo "Signing the intermediate certificate authority $name with $issuer_name for $days days"
+openssl x509 -req -sha256 -in "$base.csr" -CA "$issuer.crt" -CAkey "$issuer.key" -passin "$issuer_password" \
+	-set_serial "0x$(openssl rand -hex 16)" -days "$days" "$@" -out "$base.crt"
+
+if [ "$keep_csr" != "YES" ]; then
+	rm -f "$base.csr"
+fi
+
+# The chain is the certificate followed by its issuers
+cat "$base.crt" "$issuer.chain.crt" >"$base.chain.crt"
+
+openssl pkcs12 -export -inkey "$base.key" -passin "$password" -in "$base.crt" -certfile "$issuer.chain.crt" -name "$name" \
+	-passout "$pfx_password" -out "$base.pfx"
+chmod 600 "$base.pfx"
+
+if [ "$skip_dhparam" = "NO" ]; then
+	echo "Generating the DH parameters of the intermediate certificate authority $name"
+	openssl dhparam -out "$base.dhparam.pem" "$key_size" 2>/dev/null
+fi
+
+if [ "$trust_store" = "YES" ]; then
+	cp "$base.crt" "/usr/local/share/ca-certificates/ssl-go-ca-$name.crt"
+	update-ca-certificates
+fi
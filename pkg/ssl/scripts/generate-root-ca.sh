@@ -0,0 +1,82 @@
+#!/bin/sh
+# Generates a self-signed root certificate authority into ./bin with openssl.
+#
+# Usage: generate-root-ca.sh <name> <password> <pfx password> <trust store> <skip dhparam> <skip csr> <days> <key size>
+#
+# A password is either the password itself or @ followed by the path of a file holding it. The subject and
+# extensions are read from bin/root-ca-<name>.conf when it exists. Writes bin/root-ca-<name>.{key,crt,chain.crt,pfx},
+# bin/root-ca-<name>.csr when <skip csr> is NO and bin/root-ca-<name>.dhparam.pem when <skip dhparam> is NO. With
+# <trust store> YES the certificate is installed into the Debian trust store.
+
+set -eu
+
+if [ "$#" -ne 8 ]; then
+	echo "usage: $0 <name> <password> <pfx password> <trust store> <skip dhparam> <skip csr> <days> <key size>" >&2
+	exit 2
+fi
+
+name=$1
+trust_store=$4
+skip_dhparam=$5
+skip_csr=$6
+days=$7
+key_size=$8
+
+# Passwords are handed to openssl in the environment, so they are never on a command line and a file can hold
+# more than one of them
+read_password() {
+	case "$1" in
+	@*) cat "${1#@}" ;;
+	*) printf '%s' "$1" ;;
+	esac
+}
+
+SSL_GO_PASSWORD=$(read_password "$2")
+SSL_GO_PFX_PASSWORD=$(read_password "$3")
+export SSL_GO_PASSWORD SSL_GO_PFX_PASSWORD
+
+password=env:SSL_GO_PASSWORD
+pfx_password=env:SSL_GO_PFX_PASSWORD
+
+base="bin/root-ca-$name"
+
+# Go can only decrypt the traditional PEM encryption, which OpenSSL 3 no longer writes by default
+traditional=""
+if openssl rsa -help 2>&1 | grep -q -- "-traditional"; then
+	traditional="-traditional"
+fi
+
+mkdir -p bin
+
+echo "Generating the private key of the root certificate authority $name"
+openssl genrsa "$key_size" 2>/dev/null | openssl rsa -aes256 -passout "$password" $traditional -out "$base.key"
+chmod 600 "$base.key"
+
+if [ -f "$base.conf" ]; then
+	set -- -config "$base.conf" -extensions config_extensions
+else
+	set -- -subj "/CN=$name" -addext "basicConstraints = critical, CA:TRUE" -addext "keyUsage = critical, keyCertSign, cRLSign"
+fi
+
+echo "Self-signing the root certificate authority $name for $days days"
+openssl req -new -x509 -sha256 -key "$base.key" -passin "$password" -days "$days" -set_serial "0x$(openssl rand -hex 16)" "$@" -out "$base.crt"
+
+if [ "$skip_csr" = "NO" ]; then
+	openssl req -new -sha256 -key "$base.key" -passin "$password" "$@" -out "$base.csr"
+fi
+
+# A root certificate authority is its own chain
+cp "$base.crt" "$base.chain.crt"
+
+openssl pkcs12 -export -inkey "$base.key" -passin "$password" -in "$base.crt" -name "$name" -passout "$pfx_password" -out "$base.pfx"
+chmod 600 "$base.pfx"
+
+if [ "$skip_dhparam" = "NO" ]; then
+	echo "Generating the DH parameters of the root certificate authority $name"
+	openssl dhparam -out "$base.dhparam.pem" "$key_size" 2>/dev/null
+fi
+
+if [ "$trust_store" = "YES" ]; then
+	cp "$base.crt" "/usr/local/share/ca-certificates/ssl-go-root-ca-$name.crt"
+	update-ca-certificates
+fi
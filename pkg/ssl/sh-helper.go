@@ -1,32 +1,183 @@
+// Package ssl bundles the openssl generation scripts into the binary, so the script backend runs the pinned versions
+// without cloning anything at run time. They take the arguments of the scripts of https://github.com/mfdlabs/ssl.
 package ssl
 
 import (
-	"errors"
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"embed"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
 	"os"
-	"os/exec"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"git.mfdlabs.local/petko/mfdlabs-ssl-go/pkg/helper"
 )
 
-func tryCloneRepo() error {
-	// determine if we have git installed
-	if _, err := exec.LookPath("git"); err != nil {
-		return err
+// We have 3 scripts:
+// - generate-root-ca.sh
+// - generate-intermediate-ca.sh
+// - generate-certs-v2.sh
+const (
+	RootCertificateAuthorityScript         = "generate-root-ca.sh"
+	IntermediateCertificateAuthorityScript = "generate-intermediate-ca.sh"
+	LeafCertificateScript                  = "generate-certs-v2.sh"
+)
+
+// The file of the bundle with the SHA-256 checksum of every script, in the format of sha256sum.
+const checksumsFileName = "SHA256SUMS"
+
+var requiredScripts = []string{RootCertificateAuthorityScript, IntermediateCertificateAuthorityScript, LeafCertificateScript}
+
+//go:embed scripts
+var bundle embed.FS
+
+// The generation scripts, extracted to a directory only the current user can access.
+type Scripts struct {
+	Directory string
+
+	unregister func()
+}
+
+// Extracts the bundled scripts to a new private temporary directory and verifies the extracted files against their
+// checksums, so nothing else is run. The directory should be removed once the scripts ran.
+func ExtractScripts() (*Scripts, error) {
+	checksums, err := checkBundle()
+	if err != nil {
+		return nil, err
+	}
+
+	// TempDir creates the directory with 0700
+	directory, err := ioutil.TempDir("", "ssl-go-scripts-")
+	if err != nil {
+		return nil, err
+	}
+
+	scripts := &Scripts{Directory: directory, unregister: helper.RemoveOnSignal(directory)}
+
+	names := make([]string, 0, len(checksums))
+	for name := range checksums {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	for _, name := range names {
+		err = scripts.extract(name, checksums[name])
+		if err != nil {
+			scripts.Remove()
+			return nil, err
+		}
+	}
+
+	return scripts, nil
+}
+
+// Gets the path of an extracted script.
+func (scripts *Scripts) Path(name string) string {
+	return filepath.Join(scripts.Directory, name)
+}
+
+// Removes the extracted scripts.
+func (scripts *Scripts) Remove() error {
+	defer scripts.unregister()
+
+	return os.RemoveAll(scripts.Directory)
+}
+
+func (scripts *Scripts) extract(name string, checksum string) error {
+	content, err := bundle.ReadFile(path.Join("scripts", name))
+	if err != nil {
+		return fmt.Errorf("the generation script %s is listed in %s but not bundled", name, checksumsFileName)
 	}
 
-	// determine if the repo is already cloned
-	if _, err := os.Stat("./ssl/generate-root-ca.sh"); errors.Is(err, os.ErrNotExist) {
-		// clone the repo
-		err := exec.Command("git", "clone", "https://github.com/mfdlabs/ssl", "./ssl").Run()
+	extractedPath := scripts.Path(name)
 
+	err = ioutil.WriteFile(extractedPath, content, 0700)
+	if err != nil {
 		return err
 	}
 
+	// Check what will actually run, not what was bundled
+	extracted, err := ioutil.ReadFile(extractedPath)
+	if err != nil {
+		return err
+	}
+
+	sum := sha256.Sum256(extracted)
+
+	if hex.EncodeToString(sum[:]) != checksum {
+		return fmt.Errorf("the checksum of the generation script %s does not match %s", name, checksumsFileName)
+	}
+
 	return nil
 }
 
-// We have 3 scripts:
-// - generate-root-ca.sh
-// - generate-intermediate-ca.sh
-// - generate-certificates-v2.sh
-func DetermineIfScriptsAvailable() error {
-	return tryCloneRepo()
+// Checks that every generation script is bundled and matches its checksum, without extracting anything.
+func CheckBundle() error {
+	_, err := checkBundle()
+
+	return err
+}
+
+func checkBundle() (map[string]string, error) {
+	checksums, err := loadChecksums()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, name := range requiredScripts {
+		checksum, ok := checksums[name]
+		if !ok {
+			return nil, fmt.Errorf("the generation script %s is not bundled into this build of ssl-go, see pkg/ssl/scripts/README.md", name)
+		}
+
+		content, err := bundle.ReadFile(path.Join("scripts", name))
+		if err != nil {
+			return nil, fmt.Errorf("the generation script %s is listed in %s but not bundled", name, checksumsFileName)
+		}
+
+		sum := sha256.Sum256(content)
+
+		if hex.EncodeToString(sum[:]) != checksum {
+			return nil, fmt.Errorf("the checksum of the bundled generation script %s does not match %s", name, checksumsFileName)
+		}
+	}
+
+	return checksums, nil
+}
+
+// Parses the checksums of the bundle, lines starting with # are comments.
+func loadChecksums() (map[string]string, error) {
+	content, err := bundle.ReadFile(path.Join("scripts", checksumsFileName))
+	if err != nil {
+		return nil, err
+	}
+
+	checksums := make(map[string]string)
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+
+		fields := strings.Fields(text)
+
+		// sha256sum marks files read in binary mode with a *
+		if len(fields) != 2 || len(fields[0]) != sha256.Size*2 {
+			return nil, fmt.Errorf("line %d of %s is not a checksum and a file name", line, checksumsFileName)
+		}
+
+		checksums[strings.TrimPrefix(fields[1], "*")] = strings.ToLower(fields[0])
+	}
+
+	return checksums, scanner.Err()
 }
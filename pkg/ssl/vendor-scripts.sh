@@ -0,0 +1,45 @@
+#!/bin/sh
+# Vendors the generation scripts of mfdlabs/ssl at a commit into pkg/ssl/scripts, and records the commit and the
+# checksums of the scripts in pkg/ssl/scripts/SHA256SUMS.
+#
+# Usage: vendor-scripts.sh <commit>
+#
+# SSL_GO_SCRIPTS_REPOSITORY overrides the repository the scripts are cloned from, e.g. with a mirror.
+
+set -eu
+
+if [ "$#" -ne 1 ]; then
+	echo "usage: $0 <commit>" >&2
+	exit 2
+fi
+
+repository=${SSL_GO_SCRIPTS_REPOSITORY:-https://github.com/mfdlabs/ssl.git}
+scripts_directory="$(cd "$(dirname "$0")" && pwd)/scripts"
+scripts="generate-root-ca.sh generate-intermediate-ca.sh generate-certs-v2.sh"
+
+clone=$(mktemp -d)
+trap 'rm -rf "$clone"' EXIT
+
+git clone --quiet "$repository" "$clone"
+git -C "$clone" checkout --quiet "$1"
+
+# Record the full hash, not what was asked for
+commit=$(git -C "$clone" rev-parse HEAD)
+
+for script in $scripts; do
+	cp "$clone/$script" "$scripts_directory/$script"
+	chmod 755 "$scripts_directory/$script"
+done
+
+{
+	echo "# The SHA-256 checksums of the generation scripts bundled into ssl-go, in the format of sha256sum. A script is only"
+	echo "# run if it matches its checksum here. See README.md for how to update the scripts."
+	echo "# upstream: $repository $commit"
+	cd "$scripts_directory"
+	# shellcheck disable=SC2086
+	sha256sum $scripts
+} >"$scripts_directory/SHA256SUMS.tmp"
+
+mv "$scripts_directory/SHA256SUMS.tmp" "$scripts_directory/SHA256SUMS"
+
+echo "Vendored the generation scripts of $repository at $commit"
@@ -0,0 +1,282 @@
+// Package truststore installs certificate authorities into the trust store of a Linux distribution, and removes the
+// ones it installed.
+package truststore
+
+import (
+	"bufio"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"git.mfdlabs.local/petko/mfdlabs-ssl-go/pkg/helper"
+)
+
+// The distributions whose trust store layout is known. Auto detects the distribution from the os-release file of
+// the root.
+const (
+	AutoDistribution   = "auto"
+	DebianDistribution = "debian"
+	RedHatDistribution = "rhel"
+	AlpineDistribution = "alpine"
+)
+
+// Every installed file starts with it, so the certificate authorities of the distribution are never touched.
+const filePrefix = "ssl-go-"
+
+// update-ca-certificates only picks .crt files up.
+const fileExtension = ".crt"
+
+type distribution struct {
+	// The directory local certificate authorities are installed into.
+	directory string
+
+	// The command that rebuilds the bundles of the trust store from the directory.
+	update string
+}
+
+var distributions = map[string]*distribution{
+	DebianDistribution: {directory: "/usr/local/share/ca-certificates", update: "update-ca-certificates"},
+	RedHatDistribution: {directory: "/etc/pki/ca-trust/source/anchors", update: "update-ca-trust extract"},
+	AlpineDistribution: {directory: "/usr/local/share/ca-certificates", update: "update-ca-certificates"},
+}
+
+// The ID and ID_LIKE values of os-release of the distributions.
+var distributionIds = map[string]string{
+	"debian":    DebianDistribution,
+	"ubuntu":    DebianDistribution,
+	"rhel":      RedHatDistribution,
+	"fedora":    RedHatDistribution,
+	"centos":    RedHatDistribution,
+	"rocky":     RedHatDistribution,
+	"almalinux": RedHatDistribution,
+	"alpine":    AlpineDistribution,
+}
+
+// Where certificate authorities are installed.
+type Options struct {
+	// The distribution whose layout is used, auto until Resolve detects it.
+	Distribution string
+
+	// The absolute path of the root filesystem, / unless the trust store of e.g. a container image is built.
+	Root string
+
+	// The absolute path of the directory the certificates are installed into, inside the root. It is empty until
+	// Resolve detects the distribution if it isn't set.
+	Directory string
+
+	// Determines if the trust store is not rebuilt after a change. It is never rebuilt for another root, the
+	// command would rebuild the trust store of this system.
+	SkipUpdate bool
+}
+
+// Gets the options of a trust store, an empty root is / and an empty directory is the one of the distribution. An
+// empty distribution is auto, it is only detected by Resolve so the options can be checked on any system.
+func GetOptions(distributionName string, root string, directory string, skipUpdate bool) (*Options, error) {
+	if root == "" {
+		root = "/"
+	}
+
+	root, err := filepath.Abs(root)
+	if err != nil {
+		return nil, err
+	}
+
+	name, err := CheckDistribution(distributionName)
+	if err != nil {
+		return nil, err
+	}
+
+	if directory == "" && name != AutoDistribution {
+		directory = distributions[name].directory
+	}
+
+	if directory != "" {
+		if !filepath.IsAbs(directory) {
+			return nil, fmt.Errorf("the directory %s must be an absolute path, it is inside the root %s", directory, root)
+		}
+
+		directory = filepath.Clean(directory)
+	}
+
+	return &Options{
+		Distribution: name,
+		Root:         root,
+		Directory:    directory,
+		SkipUpdate:   skipUpdate,
+	}, nil
+}
+
+// Detects the distribution of auto options, the options with a distribution are returned as they are. The options
+// have to be resolved before anything is installed, removed or listed with them.
+func Resolve(options *Options) (*Options, error) {
+	if options.Distribution != AutoDistribution {
+		return options, nil
+	}
+
+	name, err := Detect(options.Root)
+	if err != nil {
+		return nil, err
+	}
+
+	resolved := *options
+	resolved.Distribution = name
+
+	if resolved.Directory == "" {
+		resolved.Directory = distributions[name].directory
+	}
+
+	return &resolved, nil
+}
+
+// Validates the name of a distribution, an empty name is auto.
+func CheckDistribution(name string) (string, error) {
+	name = strings.ToLower(name)
+
+	switch name {
+	case "", AutoDistribution:
+		return AutoDistribution, nil
+	case "redhat":
+		return RedHatDistribution, nil
+	}
+
+	if distribution, ok := distributionIds[name]; ok {
+		return distribution, nil
+	}
+
+	return "", fmt.Errorf("unknown distribution: %s, must be %s, %s, %s or %s", name, AutoDistribution, DebianDistribution, RedHatDistribution, AlpineDistribution)
+}
+
+// Detects the distribution of the root filesystem from the ID and ID_LIKE of its /etc/os-release.
+func Detect(root string) (string, error) {
+	path := filepath.Join(root, "etc", "os-release")
+
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		path = filepath.Join(root, "usr", "lib", "os-release")
+		file, err = os.Open(path)
+	}
+
+	if err != nil {
+		return "", fmt.Errorf("failed to detect the distribution of %s, the distribution has to be set: %s", root, err)
+	}
+
+	defer file.Close()
+
+	var ids []string
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		parts := strings.SplitN(scanner.Text(), "=", 2)
+		if len(parts) != 2 {
+			continue
+		}
+
+		value := strings.Trim(strings.TrimSpace(parts[1]), `"'`)
+
+		// ID_LIKE is only looked at if ID is unknown, e.g. linuxmint is like ubuntu
+		switch strings.TrimSpace(parts[0]) {
+		case "ID":
+			ids = append([]string{value}, ids...)
+		case "ID_LIKE":
+			ids = append(ids, strings.Fields(value)...)
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return "", err
+	}
+
+	for _, id := range ids {
+		if distribution, ok := distributionIds[strings.ToLower(id)]; ok {
+			return distribution, nil
+		}
+	}
+
+	return "", fmt.Errorf("the distribution of %s (%s) is not supported, the distribution has to be set", root, strings.Join(ids, ", "))
+}
+
+// Gets the path a certificate is installed at, the name is its artifact name like root-ca-myroot.
+func (options *Options) GetPath(name string) string {
+	return filepath.Join(options.Root, options.Directory, filePrefix+name+fileExtension)
+}
+
+// Determines if Update rebuilds the trust store.
+func (options *Options) Updates() bool {
+	return !options.SkipUpdate && options.Root == "/"
+}
+
+// Gets the command that rebuilds the trust store.
+func (options *Options) UpdateCommand() string {
+	return distributions[options.Distribution].update
+}
+
+// Installs the PEM certificate under the name, replacing the one installed under it. The trust store has to be
+// updated afterwards.
+func Install(options *Options, name string, certificate []byte) (string, error) {
+	path := options.GetPath(name)
+
+	err := os.MkdirAll(filepath.Dir(path), 0755)
+	if err != nil {
+		return "", err
+	}
+
+	err = ioutil.WriteFile(path, certificate, 0644)
+	if err != nil {
+		return "", err
+	}
+
+	return path, nil
+}
+
+// Removes the certificate installed under the name, removed is false if none is. The trust store has to be updated
+// afterwards.
+func Uninstall(options *Options, name string) (removed bool, err error) {
+	err = os.Remove(options.GetPath(name))
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+
+	if err != nil {
+		return false, fmt.Errorf("failed to remove %s from the trust store: %s", name, err)
+	}
+
+	return true, nil
+}
+
+// Gets the names of every certificate installed in the trust store, sorted.
+func Installed(options *Options) ([]string, error) {
+	entries, err := ioutil.ReadDir(filepath.Join(options.Root, options.Directory))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	var names []string
+
+	for _, entry := range entries {
+		name := entry.Name()
+
+		if !entry.IsDir() && strings.HasPrefix(name, filePrefix) && strings.HasSuffix(name, fileExtension) {
+			names = append(names, strings.TrimSuffix(strings.TrimPrefix(name, filePrefix), fileExtension))
+		}
+	}
+
+	sort.Strings(names)
+
+	return names, nil
+}
+
+// Rebuilds the trust store, unless the options say it isn't.
+func Update(options *Options) error {
+	if !options.Updates() {
+		return nil
+	}
+
+	return helper.ExecuteCommand(options.UpdateCommand())
+}
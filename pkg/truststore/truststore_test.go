@@ -0,0 +1,212 @@
+package truststore
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDetect(t *testing.T) {
+	tests := []struct {
+		name         string
+		path         string
+		osRelease    string
+		distribution string
+		err          string
+	}{
+		{name: "id", path: "etc/os-release", osRelease: "NAME=\"Debian GNU/Linux\"\nID=debian\n", distribution: DebianDistribution},
+		{name: "quoted id", path: "etc/os-release", osRelease: "ID=\"rocky\"\nID_LIKE=\"rhel centos fedora\"\n", distribution: RedHatDistribution},
+		{name: "id like", path: "etc/os-release", osRelease: "ID=linuxmint\nID_LIKE='ubuntu debian'\n", distribution: DebianDistribution},
+		{name: "id over id like", path: "etc/os-release", osRelease: "ID_LIKE=debian\nID=Alpine\n", distribution: AlpineDistribution},
+		{name: "usr lib", path: "usr/lib/os-release", osRelease: "ID=fedora\n", distribution: RedHatDistribution},
+		{name: "unsupported", path: "etc/os-release", osRelease: "ID=arch\n", err: "(arch) is not supported"},
+		{name: "missing", err: "failed to detect the distribution"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			root := t.TempDir()
+
+			if test.path != "" {
+				writeTestFile(t, filepath.Join(root, test.path), test.osRelease)
+			}
+
+			distribution, err := Detect(root)
+
+			if test.err != "" {
+				if err == nil || !strings.Contains(err.Error(), test.err) {
+					t.Fatalf("expected an error containing %q, got %q, %v", test.err, distribution, err)
+				}
+
+				return
+			}
+
+			if err != nil || distribution != test.distribution {
+				t.Fatalf("expected %s, got %q, %v", test.distribution, distribution, err)
+			}
+		})
+	}
+}
+
+func TestGetOptions(t *testing.T) {
+	tests := []struct {
+		name         string
+		distribution string
+		root         string
+		directory    string
+		options      *Options
+		err          string
+	}{
+		{name: "defaults", options: &Options{Distribution: AutoDistribution, Root: "/"}},
+		{name: "distribution", distribution: "RedHat", options: &Options{Distribution: RedHatDistribution, Root: "/", Directory: "/etc/pki/ca-trust/source/anchors"}},
+		{name: "distribution id", distribution: "ubuntu", root: "/image", options: &Options{Distribution: DebianDistribution, Root: "/image", Directory: "/usr/local/share/ca-certificates"}},
+		{name: "directory", distribution: "auto", directory: "/opt/ca//", options: &Options{Distribution: AutoDistribution, Root: "/", Directory: "/opt/ca"}},
+		{name: "relative directory", directory: "ca", err: "must be an absolute path"},
+		{name: "unknown distribution", distribution: "arch", err: "unknown distribution: arch"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			options, err := GetOptions(test.distribution, test.root, test.directory, false)
+
+			if test.err != "" {
+				if err == nil || !strings.Contains(err.Error(), test.err) {
+					t.Fatalf("expected an error containing %q, got %+v, %v", test.err, options, err)
+				}
+
+				return
+			}
+
+			if err != nil || !reflect.DeepEqual(options, test.options) {
+				t.Fatalf("expected %+v, got %+v, %v", test.options, options, err)
+			}
+		})
+	}
+}
+
+func TestResolve(t *testing.T) {
+	root := t.TempDir()
+	writeTestFile(t, filepath.Join(root, "etc", "os-release"), "ID=alpine\n")
+
+	options, err := GetOptions("", root, "", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resolved, err := Resolve(options)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := &Options{Distribution: AlpineDistribution, Root: root, Directory: "/usr/local/share/ca-certificates", SkipUpdate: true}
+	if !reflect.DeepEqual(resolved, expected) || options.Distribution != AutoDistribution {
+		t.Fatalf("expected %+v without changing the options, got %+v", expected, resolved)
+	}
+
+	// A distribution that is set is never detected
+	options, err = GetOptions(DebianDistribution, t.TempDir(), "", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if resolved, err := Resolve(options); err != nil || resolved != options {
+		t.Fatalf("expected the options to be kept, got %+v, %v", resolved, err)
+	}
+
+	options, err = GetOptions("", t.TempDir(), "", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := Resolve(options); err == nil {
+		t.Fatal("expected an error for a root without os-release")
+	}
+}
+
+func TestInstallUninstall(t *testing.T) {
+	options, err := GetOptions(RedHatDistribution, t.TempDir(), "", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if options.Updates() {
+		t.Fatal("expected the trust store of another root not to be updated")
+	}
+
+	for _, name := range []string{"root-ca-b", "root-ca-a", "ca-a"} {
+		path, err := Install(options, name, []byte(name))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if path != filepath.Join(options.Root, "etc/pki/ca-trust/source/anchors", "ssl-go-"+name+".crt") {
+			t.Fatalf("unexpected path %s", path)
+		}
+	}
+
+	// The certificates of the distribution and other files are never listed
+	directory := filepath.Join(options.Root, options.Directory)
+	writeTestFile(t, filepath.Join(directory, "corporate.crt"), "corporate")
+	writeTestFile(t, filepath.Join(directory, "ssl-go-notes.txt"), "notes")
+
+	if _, err := Install(options, "root-ca-a", []byte("replaced")); err != nil {
+		t.Fatal(err)
+	}
+
+	content, err := ioutil.ReadFile(options.GetPath("root-ca-a"))
+	if err != nil || string(content) != "replaced" {
+		t.Fatalf("expected the certificate to be replaced, got %q, %v", content, err)
+	}
+
+	installed, err := Installed(options)
+	if err != nil || !reflect.DeepEqual(installed, []string{"ca-a", "root-ca-a", "root-ca-b"}) {
+		t.Fatalf("unexpected installed certificates %v, %v", installed, err)
+	}
+
+	removed, err := Uninstall(options, "root-ca-b")
+	if err != nil || !removed {
+		t.Fatalf("expected root-ca-b to be removed, got %t, %v", removed, err)
+	}
+
+	removed, err = Uninstall(options, "root-ca-b")
+	if err != nil || removed {
+		t.Fatalf("expected nothing to be removed, got %t, %v", removed, err)
+	}
+
+	installed, err = Installed(options)
+	if err != nil || !reflect.DeepEqual(installed, []string{"ca-a", "root-ca-a"}) {
+		t.Fatalf("unexpected installed certificates %v, %v", installed, err)
+	}
+
+	if _, err := os.Stat(filepath.Join(directory, "corporate.crt")); err != nil {
+		t.Fatalf("expected the other certificates to be kept: %s", err)
+	}
+}
+
+func TestInstalledWithoutDirectory(t *testing.T) {
+	options, err := GetOptions(DebianDistribution, t.TempDir(), "", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	installed, err := Installed(options)
+	if err != nil || installed != nil {
+		t.Fatalf("expected nothing to be installed, got %v, %v", installed, err)
+	}
+}
+
+func writeTestFile(t *testing.T, path string, content string) {
+	t.Helper()
+
+	err := os.MkdirAll(filepath.Dir(path), 0755)
+	if err == nil {
+		err = ioutil.WriteFile(path, []byte(content), 0644)
+	}
+
+	if err != nil {
+		t.Fatal(err)
+	}
+}